@@ -0,0 +1,409 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Operations recorded in the audit log.
+const (
+	OpCreate     = "create"
+	OpDrop       = "drop"
+	OpExec       = "exec"
+	OpMigration  = "migration"
+	OpRotateKey  = "rotate-key"
+	OpPolicy     = "policy"
+	OpBackup     = "backup"
+	OpRestore    = "restore"
+	OpExport     = "export"
+	OpImport     = "import"
+	OpClone      = "clone"
+	OpDiff       = "diff"
+	OpPublish    = "publish-template"
+	OpUnpublish  = "delete-template"
+	OpUndrop     = "undrop"
+	OpPurge      = "purge"
+	OpSubscribe  = "subscribe"
+	OpWebhook    = "create-webhook"
+	OpUnhook     = "delete-webhook"
+	OpRedeliver  = "redeliver"
+	OpDiscard    = "discard-dead-letters"
+	OpSchedule   = "schedule-job"
+	OpUnschedule = "delete-job"
+	OpJob        = "run-job"
+	OpFanout     = "fanout-query"
+)
+
+// Entry is one audited call. Hash covers every other field, Prev included,
+// so changing or removing an entry breaks the chain after it.
+type Entry struct {
+	Time          time.Time `json:"time"`
+	Principal     string    `json:"principal"`
+	SourceIP      string    `json:"source_ip"`
+	Node          string    `json:"node"`
+	Database      string    `json:"database"`
+	Operation     string    `json:"operation"`
+	StatementHash string    `json:"statement_hash,omitempty"`
+	Outcome       string    `json:"outcome"`
+	Prev          string    `json:"prev"`
+	Hash          string    `json:"hash,omitempty"`
+}
+
+// Filter selects entries in Query. Zero values match everything.
+type Filter struct {
+	From      time.Time
+	To        time.Time
+	Principal string
+	Database  string
+}
+
+const current = "audit.log"
+
+var trail = struct {
+	sync.Mutex
+	dir     string
+	node    string
+	maxSize int64
+	file    *os.File
+	size    int64
+	last    string
+	// rotations counts rotations, so readers can tell a file moved under them
+	rotations int
+}{}
+
+// Open starts appending to the audit log in dir. The active file is rotated
+// once it grows past maxSize bytes.
+func Open(dir string, node string, maxSize int64) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	trail.Lock()
+	defer trail.Unlock()
+
+	trail.dir = dir
+	trail.node = node
+	trail.maxSize = maxSize
+
+	// continue the chain from the newest entry on disk
+	files, err := logFiles()
+	if err != nil {
+		return err
+	}
+	for i := len(files) - 1; i >= 0 && trail.last == ""; i-- {
+		entries, err := read(files[i])
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			trail.last = entries[len(entries)-1].Hash
+		}
+	}
+
+	return openCurrent()
+}
+
+func openCurrent() error {
+	file, err := os.OpenFile(filepath.Join(trail.dir, current), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	trail.file = file
+	trail.size = info.Size()
+	return nil
+}
+
+// Record appends an entry, filling in the time, node and chain hashes.
+// It does nothing when the log hasn't been opened.
+func Record(entry Entry) error {
+	trail.Lock()
+	defer trail.Unlock()
+
+	if trail.file == nil {
+		return nil
+	}
+
+	entry.Time = time.Now().UTC()
+	entry.Node = trail.node
+	entry.Prev = trail.last
+	entry.Hash = ""
+	entry.Hash = sum(entry)
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if _, err := trail.file.Write(line); err != nil {
+		return err
+	}
+	if err := trail.file.Sync(); err != nil {
+		return err
+	}
+	trail.last = entry.Hash
+	trail.size += int64(len(line))
+
+	if trail.maxSize > 0 && trail.size >= trail.maxSize {
+		return rotate()
+	}
+	return nil
+}
+
+// rotate renames the active file after the time it was closed and starts a new one.
+func rotate() error {
+	if err := trail.file.Close(); err != nil {
+		return err
+	}
+	trail.file = nil
+	trail.rotations++
+
+	name := "audit-" + time.Now().UTC().Format("20060102T150405.000000000") + ".log"
+	if err := os.Rename(filepath.Join(trail.dir, current), filepath.Join(trail.dir, name)); err != nil {
+		return err
+	}
+	return openCurrent()
+}
+
+// Hash returns the hash recorded for a statement, so the log never holds statement text.
+func Hash(statement string) string {
+	h := sha256.Sum256([]byte(statement))
+	return hex.EncodeToString(h[:])
+}
+
+// Outcome describes the result of a call for the log.
+func Outcome(err error) string {
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	return "ok"
+}
+
+func sum(entry Entry) string {
+	entry.Hash = ""
+	data, _ := json.Marshal(entry)
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// logFiles lists rotated files oldest first, followed by the active file.
+func logFiles() ([]string, error) {
+	rotated, err := filepath.Glob(filepath.Join(trail.dir, "audit-*.log"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(rotated)
+
+	if _, err := os.Stat(filepath.Join(trail.dir, current)); err == nil {
+		rotated = append(rotated, filepath.Join(trail.dir, current))
+	}
+	return rotated, nil
+}
+
+func read(path string) ([]Entry, error) {
+	entries, _, err := readRange(path, 0, -1)
+	return entries, err
+}
+
+// readRange reads the entries of a file from offset up to limit, or to its
+// end when limit is negative, and returns the offset it stopped at.
+func readRange(path string, offset int64, limit int64) ([]Entry, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+	var r io.Reader = file
+	if limit >= 0 {
+		r = io.LimitReader(file, limit-offset)
+	}
+
+	var entries []Entry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+		entries = append(entries, entry)
+		offset += int64(len(scanner.Bytes())) + 1
+	}
+	return entries, offset, scanner.Err()
+}
+
+// written lists the log files, how much of the active one was written and
+// how often the log was rotated, so the files can be read without holding
+// up Record. Rotated files don't change.
+func written() ([]string, int64, int, error) {
+	trail.Lock()
+	defer trail.Unlock()
+
+	if trail.dir == "" {
+		return nil, 0, 0, errors.New("audit log isn't enabled")
+	}
+	files, err := logFiles()
+	return files, trail.size, trail.rotations, err
+}
+
+// walk calls fn with the log files, oldest first, and how much of the active
+// one, the last, to read. It starts over when the log is rotated meanwhile.
+func walk(fn func(files []string, size int64) error) error {
+	for {
+		files, size, rotations, err := written()
+		if err != nil {
+			return err
+		}
+		err = fn(files, size)
+
+		trail.Lock()
+		rotated := trail.rotations != rotations
+		trail.Unlock()
+		if !rotated {
+			return err
+		}
+	}
+}
+
+// Query returns the entries matching the filter, oldest first.
+func Query(filter Filter) ([]Entry, error) {
+	var matched []Entry
+	err := walk(func(files []string, size int64) error {
+		matched = nil
+		for i, path := range files {
+			limit := int64(-1)
+			if i == len(files)-1 {
+				limit = size
+			}
+			entries, _, err := readRange(path, 0, limit)
+			if err != nil {
+				return err
+			}
+			for _, entry := range entries {
+				switch {
+				case !filter.From.IsZero() && entry.Time.Before(filter.From):
+				case !filter.To.IsZero() && entry.Time.After(filter.To):
+				case filter.Principal != "" && !strings.EqualFold(entry.Principal, filter.Principal):
+				case filter.Database != "" && entry.Database != filter.Database:
+				default:
+					matched = append(matched, entry)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matched, nil
+}
+
+// verifiedFile is a rotated file verified as it was at info, ending at last.
+type verifiedFile struct {
+	info os.FileInfo
+	last string
+}
+
+// verified remembers how far the chain was verified: the rotated files as
+// long as they are unchanged, and the active file up to an offset.
+var verified = struct {
+	sync.Mutex
+	files  map[string]verifiedFile
+	active os.FileInfo
+	offset int64
+	last   string
+}{files: make(map[string]verifiedFile)}
+
+// Verify walks the chain and reports the first entry that doesn't match its
+// hash or predecessor. Only what was written since the last walk is read.
+func Verify() error {
+	verified.Lock()
+	defer verified.Unlock()
+
+	return walk(func(files []string, size int64) error {
+		prev := ""
+		for i, path := range files {
+			if i == len(files)-1 {
+				return verifyActive(path, size, prev)
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				return err
+			}
+			if f, ok := verified.files[path]; ok && f.info.Size() == info.Size() && f.info.ModTime().Equal(info.ModTime()) {
+				prev = f.last
+				continue
+			}
+			entries, err := read(path)
+			if err != nil {
+				return err
+			}
+			if err := verifyChain(path, entries, prev); err != nil {
+				return err
+			}
+			if len(entries) > 0 {
+				prev = entries[len(entries)-1].Hash
+			}
+			verified.files[path] = verifiedFile{info: info, last: prev}
+		}
+		return nil
+	})
+}
+
+// verifyActive verifies the active file up to size, from where the last
+// walk stopped when it is still the same file.
+func verifyActive(path string, size int64, prev string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if verified.active == nil || !os.SameFile(verified.active, info) || verified.offset > size {
+		verified.active, verified.offset, verified.last = info, 0, prev
+	}
+	entries, offset, err := readRange(path, verified.offset, size)
+	if err != nil {
+		return err
+	}
+	if err := verifyChain(path, entries, verified.last); err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		verified.last = entries[len(entries)-1].Hash
+	}
+	verified.offset = offset
+	return nil
+}
+
+func verifyChain(path string, entries []Entry, prev string) error {
+	for i, entry := range entries {
+		if entry.Prev != prev {
+			return fmt.Errorf("%s: entry %d at %s doesn't follow the previous entry", filepath.Base(path), i, entry.Time.Format(time.RFC3339Nano))
+		}
+		if sum(entry) != entry.Hash {
+			return fmt.Errorf("%s: entry %d at %s was modified", filepath.Base(path), i, entry.Time.Format(time.RFC3339Nano))
+		}
+		prev = entry.Hash
+	}
+	return nil
+}
@@ -1,11 +1,59 @@
 package config
 
+import "time"
+
 var (
-	Name       string
-	Username   string
-	Password   string
-	HTTPAddr   string
-	GRPCAddr   string
-	GossipAddr string
-	Join       string
+	Name           string
+	Username       string
+	Password       string
+	Role           string
+	UsersFile      string
+	PolicyFile     string
+	RateLimits     string
+	HTTPAddr       string
+	GRPCAddr       string
+	GossipAddr     string
+	Join           string
+	ClusterKeyFile string
+	DataDir        string
+
+	AuditDir     string
+	AuditMaxSize int64
+
+	MasterKeyFile          string
+	PreviousMasterKeyFiles string
+
+	CursorTTL  time.Duration
+	MaxCursors int
+
+	MaxQueryTimeout   time.Duration
+	FanoutConcurrency int
+
+	UsageDir       string
+	UsageWindow    time.Duration
+	UsageFlush     time.Duration
+	UsageRetention time.Duration
+	QuotaThrottle  time.Duration
+
+	BackupDir      string
+	BackupInterval time.Duration
+	BackupKeep     int
+	BackupMaxAge   time.Duration
+
+	TemplateDir string
+
+	TrashDir       string
+	TrashRetention time.Duration
+
+	ChangelogDir       string
+	ChangelogRetention time.Duration
+
+	WebhookTimeout  time.Duration
+	WebhookAttempts int
+
+	BackupStore        string
+	BackupStoreKeyFile string
+	BackupStoreSync    time.Duration
+	BackupStoreKeep    int
+	BackupStoreMaxAge  time.Duration
 )
@@ -0,0 +1,201 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// A query may read other databases beside its own, attached under aliases.
+// Databases on this node are attached in place, or as a copy of their image
+// when encrypted, the others from a snapshot the server fetched from their
+// owner. The connection is then made query only, so none of them can be
+// written. ATTACH in the statements themselves is still up to the policy.
+
+var ErrInvalidAttach = errors.New("invalid attach")
+
+// MaxAttached is how many databases a query may attach.
+const MaxAttached = 8
+
+var aliasRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Attachment is a database a query reads under an alias. Snapshot is a copy
+// of it as exported by ExportSnapshot, when it lives on another node.
+type Attachment struct {
+	Alias    string
+	Database string
+	Snapshot string
+	Backup   Backup
+}
+
+type attachKey struct{}
+
+// WithAttached attaches databases to the queries run under ctx.
+func WithAttached(ctx context.Context, attached []Attachment) context.Context {
+	return context.WithValue(ctx, attachKey{}, attached)
+}
+
+// ValidateAttachments checks the databases attached to a query on databaseName.
+func ValidateAttachments(databaseName string, attached []Attachment) error {
+	if len(attached) > MaxAttached {
+		return fmt.Errorf("%w: a query can attach at most %d databases", ErrInvalidAttach, MaxAttached)
+	}
+	aliases := make(map[string]bool)
+	for _, at := range attached {
+		alias := strings.ToLower(at.Alias)
+		switch {
+		case !aliasRegexp.MatchString(at.Alias):
+			return fmt.Errorf("%w: alias %q may only contain letters, digits and '_' and can't start with a digit", ErrInvalidAttach, at.Alias)
+		case alias == "main" || alias == "temp":
+			return fmt.Errorf("%w: alias %q is reserved", ErrInvalidAttach, at.Alias)
+		case aliases[alias]:
+			return fmt.Errorf("%w: alias %q is used twice", ErrInvalidAttach, at.Alias)
+		case at.Database == databaseName:
+			return fmt.Errorf("%w: %s is the database queried", ErrInvalidAttach, at.Database)
+		}
+		if err := ValidateName(at.Database); err != nil {
+			return err
+		}
+		aliases[alias] = true
+	}
+	return nil
+}
+
+// attachment is an Attachment ready to attach, a file or an image.
+type attachment struct {
+	alias    string
+	database string
+	path     string
+	image    []byte // nil for a plain file
+}
+
+// attachments reads the databases attached under ctx. Encrypted images are
+// read now, as the handle they are attached to may hold its own lock for a
+// long time.
+func attachments(ctx context.Context, databaseName string) ([]attachment, error) {
+	attached, _ := ctx.Value(attachKey{}).([]Attachment)
+	if len(attached) == 0 {
+		return nil, nil
+	}
+	if err := ValidateAttachments(databaseName, attached); err != nil {
+		return nil, err
+	}
+
+	resolved := make([]attachment, 0, len(attached))
+	for _, at := range attached {
+		a, err := at.resolve()
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, a)
+	}
+	return resolved, nil
+}
+
+func (at Attachment) resolve() (attachment, error) {
+	a := attachment{alias: at.Alias, database: at.Database, path: at.Snapshot}
+	sealed := at.Backup.Encrypted
+	if at.Snapshot != "" {
+		if err := verifyBackup(at.Snapshot, at.Backup); err != nil {
+			return attachment{}, err
+		}
+	} else {
+		if err := Get(at.Database); err != nil {
+			return attachment{}, err
+		}
+		var err error
+		if a.path, err = filePath(at.Database); err != nil {
+			return attachment{}, err
+		}
+		unlock := lock(at.Database, false)
+		defer unlock()
+		if sealed, err = isSealed(a.path); err != nil {
+			return attachment{}, err
+		}
+	}
+
+	if sealed {
+		image, _, err := readSealed(a.path)
+		if err != nil {
+			return attachment{}, err
+		}
+		a.image = append([]byte{}, image...)
+	}
+	return a, nil
+}
+
+// attachAll attaches databases to a new connection and keeps it from
+// writing. Databases with row-level security are only attached for admins,
+// as their policies only shadow the tables of main.
+func (a *authorizer) attachAll(conn *sqlite3.SQLiteConn, attached []attachment) error {
+	if len(attached) == 0 {
+		return nil
+	}
+	defer func(setup bool) { a.setup = setup }(a.setup)
+	a.setup = true
+
+	for _, at := range attached {
+		schema := quoteIdent(at.alias)
+		if at.image == nil {
+			if _, err := conn.Exec(`ATTACH DATABASE ? AS `+schema, []driver.Value{at.path}); err != nil {
+				return err
+			}
+		} else {
+			if _, err := conn.Exec(`ATTACH DATABASE ':memory:' AS `+schema, nil); err != nil {
+				return err
+			}
+			if len(at.image) > 0 {
+				if err := conn.Deserialize(at.image, at.alias); err != nil {
+					return err
+				}
+			}
+		}
+
+		if a.principal.Role != AdminRole {
+			protected, err := hasRowPolicies(conn, at.alias)
+			if err != nil {
+				return err
+			}
+			if protected {
+				Denials.Add("attach", 1)
+				return fmt.Errorf("%w: %s has row-level security, only admins can attach it", ErrDenied, at.database)
+			}
+		}
+	}
+	_, err := conn.Exec(`PRAGMA query_only = ON`, nil)
+	return err
+}
+
+// hasRowPolicies reports whether the database attached as schema has policies.
+func hasRowPolicies(conn *sqlite3.SQLiteConn, schema string) (bool, error) {
+	rows, err := conn.Query(`SELECT 1 FROM pragma_table_info(?, ?)`, []driver.Value{rlsTable, schema})
+	if err != nil {
+		return false, err
+	}
+	values := make([]driver.Value, 1)
+	err = rows.Next(values)
+	rows.Close()
+	if err == io.EOF {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	rows, err = conn.Query(`SELECT 1 FROM `+quoteIdent(schema)+`.`+rlsTable+` LIMIT 1`, nil)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	err = rows.Next(values)
+	if err == io.EOF {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,351 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Backups are consistent snapshots of a database, kept as a file and a JSON
+// sidecar holding its metadata under backups/<database>/ in the backup
+// directory. Plain databases are copied with the SQLite backup API a few
+// pages at a time, so writers only wait for the step in progress and the copy
+// restarts if they changed the database. Encrypted databases are copied as
+// they are on disk, still sealed, while writers are held off.
+
+// backupDir holds the backups of every database, by database name. It
+// is under the data directory unless set otherwise.
+var backupDir = "backups"
+
+// backupStep is how many pages the backup API copies between writes.
+const backupStep = 256
+
+// Backup describes a snapshot of a database.
+type Backup struct {
+	ID        string    `json:"id"`
+	Database  string    `json:"database"`
+	Created   time.Time `json:"created"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+	Encrypted bool      `json:"encrypted"`
+}
+
+// BackupRetention decides which backups are pruned after a new one is taken.
+// The newest backup of a database is always kept.
+type BackupRetention struct {
+	Keep   int           // how many backups to keep, 0 for all
+	MaxAge time.Duration // how old a backup may get, 0 for no limit
+}
+
+var backupRetention BackupRetention
+
+// SetBackupDir creates dir if needed and keeps the backups there.
+func SetBackupDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	backupDir = dir
+	return nil
+}
+
+// SetBackupRetention sets which backups are kept.
+func SetBackupRetention(retention BackupRetention) {
+	backupRetention = retention
+}
+
+// List returns the names of the databases in the data directory.
+func List() ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dataDir, "*"+sqlite))
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), sqlite)
+		if ValidateName(name) == nil {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// TakeBackup snapshots a database into the backup directory and prunes its
+// old backups according to the retention.
+func TakeBackup(ctx context.Context, databaseName string) (Backup, error) {
+	if err := Get(databaseName); err != nil {
+		return Backup{}, err
+	}
+	databasePath, err := filePath(databaseName)
+	if err != nil {
+		return Backup{}, err
+	}
+
+	dir := filepath.Join(backupDir, databaseName)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return Backup{}, err
+	}
+	backup := Backup{
+		ID:       time.Now().UTC().Format("20060102T150405.000000000Z"),
+		Database: databaseName,
+	}
+	path := filepath.Join(dir, backup.ID+sqlite)
+
+	tmp := path + ".tmp"
+	defer os.Remove(tmp)
+	if backup.Encrypted, err = snapshot(ctx, databaseName, databasePath, tmp); err != nil {
+		return Backup{}, err
+	}
+	if !backup.Encrypted {
+		// every transaction in the snapshot is archived before it is
+		// dated, so rolling it forward never reverts part of one
+		if _, err := archiveWAL(databaseName, databasePath); err != nil {
+			return Backup{}, err
+		}
+	}
+	backup.Created = time.Now().UTC()
+
+	file, err := os.Open(tmp)
+	if err != nil {
+		return Backup{}, err
+	}
+	h := sha256.New()
+	backup.Size, err = io.Copy(h, file)
+	if err == nil {
+		err = file.Sync()
+	}
+	file.Close()
+	if err != nil {
+		return Backup{}, err
+	}
+	backup.SHA256 = hex.EncodeToString(h.Sum(nil))
+
+	if err := os.Rename(tmp, path); err != nil {
+		return Backup{}, err
+	}
+	meta, _ := json.MarshalIndent(backup, "", "  ")
+	if err := writeSynced(filepath.Join(dir, backup.ID+".json"), meta); err != nil {
+		os.Remove(path)
+		return Backup{}, err
+	}
+	wakeReplica()
+
+	return backup, pruneBackups(databaseName)
+}
+
+// snapshot writes a consistent copy of the database at databasePath to path.
+func snapshot(ctx context.Context, databaseName string, databasePath string, path string) (sealed bool, err error) {
+	unlock := lock(databaseName, false)
+	defer unlock()
+
+	sealed, err = isSealed(databasePath)
+	if err != nil {
+		return false, err
+	}
+	if sealed {
+		return true, copyFile(databasePath, path)
+	}
+
+	src, err := openPlain(databasePath)
+	if err != nil {
+		return false, err
+	}
+	defer src.Close()
+	dest, err := (&sqlite3.SQLiteDriver{}).Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer dest.Close()
+
+	backup, err := dest.(*sqlite3.SQLiteConn).Backup("main", src, "main")
+	if err != nil {
+		return false, err
+	}
+	for {
+		done, err := backup.Step(backupStep)
+		if err != nil {
+			backup.Close()
+			return false, err
+		}
+		if done {
+			break
+		}
+		// let writers in between steps
+		select {
+		case <-ctx.Done():
+			backup.Close()
+			return false, ctx.Err()
+		case <-time.After(time.Millisecond):
+		}
+	}
+	return false, backup.Finish()
+}
+
+func copyFile(src string, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Backups lists the backups of a database, newest first, whether they are
+// kept on this node or in the backup store. Backups outlive the database,
+// so they are listed even once it was dropped.
+func Backups(ctx context.Context, databaseName string) ([]Backup, error) {
+	backups, err := localBackups(databaseName)
+	if err != nil {
+		return nil, err
+	}
+
+	b := currentStore()
+	if b.store == nil {
+		return backups, nil
+	}
+	stored, err := b.storedBackups(ctx, databaseName)
+	if err != nil {
+		return nil, err
+	}
+	have := make(map[string]bool, len(backups))
+	for _, backup := range backups {
+		have[backup.ID] = true
+	}
+	for _, backup := range stored {
+		if !have[backup.ID] {
+			backups = append(backups, backup)
+		}
+	}
+	sortBackups(backups)
+	return backups, nil
+}
+
+// localBackups lists the backups of a database in the backup directory.
+func localBackups(databaseName string) ([]Backup, error) {
+	if err := ValidateName(databaseName); err != nil {
+		return nil, err
+	}
+
+	paths, err := filepath.Glob(filepath.Join(backupDir, databaseName, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	backups := []Backup{}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var backup Backup
+		if err := json.Unmarshal(data, &backup); err != nil {
+			return nil, err
+		}
+		backups = append(backups, backup)
+	}
+	sortBackups(backups)
+	return backups, nil
+}
+
+// sortBackups puts the newest backups first.
+func sortBackups(backups []Backup) {
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Created.After(backups[j].Created)
+	})
+}
+
+// pruneBackups removes the backups of a database the retention doesn't keep.
+func pruneBackups(databaseName string) error {
+	if backupRetention == (BackupRetention{}) {
+		return nil
+	}
+	backups, err := localBackups(databaseName)
+	if err != nil {
+		return err
+	}
+
+	removed, oldest := retain(backups, backupRetention)
+	for _, backup := range removed {
+		if err := removeBackup(backup); err != nil {
+			return err
+		}
+	}
+	return pruneWAL(databaseName, oldest)
+}
+
+// retain splits backups, newest first, into those the retention removes and
+// the time the oldest one kept was taken.
+func retain(backups []Backup, retention BackupRetention) (removed []Backup, oldest time.Time) {
+	sortBackups(backups)
+	for i, backup := range backups {
+		tooMany := retention.Keep > 0 && i >= retention.Keep
+		tooOld := retention.MaxAge > 0 && time.Since(backup.Created) > retention.MaxAge
+		if i == 0 || !tooMany && !tooOld {
+			oldest = backup.Created
+			continue
+		}
+		removed = append(removed, backup)
+	}
+	return removed, oldest
+}
+
+// sealBackups encrypts the plain backups of a database with dataKey.
+func sealBackups(databaseName string, dataKey []byte) error {
+	backups, err := localBackups(databaseName)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(backupDir, databaseName)
+	for _, backup := range backups {
+		if backup.Encrypted {
+			continue
+		}
+		path := filepath.Join(dir, backup.ID+sqlite)
+		image, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := writeSealed(path, rollbackImage(image), dataKey); err != nil {
+			return err
+		}
+		sealed, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(sealed)
+		backup.Encrypted, backup.Size, backup.SHA256 = true, int64(len(sealed)), hex.EncodeToString(sum[:])
+
+		meta, _ := json.MarshalIndent(backup, "", "  ")
+		if err := writeSynced(filepath.Join(dir, backup.ID+".json"), meta); err != nil {
+			return err
+		}
+	}
+	wakeReplica()
+	return nil
+}
+
+func removeBackup(backup Backup) error {
+	dir := filepath.Join(backupDir, backup.Database)
+	if err := os.Remove(filepath.Join(dir, backup.ID+sqlite)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return os.Remove(filepath.Join(dir, backup.ID+".json"))
+}
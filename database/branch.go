@@ -0,0 +1,501 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/trianglehasfoursides/bedroompop/metering"
+)
+
+// A branch is a clone of a database made from a consistent snapshot of its
+// parent, taken with the backup API. Which database it was branched from and
+// when are stored in the branch itself, so they travel with it. The snapshot
+// is kept next to the branch as its base, sealed like the branch. Diffing a
+// branch compares it with its base, attached to the connection of the
+// branch, matching rows by primary key or rowid, so it shows what changed in
+// the branch whatever happened to the parent since.
+
+const branchTable = "_branch"
+
+// baseSuffix names the base of a branch after its database file.
+const baseSuffix = "-base"
+
+// parentSchema is the schema the parent is attached as while diffing.
+const parentSchema = "parent"
+
+var (
+	ErrNotBranch    = errors.New("database is not a branch")
+	ErrNoBranchBase = errors.New("snapshot the branch was made from is missing")
+)
+
+// Branch tells which database a clone was made from and when.
+type Branch struct {
+	Parent   string    `json:"parent"`
+	Branched time.Time `json:"branched"`
+}
+
+// Change is a row differing between a branch and its parent. Parent is nil
+// for rows inserted in the branch and Row for rows deleted from it. A table
+// whose columns differ is reported once, as a schema change.
+type Change struct {
+	Table   string
+	Op      string // insert, update, delete or schema
+	Columns []Column
+	Parent  []any
+	Row     []any
+}
+
+// ExportSnapshot takes a consistent copy of a database and hands it to emit
+// along with its description, as a backup without an id.
+func ExportSnapshot(ctx context.Context, databaseName string, emit func(backup Backup, r io.Reader) error) error {
+	if PrincipalFrom(ctx).Role != AdminRole {
+		return fmt.Errorf("%w: only admins can branch databases", ErrDenied)
+	}
+	if err := Get(databaseName); err != nil {
+		return err
+	}
+	databasePath, err := filePath(databaseName)
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "snapshot-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, databaseName+sqlite)
+	backup := Backup{Database: databaseName}
+	if backup.Encrypted, err = snapshot(ctx, databaseName, databasePath, path); err != nil {
+		return err
+	}
+	backup.Created = time.Now().UTC()
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if backup.Size, err = io.Copy(h, file); err != nil {
+		return err
+	}
+	backup.SHA256 = hex.EncodeToString(h.Sum(nil))
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return emit(backup, file)
+}
+
+// Clone creates the database target from a snapshot of its parent, as
+// exported by ExportSnapshot, and records the branch in it.
+func Clone(ctx context.Context, target string, snapshot string, backup Backup) error {
+	if PrincipalFrom(ctx).Role != AdminRole {
+		return fmt.Errorf("%w: only admins can branch databases", ErrDenied)
+	}
+	if err := RestoreFrom(ctx, target, snapshot, nil, backup); err != nil {
+		return err
+	}
+	err := keepBase(target, snapshot, backup)
+	if err == nil {
+		err = markBranch(ctx, target, Branch{Parent: backup.Database, Branched: backup.Created})
+	}
+	if err != nil {
+		purge(target)
+		return err
+	}
+	return nil
+}
+
+// keepBase keeps the snapshot a branch was made from as its base. A plain
+// one is kept in rollback mode, so it can be attached without a WAL.
+func keepBase(databaseName string, snapshot string, backup Backup) error {
+	databasePath, err := filePath(databaseName)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(snapshot)
+	if err != nil {
+		return err
+	}
+	if !backup.Encrypted {
+		data = rollbackImage(data)
+	}
+	return replaceFile(databasePath+baseSuffix, data)
+}
+
+// sealBase encrypts the plain base of a branch with dataKey.
+func sealBase(databasePath string, dataKey []byte) error {
+	base := databasePath + baseSuffix
+	sealed, err := isSealed(base)
+	if errors.Is(err, os.ErrNotExist) || sealed {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	image, err := os.ReadFile(base)
+	if err != nil {
+		return err
+	}
+	return writeSealed(base, image, dataKey)
+}
+
+// markBranch stores the parent of a branch in it, replacing the one it had
+// if it was cloned from a branch.
+func markBranch(ctx context.Context, databaseName string, branch Branch) error {
+	db, err := open(ctx, databaseName)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	txn, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS main.` + branchTable + ` (parent TEXT NOT NULL, branched TEXT NOT NULL)`,
+		`DELETE FROM main.` + branchTable,
+	}
+	// a branch neither delivers the webhooks of its parent nor runs its jobs
+	for _, table := range internalTables {
+		stmts = append(stmts, `DROP TABLE IF EXISTS main.`+table)
+	}
+	for _, stmt := range stmts {
+		if _, err := txn.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	if _, err := txn.ExecContext(ctx, `INSERT INTO main.`+branchTable+` VALUES (?, ?)`,
+		branch.Parent, branch.Branched.Format(time.RFC3339Nano)); err != nil {
+		return err
+	}
+	if err := txn.Commit(); err != nil {
+		return err
+	}
+	return db.persist(ctx)
+}
+
+// BranchOf returns the parent of a branch.
+func BranchOf(ctx context.Context, databaseName string) (Branch, error) {
+	if err := Get(databaseName); err != nil {
+		return Branch{}, err
+	}
+	db, err := open(ctx, databaseName)
+	if err != nil {
+		return Branch{}, err
+	}
+	defer db.Close()
+
+	var branch Branch
+	var branched string
+	err = db.QueryRowContext(ctx, `SELECT parent, branched FROM main.`+branchTable).Scan(&branch.Parent, &branched)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such table") || errors.Is(err, sql.ErrNoRows) {
+			return Branch{}, ErrNotBranch
+		}
+		return Branch{}, db.auth.check(err)
+	}
+	if branch.Branched, err = time.Parse(time.RFC3339Nano, branched); err != nil {
+		return Branch{}, fmt.Errorf("invalid branch point %q: %w", branched, err)
+	}
+	return branch, nil
+}
+
+// Diff compares a branch with its parent as it was when the branch was
+// made, calling emit with every row that differs. Tables are compared in
+// name order, and the rows of a table as deleted, updated and then inserted.
+func Diff(ctx context.Context, databaseName string, emit func(Change) error) error {
+	if PrincipalFrom(ctx).Role != AdminRole {
+		return fmt.Errorf("%w: only admins can diff branches", ErrDenied)
+	}
+	if err := Get(databaseName); err != nil {
+		return err
+	}
+	db, err := open(ctx, databaseName)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// the handle holds the database, so the base can't be dropped meanwhile
+	base := db.path + baseSuffix
+	sealed, err := isSealed(base)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNoBranchBase
+	} else if err != nil {
+		return err
+	}
+
+	ctx, cancel := db.auth.deadline(ctx)
+	defer cancel()
+
+	if err := db.auth.admit(ctx, databaseName, db.path); err != nil {
+		return err
+	}
+	usage := metering.Usage{Queries: 1}
+	defer func() {
+		usage.RowsRead = db.auth.rows
+		db.auth.meter(databaseName, db.path, usage)
+	}()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var image []byte
+	if sealed {
+		if image, _, err = readSealed(base); err != nil {
+			return err
+		}
+	}
+	if err := db.attach(ctx, conn, parentSchema, base, image); err != nil {
+		return err
+	}
+	defer db.detach(context.Background(), conn, parentSchema)
+
+	txn, err := conn.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	d := &differ{ctx: ctx, db: db, txn: txn, emit: emit}
+	if err := d.diff(); err != nil {
+		return db.auth.check(err)
+	}
+	return nil
+}
+
+// attach attaches a database file, or the image of an encrypted one, to a
+// connection as schema. The authorizer lets it through, as it doesn't come
+// from the statements of the principal.
+func (h *handle) attach(ctx context.Context, conn *sql.Conn, schema string, path string, image []byte) error {
+	h.auth.setup = true
+	defer func() { h.auth.setup = false }()
+
+	if image == nil {
+		_, err := conn.ExecContext(ctx, `ATTACH DATABASE ? AS `+quoteIdent(schema), path)
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, `ATTACH DATABASE ':memory:' AS `+quoteIdent(schema)); err != nil {
+		return err
+	}
+	return conn.Raw(func(driverConn any) error {
+		return driverConn.(*sqlite3.SQLiteConn).Deserialize(image, schema)
+	})
+}
+
+// detach undoes attach.
+func (h *handle) detach(ctx context.Context, conn *sql.Conn, schema string) error {
+	h.auth.setup = true
+	defer func() { h.auth.setup = false }()
+
+	_, err := conn.ExecContext(ctx, `DETACH DATABASE `+quoteIdent(schema))
+	return err
+}
+
+type differ struct {
+	ctx  context.Context
+	db   *handle
+	txn  *sql.Tx
+	emit func(Change) error
+}
+
+// diffTable is a table as found on one side of a diff.
+type diffTable struct {
+	columns []string
+	keys    []string // primary key columns, or rowid
+}
+
+func (d *differ) diff() error {
+	branch, err := d.tables("main")
+	if err != nil {
+		return err
+	}
+	parent, err := d.tables(parentSchema)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for name := range branch {
+		names = append(names, name)
+	}
+	for name := range parent {
+		if _, ok := branch[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		b, inBranch := branch[name]
+		p, inParent := parent[name]
+		var err error
+		switch {
+		case !inParent:
+			err = d.rows("insert", name, `SELECT * FROM main.`+quoteIdent(name))
+		case !inBranch:
+			err = d.rows("delete", name, `SELECT * FROM `+parentSchema+`.`+quoteIdent(name))
+		case !slices.Equal(b.columns, p.columns) || !slices.Equal(b.keys, p.keys):
+			err = d.emit(Change{Table: name, Op: "schema"})
+		default:
+			err = d.table(name, b)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tables lists the tables of a schema, but for the internal ones.
+func (d *differ) tables(schema string) (map[string]diffTable, error) {
+	rows, err := d.txn.QueryContext(d.ctx, `SELECT name FROM pragma_table_list
+		WHERE schema = ? AND type = 'table' AND name NOT LIKE 'sqlite_%' AND name <> ?
+		AND `+notInternal("name"), schema, branchTable)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tables := make(map[string]diffTable, len(names))
+	for _, name := range names {
+		rows, err := d.txn.QueryContext(d.ctx, `SELECT name, pk FROM pragma_table_info(?, ?) ORDER BY cid`, name, schema)
+		if err != nil {
+			return nil, err
+		}
+		var table diffTable
+		pks := map[int]string{}
+		for rows.Next() {
+			var column string
+			var pk int
+			if err := rows.Scan(&column, &pk); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			table.columns = append(table.columns, column)
+			if pk > 0 {
+				pks[pk] = column
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		for i := 1; i <= len(pks); i++ {
+			table.keys = append(table.keys, quoteIdent(pks[i]))
+		}
+		if len(table.keys) == 0 {
+			table.keys = []string{"rowid"}
+		}
+		tables[name] = table
+	}
+	return tables, nil
+}
+
+// table emits the rows of a table present on both sides that differ.
+func (d *differ) table(name string, table diffTable) error {
+	b := `main.` + quoteIdent(name)
+	p := parentSchema + `.` + quoteIdent(name)
+
+	var sameKey, sameRow []string
+	for _, key := range table.keys {
+		sameKey = append(sameKey, `p.`+key+` IS b.`+key+` COLLATE BINARY`)
+	}
+	for _, column := range table.columns {
+		column = quoteIdent(column)
+		sameRow = append(sameRow, `p.`+column+` IS b.`+column+` COLLATE BINARY AND typeof(p.`+column+`) = typeof(b.`+column+`)`)
+	}
+	on := strings.Join(sameKey, ` AND `)
+
+	if err := d.rows("delete", name, `SELECT * FROM `+p+` AS p WHERE NOT EXISTS (SELECT 1 FROM `+b+` AS b WHERE `+on+`)`+
+		qualifiedOrder("p", table.keys)); err != nil {
+		return err
+	}
+	if err := d.rows("update", name, `SELECT p.*, b.* FROM `+b+` AS b JOIN `+p+` AS p ON `+on+
+		` WHERE NOT (`+strings.Join(sameRow, ` AND `)+`)`+qualifiedOrder("b", table.keys)); err != nil {
+		return err
+	}
+	return d.rows("insert", name, `SELECT * FROM `+b+` AS b WHERE NOT EXISTS (SELECT 1 FROM `+p+` AS p WHERE `+on+`)`+
+		qualifiedOrder("b", table.keys))
+}
+
+func qualifiedOrder(alias string, keys []string) string {
+	qualified := make([]string, len(keys))
+	for i, key := range keys {
+		qualified[i] = alias + `.` + key
+	}
+	return ` ORDER BY ` + strings.Join(qualified, `, `)
+}
+
+// rows emits every row of a query as a change. The rows of updates hold the
+// parent row followed by the branch row.
+func (d *differ) rows(op string, table string, query string) error {
+	rows, err := d.txn.QueryContext(d.ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := resultColumns(rows)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		values, err := scanRow(rows, len(columns))
+		if err != nil {
+			return err
+		}
+		if err := d.db.auth.count(values); err != nil {
+			return err
+		}
+		change := Change{Table: table, Op: op, Columns: columns}
+		switch op {
+		case "insert":
+			change.Row = values
+		case "delete":
+			change.Parent = values
+		case "update":
+			half := len(columns) / 2
+			change.Columns = columns[:half]
+			change.Parent, change.Row = values[:half], values[half:]
+		}
+		if err := d.emit(change); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
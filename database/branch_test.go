@@ -0,0 +1,89 @@
+package database
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// branch clones parent into target the way the server does.
+func branch(t *testing.T, parent string, target string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "snapshot"+sqlite)
+	var snapshot Backup
+	err := ExportSnapshot(adminContext(), parent, func(backup Backup, r io.Reader) error {
+		snapshot = backup
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(path, data, 0o600)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Clone(adminContext(), target, path, snapshot); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// changes diffs a branch into the ops and rows it reports.
+func changes(t *testing.T, databaseName string) [][]any {
+	t.Helper()
+	var got [][]any
+	err := Diff(adminContext(), databaseName, func(change Change) error {
+		got = append(got, []any{change.Op, change.Parent, change.Row})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestDiffAgainstBranchPoint(t *testing.T) {
+	useDirs(t)
+	SetTrashRetention(time.Hour)
+	t.Cleanup(func() { SetTrashRetention(0) })
+
+	if err := Create(adminContext(), "main", "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	exec(t, "main", "INSERT INTO items VALUES (1, 'widget'), (2, 'gadget')")
+	branch(t, "main", "preview")
+
+	// what happens to the parent after branching isn't a change of the branch
+	exec(t, "main", "INSERT INTO items VALUES (3, 'gizmo'); UPDATE items SET name = 'sprocket' WHERE id = 1; DELETE FROM items WHERE id = 2")
+	exec(t, "preview", "UPDATE items SET name = 'doohickey' WHERE id = 2; INSERT INTO items VALUES (4, 'thingamajig')")
+
+	want := [][]any{
+		{"update", []any{int64(2), "gadget"}, []any{int64(2), "doohickey"}},
+		{"insert", []any(nil), []any{int64(4), "thingamajig"}},
+	}
+	if got := changes(t, "preview"); !reflect.DeepEqual(got, want) {
+		t.Errorf("diff = %v, want %v", got, want)
+	}
+
+	// the base goes to the trash and back with the branch
+	if err := Drop("preview"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Undrop(adminContext(), "preview", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := changes(t, "preview"); !reflect.DeepEqual(got, want) {
+		t.Errorf("diff after a restore from the trash = %v, want %v", got, want)
+	}
+
+	// and is deleted along with it
+	path, _ := filePath("preview")
+	if err := purge("preview"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + baseSuffix); !os.IsNotExist(err) {
+		t.Errorf("the base of a purged branch is left: %v", err)
+	}
+}
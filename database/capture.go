@@ -0,0 +1,189 @@
+package database
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Every row a connection inserts, updates or deletes in the main database is
+// captured by SQLite hooks while its statement runs. The commit hook numbers
+// the transaction in commit order and hands its changes to the handle, which
+// logs them once the commit is durable: after the WAL is archived, or the
+// image of an encrypted database sealed back to disk.
+//
+// The preupdate hook captures the old and new values of every row, tables
+// without a rowid included. go-sqlite3 only has it when built with the
+// sqlite_preupdate_hook tag, without which changes can't be captured.
+
+// Operations of a change, as in a diff.
+const (
+	OpInsert = "insert"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+// ChangeEvent is a row changed by a committed transaction.
+type ChangeEvent struct {
+	Offset  uint64    `json:"offset"` // position in the changelog, to resume after
+	Commit  uint64    `json:"commit"` // sequence number of the transaction
+	Time    time.Time `json:"time"`
+	Table   string    `json:"table"`
+	Op      string    `json:"op"`
+	RowID   int64     `json:"rowid"`
+	Columns []Column  `json:"columns"`
+	Old     []any     `json:"old,omitempty"`
+	New     []any     `json:"new,omitempty"`
+}
+
+// capture collects the changes made through the connections of a handle.
+type capture struct {
+	sync.Mutex
+	feed      *feed
+	sealed    bool
+	pending   []ChangeEvent  // changes of the open transaction
+	committed []*transaction // transactions committed but not logged yet
+}
+
+// transaction is a committed transaction waiting to be logged.
+type transaction struct {
+	commit  uint64
+	time    time.Time
+	sealed  bool
+	changes []ChangeEvent
+}
+
+// captured reports whether changes to a table are captured, leaving out
+// attached databases and the tables SQLite and the server keep.
+func captured(schema string, table string) bool {
+	if schema != "main" || table == branchTable || table == rlsTable || isInternalTable(table) {
+		return false
+	}
+	return len(table) < 7 || table[:7] != "sqlite_"
+}
+
+func opName(op int) string {
+	switch op {
+	case sqlite3.SQLITE_INSERT:
+		return OpInsert
+	case sqlite3.SQLITE_UPDATE:
+		return OpUpdate
+	}
+	return OpDelete
+}
+
+// install registers the hooks of the capture on conn.
+func (c *capture) install(conn *sqlite3.SQLiteConn) error {
+	if err := c.watch(conn); err != nil {
+		return err
+	}
+	conn.RegisterCommitHook(func() int {
+		c.Lock()
+		defer c.Unlock()
+		if len(c.pending) > 0 {
+			c.committed = append(c.committed, &transaction{
+				commit:  c.feed.number(),
+				time:    time.Now().UTC(),
+				sealed:  c.sealed,
+				changes: c.pending,
+			})
+			c.pending = nil
+		}
+		return 0
+	})
+	conn.RegisterRollbackHook(func() {
+		c.Lock()
+		c.pending = nil
+		c.Unlock()
+	})
+	return nil
+}
+
+func (c *capture) add(change ChangeEvent) {
+	c.Lock()
+	c.pending = append(c.pending, change)
+	c.Unlock()
+}
+
+func (c *capture) take() []*transaction {
+	c.Lock()
+	defer c.Unlock()
+	committed := c.committed
+	c.committed = nil
+	return committed
+}
+
+// logChanges logs the transactions the handle committed. With durable false
+// they are only released, as their commit didn't make it to disk.
+func (h *handle) logChanges(ctx context.Context, durable bool) {
+	if h.capture == nil {
+		return
+	}
+	committed := h.capture.take()
+	if len(committed) == 0 {
+		return
+	}
+	if durable {
+		h.auth.setup = true
+		h.describe(ctx, committed)
+		h.auth.setup = false
+	}
+	for _, txn := range committed {
+		if !durable {
+			txn.changes = nil
+		}
+		h.capture.feed.log(txn)
+	}
+}
+
+// describe fills in the columns of the changes.
+func (h *handle) describe(ctx context.Context, committed []*transaction) {
+	columns := make(map[string][]Column)
+	for _, txn := range committed {
+		for i := range txn.changes {
+			change := &txn.changes[i]
+			if _, ok := columns[change.Table]; !ok {
+				columns[change.Table] = h.tableColumns(ctx, change.Table)
+			}
+			change.Columns = fitColumns(columns[change.Table], max(len(change.Old), len(change.New)))
+		}
+	}
+}
+
+// fitColumns matches the columns of a table to the values of a row captured
+// before its schema changed, naming the columns it no longer has by position.
+func fitColumns(columns []Column, n int) []Column {
+	if n == 0 || len(columns) == n {
+		return columns
+	}
+	fitted := make([]Column, n)
+	for i := range fitted {
+		if i < len(columns) {
+			fitted[i] = columns[i]
+		} else {
+			fitted[i] = Column{Name: "column" + strconv.Itoa(i+1)}
+		}
+	}
+	return fitted
+}
+
+// tableColumns lists the columns of a table in the order rows store them.
+func (h *handle) tableColumns(ctx context.Context, table string) []Column {
+	rows, err := h.QueryContext(ctx, `SELECT name, type FROM pragma_table_xinfo(?, 'main')`, table)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var columns []Column
+	for rows.Next() {
+		var column Column
+		if rows.Scan(&column.Name, &column.Type) == nil {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
@@ -0,0 +1,13 @@
+//go:build !sqlite_preupdate_hook
+
+package database
+
+import "github.com/mattn/go-sqlite3"
+
+// CaptureAvailable reports whether this build can capture changes.
+const CaptureAvailable = false
+
+// watch is never called, as changes can't be captured without the preupdate hook.
+func (c *capture) watch(conn *sqlite3.SQLiteConn) error {
+	return ErrNoCapture
+}
@@ -0,0 +1,29 @@
+//go:build !sqlite_preupdate_hook
+
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNoCaptureRefusesFollowers(t *testing.T) {
+	useDirs(t)
+	if err := Create(adminContext(), "orders", "CREATE TABLE items (name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Changelog(adminContext(), "orders"); !errors.Is(err, ErrNoCapture) {
+		t.Errorf("changelog = %v, want ErrNoCapture", err)
+	}
+	err := Subscribe(adminContext(), "orders", -1, func(Position) error { return nil }, func(ChangeEvent) error { return nil })
+	if !errors.Is(err, ErrNoCapture) {
+		t.Errorf("subscribe = %v, want ErrNoCapture", err)
+	}
+	if _, err := CreateWebhook(adminContext(), "orders", Webhook{URL: "https://example.com/hook"}); !errors.Is(err, ErrNoCapture) {
+		t.Errorf("webhook creation = %v, want ErrNoCapture", err)
+	}
+	if err := SetChangelogRetention(1); !errors.Is(err, ErrNoCapture) {
+		t.Errorf("setting a changelog retention = %v, want ErrNoCapture", err)
+	}
+}
@@ -0,0 +1,89 @@
+//go:build sqlite_preupdate_hook
+
+package database
+
+/*
+typedef struct sqlite3 sqlite3;
+typedef struct sqlite3_value sqlite3_value;
+int sqlite3_preupdate_count(sqlite3*);
+int sqlite3_preupdate_old(sqlite3*, int, sqlite3_value**);
+int sqlite3_preupdate_new(sqlite3*, int, sqlite3_value**);
+int sqlite3_value_type(sqlite3_value*);
+long long sqlite3_value_int64(sqlite3_value*);
+double sqlite3_value_double(sqlite3_value*);
+const void *sqlite3_value_blob(sqlite3_value*);
+const unsigned char *sqlite3_value_text(sqlite3_value*);
+int sqlite3_value_bytes(sqlite3_value*);
+
+#define BEDROOMPOP_INTEGER 1
+#define BEDROOMPOP_FLOAT 2
+#define BEDROOMPOP_TEXT 3
+#define BEDROOMPOP_BLOB 4
+*/
+import "C"
+
+import (
+	"unsafe"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// CaptureAvailable reports whether this build can capture changes.
+const CaptureAvailable = true
+
+// watch captures the rows changed on conn with their values through the
+// preupdate hook. go-sqlite3 hands text over as bytes, so the values are
+// read from the raw connection to keep their storage class.
+func (c *capture) watch(conn *sqlite3.SQLiteConn) error {
+	handle, err := rawHandle(conn)
+	if err != nil {
+		return err
+	}
+	db := (*C.sqlite3)(handle)
+	conn.RegisterPreUpdateHook(func(d sqlite3.SQLitePreUpdateData) {
+		if !captured(d.DatabaseName, d.TableName) {
+			return
+		}
+		n := int(C.sqlite3_preupdate_count(db))
+		change := ChangeEvent{Table: d.TableName, Op: opName(d.Op), RowID: d.NewRowID}
+		if d.Op != sqlite3.SQLITE_INSERT {
+			change.Old = preupdateValues(db, n, false)
+		}
+		if d.Op == sqlite3.SQLITE_DELETE {
+			change.RowID = d.OldRowID
+		} else {
+			change.New = preupdateValues(db, n, true)
+		}
+		c.add(change)
+	})
+	return nil
+}
+
+// preupdateValues copies the old or the new values of the row being changed.
+func preupdateValues(db *C.sqlite3, n int, new bool) []any {
+	values := make([]any, n)
+	for i := range values {
+		var v *C.sqlite3_value
+		if new {
+			C.sqlite3_preupdate_new(db, C.int(i), &v)
+		} else {
+			C.sqlite3_preupdate_old(db, C.int(i), &v)
+		}
+		if v == nil {
+			continue
+		}
+		switch C.sqlite3_value_type(v) {
+		case C.BEDROOMPOP_INTEGER:
+			values[i] = int64(C.sqlite3_value_int64(v))
+		case C.BEDROOMPOP_FLOAT:
+			values[i] = float64(C.sqlite3_value_double(v))
+		case C.BEDROOMPOP_TEXT:
+			text := unsafe.Pointer(C.sqlite3_value_text(v))
+			values[i] = C.GoStringN((*C.char)(text), C.sqlite3_value_bytes(v))
+		case C.BEDROOMPOP_BLOB:
+			blob := C.sqlite3_value_blob(v)
+			values[i] = C.GoBytes(blob, C.sqlite3_value_bytes(v))
+		}
+	}
+	return values
+}
@@ -0,0 +1,658 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// The changes of every database are logged by the node owning it under
+// changelog/<database>/ in the changelog directory, as segments of JSON lines
+// named after the offset of their first change. Offsets and commit sequence
+// numbers only grow, so a subscriber resumes after the last offset it saw
+// for as long as the segment holding it is retained. Changes of encrypted
+// databases are sealed with the master key, only their position in clear.
+
+// changelogDir holds the changelogs, by database name. It is under the data
+// directory unless set otherwise.
+var changelogDir = "changelog"
+
+// changelogRetention is how long changes are kept, 0 to capture none.
+var changelogRetention time.Duration
+
+// changelogSegmentSize is how large a segment grows before the next one is started.
+const changelogSegmentSize = 16 << 20
+
+var (
+	ErrOffsetExpired = errors.New("changelog offset is no longer retained")
+	ErrNoChangelog   = errors.New("changes aren't captured on this node")
+	ErrNoCapture     = errors.New("capturing changes needs a build with -tags sqlite_preupdate_hook")
+)
+
+// SetChangelogDir creates dir if needed and keeps the changelogs there.
+func SetChangelogDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	changelogDir = dir
+	return nil
+}
+
+// SetChangelogRetention sets how long changes are kept, 0 turning capture
+// off. Builds without the preupdate hook can't turn it on.
+func SetChangelogRetention(retention time.Duration) error {
+	if retention > 0 && !CaptureAvailable {
+		return ErrNoCapture
+	}
+	changelogRetention = retention
+	return nil
+}
+
+// feed numbers the commits of a database and writes their changes to its
+// changelog in commit order, waking up its subscribers.
+type feed struct {
+	sync.Mutex
+	name    string
+	loaded  error
+	ready   bool
+	id      string // tells this changelog from those of the database on other nodes
+	offset  uint64 // last change written
+	commit  uint64 // last commit numbered
+	written uint64 // last commit written
+	waiting map[uint64]*transaction
+	segment uint64 // first offset of the last segment
+	size    int64  // of the last segment
+	notify  chan struct{}
+}
+
+var feeds = struct {
+	sync.Mutex
+	m map[string]*feed
+}{m: make(map[string]*feed)}
+
+func feedOf(databaseName string) *feed {
+	feeds.Lock()
+	defer feeds.Unlock()
+	f, ok := feeds.m[databaseName]
+	if !ok {
+		f = &feed{name: databaseName, waiting: make(map[uint64]*transaction), notify: make(chan struct{})}
+		feeds.m[databaseName] = f
+	}
+	return f
+}
+
+func (f *feed) dir() string {
+	return filepath.Join(changelogDir, f.name)
+}
+
+// segments lists the first offsets of the segments of a changelog, in order.
+func segments(dir string) ([]uint64, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.log"))
+	if err != nil {
+		return nil, err
+	}
+	var firsts []uint64
+	for _, path := range paths {
+		first, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(path), ".log"), 10, 64)
+		if err == nil {
+			firsts = append(firsts, first)
+		}
+	}
+	sort.Slice(firsts, func(i, j int) bool { return firsts[i] < firsts[j] })
+	return firsts, nil
+}
+
+func segmentPath(dir string, first uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%020d.log", first))
+}
+
+// changelogID reads the id of a changelog, drawing it when the changelog is
+// new. Offsets only mean something within the changelog they come from.
+func changelogID(dir string) (string, error) {
+	path := filepath.Join(dir, "id")
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return strings.TrimSpace(string(data)), nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(buf)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	return id, os.WriteFile(path, []byte(id+"\n"), 0o600)
+}
+
+// load picks up where the changelog left off, from the last line of its last
+// segment. It is called with f locked.
+func (f *feed) load() error {
+	if f.ready {
+		return f.loaded
+	}
+	f.ready = true
+
+	if f.id, f.loaded = changelogID(f.dir()); f.loaded != nil {
+		return f.loaded
+	}
+	firsts, err := segments(f.dir())
+	if err != nil || len(firsts) == 0 {
+		f.loaded = err
+		return err
+	}
+	f.segment = firsts[len(firsts)-1]
+	data, err := os.ReadFile(segmentPath(f.dir(), f.segment))
+	if err != nil {
+		f.loaded = err
+		return err
+	}
+	f.size = int64(len(data))
+	// a line cut short by a crash is left behind, and skipped when read
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		var position logLine
+		if json.Unmarshal(line, &position) == nil && position.Offset > f.offset {
+			f.offset, f.commit = position.Offset, position.Commit
+		}
+	}
+	f.written = f.commit
+	return nil
+}
+
+// number hands out the sequence number of a commit.
+func (f *feed) number() uint64 {
+	f.Lock()
+	defer f.Unlock()
+	f.load()
+	f.commit++
+	return f.commit
+}
+
+// log writes a committed transaction once every commit numbered before it
+// is written. Transactions without changes only release their number.
+func (f *feed) log(txn *transaction) {
+	f.Lock()
+	defer f.Unlock()
+
+	f.waiting[txn.commit] = txn
+	wrote := false
+	for {
+		next, ok := f.waiting[f.written+1]
+		if !ok {
+			break
+		}
+		delete(f.waiting, f.written+1)
+		f.written++
+		if len(next.changes) == 0 {
+			continue
+		}
+		if err := f.write(next); err != nil {
+			log.Error("can't log changes", "database", f.name, "commit", next.commit, "err", err)
+			continue
+		}
+		wrote = true
+	}
+	if wrote {
+		close(f.notify)
+		f.notify = make(chan struct{})
+	}
+}
+
+// write appends the changes of a transaction to the last segment, starting
+// the next one when it grew too large. It is called with f locked.
+func (f *feed) write(txn *transaction) error {
+	var buf bytes.Buffer
+	offset := f.offset
+	for _, change := range txn.changes {
+		offset++
+		line, err := encodeChange(offset, txn, change)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	if err := os.MkdirAll(f.dir(), 0o700); err != nil {
+		return err
+	}
+	if f.segment == 0 || f.size >= changelogSegmentSize {
+		f.segment, f.size = f.offset+1, 0
+	}
+	path := segmentPath(f.dir(), f.segment)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(buf.Bytes())
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// leave no partial line for the next transaction to follow
+		os.Truncate(path, f.size)
+		return err
+	}
+	f.size += int64(buf.Len())
+	f.offset = offset
+	return nil
+}
+
+// logLine is a change as written to the changelog.
+type logLine struct {
+	Offset uint64        `json:"offset"`
+	Commit uint64        `json:"commit"`
+	Change *loggedChange `json:"change,omitempty"`
+	Key    string        `json:"key,omitempty"`    // id of the master key sealing the change
+	Sealed []byte        `json:"sealed,omitempty"` // the change of an encrypted database
+}
+
+type loggedChange struct {
+	Time    time.Time     `json:"time"`
+	Table   string        `json:"table"`
+	Op      string        `json:"op"`
+	RowID   int64         `json:"rowid"`
+	Columns []Column      `json:"columns"`
+	Old     []loggedValue `json:"old,omitempty"`
+	New     []loggedValue `json:"new,omitempty"`
+}
+
+// loggedValue keeps the storage class of a value through JSON, as an object
+// naming it, or null.
+type loggedValue struct {
+	v any
+}
+
+func (l loggedValue) MarshalJSON() ([]byte, error) {
+	switch v := l.v.(type) {
+	case int64:
+		return json.Marshal(map[string]int64{"integer": v})
+	case float64:
+		return json.Marshal(map[string]float64{"real": v})
+	case string:
+		return json.Marshal(map[string]string{"text": v})
+	case []byte:
+		return json.Marshal(map[string]string{"blob": base64.StdEncoding.EncodeToString(v)})
+	}
+	return []byte("null"), nil
+}
+
+func (l *loggedValue) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Integer *int64   `json:"integer"`
+		Real    *float64 `json:"real"`
+		Text    *string  `json:"text"`
+		Blob    *string  `json:"blob"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch {
+	case v.Integer != nil:
+		l.v = *v.Integer
+	case v.Real != nil:
+		l.v = *v.Real
+	case v.Text != nil:
+		l.v = *v.Text
+	case v.Blob != nil:
+		blob, err := base64.StdEncoding.DecodeString(*v.Blob)
+		if err != nil {
+			return err
+		}
+		l.v = blob
+	default:
+		l.v = nil
+	}
+	return nil
+}
+
+func loggedValues(row []any) []loggedValue {
+	if row == nil {
+		return nil
+	}
+	values := make([]loggedValue, len(row))
+	for i, v := range row {
+		values[i] = loggedValue{v}
+	}
+	return values
+}
+
+func rowValues(values []loggedValue) []any {
+	if values == nil {
+		return nil
+	}
+	row := make([]any, len(values))
+	for i, v := range values {
+		row[i] = v.v
+	}
+	return row
+}
+
+func encodeChange(offset uint64, txn *transaction, change ChangeEvent) ([]byte, error) {
+	line := logLine{Offset: offset, Commit: txn.commit, Change: &loggedChange{
+		Time:    txn.time,
+		Table:   change.Table,
+		Op:      change.Op,
+		RowID:   change.RowID,
+		Columns: change.Columns,
+		Old:     loggedValues(change.Old),
+		New:     loggedValues(change.New),
+	}}
+	if txn.sealed {
+		plain, err := json.Marshal(line.Change)
+		if err != nil {
+			return nil, err
+		}
+		if line.Key, line.Sealed, err = sealChange(plain, offset); err != nil {
+			return nil, err
+		}
+		line.Change = nil
+	}
+	return json.Marshal(line)
+}
+
+func decodeChange(data []byte) (ChangeEvent, error) {
+	var line logLine
+	if err := json.Unmarshal(data, &line); err != nil {
+		return ChangeEvent{}, err
+	}
+	if line.Sealed != nil {
+		plain, err := unsealChange(line.Key, line.Sealed, line.Offset)
+		if err != nil {
+			return ChangeEvent{}, err
+		}
+		if err := json.Unmarshal(plain, &line.Change); err != nil {
+			return ChangeEvent{}, err
+		}
+	}
+	if line.Change == nil {
+		return ChangeEvent{}, errors.New("changelog line holds no change")
+	}
+	return ChangeEvent{
+		Offset:  line.Offset,
+		Commit:  line.Commit,
+		Time:    line.Change.Time,
+		Table:   line.Change.Table,
+		Op:      line.Change.Op,
+		RowID:   line.Change.RowID,
+		Columns: line.Change.Columns,
+		Old:     rowValues(line.Change.Old),
+		New:     rowValues(line.Change.New),
+	}, nil
+}
+
+// sealChange seals a change with the current master key, bound to its offset.
+func sealChange(plain []byte, offset uint64) (string, []byte, error) {
+	keyring.RLock()
+	id := keyring.current
+	master, ok := keyring.keys[id]
+	keyring.RUnlock()
+	if !ok {
+		return "", nil, ErrNoMasterKey
+	}
+	aead, err := newGCM(master)
+	if err != nil {
+		return "", nil, err
+	}
+	sealed, err := seal(aead, plain, []byte(strconv.FormatUint(offset, 10)))
+	return hex.EncodeToString(id[:]), sealed, err
+}
+
+func unsealChange(key string, sealed []byte, offset uint64) ([]byte, error) {
+	var id keyID
+	if raw, err := hex.DecodeString(key); err != nil || len(raw) != keyIDSize {
+		return nil, errors.New("invalid master key id in changelog")
+	} else {
+		copy(id[:], raw)
+	}
+	keyring.RLock()
+	master, ok := keyring.keys[id]
+	keyring.RUnlock()
+	if !ok {
+		return nil, ErrNoMasterKey
+	}
+	aead, err := newGCM(master)
+	if err != nil {
+		return nil, err
+	}
+	return unseal(aead, sealed, []byte(strconv.FormatUint(offset, 10)))
+}
+
+// Position is where a subscription starts in the changelog of a database.
+type Position struct {
+	Log    string `json:"log"`    // id of the changelog the offsets are in
+	After  uint64 `json:"after"`  // the subscription starts with the change after this offset
+	Oldest uint64 `json:"oldest"` // oldest offset retained, 0 when nothing is
+	Latest uint64 `json:"latest"` // last offset written
+}
+
+// sealChangelog seals the changes logged in the clear before a database was
+// encrypted. Lines cut short by a crash are dropped, readers skip them anyway.
+func sealChangelog(databaseName string) error {
+	f := feedOf(databaseName)
+	f.Lock()
+	defer f.Unlock()
+
+	if err := f.load(); err != nil {
+		return err
+	}
+	firsts, err := segments(f.dir())
+	if err != nil {
+		return err
+	}
+	for _, first := range firsts {
+		path := segmentPath(f.dir(), first)
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			// expired meanwhile
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		var out bytes.Buffer
+		for _, raw := range bytes.SplitAfter(data, []byte("\n")) {
+			var line logLine
+			if !bytes.HasSuffix(raw, []byte("\n")) || json.Unmarshal(raw, &line) != nil {
+				continue
+			}
+			if line.Change != nil {
+				plain, err := json.Marshal(line.Change)
+				if err != nil {
+					return err
+				}
+				if line.Key, line.Sealed, err = sealChange(plain, line.Offset); err != nil {
+					return err
+				}
+				line.Change = nil
+				if raw, err = json.Marshal(line); err != nil {
+					return err
+				}
+				raw = append(raw, '\n')
+			}
+			out.Write(raw)
+		}
+		if err := replaceFile(path, out.Bytes()); err != nil {
+			return err
+		}
+		if first == f.segment {
+			f.size = int64(out.Len())
+		}
+	}
+	return nil
+}
+
+// position returns the changes a subscription could start from, after the
+// last one.
+func (f *feed) position() (Position, error) {
+	f.Lock()
+	err := f.load()
+	position := Position{Log: f.id, After: f.offset, Latest: f.offset}
+	f.Unlock()
+	if err != nil {
+		return Position{}, err
+	}
+	firsts, err := segments(f.dir())
+	if err != nil {
+		return Position{}, err
+	}
+	if len(firsts) > 0 {
+		position.Oldest = firsts[0]
+	}
+	return position, nil
+}
+
+// Changelog returns the changes retained for a database. Only admins can
+// follow changes.
+func Changelog(ctx context.Context, databaseName string) (Position, error) {
+	if PrincipalFrom(ctx).Role != AdminRole {
+		return Position{}, fmt.Errorf("%w: only admins can subscribe to changes", ErrDenied)
+	}
+	if !CaptureAvailable {
+		return Position{}, ErrNoCapture
+	}
+	if changelogRetention <= 0 {
+		return Position{}, ErrNoChangelog
+	}
+	if err := Get(databaseName); err != nil {
+		return Position{}, err
+	}
+	return feedOf(databaseName).position()
+}
+
+// Subscribe sends where the subscription starts and then every change of a
+// database logged after the offset, or after the last change when it is
+// negative, following new changes until ctx is done. Changes hold every row
+// whatever the policies, so only admins can subscribe.
+func Subscribe(ctx context.Context, databaseName string, after int64, start func(Position) error, send func(ChangeEvent) error) error {
+	position, err := Changelog(ctx, databaseName)
+	if err != nil {
+		return err
+	}
+	f := feedOf(databaseName)
+	switch {
+	case after < 0 || uint64(after) > position.Latest:
+		position.After = position.Latest
+	case after > 0 && position.Oldest > uint64(after)+1:
+		return fmt.Errorf("%w: the oldest change retained is %d", ErrOffsetExpired, position.Oldest)
+	default:
+		position.After = uint64(after)
+	}
+	if err := start(position); err != nil {
+		return err
+	}
+
+	next := position.After
+	for {
+		f.Lock()
+		notify := f.notify
+		latest := f.offset
+		f.Unlock()
+
+		if next < latest {
+			if next, err = f.read(next, latest, send); err != nil {
+				return err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-notify:
+		}
+	}
+}
+
+// read sends the changes after offset up to latest, returning the last
+// offset sent.
+func (f *feed) read(after uint64, latest uint64, send func(ChangeEvent) error) (uint64, error) {
+	firsts, err := segments(f.dir())
+	if err != nil {
+		return after, err
+	}
+	for i, first := range firsts {
+		if i+1 < len(firsts) && firsts[i+1] <= after+1 {
+			continue
+		}
+		if first > after+1 {
+			return after, fmt.Errorf("%w: changes after %d were purged while being read", ErrOffsetExpired, after)
+		}
+		file, err := os.Open(segmentPath(f.dir(), first))
+		if err != nil {
+			return after, err
+		}
+		r := bufio.NewReader(file)
+		for after < latest {
+			line, err := r.ReadBytes('\n')
+			if err != nil {
+				// the rest of the segment is still being written
+				break
+			}
+			change, err := decodeChange(line)
+			if err != nil {
+				file.Close()
+				return after, err
+			}
+			if change.Offset <= after {
+				continue
+			}
+			if err := send(change); err != nil {
+				file.Close()
+				return after, err
+			}
+			after = change.Offset
+		}
+		file.Close()
+		if after >= latest {
+			break
+		}
+	}
+	return after, nil
+}
+
+// ExpireChangelog removes the segments older than the retention once per
+// interval, keeping the last one of every database it keeps writing to.
+func ExpireChangelog(interval time.Duration) {
+	for range time.Tick(interval) {
+		dirs, err := os.ReadDir(changelogDir)
+		if err != nil {
+			log.Error("can't list changelogs", "err", err)
+			continue
+		}
+		for _, dir := range dirs {
+			path := filepath.Join(changelogDir, dir.Name())
+			firsts, err := segments(path)
+			if err != nil {
+				continue
+			}
+			for _, first := range firsts[:max(len(firsts)-1, 0)] {
+				segment := segmentPath(path, first)
+				info, err := os.Stat(segment)
+				if err != nil || time.Since(info.ModTime()) < changelogRetention {
+					continue
+				}
+				if err := os.Remove(segment); err != nil {
+					log.Error("can't expire changelog segment", "database", dir.Name(), "err", err)
+				}
+			}
+		}
+	}
+}
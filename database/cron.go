@@ -0,0 +1,158 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// Schedule is a cron expression: minute, hour, day of month, month and day
+// of week, or one of @yearly, @monthly, @weekly, @daily and @hourly. Fields
+// take *, values, ranges, steps and lists of them, months and days of week
+// by their English abbreviations too, with 0 or 7 for Sunday. As in cron, a
+// day matches when either day field does if both are restricted.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	anyDay                        bool // one of the day fields is *
+}
+
+var scheduleMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var monthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+var dayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// ParseSchedule parses a cron expression.
+func ParseSchedule(expr string) (Schedule, error) {
+	if macro, ok := scheduleMacros[strings.ToLower(strings.TrimSpace(expr))]; ok {
+		expr = macro
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return Schedule{}, fmt.Errorf("%w: %q needs 5 fields, minute hour day-of-month month day-of-week", ErrInvalidSchedule, expr)
+	}
+
+	var s Schedule
+	var err error
+	if s.minute, err = cronField(fields[0], 0, 59, nil); err != nil {
+		return Schedule{}, err
+	}
+	if s.hour, err = cronField(fields[1], 0, 23, nil); err != nil {
+		return Schedule{}, err
+	}
+	if s.dom, err = cronField(fields[2], 1, 31, nil); err != nil {
+		return Schedule{}, err
+	}
+	if s.month, err = cronField(fields[3], 1, 12, monthNames); err != nil {
+		return Schedule{}, err
+	}
+	if s.dow, err = cronField(fields[4], 0, 7, dayNames); err != nil {
+		return Schedule{}, err
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.anyDay = strings.HasPrefix(fields[2], "*") || strings.HasPrefix(fields[4], "*")
+	return s, nil
+}
+
+// cronField parses a field into the set of values it matches.
+func cronField(field string, lo int, hi int, names []string) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		bounds, step := part, 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("%w: bad step in %q", ErrInvalidSchedule, part)
+			}
+			bounds, step = part[:i], n
+		}
+
+		first, last := lo, hi
+		if bounds != "*" {
+			from, to, isRange := strings.Cut(bounds, "-")
+			var err error
+			if first, err = cronValue(from, lo, hi, names); err != nil {
+				return 0, err
+			}
+			last = first
+			if isRange {
+				if last, err = cronValue(to, lo, hi, names); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				// 5/15 runs from 5 to the end of the range
+				last = hi
+			}
+			if first > last {
+				return 0, fmt.Errorf("%w: range %q goes backwards", ErrInvalidSchedule, bounds)
+			}
+		}
+		for v := first; v <= last; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+func cronValue(s string, lo int, hi int, names []string) (int, error) {
+	for i, name := range names {
+		if strings.EqualFold(s, name) {
+			return i + lo, nil
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < lo || n > hi {
+		return 0, fmt.Errorf("%w: %q isn't between %d and %d", ErrInvalidSchedule, s, lo, hi)
+	}
+	return n, nil
+}
+
+func (s Schedule) matchesDay(t time.Time) bool {
+	dom := s.dom&(1<<t.Day()) != 0
+	dow := s.dow&(1<<int(t.Weekday())) != 0
+	if s.anyDay {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next returns the first time after t the schedule matches, in the location
+// of t, or the zero time if it never does, like on the 30th of February.
+func (s Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hour&(1<<t.Hour()) == 0:
+			// by the clock, as the next hour by the calendar may not exist
+			t = t.Add(time.Hour - time.Duration(t.Minute())*time.Minute)
+		case s.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// never reports whether the schedule can't match, leap days included.
+func (s Schedule) never() bool {
+	return s.Next(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)).IsZero()
+}
@@ -0,0 +1,377 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Encrypted database files replace the SQLite file with a header holding the
+// data key of the database, wrapped by a master key, followed by every SQLite
+// page sealed on its own with AES-256-GCM:
+//
+//	magic | master key id | wrapped data key | page size | page count
+//	nonce | sealed page 0 | nonce | sealed page 1 | ...
+//
+// Each page is sealed with the magic, page size and page count of the header
+// and its own number, so the header can't be altered and pages can't be
+// dropped or reordered. This isn't page-level encryption as SQLCipher does
+// it: go-sqlite3 has no VFS hook, so a sealed file is decrypted as a whole
+// into an in-memory connection when opened and sealed again as a whole after
+// a commit changed it, and writers hold the database exclusively. No
+// plaintext of a sealed database is ever written to disk.
+
+var ErrNoMasterKey = errors.New("no master key can unwrap the data key of this database")
+
+var sealedMagic = []byte("BPCRYPT2")
+
+const (
+	keySize     = 32
+	keyIDSize   = 8
+	wrappedSize = 12 + keySize + 16
+	headerSize  = 8 + keyIDSize + wrappedSize + 4 + 4
+	defaultPage = 4096
+)
+
+type keyID [keyIDSize]byte
+
+// keyring holds the master key new data keys are wrapped with, plus previous
+// master keys that can still unwrap data keys until they are rotated.
+var keyring = struct {
+	sync.RWMutex
+	current keyID
+	keys    map[keyID][]byte
+}{}
+
+// LoadMasterKeys reads the master key from a keyfile, holding either 32 raw
+// bytes or 64 hex characters. Previous keyfiles are only used for unwrapping.
+func LoadMasterKeys(current string, previous ...string) error {
+	keys := make(map[keyID][]byte)
+	var currentID keyID
+	for i, path := range append([]string{current}, previous...) {
+		key, err := readKeyFile(path)
+		if err != nil {
+			return err
+		}
+		id := masterKeyID(key)
+		keys[id] = key
+		if i == 0 {
+			currentID = id
+		}
+	}
+
+	keyring.Lock()
+	defer keyring.Unlock()
+
+	keyring.current = currentID
+	keyring.keys = keys
+	return nil
+}
+
+// Encryption reports whether new databases are encrypted.
+func Encryption() bool {
+	keyring.RLock()
+	defer keyring.RUnlock()
+
+	return keyring.keys != nil
+}
+
+func readKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == keySize {
+		return data, nil
+	}
+
+	key, err := hex.DecodeString(string(bytes.TrimSpace(data)))
+	if err != nil || len(key) != keySize {
+		return nil, fmt.Errorf("%s: a master key must be %d raw bytes or %d hex characters", path, keySize, keySize*2)
+	}
+	return key, nil
+}
+
+func masterKeyID(key []byte) (id keyID) {
+	sum := sha256.Sum256(key)
+	copy(id[:], sum[:])
+	return
+}
+
+func newDataKey() ([]byte, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func seal(aead cipher.AEAD, plain []byte, additional []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plain)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plain, additional), nil
+}
+
+func unseal(aead cipher.AEAD, sealed []byte, additional []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed data is truncated")
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additional)
+}
+
+// isSealed reports whether the file at path is an encrypted database.
+func isSealed(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	magic := make([]byte, len(sealedMagic))
+	if _, err := io.ReadFull(file, magic); err != nil {
+		return false, nil
+	}
+	return bytes.Equal(magic, sealedMagic), nil
+}
+
+// readSealed decrypts an encrypted database file, returning the SQLite image and its data key.
+func readSealed(path string) (image []byte, dataKey []byte, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(data) < headerSize || !bytes.Equal(data[:8], sealedMagic) {
+		return nil, nil, errors.New("not an encrypted database")
+	}
+
+	var id keyID
+	copy(id[:], data[8:])
+	keyring.RLock()
+	master, ok := keyring.keys[id]
+	keyring.RUnlock()
+	if !ok {
+		return nil, nil, ErrNoMasterKey
+	}
+
+	masterGCM, err := newGCM(master)
+	if err != nil {
+		return nil, nil, err
+	}
+	dataKey, err = unseal(masterGCM, data[8+keyIDSize:8+keyIDSize+wrappedSize], id[:])
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't unwrap data key: %w", err)
+	}
+
+	pageSize := int(binary.BigEndian.Uint32(data[headerSize-8:]))
+	pages := int(binary.BigEndian.Uint32(data[headerSize-4:]))
+	dataGCM, err := newGCM(dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sealedPage := dataGCM.NonceSize() + pageSize + dataGCM.Overhead()
+	if len(data) != headerSize+pages*sealedPage {
+		return nil, nil, errors.New("encrypted database is truncated")
+	}
+
+	image = make([]byte, 0, pages*pageSize)
+	for i := 0; i < pages; i++ {
+		offset := headerSize + i*sealedPage
+		page, err := unseal(dataGCM, data[offset:offset+sealedPage], pageAdditional(data[:headerSize], i))
+		if err != nil {
+			return nil, nil, fmt.Errorf("can't decrypt page %d: %w", i, err)
+		}
+		image = append(image, page...)
+	}
+
+	return image, dataKey, nil
+}
+
+// writeSealed encrypts a SQLite image with dataKey, wraps the key with the
+// current master key and atomically replaces the file at path.
+func writeSealed(path string, image []byte, dataKey []byte) error {
+	keyring.RLock()
+	id := keyring.current
+	master, ok := keyring.keys[id]
+	keyring.RUnlock()
+	if !ok {
+		return ErrNoMasterKey
+	}
+
+	masterGCM, err := newGCM(master)
+	if err != nil {
+		return err
+	}
+	wrapped, err := seal(masterGCM, dataKey, id[:])
+	if err != nil {
+		return err
+	}
+
+	pageSize := imagePageSize(image)
+	if len(image)%pageSize != 0 {
+		return errors.New("database image isn't a whole number of pages")
+	}
+	pages := len(image) / pageSize
+
+	out := bytes.NewBuffer(make([]byte, 0, headerSize+len(image)+pages*(12+16)))
+	out.Write(sealedMagic)
+	out.Write(id[:])
+	out.Write(wrapped)
+	binary.Write(out, binary.BigEndian, uint32(pageSize))
+	binary.Write(out, binary.BigEndian, uint32(pages))
+
+	dataGCM, err := newGCM(dataKey)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < pages; i++ {
+		page, err := seal(dataGCM, image[i*pageSize:(i+1)*pageSize], pageAdditional(out.Bytes()[:headerSize], i))
+		if err != nil {
+			return err
+		}
+		out.Write(page)
+	}
+
+	return replaceFile(path, out.Bytes())
+}
+
+// replaceFile writes data next to path and renames it into place.
+func replaceFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// imagePageSize reads the page size from the SQLite header of image.
+func imagePageSize(image []byte) int {
+	if len(image) < 100 {
+		return defaultPage
+	}
+	size := int(binary.BigEndian.Uint16(image[16:18]))
+	if size == 1 {
+		return 65536
+	}
+	return size
+}
+
+// pageAdditional is the additional data sealed with page i of a file with
+// header: its magic, page size and page count followed by the page number.
+func pageAdditional(header []byte, i int) []byte {
+	additional := append([]byte{}, header[:len(sealedMagic)]...)
+	additional = append(additional, header[headerSize-8:headerSize]...)
+	return binary.BigEndian.AppendUint64(additional, uint64(i))
+}
+
+// locks guards database files against being replaced while in use. Plain
+// databases are shared since SQLite does its own locking, encrypted ones are
+// exclusive because each writer rewrites the whole image.
+var locks sync.Map
+
+func lock(databaseName string, exclusive bool) func() {
+	v, _ := locks.LoadOrStore(databaseName, new(sync.RWMutex))
+	mtx := v.(*sync.RWMutex)
+	if exclusive {
+		mtx.Lock()
+		return mtx.Unlock
+	}
+	mtx.RLock()
+	return mtx.RUnlock
+}
+
+// RotateKey re-encrypts a database under a fresh data key wrapped by the
+// current master key. Plain databases are encrypted in the process, along
+// with their backups, changelog and the base of a branch, and their WAL and
+// its archive are removed.
+func RotateKey(databaseName string) error {
+	if !Encryption() {
+		return ErrNoMasterKey
+	}
+	if err := Get(databaseName); err != nil {
+		return err
+	}
+	databasePath, err := filePath(databaseName)
+	if err != nil {
+		return err
+	}
+
+	unlock := lock(databaseName, true)
+	defer unlock()
+
+	sealed, err := isSealed(databasePath)
+	if err != nil {
+		return err
+	}
+
+	var image []byte
+	if sealed {
+		image, _, err = readSealed(databasePath)
+	} else {
+		image, err = readPlain(databasePath)
+	}
+	if err != nil {
+		return err
+	}
+
+	dataKey, err := newDataKey()
+	if err != nil {
+		return err
+	}
+	if err := writeSealed(databasePath, image, dataKey); err != nil {
+		return err
+	}
+	if sealed {
+		return nil
+	}
+
+	// leave nothing of the plain database in the clear
+	forgetWAL(databaseName, databasePath)
+	if err := dropWALArchive(databaseName); err != nil {
+		return err
+	}
+	if err := sealBackups(databaseName, dataKey); err != nil {
+		return err
+	}
+	if err := sealBase(databasePath, dataKey); err != nil {
+		return err
+	}
+	if err := sealChangelog(databaseName); err != nil {
+		return err
+	}
+	return dropStoredPlaintext(context.Background(), databaseName)
+}
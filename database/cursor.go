@@ -0,0 +1,325 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/trianglehasfoursides/bedroompop/metering"
+)
+
+// Cursors page through a result without the client adding LIMIT and OFFSET.
+// A cursor reads from a snapshot of the database taken when it is opened, so
+// it holds no lock while the client takes its time between pages and writers
+// are never blocked by it. On a plain database the snapshot is the read
+// transaction its statement holds on the WAL, which keeps checkpoints from
+// truncating the WAL until the cursor is closed. An encrypted database is
+// read from a copy of its image. Cursors live on the node owning the database and
+// are closed once exhausted, after sitting idle for the TTL, or when the
+// database is dropped.
+
+var (
+	ErrCursorNotFound = errors.New("cursor not found or expired")
+	ErrTooManyCursors = errors.New("too many open cursors")
+)
+
+// MaxPageSize is the largest page a cursor returns.
+const MaxPageSize = 10000
+
+var cursorTTL = time.Minute
+var maxCursors = 1024
+
+// SetCursorOptions sets how long an idle cursor is kept and how many may be open on this node.
+func SetCursorOptions(ttl time.Duration, max int) {
+	cursorTTL = ttl
+	maxCursors = max
+}
+
+// Page is a page of a result. Cursor fetches the next one and is empty once the result is exhausted.
+type Page struct {
+	Columns []Column
+	Rows    [][]any
+	Cursor  string
+}
+
+type cursor struct {
+	sync.Mutex
+	database  string
+	principal string
+	auth      *authorizer
+	path      string
+	db        *sql.DB
+	rows      *sql.Rows
+	columns   []Column
+	next      []any // the row after the page returned last, if any
+	cancel    context.CancelFunc
+	timer     *time.Timer
+}
+
+var cursors = struct {
+	sync.Mutex
+	open map[string]*cursor
+}{open: make(map[string]*cursor)}
+
+// QueryPage runs a read-only query and returns its first page, with a cursor for the next one if there is more.
+func QueryPage(ctx context.Context, databaseName string, query string, pageSize int) (Page, error) {
+	if err := Get(databaseName); err != nil {
+		return Page{}, err
+	}
+	pageSize = clampPageSize(pageSize)
+
+	cursors.Lock()
+	full := len(cursors.open) >= maxCursors
+	cursors.Unlock()
+	if full {
+		return Page{}, ErrTooManyCursors
+	}
+
+	c, err := openCursor(ctx, databaseName, query)
+	if err != nil {
+		return Page{}, err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	page, err := c.fetch(ctx, pageSize)
+	c.auth.meter(databaseName, c.path, metering.Usage{Queries: 1, RowsRead: int64(len(page.Rows))})
+	if err != nil || c.next == nil {
+		c.close()
+		return page, err
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		c.close()
+		return Page{}, fmt.Errorf("can't create cursor: %w", err)
+	}
+	id := hex.EncodeToString(buf)
+	page.Cursor = id
+
+	cursors.Lock()
+	defer cursors.Unlock()
+	cursors.open[id] = c
+	c.timer = time.AfterFunc(cursorTTL, func() { CloseCursor(context.WithoutCancel(ctx), databaseName, id) })
+	return page, nil
+}
+
+// NextPage returns the page after the one last returned for a cursor.
+func NextPage(ctx context.Context, databaseName string, id string, pageSize int) (Page, error) {
+	c, err := lookupCursor(ctx, databaseName, id)
+	if err != nil {
+		return Page{}, err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	if c.rows == nil {
+		// closed while waiting for the lock
+		return Page{}, ErrCursorNotFound
+	}
+	c.timer.Reset(cursorTTL)
+
+	if err := c.auth.admit(ctx, databaseName, c.path); err != nil {
+		return Page{}, err
+	}
+	page, err := c.fetch(ctx, clampPageSize(pageSize))
+	c.auth.meter(databaseName, c.path, metering.Usage{RowsRead: int64(len(page.Rows))})
+	if err != nil || c.next == nil {
+		forget(id)
+		c.close()
+		return page, err
+	}
+	page.Cursor = id
+	return page, nil
+}
+
+// CloseCursor releases a cursor before it is exhausted.
+func CloseCursor(ctx context.Context, databaseName string, id string) error {
+	c, err := lookupCursor(ctx, databaseName, id)
+	if err != nil {
+		return err
+	}
+	forget(id)
+
+	c.Lock()
+	defer c.Unlock()
+	c.close()
+	return nil
+}
+
+// closeCursors closes every cursor reading from a database.
+func closeCursors(databaseName string) {
+	cursors.Lock()
+	var closing []*cursor
+	for id, c := range cursors.open {
+		if c.database == databaseName {
+			closing = append(closing, c)
+			delete(cursors.open, id)
+		}
+	}
+	cursors.Unlock()
+
+	for _, c := range closing {
+		c.Lock()
+		c.close()
+		c.Unlock()
+	}
+}
+
+func clampPageSize(pageSize int) int {
+	if pageSize <= 0 {
+		return DefaultBatchSize
+	}
+	return min(pageSize, MaxPageSize)
+}
+
+func lookupCursor(ctx context.Context, databaseName string, id string) (*cursor, error) {
+	cursors.Lock()
+	defer cursors.Unlock()
+	c, ok := cursors.open[id]
+	// a cursor only exists for the principal who opened it
+	if !ok || c.database != databaseName || c.principal != PrincipalFrom(ctx).Name {
+		return nil, ErrCursorNotFound
+	}
+	return c, nil
+}
+
+func forget(id string) {
+	cursors.Lock()
+	defer cursors.Unlock()
+	delete(cursors.open, id)
+}
+
+// openCursor starts the query on a read-only snapshot of the database.
+func openCursor(ctx context.Context, databaseName string, query string) (*cursor, error) {
+	databasePath, err := filePath(databaseName)
+	if err != nil {
+		return nil, err
+	}
+
+	auth := &authorizer{
+		policy:    policyFor(databaseName, PrincipalFrom(ctx).Role),
+		principal: PrincipalFrom(ctx),
+	}
+	conn := &connector{dsn: databasePath + "?_journal_mode=WAL", auth: auth}
+	unlock := lock(databaseName, false)
+	sealed, err := isSealed(databasePath)
+	if err == nil && sealed {
+		conn.image, _, err = readSealed(databasePath)
+		if conn.image == nil {
+			conn.image = []byte{}
+		}
+	}
+	unlock()
+	if err != nil {
+		return nil, err
+	}
+	db := sql.OpenDB(conn)
+	db.SetMaxOpenConns(1)
+
+	// the cursor outlives the request that opened it
+	cctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	c := &cursor{
+		database:  databaseName,
+		principal: PrincipalFrom(ctx).Name,
+		auth:      auth,
+		path:      databasePath,
+		db:        db,
+		cancel:    cancel,
+	}
+
+	ctx, stopDeadline := auth.deadline(ctx)
+	defer stopDeadline()
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+
+	fail := func(err error) (*cursor, error) {
+		c.close()
+		if ctx.Err() != nil {
+			return nil, auth.check(ctx.Err())
+		}
+		return nil, err
+	}
+	if err := auth.admit(ctx, databaseName, databasePath); err != nil {
+		return fail(err)
+	}
+	if _, err := db.ExecContext(cctx, `PRAGMA query_only = ON`); err != nil {
+		return fail(err)
+	}
+	query, err = auth.guard(query)
+	if err != nil {
+		return fail(err)
+	}
+	c.rows, err = db.QueryContext(cctx, query)
+	if err != nil {
+		return fail(auth.check(err))
+	}
+	c.columns, err = resultColumns(c.rows)
+	if err != nil {
+		return fail(err)
+	}
+	if err := c.advance(); err != nil {
+		return fail(auth.check(err))
+	}
+	return c, nil
+}
+
+// advance reads the row after the current page into next.
+func (c *cursor) advance() error {
+	c.next = nil
+	if !c.rows.Next() {
+		return c.rows.Err()
+	}
+	row, err := scanRow(c.rows, len(c.columns))
+	if err != nil {
+		return err
+	}
+	if err := c.auth.count(row); err != nil {
+		return err
+	}
+	c.next = row
+	return nil
+}
+
+// fetch reads the next page. The statement runs beyond the request that
+// opened it, so a request ending before its page is read interrupts the
+// statement and the cursor with it. Each request gets the whole execution
+// time limit, while rows and steps count over all the pages.
+func (c *cursor) fetch(ctx context.Context, pageSize int) (Page, error) {
+	ctx, cancel := c.auth.deadline(ctx)
+	defer cancel()
+	stop := context.AfterFunc(ctx, c.cancel)
+	defer stop()
+
+	page := Page{Columns: c.columns, Rows: make([][]any, 0, pageSize)}
+	for c.next != nil && len(page.Rows) < pageSize {
+		page.Rows = append(page.Rows, c.next)
+		if err := c.advance(); err != nil {
+			if ctx.Err() != nil {
+				return Page{}, c.auth.check(ctx.Err())
+			}
+			return Page{}, c.auth.check(err)
+		}
+	}
+	return page, nil
+}
+
+func (c *cursor) close() {
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	if c.rows != nil {
+		c.rows.Close()
+		c.rows = nil
+	}
+	c.cancel()
+	c.db.Close()
+	if c.auth.release != nil {
+		c.auth.release()
+	}
+}
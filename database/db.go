@@ -2,180 +2,604 @@ package database
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
 	"errors"
 	"os"
 	"sync"
+	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/charmbracelet/log"
+	"github.com/mattn/go-sqlite3"
+	"github.com/trianglehasfoursides/bedroompop/metering"
 )
 
 var sqlite = ".sqlite"
 
-func Create(databaseName string, migration string) error {
+var (
+	ErrNotFound = errors.New("database not found")
+	ErrExists   = errors.New("database already exist")
+	ErrDenied   = errors.New("statement denied by policy")
+)
+
+// connector opens SQLite connections with the authorizer of the caller installed.
+// When image is set the connection is an in-memory copy of a decrypted database.
+type connector struct {
+	dsn      string
+	auth     *authorizer
+	image    []byte
+	capture  *capture
+	attached []attachment
+}
+
+func (c *connector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.auth.session(conn.(*sqlite3.SQLiteConn)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := c.auth.attachAll(conn.(*sqlite3.SQLiteConn), c.attached); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (c *connector) connect() (driver.Conn, error) {
+	drv := &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			if c.image == nil {
+				if err := walOptions(conn); err != nil {
+					return err
+				}
+			}
+			if c.capture != nil {
+				if err := c.capture.install(conn); err != nil {
+					return err
+				}
+			}
+			conn.RegisterAuthorizer(c.auth.authorize)
+			return nil
+		},
+	}
+	if c.image == nil {
+		return drv.Open(c.dsn)
+	}
+
+	conn, err := drv.Open(":memory:")
+	if err != nil {
+		return nil, err
+	}
+	if len(c.image) == 0 {
+		return conn, nil
+	}
+
+	// A deserialized database can't grow, so it is only used as the source
+	// of a backup into the connection handed out.
+	src, err := (&sqlite3.SQLiteDriver{}).Open(":memory:")
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	defer src.Close()
+
+	if err := src.(*sqlite3.SQLiteConn).Deserialize(c.image, "main"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := copyDatabase(conn.(*sqlite3.SQLiteConn), src.(*sqlite3.SQLiteConn)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return &sqlite3.SQLiteDriver{}
+}
+
+// copyDatabase copies the main database of src over the one of dest with the backup API.
+func copyDatabase(dest *sqlite3.SQLiteConn, src *sqlite3.SQLiteConn) error {
+	backup, err := dest.Backup("main", src, "main")
+	if err != nil {
+		return err
+	}
+	if _, err := backup.Step(-1); err != nil {
+		backup.Close()
+		return err
+	}
+	return backup.Finish()
+}
+
+// handle is an open database. Encrypted databases are sealed back to disk by persist.
+type handle struct {
+	*sql.DB
+	auth       *authorizer
+	name       string
+	path       string
+	checkpoint bool // the WAL grew large enough to be checkpointed once closed
+	dataKey    []byte
+	digest     [sha256.Size]byte
+	capture    *capture
+	unlock     func()
+}
+
+// open opens the database file guarded by the policy of the principal in ctx.
+func open(ctx context.Context, databaseName string) (*handle, error) {
+	databasePath, err := filePath(databaseName)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := isSealed(databasePath)
+	if err != nil {
+		return nil, err
+	}
+	attached, err := attachments(ctx, databaseName)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &handle{
+		auth: &authorizer{
+			policy:    policyFor(databaseName, PrincipalFrom(ctx).Role),
+			principal: PrincipalFrom(ctx),
+		},
+		name:   databaseName,
+		path:   databasePath,
+		unlock: lock(databaseName, sealed),
+	}
+	if changelogRetention > 0 {
+		h.capture = &capture{feed: feedOf(databaseName), sealed: sealed}
+		h.auth.capturing = true
+	}
+	conn := &connector{dsn: databasePath + "?_journal_mode=WAL", auth: h.auth, capture: h.capture, attached: attached}
+	if sealed {
+		conn.image, h.dataKey, err = readSealed(databasePath)
+		if err != nil {
+			h.unlock()
+			return nil, err
+		}
+		if conn.image == nil {
+			conn.image = []byte{}
+		}
+		h.digest = sha256.Sum256(conn.image)
+	}
+
+	h.DB = sql.OpenDB(conn)
+	h.SetMaxOpenConns(1)
+	return h, nil
+}
+
+// persist makes what the handle committed durable and then logs its changes.
+func (h *handle) persist(ctx context.Context) error {
+	if err := h.save(ctx); err != nil {
+		return err
+	}
+	h.logChanges(ctx, true)
+	return nil
+}
+
+// save seals an encrypted database back to disk if its image changed, or
+// archives the WAL of a plain one. A plain database already committed, so
+// failing to archive is only logged, leaving a gap in recovery.
+func (h *handle) save(ctx context.Context) error {
+	if h.dataKey == nil {
+		checkpoint, err := archiveWAL(h.name, h.path)
+		if err != nil {
+			log.Error("can't archive WAL", "database", h.name, "err", err)
+		}
+		h.checkpoint = h.checkpoint || checkpoint
+		return nil
+	}
+
+	conn, err := h.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var image []byte
+	if err := conn.Raw(func(dc any) (err error) {
+		image, err = dc.(*sqlite3.SQLiteConn).Serialize("main")
+		return
+	}); err != nil {
+		return err
+	}
+	if sha256.Sum256(image) == h.digest {
+		return nil
+	}
+	return writeSealed(h.path, image, h.dataKey)
+}
+
+// changes returns how many rows the connection of q wrote, triggers included.
+func (h *handle) changes(ctx context.Context, q interface {
+	QueryRowContext(context.Context, string, ...any) *sql.Row
+}) int64 {
+	h.auth.setup = true
+	defer func() { h.auth.setup = false }()
+
+	var n int64
+	q.QueryRowContext(ctx, `SELECT total_changes()`).Scan(&n)
+	return n
+}
+
+func (h *handle) Close() error {
+	// commits left unpersisted only made it to disk on a plain database
+	h.logChanges(context.Background(), h.dataKey == nil)
+	err := h.DB.Close()
+	if h.auth.release != nil {
+		h.auth.release()
+	}
+	h.unlock()
+	if h.checkpoint {
+		go checkpointWAL(h.name, h.path)
+	}
+	return err
+}
+
+// readPlain takes a consistent image of a plain database through SQLite.
+func readPlain(path string) ([]byte, error) {
+	if info, err := os.Stat(path); err != nil || info.Size() == 0 {
+		return nil, err
+	}
+
+	conn, err := openPlain(path)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	image, err := conn.Serialize("main")
+	if err != nil {
+		return nil, err
+	}
+	return rollbackImage(image), nil
+}
+
+// rollbackImage marks a database image as using a rollback journal, as an
+// image in WAL mode can't be deserialized.
+func rollbackImage(image []byte) []byte {
+	if len(image) >= 100 && image[18] == 2 {
+		image[18], image[19] = 1, 1
+	}
+	return image
+}
+
+func Create(ctx context.Context, databaseName string, migration string) error {
 	mtx := new(sync.Mutex)
 	mtx.Lock()
 	defer mtx.Unlock()
 
-	// Check if the database file already exists
-	if _, err := os.Stat(databaseName + sqlite); err == nil {
-		return errors.New("database already exist")
+	databasePath, err := filePath(databaseName)
+	if err != nil {
+		return err
 	}
 
-	// Create SQLite database
-	if _, err := os.Create(databaseName + sqlite); err != nil {
+	// Create SQLite database, failing if the file (or a link in its place) already exists
+	file, err := os.OpenFile(databasePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return ErrExists
+	}
+	if err != nil {
 		return err
 	}
+	file.Close()
 
-	if migration != "" {
-
-		db, err := sql.Open("sqlite3", databaseName+sqlite)
+	// Encrypt it under a data key of its own when a master key is loaded
+	if Encryption() {
+		dataKey, err := newDataKey()
 		if err != nil {
 			return err
 		}
-		if _, err := db.ExecContext(context.Background(), migration); err != nil {
-			return errors.New("can't run migration due to error : " + err.Error())
+		if err := writeSealed(databasePath, nil, dataKey); err != nil {
+			return err
 		}
 	}
 
+	if migration != "" {
+		return migrate(ctx, databaseName, migration)
+	}
+
 	return nil //  created successfully
 }
 
-// Delete deletes a database file (SQLite, BoltDB, or DuckDB) along with its configuration.
-func Drop(databaseName string) error {
-	// Construct the full path for the database file
-	databasePath := databaseName + sqlite
+// migrate runs the migration of a new database.
+func migrate(ctx context.Context, databaseName string, migration string) error {
+	db, err := open(ctx, databaseName)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
 
-	// Attempt to remove the database file
-	_ = os.Remove(databasePath)
-	return nil
+	ctx, cancel := db.auth.deadline(ctx)
+	defer cancel()
+	usage := metering.Usage{Queries: 1}
+	defer func() {
+		db.auth.meter(databaseName, db.path, usage)
+	}()
+
+	if _, err := db.ExecContext(ctx, migration); err != nil {
+		if err = db.auth.check(err); errors.Is(err, ErrDenied) {
+			return err
+		}
+		return errors.New("can't run migration due to error : " + err.Error())
+	}
+	usage.RowsWritten = db.changes(ctx, db)
+	return db.persist(ctx)
 }
 
 // Get retrieves the configuration for a specific database.
 func Get(databaseName string) error {
 	// Construct the full path for the database file
-	databasePath := databaseName + sqlite
+	databasePath, err := filePath(databaseName)
+	if err != nil {
+		return err
+	}
 
 	// Check if the database file exists
 	if _, err := os.Stat(databasePath); err != nil {
-		return errors.New("database not found")
+		return ErrNotFound
 	}
 
 	return nil
 }
 
-// Query executes a SQL query on the specified SQLite database.
-// It supports both SELECT queries (returns rows as JSON) and non-SELECT queries (logs affected rows).
-func Query(databaseName string, query string) ([]byte, error) {
-	if err := Get(databaseName); err != nil {
+// DefaultBatchSize is how many rows QueryStream hands over at once unless told otherwise.
+const DefaultBatchSize = 500
+
+// Column describes a result column. Type is the declared type of the column
+// it was read from, empty for expressions.
+type Column struct {
+	Name string
+	Type string
+}
+
+// Query executes a read-only SQL query on the specified SQLite database and
+// returns its rows as JSON.
+func Query(ctx context.Context, databaseName string, query string) ([]byte, error) {
+	columns, rows, err := QueryRows(ctx, databaseName, query)
+	if err != nil {
 		return nil, err
 	}
-	ctx := context.Background()
+
+	// Prepare a slice to hold the result rows
+	var resultRows []map[string]any
+	for _, row := range rows {
+		// Map the column names to their corresponding values
+		rowMap := make(map[string]any)
+		for i, col := range columns {
+			// Convert []byte to string for readability
+			if b, ok := row[i].([]byte); ok {
+				rowMap[col.Name] = string(b)
+			} else {
+				rowMap[col.Name] = row[i]
+			}
+		}
+		resultRows = append(resultRows, rowMap)
+	}
+
+	// Convert the result rows to JSON
+	jsonData, _ := json.Marshal(resultRows)
+	return jsonData, nil // Return the JSON result
+}
+
+// QueryRows executes a SQL query and returns its columns and typed rows.
+func QueryRows(ctx context.Context, databaseName string, query string) ([]Column, [][]any, error) {
+	var columns []Column
+	var result [][]any
+	err := QueryStream(ctx, databaseName, query, DefaultBatchSize, func(c []Column, rows [][]any) error {
+		columns = c
+		result = append(result, rows...)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return columns, result, nil
+}
+
+// QueryStream executes a SQL query and hands its rows to send in batches of
+// at most batchSize rows, so the result never has to be held in memory at
+// once. Values are int64, float64, string, []byte or nil, in column order.
+// send is called at least once, so the columns of an empty result are known
+// too. Iteration stops as soon as send fails or ctx is done. Queries can't
+// write, writes go through Exec, where they are audited.
+func QueryStream(ctx context.Context, databaseName string, query string, batchSize int, send func(columns []Column, rows [][]any) error) error {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	if err := Get(databaseName); err != nil {
+		return err
+	}
 	// Open the SQLite database file
-	db, err := sql.Open("sqlite3", databaseName+sqlite)
+	db, err := open(ctx, databaseName)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer db.Close()
 
+	ctx, cancel := db.auth.deadline(ctx)
+	defer cancel()
+
+	if err := db.auth.admit(ctx, databaseName, db.path); err != nil {
+		return err
+	}
+	usage := metering.Usage{Queries: 1}
+	defer func() {
+		usage.RowsRead = db.auth.rows
+		db.auth.meter(databaseName, db.path, usage)
+	}()
+
+	db.auth.setup = true
+	_, err = db.ExecContext(ctx, `PRAGMA query_only = ON`)
+	db.auth.setup = false
+	if err != nil {
+		return err
+	}
+
 	// Begin a transaction
-	txn, err := db.Begin()
+	txn, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer txn.Rollback()
 
+	query, err = db.auth.guard(query)
+	if err != nil {
+		return err
+	}
+
 	// Execute the query
 	rows, err := txn.QueryContext(ctx, query)
 	if err != nil {
-		return nil, err
+		return db.auth.check(err)
 	}
+	defer rows.Close()
 
-	// Retrieve column names from the result set
-	columns, err := rows.Columns()
+	// Retrieve the columns of the result set
+	columns, err := resultColumns(rows)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	// Prepare a slice to hold the result rows
-	var resultRows []map[string]any
+	batch := make([][]any, 0, batchSize)
+	sent := false
 
 	// Iterate over the rows and process each one
 	for rows.Next() {
-		// Prepare slices to hold column values
-		values := make([]any, len(columns))
-		valuePtrs := make([]any, len(columns))
-
-		// Assign pointers to the values slice
-		for i := range values {
-			valuePtrs[i] = &values[i]
+		values, err := scanRow(rows, len(columns))
+		if err != nil {
+			return err
 		}
-
-		// Scan the current row into the value pointers
-		if err := rows.Scan(valuePtrs...); err != nil {
-			return nil, err
+		if err := db.auth.count(values); err != nil {
+			return err
 		}
 
-		// Map the column names to their corresponding values
-		rowMap := make(map[string]any)
-		for i, col := range columns {
-			var v any
-			val := values[i]
-
-			// Convert []byte to string for readability
-			if b, ok := val.([]byte); ok {
-				v = string(b)
-			} else {
-				v = val
+		batch = append(batch, values)
+		if len(batch) == batchSize {
+			if err := send(columns, batch); err != nil {
+				return err
 			}
-
-			rowMap[col] = v
+			sent = true
+			batch = make([][]any, 0, batchSize)
 		}
+	}
+	if err := rows.Err(); err != nil {
+		return db.auth.check(err)
+	}
+	if len(batch) > 0 || !sent {
+		if err := send(columns, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-		// Append the row to the result set
-		resultRows = append(resultRows, rowMap)
+// scanRow reads the current row of rows as typed values.
+func scanRow(rows *sql.Rows, n int) ([]any, error) {
+	// Prepare slices to hold column values
+	values := make([]any, n)
+	valuePtrs := make([]any, n)
+
+	// Assign pointers to the values slice
+	for i := range values {
+		valuePtrs[i] = &values[i]
 	}
 
-	// Commit the transaction
-	if err := txn.Commit(); err != nil {
+	// Scan the current row into the value pointers
+	if err := rows.Scan(valuePtrs...); err != nil {
 		return nil, err
 	}
+	for i := range values {
+		values[i] = storageValue(values[i])
+	}
+	return values, nil
+}
 
-	// Convert the result rows to JSON
-	jsonData, _ := json.Marshal(resultRows)
-	return jsonData, nil // Return the JSON result
+// resultColumns describes the columns of rows.
+func resultColumns(rows *sql.Rows) ([]Column, error) {
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	columns := make([]Column, len(types))
+	for i, t := range types {
+		columns[i] = Column{Name: t.Name(), Type: t.DatabaseTypeName()}
+	}
+	return columns, nil
+}
+
+// storageValue maps what the driver scanned back onto the SQLite storage
+// classes. The driver turns columns declared as dates and booleans into
+// time.Time and bool, which are stored as text and integers.
+func storageValue(v any) any {
+	switch v := v.(type) {
+	case time.Time:
+		return v.Format(sqlite3.SQLiteTimestampFormats[0])
+	case bool:
+		if v {
+			return int64(1)
+		}
+		return int64(0)
+	}
+	return v
 }
 
 // Exec executes a non-SELECT SQL query (e.g., INSERT, UPDATE, DELETE) on the specified SQLite database.
 // It returns the number of rows affected.
-func Exec(databaseName string, query string) (int64, error) {
+func Exec(ctx context.Context, databaseName string, query string) (int64, error) {
 	if err := Get(databaseName); err != nil {
 		return 0, err
 	}
-	ctx := context.Background()
 	// Open the SQLite database file
-	db, err := sql.Open("sqlite3", databaseName+sqlite)
+	db, err := open(ctx, databaseName)
 	if err != nil {
 		return 0, err
 	}
 	defer db.Close()
 
+	ctx, cancel := db.auth.deadline(ctx)
+	defer cancel()
+
+	if err := db.auth.admit(ctx, databaseName, db.path); err != nil {
+		return 0, err
+	}
+	usage := metering.Usage{Queries: 1}
+	defer func() {
+		db.auth.meter(databaseName, db.path, usage)
+	}()
+
 	// Begin a transaction
-	txn, err := db.Begin()
+	txn, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return 0, err
 	}
 	defer txn.Rollback()
 
+	query, err = db.auth.guard(query)
+	if err != nil {
+		return 0, err
+	}
+
 	// Execute the query
 	result, err := txn.ExecContext(ctx, query)
 	if err != nil {
-		return 0, err
+		return 0, db.auth.check(err)
 	}
+	written := db.changes(ctx, txn)
 
 	// Commit the transaction
 	if err := txn.Commit(); err != nil {
+		return 0, db.auth.check(err)
+	}
+	usage.RowsWritten = written
+	if err := db.persist(ctx); err != nil {
 		return 0, err
 	}
 
@@ -0,0 +1,387 @@
+package database
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/trianglehasfoursides/bedroompop/metering"
+)
+
+// Formats databases are exported and imported in. A SQL dump recreates the
+// schema and rows, CSV is a tar holding a <table>.csv per table, and NDJSON is
+// a line per row naming its table. CSV and NDJSON only carry rows: NULL is an
+// empty CSV field and blobs are base64, decoded again on import into columns
+// declared as BLOB.
+const (
+	FormatSQL    = "sql"
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+	FormatSQLite = "sqlite" // import only, a database file
+)
+
+var ErrInvalidFormat = errors.New("invalid format")
+
+// schemaObject is an entry of sqlite_master.
+type schemaObject struct {
+	kind    string
+	name    string
+	sql     string
+	virtual bool
+}
+
+// Export writes the database in format to w, as seen by the principal in
+// ctx, from a single read transaction.
+func Export(ctx context.Context, databaseName string, format string, w io.Writer) error {
+	if format != FormatSQL && format != FormatCSV && format != FormatNDJSON {
+		return fmt.Errorf("%w: can't export as %q", ErrInvalidFormat, format)
+	}
+	if err := Get(databaseName); err != nil {
+		return err
+	}
+	db, err := open(ctx, databaseName)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx, cancel := db.auth.deadline(ctx)
+	defer cancel()
+
+	if err := db.auth.admit(ctx, databaseName, db.path); err != nil {
+		return err
+	}
+	usage := metering.Usage{Queries: 1}
+	defer func() {
+		usage.RowsRead = db.auth.rows
+		db.auth.meter(databaseName, db.path, usage)
+	}()
+
+	txn, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	objects, err := schema(ctx, txn)
+	if err != nil {
+		return db.auth.check(err)
+	}
+
+	e := &exporter{ctx: ctx, db: db, txn: txn}
+	switch format {
+	case FormatSQL:
+		err = e.dump(objects, w)
+	case FormatCSV:
+		err = e.csv(objects, w)
+	case FormatNDJSON:
+		err = e.ndjson(objects, w)
+	}
+	if err != nil {
+		return db.auth.check(err)
+	}
+	return nil
+}
+
+// schema lists the tables, indexes, views and triggers of the database in
+// the order they were created, tables first.
+func schema(ctx context.Context, q interface {
+	QueryContext(context.Context, string, ...any) (*sql.Rows, error)
+}) ([]schemaObject, error) {
+	rows, err := q.QueryContext(ctx, `SELECT type, name, sql FROM sqlite_master
+		WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%' AND `+notInternal("tbl_name")+`
+		ORDER BY type = 'table' DESC, rowid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var objects []schemaObject
+	for rows.Next() {
+		var o schemaObject
+		if err := rows.Scan(&o.kind, &o.name, &o.sql); err != nil {
+			return nil, err
+		}
+		o.virtual = strings.HasPrefix(strings.ToUpper(o.sql), "CREATE VIRTUAL TABLE")
+		objects = append(objects, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// the shadow tables of virtual tables are filled through the virtual table
+	var kept []schemaObject
+	for _, o := range objects {
+		shadow := false
+		for _, v := range objects {
+			if v.virtual && o.kind == "table" && strings.HasPrefix(o.name, v.name+"_") {
+				shadow = true
+			}
+		}
+		if !shadow {
+			kept = append(kept, o)
+		}
+	}
+	return kept, nil
+}
+
+type exporter struct {
+	ctx context.Context
+	db  *handle
+	txn *sql.Tx
+}
+
+// rows calls fn with the columns and every row of a table.
+func (e *exporter) rows(table string, fn func(columns []Column, row []any) error) error {
+	query, err := e.db.auth.guard(`SELECT * FROM ` + quoteIdent(table))
+	if err != nil {
+		return err
+	}
+	rows, err := e.txn.QueryContext(e.ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := resultColumns(rows)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		values, err := scanRow(rows, len(columns))
+		if err != nil {
+			return err
+		}
+		if err := e.db.auth.count(values); err != nil {
+			return err
+		}
+		if err := fn(columns, values); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// dump writes the statements recreating the database.
+func (e *exporter) dump(objects []schemaObject, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("BEGIN TRANSACTION;\n")
+	for _, o := range objects {
+		bw.WriteString(o.sql + ";\n")
+		if o.kind != "table" {
+			continue
+		}
+		prefix := "INSERT INTO " + quoteIdent(o.name) + " VALUES("
+		err := e.rows(o.name, func(columns []Column, row []any) error {
+			bw.WriteString(prefix)
+			for i, v := range row {
+				if i > 0 {
+					bw.WriteByte(',')
+				}
+				bw.WriteString(sqlLiteral(v))
+			}
+			_, err := bw.WriteString(");\n")
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	bw.WriteString("COMMIT;\n")
+	return bw.Flush()
+}
+
+// csv writes a tar holding a CSV file per table.
+func (e *exporter) csv(objects []schemaObject, w io.Writer) error {
+	tw := tar.NewWriter(w)
+	for _, o := range objects {
+		if o.kind != "table" {
+			continue
+		}
+		if err := e.csvTable(o.name, tw); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// csvTable adds the CSV file of a table to a tar. It is spooled to a
+// temporary file first, as tar needs its size up front.
+func (e *exporter) csvTable(table string, tw *tar.Writer) error {
+	spool, err := os.CreateTemp("", "export-*.csv")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	cw := csv.NewWriter(spool)
+	header := true
+	err = e.rows(table, func(columns []Column, row []any) error {
+		if header {
+			header = false
+			if err := cw.Write(columnNames(columns)); err != nil {
+				return err
+			}
+		}
+		record := make([]string, len(row))
+		for i, v := range row {
+			record[i] = textValue(v)
+		}
+		return cw.Write(record)
+	})
+	if err != nil {
+		return err
+	}
+	if header {
+		// an empty table still tells its columns
+		columns, err := columnTypes(e.ctx, e.txn, table)
+		if err != nil {
+			return err
+		}
+		cw.Write(columnNames(columns))
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+
+	size, err := spool.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    table + ".csv",
+		Mode:    0o644,
+		Size:    size,
+		ModTime: time.Now(),
+	}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, spool)
+	return err
+}
+
+// ndjson writes a line per row, holding its table and its columns in order.
+func (e *exporter) ndjson(objects []schemaObject, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, o := range objects {
+		if o.kind != "table" {
+			continue
+		}
+		table, err := json.Marshal(o.name)
+		if err != nil {
+			return err
+		}
+		err = e.rows(o.name, func(columns []Column, row []any) error {
+			bw.WriteString(`{"table":`)
+			bw.Write(table)
+			bw.WriteString(`,"row":{`)
+			for i, c := range columns {
+				if i > 0 {
+					bw.WriteByte(',')
+				}
+				name, _ := json.Marshal(c.Name)
+				value, err := json.Marshal(row[i])
+				if err != nil {
+					return err
+				}
+				bw.Write(name)
+				bw.WriteByte(':')
+				bw.Write(value)
+			}
+			_, err := bw.WriteString("}}\n")
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// columnTypes returns the columns of a table with their declared types.
+func columnTypes(ctx context.Context, q interface {
+	QueryContext(context.Context, string, ...any) (*sql.Rows, error)
+}, table string) ([]Column, error) {
+	rows, err := q.QueryContext(ctx, `SELECT name, type FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []Column
+	for rows.Next() {
+		var c Column
+		if err := rows.Scan(&c.Name, &c.Type); err != nil {
+			return nil, err
+		}
+		columns = append(columns, c)
+	}
+	return columns, rows.Err()
+}
+
+func columnNames(columns []Column) []string {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// sqlLiteral writes a value as a SQL literal of its storage class.
+func sqlLiteral(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		if math.IsInf(v, 1) {
+			return "1e999"
+		} else if math.IsInf(v, -1) {
+			return "-1e999"
+		}
+		s := strconv.FormatFloat(v, 'g', 17, 64)
+		if !strings.ContainsAny(s, ".eEIN") {
+			s += ".0"
+		}
+		return s
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	case []byte:
+		return "X'" + hex.EncodeToString(v) + "'"
+	}
+	return "NULL"
+}
+
+// textValue formats a value for CSV.
+func textValue(v any) string {
+	switch v := v.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		return v
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	}
+	return ""
+}
@@ -0,0 +1,28 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"path"
+)
+
+var ErrInvalidPattern = errors.New("invalid pattern")
+
+// Match lists the databases on this node whose names match pattern, in
+// which * matches any characters and ? any single one.
+func Match(pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidPattern, pattern)
+	}
+	names, err := List()
+	if err != nil {
+		return nil, err
+	}
+	var matched []string
+	for _, name := range names {
+		if ok, _ := path.Match(pattern, name); ok {
+			matched = append(matched, name)
+		}
+	}
+	return matched, nil
+}
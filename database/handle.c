@@ -0,0 +1,34 @@
+#include <stdint.h>
+
+typedef struct sqlite3 sqlite3;
+typedef struct sqlite3_context sqlite3_context;
+typedef struct sqlite3_value sqlite3_value;
+typedef struct sqlite3_api_routines sqlite3_api_routines;
+typedef long long sqlite3_int64;
+
+int sqlite3_auto_extension(void (*)(void));
+int sqlite3_create_function(sqlite3*, const char*, int, int, void*,
+	void (*)(sqlite3_context*, int, sqlite3_value**),
+	void (*)(sqlite3_context*, int, sqlite3_value**),
+	void (*)(sqlite3_context*));
+sqlite3 *sqlite3_context_db_handle(sqlite3_context*);
+void sqlite3_result_int64(sqlite3_context*, sqlite3_int64);
+
+#define SQLITE_UTF8 1
+#define SQLITE_DIRECTONLY 0x000080000
+
+static void handle(sqlite3_context *ctx, int argc, sqlite3_value **argv) {
+	sqlite3_result_int64(ctx, (sqlite3_int64)(uintptr_t)sqlite3_context_db_handle(ctx));
+}
+
+static int attach(sqlite3 *db, char **err, const sqlite3_api_routines *api) {
+	return sqlite3_create_function(db, "bedroompop_handle", 0, SQLITE_UTF8 | SQLITE_DIRECTONLY, 0, handle, 0, 0);
+}
+
+void *bedroompop_handle_pointer(sqlite3_int64 handle) {
+	return (void *)(uintptr_t)handle;
+}
+
+int bedroompop_register_handle(void) {
+	return sqlite3_auto_extension((void (*)(void))attach);
+}
@@ -0,0 +1,49 @@
+package database
+
+/*
+int bedroompop_register_handle(void);
+void *bedroompop_handle_pointer(long long handle);
+*/
+import "C"
+
+import (
+	"database/sql/driver"
+	"errors"
+	"unsafe"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// go-sqlite3 doesn't expose the sqlite3* of a connection, which the hooks
+// and options it lacks are set on. Every connection gets a function
+// returning it instead, registered as an automatic extension. Statements
+// can't call it, the authorizer only lets it run while a handle is set up.
+
+// handleFunction returns the sqlite3* of the connection it runs on.
+const handleFunction = "bedroompop_handle"
+
+func init() {
+	if rc := C.bedroompop_register_handle(); rc != 0 {
+		panic(sqlite3.Error{Code: sqlite3.ErrNo(rc)})
+	}
+}
+
+// rawHandle returns the sqlite3* of conn. The authorizer of conn, if any,
+// must be in setup.
+func rawHandle(conn *sqlite3.SQLiteConn) (unsafe.Pointer, error) {
+	rows, err := conn.Query(`SELECT `+handleFunction+`()`, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := make([]driver.Value, 1)
+	if err := rows.Next(values); err != nil {
+		return nil, err
+	}
+	ptr, ok := values[0].(int64)
+	if !ok || ptr == 0 {
+		return nil, errors.New("can't get the handle of a connection")
+	}
+	return C.bedroompop_handle_pointer(C.longlong(ptr)), nil
+}
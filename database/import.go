@@ -0,0 +1,524 @@
+package database
+
+/*
+#include <stdlib.h>
+
+int sqlite3_complete(const char*);
+*/
+import "C"
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+	"unsafe"
+
+	"github.com/trianglehasfoursides/bedroompop/metering"
+)
+
+// ImportStats tells how far an import got.
+type ImportStats struct {
+	Bytes      int64 `json:"bytes"`
+	Statements int64 `json:"statements"`
+	Rows       int64 `json:"rows"`
+}
+
+// importProgressInterval is how often an import reports its progress.
+const importProgressInterval = time.Second
+
+// Import loads r, in one of the export formats or as a SQLite database file,
+// into a database in a single transaction, creating the database if needed.
+// CSV and NDJSON rows go into existing tables, or into tables created with
+// the columns they name. progress, when set, is called as the import goes.
+func Import(ctx context.Context, databaseName string, format string, r io.Reader, progress func(ImportStats)) (ImportStats, error) {
+	if format != FormatSQL && format != FormatCSV && format != FormatNDJSON && format != FormatSQLite {
+		return ImportStats{}, fmt.Errorf("%w: can't import %q", ErrInvalidFormat, format)
+	}
+
+	created := false
+	if err := Get(databaseName); errors.Is(err, ErrNotFound) {
+		if err := Create(ctx, databaseName, ""); err != nil && !errors.Is(err, ErrExists) {
+			return ImportStats{}, err
+		} else if err == nil {
+			created = true
+		}
+	} else if err != nil {
+		return ImportStats{}, err
+	}
+
+	stats, err := load(ctx, databaseName, format, r, progress)
+	if err != nil && created {
+		purge(databaseName)
+	}
+	return stats, err
+}
+
+func load(ctx context.Context, databaseName string, format string, r io.Reader, progress func(ImportStats)) (ImportStats, error) {
+	db, err := open(ctx, databaseName)
+	if err != nil {
+		return ImportStats{}, err
+	}
+	defer db.Close()
+
+	ctx, cancel := db.auth.deadline(ctx)
+	defer cancel()
+
+	if err := db.auth.admit(ctx, databaseName, db.path); err != nil {
+		return ImportStats{}, err
+	}
+	usage := metering.Usage{Queries: 1}
+	defer func() {
+		db.auth.meter(databaseName, db.path, usage)
+	}()
+
+	txn, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return ImportStats{}, err
+	}
+	defer txn.Rollback()
+
+	im := &importer{
+		ctx:      ctx,
+		db:       db,
+		txn:      txn,
+		progress: progress,
+		reported: time.Now(),
+	}
+	im.src = &countingReader{r: r, n: &im.stats.Bytes}
+	switch format {
+	case FormatSQL:
+		err = im.sql()
+	case FormatCSV:
+		err = im.csv()
+	case FormatNDJSON:
+		err = im.ndjson()
+	case FormatSQLite:
+		err = im.sqlite()
+	}
+	if err != nil {
+		return im.stats, db.auth.check(err)
+	}
+	written := db.changes(ctx, txn)
+
+	if err := txn.Commit(); err != nil {
+		return im.stats, db.auth.check(err)
+	}
+	usage.RowsWritten = written
+	if err := db.persist(ctx); err != nil {
+		return im.stats, err
+	}
+	return im.stats, nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	*c.n += int64(n)
+	return n, err
+}
+
+type importer struct {
+	ctx      context.Context
+	db       *handle
+	txn      *sql.Tx
+	src      io.Reader
+	stats    ImportStats
+	progress func(ImportStats)
+	reported time.Time
+	inserts  map[string]*insert
+}
+
+// insert is a prepared insert into some columns of a table.
+type insert struct {
+	stmt  *sql.Stmt
+	blobs []bool // whether a column is declared as BLOB
+}
+
+// exec runs a statement of the import.
+func (im *importer) exec(query string, args ...any) error {
+	query, err := im.db.auth.guard(query)
+	if err != nil {
+		return err
+	}
+	result, err := im.txn.ExecContext(im.ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	var n int64
+	if keyword := strings.ToUpper(strings.Fields(query)[0]); keyword == "INSERT" || keyword == "REPLACE" ||
+		keyword == "UPDATE" || keyword == "DELETE" {
+		// SQLite keeps the count of the last statement that changed rows
+		n, _ = result.RowsAffected()
+	}
+	im.done(1, n)
+	return nil
+}
+
+// done counts statements and rows, reporting progress now and then.
+func (im *importer) done(statements int64, rows int64) {
+	im.stats.Statements += statements
+	im.stats.Rows += rows
+	if im.progress != nil && time.Since(im.reported) >= importProgressInterval {
+		im.reported = time.Now()
+		im.progress(im.stats)
+	}
+}
+
+// sql runs the statements of a dump. The transaction statements of the dump
+// itself are skipped, as the whole import is one transaction.
+func (im *importer) sql() error {
+	br := bufio.NewReader(im.src)
+	var statement strings.Builder
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		statement.WriteString(line)
+
+		if s := strings.TrimSpace(statement.String()); s != "" && (strings.HasSuffix(s, ";") || errors.Is(err, io.EOF)) && complete(s) {
+			statement.Reset()
+			if !transactionControl(s) {
+				if err := im.exec(s); err != nil {
+					return err
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+	}
+	if s := strings.TrimSpace(statement.String()); s != "" {
+		return fmt.Errorf("%w: dump ends in an incomplete statement", ErrInvalidFormat)
+	}
+	return nil
+}
+
+// complete reports whether s ends a whole SQL statement.
+func complete(s string) bool {
+	cs := C.CString(s)
+	defer C.free(unsafe.Pointer(cs))
+	return C.sqlite3_complete(cs) != 0
+}
+
+// transactionControl reports whether a statement begins or ends a
+// transaction, or turns off foreign keys, as dumps do.
+func transactionControl(s string) bool {
+	fields := strings.Fields(strings.ToUpper(strings.TrimSuffix(s, ";")))
+	if len(fields) == 0 {
+		return false
+	}
+	switch fields[0] {
+	case "BEGIN", "COMMIT", "END", "ROLLBACK", "SAVEPOINT", "RELEASE":
+		return true
+	case "PRAGMA":
+		return strings.HasPrefix(strings.Join(fields[1:], ""), "FOREIGN_KEYS")
+	}
+	return false
+}
+
+// csv loads a tar holding a CSV file per table, named after it.
+func (im *importer) csv() error {
+	tr := tar.NewReader(im.src)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidFormat, err.Error())
+		}
+		if header.Typeflag != tar.TypeReg || !strings.HasSuffix(header.Name, ".csv") {
+			continue
+		}
+		table := strings.TrimSuffix(path.Base(header.Name), ".csv")
+
+		cr := csv.NewReader(tr)
+		columns, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %s: %s", ErrInvalidFormat, header.Name, err.Error())
+		}
+		ins, err := im.insert(table, columns)
+		if err != nil {
+			return err
+		}
+
+		args := make([]any, len(columns))
+		for {
+			record, err := cr.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("%w: %s: %s", ErrInvalidFormat, header.Name, err.Error())
+			}
+			for i, field := range record {
+				args[i] = nil
+				if field != "" {
+					args[i] = importValue(field, ins.blobs[i])
+				}
+			}
+			if err := im.run(ins, args); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ndjson loads a line per row, holding its table and its columns.
+func (im *importer) ndjson() error {
+	dec := json.NewDecoder(im.src)
+	dec.UseNumber()
+	for {
+		var line struct {
+			Table string     `json:"table"`
+			Row   orderedRow `json:"row"`
+		}
+		if err := dec.Decode(&line); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidFormat, err.Error())
+		}
+		if line.Table == "" || len(line.Row.columns) == 0 {
+			return fmt.Errorf("%w: every line needs a table and a row", ErrInvalidFormat)
+		}
+
+		ins, err := im.insert(line.Table, line.Row.columns)
+		if err != nil {
+			return err
+		}
+		args := make([]any, len(line.Row.values))
+		for i, v := range line.Row.values {
+			args[i] = importValue(v, ins.blobs[i])
+		}
+		if err := im.run(ins, args); err != nil {
+			return err
+		}
+	}
+}
+
+// orderedRow is a JSON object keeping the order of its columns, so tables
+// created on import get them in the order they were exported in.
+type orderedRow struct {
+	columns []string
+	values  []any
+}
+
+func (r *orderedRow) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if token, err := dec.Token(); err != nil || token != json.Delim('{') {
+		return errors.New("row must be an object")
+	}
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		var value any
+		if err := dec.Decode(&value); err != nil {
+			return err
+		}
+		r.columns = append(r.columns, token.(string))
+		r.values = append(r.values, value)
+	}
+	_, err := dec.Token()
+	return err
+}
+
+// sqlite copies the schema and rows of an uploaded database file. It is
+// spooled to a temporary file first, which SQLite checks before it is read.
+func (im *importer) sqlite() error {
+	spool, err := os.CreateTemp("", "import-*.sqlite")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(spool.Name())
+	_, err = io.Copy(spool, im.src)
+	if closeErr := spool.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(spool.Name())
+	if err != nil {
+		return err
+	}
+	header := make([]byte, 16)
+	_, err = io.ReadFull(file, header)
+	file.Close()
+	if err != nil || !bytes.Equal(header, sqliteMagic) {
+		return fmt.Errorf("%w: not a SQLite database file", ErrInvalidFormat)
+	}
+
+	src, err := sql.Open("sqlite3", "file:"+spool.Name()+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	var check string
+	if err := src.QueryRowContext(im.ctx, `PRAGMA quick_check`).Scan(&check); err != nil || check != "ok" {
+		return fmt.Errorf("%w: the database file is corrupt", ErrInvalidFormat)
+	}
+
+	objects, err := schema(im.ctx, src)
+	if err != nil {
+		return err
+	}
+	// indexes, views and triggers go in once the rows are
+	for _, o := range objects {
+		if o.kind == "table" {
+			if err := im.exec(o.sql); err != nil {
+				return err
+			}
+			if err := im.copyTable(src, o.name); err != nil {
+				return err
+			}
+		}
+	}
+	for _, o := range objects {
+		if o.kind != "table" {
+			if err := im.exec(o.sql); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// copyTable inserts the rows of a table of src into the same table.
+func (im *importer) copyTable(src *sql.DB, table string) error {
+	rows, err := src.QueryContext(im.ctx, `SELECT * FROM `+quoteIdent(table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	ins, err := im.insert(table, columns)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		values, err := scanRow(rows, len(columns))
+		if err != nil {
+			return err
+		}
+		if err := im.run(ins, values); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// insert prepares an insert into columns of a table, creating the table with
+// untyped columns if it doesn't exist.
+func (im *importer) insert(table string, columns []string) (*insert, error) {
+	key := table + "\x00" + strings.Join(columns, "\x00")
+	if ins, ok := im.inserts[key]; ok {
+		return ins, nil
+	}
+
+	declared, err := columnTypes(im.ctx, im.txn, table)
+	if err != nil {
+		return nil, err
+	}
+	if len(declared) == 0 {
+		quoted := make([]string, len(columns))
+		for i, c := range columns {
+			quoted[i] = quoteIdent(c)
+		}
+		if err := im.exec(`CREATE TABLE ` + quoteIdent(table) + ` (` + strings.Join(quoted, ", ") + `)`); err != nil {
+			return nil, err
+		}
+	}
+	types := make(map[string]string, len(declared))
+	for _, c := range declared {
+		types[strings.ToLower(c.Name)] = c.Type
+	}
+
+	ins := &insert{blobs: make([]bool, len(columns))}
+	quoted := make([]string, len(columns))
+	params := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = quoteIdent(c)
+		params[i] = "?"
+		ins.blobs[i] = strings.Contains(strings.ToUpper(types[strings.ToLower(c)]), "BLOB")
+	}
+	query, err := im.db.auth.guard(`INSERT INTO ` + quoteIdent(table) + ` (` + strings.Join(quoted, ", ") + `) VALUES (` + strings.Join(params, ", ") + `)`)
+	if err != nil {
+		return nil, err
+	}
+	if ins.stmt, err = im.txn.PrepareContext(im.ctx, query); err != nil {
+		return nil, err
+	}
+
+	if im.inserts == nil {
+		im.inserts = make(map[string]*insert)
+	}
+	im.inserts[key] = ins
+	return ins, nil
+}
+
+// run inserts a row with a prepared insert.
+func (im *importer) run(ins *insert, args []any) error {
+	if _, err := ins.stmt.ExecContext(im.ctx, args...); err != nil {
+		return err
+	}
+	im.done(0, 1)
+	return nil
+}
+
+// importValue turns a CSV field or JSON value into what is bound for a
+// column: base64 strings become blobs in BLOB columns, JSON numbers become
+// integers when they are whole, and nested JSON stays JSON text.
+func importValue(v any, blob bool) any {
+	switch v := v.(type) {
+	case string:
+		if blob {
+			if b, err := base64.StdEncoding.DecodeString(v); err == nil {
+				return b
+			}
+		}
+		return v
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case bool:
+		if v {
+			return int64(1)
+		}
+		return int64(0)
+	case map[string]any, []any:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+	return v
+}
@@ -0,0 +1,310 @@
+package database
+
+import (
+	"archive/tar"
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// rowsOf returns every row a query reads from a database.
+func rowsOf(t *testing.T, databaseName string, query string) [][]any {
+	t.Helper()
+	_, rows, err := QueryRows(adminContext(), databaseName, query)
+	if err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return rows
+}
+
+// csvTar builds a tar holding a file per name.
+func csvTar(t *testing.T, files ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for i := 0; i < len(files); i += 2 {
+		if err := tw.WriteHeader(&tar.Header{Name: files[i], Mode: 0o644, Size: int64(len(files[i+1])), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(files[i+1]))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestImportSQLDump(t *testing.T) {
+	useDirs(t)
+
+	dump := `PRAGMA foreign_keys=OFF;
+BEGIN TRANSACTION;
+CREATE TABLE notes (id INTEGER PRIMARY KEY, body TEXT);
+INSERT INTO notes VALUES (1, 'semicolons; inside
+a string spanning lines;');
+INSERT INTO notes VALUES (2, 'plain');
+CREATE TABLE audit (note INTEGER);
+CREATE TRIGGER notes_audit AFTER INSERT ON notes BEGIN
+  INSERT INTO audit VALUES (new.id);
+END;
+INSERT INTO notes VALUES (3, 'audited');
+COMMIT;
+`
+	stats, err := Import(adminContext(), "notes", FormatSQL, strings.NewReader(dump), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Bytes != int64(len(dump)) || stats.Statements != 6 || stats.Rows != 3 {
+		t.Errorf("stats = %+v, want %d bytes, 6 statements and 3 rows", stats, len(dump))
+	}
+
+	rows := rowsOf(t, "notes", "SELECT body FROM notes WHERE id = 1")
+	if rows[0][0] != "semicolons; inside\na string spanning lines;" {
+		t.Errorf("body = %q", rows[0][0])
+	}
+	if n := count(t, "notes", "audit"); n != 1 {
+		t.Errorf("the trigger ran %d times, want once", n)
+	}
+}
+
+func TestImportSQLDumpFailsWhole(t *testing.T) {
+	useDirs(t)
+
+	if err := Create(adminContext(), "stock", "CREATE TABLE items (name TEXT UNIQUE)"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Import(adminContext(), "stock", FormatSQL, strings.NewReader(
+		"INSERT INTO items VALUES ('bolt');\nINSERT INTO items VALUES ('bolt');\n"), nil)
+	if err == nil {
+		t.Fatal("imported a dump breaking a constraint")
+	}
+	if n := count(t, "stock", "items"); n != 0 {
+		t.Errorf("%d rows of a failed import were kept", n)
+	}
+
+	// a database created by a failed import is removed again
+	_, err = Import(adminContext(), "fresh", FormatSQL, strings.NewReader("CREATE TABLE t (x);\nINSERT INTO t VALUES (1"), nil)
+	if !errors.Is(err, ErrInvalidFormat) {
+		t.Fatalf("incomplete dump = %v, want ErrInvalidFormat", err)
+	}
+	if err := Get("fresh"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("database of a failed import = %v, want ErrNotFound", err)
+	}
+}
+
+func TestImportCSV(t *testing.T) {
+	useDirs(t)
+
+	if err := Create(adminContext(), "files", "CREATE TABLE files (name TEXT, size INTEGER, data BLOB)"); err != nil {
+		t.Fatal(err)
+	}
+	archive := csvTar(t,
+		"export/files.csv", "name,size,data\nreadme,5,aGVsbG8=\nempty,,\n",
+		"export/tags.csv", "tag,file\nimportant,readme\n",
+		"export/skipped.txt", "not,a,table\n",
+		"export/nothing.csv", "",
+	)
+	stats, err := Import(adminContext(), "files", FormatCSV, bytes.NewReader(archive), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Rows != 3 {
+		t.Errorf("imported %d rows, want 3", stats.Rows)
+	}
+
+	rows := rowsOf(t, "files", "SELECT name, size, data FROM files ORDER BY name")
+	want := [][]any{{"empty", nil, nil}, {"readme", int64(5), []byte("hello")}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("files = %v, want %v", rows, want)
+	}
+	// tables missing from the database are created with the columns of the file
+	if rows := rowsOf(t, "files", "SELECT tag, file FROM tags"); !reflect.DeepEqual(rows, [][]any{{"important", "readme"}}) {
+		t.Errorf("tags = %v", rows)
+	}
+	for _, table := range []string{"skipped", "nothing"} {
+		if _, _, err := QueryRows(adminContext(), "files", "SELECT * FROM "+table); err == nil {
+			t.Errorf("table %s was created", table)
+		}
+	}
+
+	_, err = Import(adminContext(), "files", FormatCSV, strings.NewReader("not a tar archive at all, only text"), nil)
+	if !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("import of a broken tar = %v, want ErrInvalidFormat", err)
+	}
+	_, err = Import(adminContext(), "files", FormatCSV, bytes.NewReader(csvTar(t, "files.csv", "name,size\n\"unterminated,1\n")), nil)
+	if !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("import of a broken CSV = %v, want ErrInvalidFormat", err)
+	}
+	if n := count(t, "files", "files"); n != 2 {
+		t.Errorf("failed imports left %d files, want 2", n)
+	}
+}
+
+func TestImportNDJSON(t *testing.T) {
+	useDirs(t)
+
+	lines := `{"table":"events","row":{"zeta":1,"alpha":"first","big":9007199254740993,"ratio":0.5}}
+{"table":"events","row":{"zeta":2,"alpha":null,"ok":true,"meta":{"k":[1,2]}}}
+{"table":"events","row":{"zeta":3,"ok":false}}
+`
+	// lines may leave out columns of the table, which stay NULL
+	if err := Create(adminContext(), "events", "CREATE TABLE events (zeta, alpha, big, ratio, ok, meta)"); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := Import(adminContext(), "events", FormatNDJSON, strings.NewReader(lines), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Rows != 3 {
+		t.Errorf("imported %d rows, want 3", stats.Rows)
+	}
+	rows := rowsOf(t, "events", "SELECT zeta, alpha, big, ratio, ok, meta FROM events ORDER BY zeta")
+	want := [][]any{
+		{int64(1), "first", int64(9007199254740993), 0.5, nil, nil},
+		{int64(2), nil, nil, nil, int64(1), `{"k":[1,2]}`},
+		{int64(3), nil, nil, nil, int64(0), nil},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("events = %v, want %v", rows, want)
+	}
+
+	// tables created on import get their columns in the order of the first line
+	if _, err := Import(adminContext(), "ordered", FormatNDJSON, strings.NewReader(`{"table":"t","row":{"b":1,"a":2,"c":3}}`), nil); err != nil {
+		t.Fatal(err)
+	}
+	columns, _, err := QueryRows(adminContext(), "ordered", "SELECT * FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := columnNames(columns); strings.Join(names, ",") != "b,a,c" {
+		t.Errorf("created columns %v, want b,a,c", names)
+	}
+
+	for _, bad := range []string{
+		`{"row":{"a":1}}`,
+		`{"table":"t","row":{}}`,
+		`{"table":"t","row":[1,2]}`,
+		`{"table":"t","row":{"a":1}`,
+	} {
+		if _, err := Import(adminContext(), "events", FormatNDJSON, strings.NewReader(bad), nil); !errors.Is(err, ErrInvalidFormat) {
+			t.Errorf("import of %s = %v, want ErrInvalidFormat", bad, err)
+		}
+	}
+}
+
+func TestImportSQLiteFile(t *testing.T) {
+	useDirs(t)
+
+	path := filepath.Join(t.TempDir(), "upload.sqlite")
+	src, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		`CREATE TABLE authors (id INTEGER PRIMARY KEY, name TEXT NOT NULL)`,
+		`CREATE TABLE books (title TEXT, author INTEGER REFERENCES authors(id), cover BLOB)`,
+		`CREATE INDEX books_author ON books (author)`,
+		`CREATE VIEW titles AS SELECT title FROM books`,
+		`INSERT INTO authors VALUES (1, 'Le Guin'), (2, 'Lem')`,
+		`INSERT INTO books VALUES ('The Dispossessed', 1, x'00ff'), ('Solaris', 2, NULL)`,
+	} {
+		if _, err := src.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	src.Close()
+	file, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := Import(adminContext(), "library", FormatSQLite, bytes.NewReader(file), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Rows != 4 || stats.Bytes != int64(len(file)) {
+		t.Errorf("stats = %+v, want 4 rows and %d bytes", stats, len(file))
+	}
+	rows := rowsOf(t, "library", "SELECT title, name, cover FROM books JOIN authors ON authors.id = author ORDER BY title")
+	want := [][]any{{"Solaris", "Lem", nil}, {"The Dispossessed", "Le Guin", []byte{0, 0xff}}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("books = %v, want %v", rows, want)
+	}
+	objects := rowsOf(t, "library", "SELECT type, name FROM sqlite_master WHERE type IN ('index', 'view') ORDER BY name")
+	if !reflect.DeepEqual(objects, [][]any{{"index", "books_author"}, {"view", "titles"}}) {
+		t.Errorf("indexes and views = %v", objects)
+	}
+
+	if _, err := Import(adminContext(), "other", FormatSQLite, strings.NewReader("CREATE TABLE t (x);"), nil); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("import of a dump as a database file = %v, want ErrInvalidFormat", err)
+	}
+	corrupt := bytes.Clone(file)
+	for i := 100; i < len(corrupt); i++ {
+		corrupt[i] = 0xaa
+	}
+	if _, err := Import(adminContext(), "other", FormatSQLite, bytes.NewReader(corrupt), nil); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("import of a corrupt database file = %v, want ErrInvalidFormat", err)
+	}
+	if err := Get("other"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("database of a failed import = %v, want ErrNotFound", err)
+	}
+}
+
+func TestImportRejectsUnknownFormat(t *testing.T) {
+	useDirs(t)
+
+	if _, err := Import(adminContext(), "x", "xml", strings.NewReader("<rows/>"), nil); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("import as xml = %v, want ErrInvalidFormat", err)
+	}
+	if err := Get("x"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("import in an unknown format created the database: %v", err)
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	useDirs(t)
+
+	if err := Create(adminContext(), "origin", `CREATE TABLE mixed (i INTEGER, r REAL, s TEXT, b BLOB, n);
+		INSERT INTO mixed VALUES (1, 1.5, 'a,"quoted"'||char(10)||'line', x'deadbeef', NULL);
+		INSERT INTO mixed VALUES (-9223372036854775808, -0.25, '', x'', 'x');`); err != nil {
+		t.Fatal(err)
+	}
+	want := rowsOf(t, "origin", "SELECT * FROM mixed ORDER BY i")
+
+	for _, format := range []string{FormatSQL, FormatCSV, FormatNDJSON} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Export(adminContext(), "origin", format, &buf); err != nil {
+				t.Fatal(err)
+			}
+			copyName := "copy-" + format
+			if format != FormatSQL {
+				// rows only, the schema keeps the BLOB column a blob
+				if err := Create(adminContext(), copyName, "CREATE TABLE mixed (i INTEGER, r REAL, s TEXT, b BLOB, n)"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := Import(adminContext(), copyName, format, &buf, nil); err != nil {
+				t.Fatal(err)
+			}
+			got := rowsOf(t, copyName, "SELECT * FROM mixed ORDER BY i")
+			// CSV can't tell an empty string or blob from NULL
+			if format == FormatCSV {
+				want := [][]any{{want[0][0], want[0][1], nil, nil, want[0][4]}, want[1]}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("rows = %v, want %v", got, want)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("rows = %v, want %v", fmt.Sprint(got), fmt.Sprint(want))
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// The server keeps webhooks and jobs in tables of the databases they belong
+// to. Only admins can see these tables, their changes aren't captured, and
+// they are left out of exports, imports, diffs, branches and table limits.
+
+// internalTables are the tables of webhooks and jobs.
+var internalTables = []string{webhookTable, deliveryTable, deadLetterTable, jobTable, jobRunTable}
+
+// isInternalTable reports whether the server keeps a table.
+func isInternalTable(table string) bool {
+	return slices.ContainsFunc(internalTables, func(internal string) bool {
+		return strings.EqualFold(internal, table)
+	})
+}
+
+// notInternal is a SQL condition leaving the internal tables out, for a
+// column holding table names.
+func notInternal(column string) string {
+	return "lower(" + column + ") NOT IN ('" + strings.Join(internalTables, "', '") + "')"
+}
+
+// internalCache keeps what was read from the internal tables of every
+// database until its files change, so looking for webhooks and jobs doesn't
+// open the databases that have none.
+type internalCache[T any] struct {
+	sync.Mutex
+	m map[string]cachedRows[T]
+}
+
+type cachedRows[T any] struct {
+	stamp string
+	rows  []T
+}
+
+// fileStamp changes whenever a database is written to.
+func fileStamp(path string) string {
+	var stamp strings.Builder
+	for _, p := range []string{path, path + "-wal"} {
+		if info, err := os.Stat(p); err == nil {
+			fmt.Fprintf(&stamp, "%d:%d;", info.ModTime().UnixNano(), info.Size())
+		}
+	}
+	return stamp.String()
+}
+
+// get returns the rows of a database, reading them again if it changed.
+func (c *internalCache[T]) get(ctx context.Context, databaseName string, read func(context.Context, *handle) ([]T, error)) ([]T, error) {
+	databasePath, err := filePath(databaseName)
+	if err != nil {
+		return nil, err
+	}
+	if err := Get(databaseName); err != nil {
+		return nil, err
+	}
+
+	stamp := fileStamp(databasePath)
+	c.Lock()
+	cached, ok := c.m[databaseName]
+	c.Unlock()
+	if ok && cached.stamp == stamp {
+		return slices.Clone(cached.rows), nil
+	}
+
+	db, err := open(ctx, databaseName)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := read(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	c.Lock()
+	if c.m == nil {
+		c.m = make(map[string]cachedRows[T])
+	}
+	c.m[databaseName] = cachedRows[T]{stamp: stamp, rows: rows}
+	c.Unlock()
+	return slices.Clone(rows), nil
+}
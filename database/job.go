@@ -0,0 +1,329 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // time zones of jobs, whatever the host has installed
+)
+
+// Jobs run SQL on a cron schedule. Like webhooks they are kept in the
+// database they run on, with the history of their runs, so they go
+// wherever the database does, and the server runs them from the node owning
+// the database. A job runs as the principal who scheduled it last.
+
+const (
+	jobTable    = "_jobs"
+	jobRunTable = "_job_runs"
+)
+
+// maxJobRuns is how many runs are kept per job.
+const maxJobRuns = 1000
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrInvalidJob  = errors.New("invalid job")
+)
+
+// Outcomes of a job run.
+const (
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+	RunSkipped   = "skipped" // the previous run was still going
+)
+
+// Job is SQL run on a schedule.
+type Job struct {
+	Name     string    `json:"name"`
+	Schedule string    `json:"schedule"`            // cron expression, see Schedule
+	Timezone string    `json:"timezone"`            // the schedule is in, UTC when empty
+	SQL      string    `json:"sql"`                 // run with Exec
+	AlertURL string    `json:"alert_url,omitempty"` // failed runs are posted to
+	Owner    Principal `json:"-"`                   // the job runs as
+	Created  time.Time `json:"created"`
+	Updated  time.Time `json:"updated"`
+}
+
+// Next returns when the job runs next after t, the zero time if never.
+func (j Job) Next(t time.Time) time.Time {
+	schedule, err := ParseSchedule(j.Schedule)
+	if err != nil {
+		return time.Time{}
+	}
+	location, err := time.LoadLocation(j.Timezone)
+	if err != nil {
+		return time.Time{}
+	}
+	return schedule.Next(t.In(location))
+}
+
+// JobRun is a run of a job.
+type JobRun struct {
+	ID        int64         `json:"id"`
+	Job       string        `json:"job"`
+	Scheduled time.Time     `json:"scheduled"` // when the run was due, zero for runs on request
+	Started   time.Time     `json:"started"`
+	Duration  time.Duration `json:"duration"`
+	Status    string        `json:"status"`
+	Error     string        `json:"error,omitempty"`
+	Rows      int64         `json:"rows"` // changed by the last statement
+}
+
+// validateJob checks a job and fills in its defaults.
+func validateJob(job *Job) error {
+	if job.Name == "" || len(job.Name) > MaxNameLength || !nameRegexp.MatchString(job.Name) {
+		return fmt.Errorf("%w: the name may only contain letters, digits, '_' and '-' and must start with a letter or digit", ErrInvalidJob)
+	}
+	schedule, err := ParseSchedule(job.Schedule)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidJob, err)
+	}
+	if schedule.never() {
+		return fmt.Errorf("%w: %q never runs", ErrInvalidJob, job.Schedule)
+	}
+	if job.Timezone == "" {
+		job.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(job.Timezone); err != nil {
+		return fmt.Errorf("%w: unknown time zone %q", ErrInvalidJob, job.Timezone)
+	}
+	if strings.TrimSpace(job.SQL) == "" {
+		return fmt.Errorf("%w: the sql can't be empty", ErrInvalidJob)
+	}
+	if job.AlertURL != "" {
+		if err := validateWebhook(&Webhook{URL: job.AlertURL}); err != nil {
+			return fmt.Errorf("%w: the alert url must be an absolute http or https URL", ErrInvalidJob)
+		}
+	}
+	return nil
+}
+
+// internal opens a database to work on its internal tables, whoever asks.
+func internal(ctx context.Context, databaseName string) (*handle, error) {
+	if err := Get(databaseName); err != nil {
+		return nil, err
+	}
+	db, err := open(ctx, databaseName)
+	if err != nil {
+		return nil, err
+	}
+	db.auth.setup = true
+	return db, nil
+}
+
+// PutJob schedules a job, replacing the one with the same name, to run as
+// the principal of ctx.
+func PutJob(ctx context.Context, databaseName string, job Job) (Job, error) {
+	if err := validateJob(&job); err != nil {
+		return Job{}, err
+	}
+	principal := PrincipalFrom(ctx)
+	job.Owner = Principal{Name: principal.Name, Role: principal.Role, Vars: principal.Vars}
+	owner, err := json.Marshal(job.Owner)
+	if err != nil {
+		return Job{}, err
+	}
+	now := time.Now().UTC()
+	job.Created, job.Updated = now, now
+
+	db, err := internal(ctx, databaseName)
+	if err != nil {
+		return Job{}, err
+	}
+	defer db.Close()
+
+	txn, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return Job{}, err
+	}
+	defer txn.Rollback()
+
+	for _, stmt := range []string{
+		`CREATE TABLE IF NOT EXISTS main.` + jobTable + ` (name TEXT PRIMARY KEY, schedule TEXT NOT NULL,
+			timezone TEXT NOT NULL, sql TEXT NOT NULL, alert_url TEXT NOT NULL, owner TEXT NOT NULL,
+			created TEXT NOT NULL, updated TEXT NOT NULL)`,
+		`CREATE TABLE IF NOT EXISTS main.` + jobRunTable + ` (id INTEGER PRIMARY KEY, job TEXT NOT NULL,
+			scheduled TEXT NOT NULL, started TEXT NOT NULL, duration INTEGER NOT NULL, status TEXT NOT NULL,
+			error TEXT NOT NULL, rows INTEGER NOT NULL)`,
+	} {
+		if _, err := txn.ExecContext(ctx, stmt); err != nil {
+			return Job{}, err
+		}
+	}
+	var created string
+	err = txn.QueryRowContext(ctx, `INSERT INTO main.`+jobTable+` VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET schedule = excluded.schedule, timezone = excluded.timezone,
+		sql = excluded.sql, alert_url = excluded.alert_url, owner = excluded.owner, updated = excluded.updated
+		RETURNING created`,
+		job.Name, job.Schedule, job.Timezone, job.SQL, job.AlertURL, string(owner),
+		now.Format(time.RFC3339Nano), now.Format(time.RFC3339Nano)).Scan(&created)
+	if err != nil {
+		return Job{}, err
+	}
+	job.Created, _ = time.Parse(time.RFC3339Nano, created)
+	if err := txn.Commit(); err != nil {
+		return Job{}, err
+	}
+	return job, db.persist(ctx)
+}
+
+var jobCache internalCache[Job]
+
+// Jobs lists the jobs of a database by name.
+func Jobs(ctx context.Context, databaseName string) ([]Job, error) {
+	return jobCache.get(ctx, databaseName, readJobs)
+}
+
+func readJobs(ctx context.Context, db *handle) ([]Job, error) {
+	db.auth.setup = true
+	rows, err := db.QueryContext(ctx, `SELECT name, schedule, timezone, sql, alert_url, owner, created, updated
+		FROM main.`+jobTable+` ORDER BY name`)
+	if noTable(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []Job
+	for rows.Next() {
+		var job Job
+		var owner, created, updated string
+		if err := rows.Scan(&job.Name, &job.Schedule, &job.Timezone, &job.SQL, &job.AlertURL, &owner,
+			&created, &updated); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(owner), &job.Owner); err != nil {
+			return nil, fmt.Errorf("job %s has an invalid owner: %w", job.Name, err)
+		}
+		job.Created, _ = time.Parse(time.RFC3339Nano, created)
+		job.Updated, _ = time.Parse(time.RFC3339Nano, updated)
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// FindJob returns a job of a database.
+func FindJob(ctx context.Context, databaseName string, name string) (Job, error) {
+	jobs, err := Jobs(ctx, databaseName)
+	if err != nil {
+		return Job{}, err
+	}
+	for _, job := range jobs {
+		if job.Name == name {
+			return job, nil
+		}
+	}
+	return Job{}, ErrJobNotFound
+}
+
+// DeleteJob removes a job with its runs.
+func DeleteJob(ctx context.Context, databaseName string, name string) error {
+	db, err := internal(ctx, databaseName)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	result, err := db.ExecContext(ctx, `DELETE FROM main.`+jobTable+` WHERE name = ?`, name)
+	if noTable(err) {
+		return ErrJobNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrJobNotFound
+	}
+	if _, err := db.ExecContext(ctx, `DELETE FROM main.`+jobRunTable+` WHERE job = ?`, name); err != nil {
+		return err
+	}
+	return db.persist(ctx)
+}
+
+// RecordRun adds a run to the history of its job, keeping the last ones,
+// and returns it with its id.
+func RecordRun(ctx context.Context, databaseName string, run JobRun) (JobRun, error) {
+	db, err := internal(ctx, databaseName)
+	if err != nil {
+		return JobRun{}, err
+	}
+	defer db.Close()
+
+	txn, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return JobRun{}, err
+	}
+	defer txn.Rollback()
+
+	var n int
+	err = txn.QueryRowContext(ctx, `SELECT count(*) FROM main.`+jobTable+` WHERE name = ?`, run.Job).Scan(&n)
+	if noTable(err) || (err == nil && n == 0) {
+		return JobRun{}, ErrJobNotFound
+	}
+	if err != nil {
+		return JobRun{}, err
+	}
+	var scheduled string
+	if !run.Scheduled.IsZero() {
+		scheduled = run.Scheduled.UTC().Format(time.RFC3339Nano)
+	}
+	result, err := txn.ExecContext(ctx, `INSERT INTO main.`+jobRunTable+
+		` (job, scheduled, started, duration, status, error, rows) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		run.Job, scheduled, run.Started.UTC().Format(time.RFC3339Nano), int64(run.Duration), run.Status,
+		run.Error, run.Rows)
+	if err != nil {
+		return JobRun{}, err
+	}
+	if run.ID, err = result.LastInsertId(); err != nil {
+		return JobRun{}, err
+	}
+	if _, err := txn.ExecContext(ctx, `DELETE FROM main.`+jobRunTable+` WHERE job = ? AND id <=
+		(SELECT id FROM main.`+jobRunTable+` WHERE job = ? ORDER BY id DESC LIMIT 1 OFFSET ?)`,
+		run.Job, run.Job, maxJobRuns); err != nil {
+		return JobRun{}, err
+	}
+	if err := txn.Commit(); err != nil {
+		return JobRun{}, err
+	}
+	return run, db.persist(ctx)
+}
+
+// JobRuns lists the last runs of a job, most recent first.
+func JobRuns(ctx context.Context, databaseName string, name string) ([]JobRun, error) {
+	if _, err := FindJob(ctx, databaseName, name); err != nil {
+		return nil, err
+	}
+	db, err := internal(ctx, databaseName)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, `SELECT id, job, scheduled, started, duration, status, error, rows
+		FROM main.`+jobRunTable+` WHERE job = ? ORDER BY id DESC`, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []JobRun{}
+	for rows.Next() {
+		var run JobRun
+		var scheduled, started string
+		var duration int64
+		if err := rows.Scan(&run.ID, &run.Job, &scheduled, &started, &duration, &run.Status, &run.Error,
+			&run.Rows); err != nil {
+			return nil, err
+		}
+		run.Scheduled, _ = time.Parse(time.RFC3339Nano, scheduled)
+		run.Started, _ = time.Parse(time.RFC3339Nano, started)
+		run.Duration = time.Duration(duration)
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
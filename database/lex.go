@@ -0,0 +1,153 @@
+package database
+
+import "strings"
+
+// token is a lexical token of a SQL statement. Identifiers, in any of the
+// quoting styles SQLite accepts, carry their unquoted name. start and end
+// locate the token in the statement.
+type token struct {
+	ident  bool
+	quoted bool
+	text   string
+	start  int
+	end    int
+}
+
+// lex splits sql into tokens, dropping whitespace and comments. It knows just
+// enough SQL to find identifiers, not to validate statements.
+func lex(sql string) []token {
+	var tokens []token
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+		case strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				return tokens
+			}
+			i += end + 1
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				return tokens
+			}
+			i += end + 4
+		case c == '"' || c == '`' || c == '\'':
+			// string literals are accepted as identifiers in some places, so treat them alike
+			text, n := quoted(sql[i:], c)
+			tokens = append(tokens, token{ident: true, quoted: true, text: text, start: i, end: i + n})
+			i += n
+		case c == '[':
+			end := strings.IndexByte(sql[i:], ']')
+			if end < 0 {
+				end = len(sql) - i - 1
+			}
+			tokens = append(tokens, token{ident: true, quoted: true, text: sql[i+1 : i+end], start: i, end: i + end + 1})
+			i += end + 1
+		case isIdentStart(c):
+			j := i + 1
+			for j < len(sql) && isIdentPart(sql[j]) {
+				j++
+			}
+			tokens = append(tokens, token{ident: true, text: sql[i:j], start: i, end: j})
+			i = j
+		default:
+			tokens = append(tokens, token{text: string(c), start: i, end: i + 1})
+			i++
+		}
+	}
+	return tokens
+}
+
+// quoted reads a token quoted with q, where a doubled q stands for itself.
+func quoted(s string, q byte) (string, int) {
+	var b strings.Builder
+	i := 1
+	for i < len(s) {
+		if s[i] == q {
+			if i+1 < len(s) && s[i+1] == q {
+				b.WriteByte(q)
+				i += 2
+				continue
+			}
+			return b.String(), i + 1
+		}
+		b.WriteByte(s[i])
+		i++
+	}
+	return b.String(), i
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || c >= '0' && c <= '9' || c == '$'
+}
+
+// qualified reports whether sql names table through the given schema, as in main.table.
+func qualified(sql string, schema string, table string) bool {
+	tokens := lex(sql)
+	for i := 0; i+2 < len(tokens); i++ {
+		if tokens[i].ident && strings.EqualFold(tokens[i].text, schema) &&
+			tokens[i+1].text == "." &&
+			tokens[i+2].ident && strings.EqualFold(tokens[i+2].text, table) {
+			return true
+		}
+	}
+	return false
+}
+
+// keyword reports whether t is the unquoted keyword kw.
+func (t token) keyword(kw string) bool {
+	return t.ident && !t.quoted && strings.EqualFold(t.text, kw)
+}
+
+// retarget qualifies the unqualified targets of INSERT, UPDATE and DELETE
+// statements naming one of tables with schema.
+func retarget(sql string, schema string, tables []string) string {
+	tokens := lex(sql)
+	var b strings.Builder
+	last := 0
+	for i, t := range tokens {
+		var target int
+		switch {
+		case t.keyword("INTO"):
+			target = i + 1
+		case t.keyword("UPDATE"):
+			target = i + 1
+			if target+1 < len(tokens) && tokens[target].keyword("OR") {
+				target += 2
+			}
+		case t.keyword("DELETE") && i+1 < len(tokens) && tokens[i+1].keyword("FROM"):
+			target = i + 2
+		default:
+			continue
+		}
+		if target >= len(tokens) || !tokens[target].ident ||
+			target+1 < len(tokens) && tokens[target+1].text == "." {
+			continue
+		}
+		for _, table := range tables {
+			if strings.EqualFold(tokens[target].text, table) {
+				b.WriteString(sql[last:tokens[target].start])
+				b.WriteString(schema + "." + quoteIdent(tokens[target].text))
+				last = tokens[target].end
+				break
+			}
+		}
+	}
+	if last == 0 {
+		return sql
+	}
+	b.WriteString(sql[last:])
+	return b.String()
+}
+
+// quoteIdent quotes a name for use as an identifier.
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,191 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// ErrLimitExceeded is returned when a statement goes over a resource limit of its policy.
+var ErrLimitExceeded = errors.New("resource limit exceeded")
+
+// LimitsExceeded counts statements stopped by a resource limit, keyed by the limit.
+var LimitsExceeded = expvar.NewMap("limits_exceeded")
+
+// Limits bound the resources a statement, or the database it writes to, may
+// use. Zero leaves a resource unbounded.
+type Limits struct {
+	MaxRows        int64 `json:"max_rows"`         // rows returned by a query, or a cursor over all its pages
+	MaxResultBytes int64 `json:"max_result_bytes"` // size of the values returned by a query
+	MaxExecutionMs int64 `json:"max_execution_ms"` // time a request may spend running statements
+	MaxVMSteps     int64 `json:"max_vm_steps"`     // virtual machine instructions, checked every thousand
+	MaxFileSize    int64 `json:"max_file_size"`    // size in bytes the database may grow to
+	MaxTables      int64 `json:"max_tables"`       // tables in the database
+}
+
+// limit records that a resource limit was hit. The error is reported by
+// check, since SQLite only sees an interrupted or denied statement.
+func (a *authorizer) limit(name string, detail string) {
+	LimitsExceeded.Add(name, 1)
+	a.exceeded = fmt.Errorf("%w: %s", ErrLimitExceeded, detail)
+}
+
+// deadline bounds ctx by the execution time limit.
+func (a *authorizer) deadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	max := a.policy.Limits.MaxExecutionMs
+	if max <= 0 {
+		return context.WithCancel(ctx)
+	}
+	cause := fmt.Errorf("%w: execution took longer than %dms", ErrLimitExceeded, max)
+	ctx, cancel := context.WithTimeoutCause(ctx, time.Duration(max)*time.Millisecond, cause)
+	a.ctx = ctx
+	return ctx, cancel
+}
+
+// count adds a row read for the client to the totals of the statement.
+func (a *authorizer) count(row []any) error {
+	a.rows++
+	for _, v := range row {
+		switch v := v.(type) {
+		case int64, float64:
+			a.bytes += 8
+		case string:
+			a.bytes += int64(len(v))
+		case []byte:
+			a.bytes += int64(len(v))
+		}
+	}
+
+	limits := a.policy.Limits
+	switch {
+	case limits.MaxRows > 0 && a.rows > limits.MaxRows:
+		a.limit("rows", fmt.Sprintf("query returned more than %d rows", limits.MaxRows))
+		return a.exceeded
+	case limits.MaxResultBytes > 0 && a.bytes > limits.MaxResultBytes:
+		a.limit("result_bytes", fmt.Sprintf("query returned more than %d bytes", limits.MaxResultBytes))
+		return a.exceeded
+	}
+	return nil
+}
+
+// progress is called by SQLite every progressInterval steps. It returns true to interrupt the statement.
+func (a *authorizer) progress() bool {
+	if a.setup {
+		return false
+	}
+	a.steps += progressInterval
+	if max := a.policy.Limits.MaxVMSteps; max > 0 && a.steps > max {
+		a.limit("vm_steps", fmt.Sprintf("statement ran more than %d virtual machine steps", max))
+		return true
+	}
+	return false
+}
+
+// createTable decides whether one more table fits in the database.
+func (a *authorizer) createTable(table string) int {
+	max := a.policy.Limits.MaxTables
+	if max <= 0 || a.tables[strings.ToLower(table)] || isInternalTable(table) {
+		return sqlite3.SQLITE_OK
+	}
+	if int64(len(a.tables)) >= max {
+		a.limit("tables", fmt.Sprintf("database can't have more than %d tables", max))
+		return sqlite3.SQLITE_DENY
+	}
+	a.tables[strings.ToLower(table)] = true
+	return sqlite3.SQLITE_OK
+}
+
+// limitSession applies the limits of the policy to a new connection.
+func (a *authorizer) limitSession(conn *sqlite3.SQLiteConn) error {
+	limits := a.policy.Limits
+	if limits.MaxFileSize > 0 {
+		pageSize, err := pragmaInt(conn, "page_size")
+		if err != nil {
+			return err
+		}
+		pages := max(limits.MaxFileSize/pageSize, 1)
+		if _, err := conn.Exec(fmt.Sprintf("PRAGMA max_page_count = %d", pages), nil); err != nil {
+			return err
+		}
+	}
+	if limits.MaxTables > 0 {
+		tables, err := tableNames(conn)
+		if err != nil {
+			return err
+		}
+		a.tables = make(map[string]bool, len(tables))
+		for _, table := range tables {
+			a.tables[strings.ToLower(table)] = true
+		}
+	}
+	// steps are counted for metering even when they aren't limited
+	release, err := watchProgress(conn, a)
+	if err != nil {
+		return err
+	}
+	a.release = release
+	return nil
+}
+
+func pragmaInt(conn *sqlite3.SQLiteConn, pragma string) (int64, error) {
+	rows, err := conn.Query("PRAGMA "+pragma, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	values := make([]driver.Value, 1)
+	if err := rows.Next(values); err != nil {
+		return 0, err
+	}
+	n, _ := values[0].(int64)
+	return n, nil
+}
+
+func tableNames(conn *sqlite3.SQLiteConn) ([]string, error) {
+	rows, err := conn.Query(`SELECT name FROM main.sqlite_master WHERE type = 'table'
+		AND name NOT LIKE 'sqlite\_%' ESCAPE '\' AND `+notInternal("name"), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	values := make([]driver.Value, 1)
+	for {
+		if err := rows.Next(values); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		name, _ := values[0].(string)
+		tables = append(tables, name)
+	}
+	return tables, nil
+}
+
+// exceededBy reports the limit behind err, if a limit ended the statement.
+func (a *authorizer) exceededBy(err error) error {
+	if a.exceeded != nil {
+		return a.exceeded
+	}
+	if errors.Is(err, context.DeadlineExceeded) && a.ctx != nil {
+		if cause := context.Cause(a.ctx); errors.Is(cause, ErrLimitExceeded) {
+			LimitsExceeded.Add("execution_time", 1)
+			return cause
+		}
+	}
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrFull && a.policy.Limits.MaxFileSize > 0 {
+		LimitsExceeded.Add("file_size", 1)
+		return fmt.Errorf("%w: database can't grow beyond %d bytes", ErrLimitExceeded, a.policy.Limits.MaxFileSize)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrInvalidName    = errors.New("invalid database name")
+	ErrOutsideDataDir = errors.New("database path escapes the data directory")
+)
+
+// MaxNameLength is the longest database name accepted.
+const MaxNameLength = 64
+
+var nameRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// reservedNames can't be used as database names, either because the
+// gateway routes them or because some filesystems treat them specially.
+var reservedNames = map[string]bool{
+	"query": true, "exec": true, "debug": true, "audit": true,
+	"usage": true, "ratelimits": true, "templates": true, "trash": true, "fanout": true,
+	"con": true, "prn": true, "aux": true, "nul": true,
+	"com1": true, "com2": true, "com3": true, "com4": true, "com5": true,
+	"com6": true, "com7": true, "com8": true, "com9": true,
+	"lpt1": true, "lpt2": true, "lpt3": true, "lpt4": true, "lpt5": true,
+	"lpt6": true, "lpt7": true, "lpt8": true, "lpt9": true,
+}
+
+// dataDir is the resolved directory every database file lives in.
+var dataDir = "."
+
+// SetDataDir creates dir if needed and confines every database file to it.
+// The backups, templates, trash and changelogs move under it too, so it has
+// to be set before the directories of those.
+func SetDataDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return err
+	}
+
+	dataDir = resolved
+	backupDir = filepath.Join(resolved, "backups")
+	templateDir = filepath.Join(resolved, "templates")
+	trashDir = filepath.Join(resolved, "trash")
+	changelogDir = filepath.Join(resolved, "changelog")
+	return nil
+}
+
+// ValidateName checks a database name against the charset, length and reserved names rules.
+func ValidateName(databaseName string) error {
+	switch {
+	case databaseName == "":
+		return fmt.Errorf("%w: name can't be empty", ErrInvalidName)
+	case len(databaseName) > MaxNameLength:
+		return fmt.Errorf("%w: name is longer than %d characters", ErrInvalidName, MaxNameLength)
+	case !nameRegexp.MatchString(databaseName):
+		return fmt.Errorf("%w: name may only contain letters, digits, '_' and '-' and must start with a letter or digit", ErrInvalidName)
+	case reservedNames[strings.ToLower(databaseName)]:
+		return fmt.Errorf("%w: %q is reserved", ErrInvalidName, databaseName)
+	}
+	return nil
+}
+
+// filePath returns the location of a database file inside the data directory.
+// Symlinks are resolved so a link can't point the file somewhere else.
+func filePath(databaseName string) (string, error) {
+	if err := ValidateName(databaseName); err != nil {
+		return "", err
+	}
+	return confine(filepath.Join(dataDir, databaseName+sqlite))
+}
+
+// confine makes sure path, once its symlinks are resolved, stays under the data directory.
+func confine(path string) (string, error) {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return path, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if info.Mode()&fs.ModeSymlink == 0 {
+		return path, nil
+	}
+
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", ErrOutsideDataDir
+	}
+	rel, err := filepath.Rel(dataDir, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", ErrOutsideDataDir
+	}
+	return path, nil
+}
@@ -0,0 +1,220 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Denials counts statements rejected by the authorizer, keyed by the denied action.
+var Denials = expvar.NewMap("authorizer_denials")
+
+// Policy decides which statements a connection is allowed to prepare.
+type Policy struct {
+	AllowAttach     bool     `json:"allow_attach"`
+	AllowExtensions bool     `json:"allow_extensions"`
+	DenyPragmas     []string `json:"deny_pragmas"`
+	DenyFunctions   []string `json:"deny_functions"`
+	Limits          Limits   `json:"limits"`
+	Quotas          Quotas   `json:"quotas"`
+}
+
+// DefaultPolicy is used when no policy is configured for a database or role.
+var DefaultPolicy = Policy{
+	DenyPragmas: []string{
+		"writable_schema",
+		"trusted_schema",
+		"temp_store_directory",
+		"data_store_directory",
+		"mmap_size",
+	},
+	DenyFunctions: []string{
+		"load_extension",
+		"fts3_tokenizer",
+	},
+}
+
+var (
+	policyMtx sync.RWMutex
+	policies  = struct {
+		Default   *Policy           `json:"default"`
+		Roles     map[string]Policy `json:"roles"`
+		Databases map[string]Policy `json:"databases"`
+	}{}
+)
+
+// LoadPolicies reads per database and per role policies from a JSON file.
+func LoadPolicies(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	policyMtx.Lock()
+	defer policyMtx.Unlock()
+
+	return json.Unmarshal(data, &policies)
+}
+
+// SetPolicy overrides the policy of a single database.
+func SetPolicy(databaseName string, policy Policy) {
+	policyMtx.Lock()
+	defer policyMtx.Unlock()
+
+	if policies.Databases == nil {
+		policies.Databases = make(map[string]Policy)
+	}
+	policies.Databases[databaseName] = policy
+}
+
+// policyFor resolves the policy of a database, then of a role, then the default.
+func policyFor(databaseName string, role string) Policy {
+	policyMtx.RLock()
+	defer policyMtx.RUnlock()
+
+	if p, ok := policies.Databases[databaseName]; ok {
+		return p
+	}
+	if p, ok := policies.Roles[role]; ok {
+		return p
+	}
+	if policies.Default != nil {
+		return *policies.Default
+	}
+	return DefaultPolicy
+}
+
+// authorizer holds the reason of the last denial so it can be reported back
+// to the caller, and what the statements of a request used of their limits.
+type authorizer struct {
+	policy    Policy
+	principal Principal
+	reason    string
+
+	setup     bool     // statements of the server itself are trusted
+	protected []string // tables shadowed by row-level security
+	capturing bool     // changes are captured, see capture
+
+	ctx         context.Context // bounded by the execution time limit
+	steps       int64           // virtual machine steps run, see progress
+	metered     int64           // steps already added to the usage, see meter
+	rows, bytes int64
+	tables      map[string]bool // tables of the database, when their number is limited
+	exceeded    error
+	release     func() // stops counting steps
+}
+
+func (a *authorizer) authorize(op int, arg1, arg2, arg3 string) int {
+	if a.setup {
+		return sqlite3.SQLITE_OK
+	}
+
+	if a.principal.Role != AdminRole {
+		switch op {
+		case sqlite3.SQLITE_INSERT, sqlite3.SQLITE_UPDATE, sqlite3.SQLITE_DELETE, sqlite3.SQLITE_DROP_TABLE:
+			if strings.EqualFold(arg1, rlsTable) {
+				return a.deny("rls", "only admins can manage row-level security")
+			}
+			if isInternalTable(arg1) {
+				return a.deny("internal", "only admins can access the tables of webhooks and jobs")
+			}
+		case sqlite3.SQLITE_ALTER_TABLE:
+			if strings.EqualFold(arg2, rlsTable) {
+				return a.deny("rls", "only admins can manage row-level security")
+			}
+			if isInternalTable(arg2) {
+				return a.deny("internal", "only admins can access the tables of webhooks and jobs")
+			}
+		case sqlite3.SQLITE_READ, sqlite3.SQLITE_CREATE_TABLE:
+			// webhooks hold the secrets their deliveries are signed with
+			if isInternalTable(arg1) {
+				return a.deny("internal", "only admins can access the tables of webhooks and jobs")
+			}
+		case sqlite3.SQLITE_CREATE_VIEW, sqlite3.SQLITE_CREATE_TRIGGER,
+			sqlite3.SQLITE_CREATE_TEMP_VIEW, sqlite3.SQLITE_CREATE_TEMP_TRIGGER,
+			sqlite3.SQLITE_DROP_TEMP_VIEW, sqlite3.SQLITE_DROP_TEMP_TRIGGER:
+			// views and triggers could read protected tables around their policies
+			if len(a.protected) > 0 {
+				return a.deny("rls", "views and triggers can't be changed on a database with row-level security")
+			}
+		}
+	}
+
+	switch op {
+	case sqlite3.SQLITE_CREATE_TABLE:
+		return a.createTable(arg1)
+	case sqlite3.SQLITE_DROP_TABLE:
+		delete(a.tables, strings.ToLower(arg1))
+	case sqlite3.SQLITE_DELETE:
+		// ignoring a delete only turns off the truncate optimization, which
+		// would clear the table without the hooks seeing its rows
+		if a.capturing && captured(arg3, arg1) {
+			return sqlite3.SQLITE_IGNORE
+		}
+	case sqlite3.SQLITE_ATTACH:
+		if !a.policy.AllowAttach {
+			return a.deny("attach", "ATTACH DATABASE is not allowed")
+		}
+	case sqlite3.SQLITE_DETACH:
+		if !a.policy.AllowAttach {
+			return a.deny("detach", "DETACH DATABASE is not allowed")
+		}
+	case sqlite3.SQLITE_PRAGMA:
+		if len(a.protected) > 0 && strings.EqualFold(arg1, "recursive_triggers") && arg2 != "" {
+			return a.deny("rls", "PRAGMA recursive_triggers can't be changed under row-level security")
+		}
+		if a.policy.Limits.MaxFileSize > 0 && strings.EqualFold(arg1, "max_page_count") {
+			return a.deny("pragma:max_page_count", "PRAGMA max_page_count is not allowed when the file size is limited")
+		}
+		for _, pragma := range a.policy.DenyPragmas {
+			if strings.EqualFold(pragma, arg1) {
+				return a.deny("pragma:"+pragma, "PRAGMA "+pragma+" is not allowed")
+			}
+		}
+	case sqlite3.SQLITE_FUNCTION:
+		if strings.EqualFold(arg2, handleFunction) {
+			return a.deny("function:"+handleFunction, "function "+handleFunction+"() is internal")
+		}
+		if !a.policy.AllowExtensions && strings.EqualFold(arg2, "load_extension") {
+			return a.deny("extension", "loading extensions is not allowed")
+		}
+		for _, function := range a.policy.DenyFunctions {
+			if strings.EqualFold(function, arg2) {
+				return a.deny("function:"+function, "function "+function+"() is not allowed")
+			}
+		}
+	}
+
+	return sqlite3.SQLITE_OK
+}
+
+func (a *authorizer) deny(action string, reason string) int {
+	Denials.Add(action, 1)
+	a.reason = reason
+	return sqlite3.SQLITE_DENY
+}
+
+// check turns an authorization failure into ErrDenied with the reason
+// attached, and a statement stopped by a limit into ErrLimitExceeded.
+func (a *authorizer) check(err error) error {
+	if err == nil {
+		return nil
+	}
+	if exceeded := a.exceededBy(err); exceeded != nil {
+		return exceeded
+	}
+
+	// SQLite reports some denials, such as functions, with a generic error code
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && a.reason != "" {
+		return fmt.Errorf("%w: %s", ErrDenied, a.reason)
+	}
+	return err
+}
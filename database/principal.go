@@ -0,0 +1,24 @@
+package database
+
+import "context"
+
+// Principal is the authenticated caller a statement runs on behalf of.
+type Principal struct {
+	Name string
+	Role string
+	Addr string // address the principal connected from
+	Vars map[string]string
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal.
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFrom returns the principal stored in ctx, or the zero Principal.
+func PrincipalFrom(ctx context.Context) Principal {
+	principal, _ := ctx.Value(principalKey{}).(Principal)
+	return principal
+}
@@ -0,0 +1,13 @@
+#include <stdint.h>
+#include "_cgo_export.h"
+
+typedef struct sqlite3 sqlite3;
+void sqlite3_progress_handler(sqlite3*, int, int(*)(void*), void*);
+
+static int progress(void *target) {
+	return bedroompopProgress((uintptr_t)target);
+}
+
+void bedroompop_set_progress(void *db, int steps, uintptr_t target) {
+	sqlite3_progress_handler((sqlite3*)db, steps, target ? progress : 0, (void*)target);
+}
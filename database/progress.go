@@ -0,0 +1,48 @@
+package database
+
+/*
+#include <stdint.h>
+void bedroompop_set_progress(void *db, int steps, uintptr_t target);
+*/
+import "C"
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// go-sqlite3 doesn't expose sqlite3_progress_handler, so the handler is
+// installed on the raw connection, see rawHandle.
+
+// progressInterval is how many virtual machine steps run between calls of the handler.
+const progressInterval = 1000
+
+var (
+	progressTargets sync.Map // id to the *authorizer counting the steps of a connection
+	progressIDs     atomic.Uint64
+)
+
+//export bedroompopProgress
+func bedroompopProgress(id C.uintptr_t) C.int {
+	v, ok := progressTargets.Load(uint64(id))
+	if ok && v.(*authorizer).progress() {
+		// a non-zero result interrupts the statement
+		return 1
+	}
+	return 0
+}
+
+// watchProgress reports the steps run on conn to a until release is called.
+func watchProgress(conn *sqlite3.SQLiteConn, a *authorizer) (release func(), err error) {
+	db, err := rawHandle(conn)
+	if err != nil {
+		return nil, err
+	}
+	id := progressIDs.Add(1)
+	progressTargets.Store(id, a)
+
+	C.bedroompop_set_progress(db, progressInterval, C.uintptr_t(id))
+	return func() { progressTargets.Delete(id) }, nil
+}
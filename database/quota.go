@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/trianglehasfoursides/bedroompop/metering"
+)
+
+// Quotas cap the usage of a database in each metering window. Once a soft
+// quota is exceeded requests are slowed down, once a hard quota is exceeded
+// they are rejected until the next window. Storage is checked against the
+// size of the database file, execution against the virtual machine steps
+// SQLite ran for the statements of the database, which unlike time doesn't
+// depend on how busy the node was. Usage is counted by the node running the
+// statements, which is the owner of the database, and checked along with
+// what other nodes counted while they owned it, see metering.SetRemote.
+type Quotas struct {
+	Soft Quota `json:"soft"`
+	Hard Quota `json:"hard"`
+}
+
+// Quota bounds each field of metering.Usage. Zero leaves it unbounded.
+type Quota struct {
+	StorageBytes int64 `json:"storage_bytes"`
+	RowsRead     int64 `json:"rows_read"`
+	RowsWritten  int64 `json:"rows_written"`
+	Queries      int64 `json:"queries"`
+	VMSteps      int64 `json:"vm_steps"`
+}
+
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// QuotasExceeded counts requests rejected or slowed down by a quota, keyed by kind and quota.
+var QuotasExceeded = expvar.NewMap("quotas_exceeded")
+
+// quotaThrottle is how long requests over a soft quota are held back.
+var quotaThrottle = time.Second
+
+// SetQuotaThrottle sets how long requests over a soft quota are held back.
+func SetQuotaThrottle(delay time.Duration) {
+	quotaThrottle = delay
+}
+
+// exceeded returns the name of the first field of usage over the quota.
+func (q Quota) exceeded(usage metering.Usage) string {
+	switch {
+	case q.StorageBytes > 0 && usage.StorageBytes >= q.StorageBytes:
+		return "storage_bytes"
+	case q.RowsRead > 0 && usage.RowsRead >= q.RowsRead:
+		return "rows_read"
+	case q.RowsWritten > 0 && usage.RowsWritten >= q.RowsWritten:
+		return "rows_written"
+	case q.Queries > 0 && usage.Queries >= q.Queries:
+		return "queries"
+	case q.VMSteps > 0 && usage.VMSteps >= q.VMSteps:
+		return "vm_steps"
+	}
+	return ""
+}
+
+// admit checks the usage of the database against its quotas before a
+// request runs, rejecting it over a hard quota and delaying it over a soft one.
+func (a *authorizer) admit(ctx context.Context, databaseName string, path string) error {
+	quotas := a.policy.Quotas
+	if quotas == (Quotas{}) {
+		return nil
+	}
+
+	usage := metering.Current(databaseName)
+	if info, err := os.Stat(path); err == nil {
+		usage.StorageBytes = info.Size()
+	}
+
+	if field := quotas.Hard.exceeded(usage); field != "" {
+		QuotasExceeded.Add("hard:"+field, 1)
+		return fmt.Errorf("%w: %s of database %s is over its quota", ErrQuotaExceeded, field, databaseName)
+	}
+	if field := quotas.Soft.exceeded(usage); field != "" {
+		QuotasExceeded.Add("soft:"+field, 1)
+		select {
+		case <-time.After(quotaThrottle):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// meter adds what a request used to the usage of its database, along with
+// the steps run since it was last metered.
+func (a *authorizer) meter(databaseName string, path string, usage metering.Usage) {
+	usage.VMSteps = a.steps - a.metered
+	a.metered = a.steps
+	if info, err := os.Stat(path); err == nil {
+		usage.StorageBytes = info.Size()
+	}
+	metering.Add(databaseName, usage)
+}
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/trianglehasfoursides/bedroompop/metering"
+)
+
+// series makes SQLite run about ten steps per row it counts.
+const series = "WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 100000) SELECT count(*) FROM n"
+
+func TestMeterCountsSteps(t *testing.T) {
+	useDirs(t)
+	if err := Create(adminContext(), "steps", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	before := metering.Current("steps").VMSteps
+	rowsOf(t, "steps", series)
+	once := metering.Current("steps").VMSteps - before
+	if once < 100000 {
+		t.Fatalf("metered %d steps for a query over 100000 rows", once)
+	}
+	rowsOf(t, "steps", series)
+	if twice := metering.Current("steps").VMSteps - before; twice != 2*once {
+		t.Errorf("metered %d steps for the query twice, want %d", twice, 2*once)
+	}
+
+	// a cursor meters each page with the steps it ran since the last one
+	before = metering.Current("steps").VMSteps
+	page, err := QueryPage(adminContext(), "steps", "WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 100000) SELECT i FROM n", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := metering.Current("steps").VMSteps - before
+	if _, err := NextPage(adminContext(), "steps", page.Cursor, 1000); err != nil {
+		t.Fatal(err)
+	}
+	second := metering.Current("steps").VMSteps - before - first
+	if first == 0 || second == 0 || second > 2*first {
+		t.Errorf("metered %d steps for the first page and %d for the second", first, second)
+	}
+}
+
+func TestHardQuotaOnSteps(t *testing.T) {
+	useDirs(t)
+	if err := Create(adminContext(), "capped", ""); err != nil {
+		t.Fatal(err)
+	}
+	SetPolicy("capped", Policy{Quotas: Quotas{Hard: Quota{VMSteps: 10000}}})
+	t.Cleanup(func() {
+		policyMtx.Lock()
+		delete(policies.Databases, "capped")
+		policyMtx.Unlock()
+	})
+
+	rowsOf(t, "capped", series)
+	if _, _, err := QueryRows(adminContext(), "capped", "SELECT 1"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("query over the steps quota = %v, want ErrQuotaExceeded", err)
+	}
+}
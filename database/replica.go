@@ -0,0 +1,560 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/trianglehasfoursides/bedroompop/storage"
+)
+
+// The backup directory is shipped to a backup store off the node: snapshots
+// as soon as they are taken and WAL segments as soon as they are archived,
+// so losing the node loses at most the last sync interval. Objects keep the
+// layout of the backup directory, sidecars last so a listed backup is always
+// complete. When a key is set every object is encrypted with it. Restores
+// read from the backup directory first and from the store for what is
+// missing, so a database can be restored on any node.
+
+// backupStore is the store backups are shipped to, with the key sealing
+// the objects in it and the retention pruning them.
+type backupStore struct {
+	store     storage.Store
+	aead      cipher.AEAD
+	keyID     keyID
+	retention BackupRetention
+}
+
+var replica = struct {
+	sync.Mutex
+	backupStore
+	shipped  map[string]int64 // size of the objects known to be in the store
+	wake     chan struct{}
+	shipping sync.Mutex // held while the store is written, which isn't under the lock
+}{wake: make(chan struct{}, 1)}
+
+// currentStore returns the backup store, whose store is nil when there is none.
+func currentStore() backupStore {
+	replica.Lock()
+	defer replica.Unlock()
+	return replica.backupStore
+}
+
+// isShipped reports whether an object of size is known to be in the store.
+func isShipped(key string, size int64) bool {
+	replica.Lock()
+	defer replica.Unlock()
+	shipped, ok := replica.shipped[key]
+	return ok && shipped == size
+}
+
+// setShipped records the size of an object in the store, -1 once removed.
+func setShipped(key string, size int64) {
+	replica.Lock()
+	defer replica.Unlock()
+	if size < 0 {
+		delete(replica.shipped, key)
+	} else if replica.shipped != nil {
+		replica.shipped[key] = size
+	}
+}
+
+var storeMagic = []byte("BPSTORE1")
+
+// storeChunk is how much of an object is sealed at once.
+const storeChunk = 64 << 10
+
+// SetBackupStore ships backups to store, encrypted with the key in keyFile
+// unless it is empty, and prunes them there according to retention.
+func SetBackupStore(store storage.Store, keyFile string, retention BackupRetention) error {
+	replica.Lock()
+	defer replica.Unlock()
+
+	if keyFile != "" {
+		key, err := readKeyFile(keyFile)
+		if err != nil {
+			return err
+		}
+		if replica.aead, err = newGCM(key); err != nil {
+			return err
+		}
+		replica.keyID = masterKeyID(key)
+	}
+	replica.store = store
+	replica.retention = retention
+	replica.shipped = nil
+	return nil
+}
+
+// Replicate ships new backups and WAL segments to the backup store once per
+// interval, or as soon as they are written.
+func Replicate(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-replica.wake:
+		}
+		if err := ShipBackups(context.Background()); err != nil {
+			log.Error("can't ship backups", "err", err)
+		}
+	}
+}
+
+// wakeReplica has Replicate ship what was just written.
+func wakeReplica() {
+	select {
+	case replica.wake <- struct{}{}:
+	default:
+	}
+}
+
+// ShipBackups uploads what the backup store is missing from the backup
+// directory, then prunes the databases that got a new backup. Uploads run
+// without the lock, so listing and restoring backups go on meanwhile.
+func ShipBackups(ctx context.Context) error {
+	replica.shipping.Lock()
+	defer replica.shipping.Unlock()
+
+	b := currentStore()
+	if b.store == nil {
+		return nil
+	}
+	replica.Lock()
+	listed := replica.shipped != nil
+	replica.Unlock()
+	if !listed {
+		objects, err := b.store.List(ctx, "")
+		if err != nil {
+			return err
+		}
+		shipped := make(map[string]int64, len(objects))
+		for _, object := range objects {
+			shipped[object.Key] = object.Size
+		}
+		replica.Lock()
+		replica.shipped = shipped
+		replica.Unlock()
+	}
+
+	local, err := storage.NewDir(backupDir)
+	if err != nil {
+		return err
+	}
+	objects, err := local.List(ctx, "")
+	if err != nil {
+		return err
+	}
+	// sidecars go last, once the snapshot they describe is in the store
+	sort.SliceStable(objects, func(i, j int) bool {
+		return !isSidecar(objects[i].Key) && isSidecar(objects[j].Key)
+	})
+
+	backedUp := make(map[string]bool)
+	for _, object := range objects {
+		size := b.storedSize(object.Size)
+		if isShipped(object.Key, size) {
+			continue
+		}
+		file, err := local.Get(ctx, object.Key)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotExist) {
+				// pruned meanwhile
+				continue
+			}
+			return err
+		}
+		err = b.store.Put(ctx, object.Key, b.sealObject(file, object.Key), size)
+		file.Close()
+		if err != nil {
+			return err
+		}
+		setShipped(object.Key, size)
+		if isSidecar(object.Key) {
+			backedUp[strings.Split(object.Key, "/")[0]] = true
+		}
+	}
+
+	for databaseName := range backedUp {
+		if err := b.pruneStore(ctx, databaseName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropStoredPlaintext removes from the backup store the archived WAL of a
+// database that was encrypted, and its plain backups no longer kept on this
+// node. Those kept here are sealed and shipped again over the plain ones.
+func dropStoredPlaintext(ctx context.Context, databaseName string) error {
+	replica.shipping.Lock()
+	defer replica.shipping.Unlock()
+
+	b := currentStore()
+	if b.store == nil {
+		return nil
+	}
+	objects, err := b.store.List(ctx, databaseName+"/wal/")
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(objects))
+	for _, object := range objects {
+		keys = append(keys, object.Key)
+	}
+
+	stored, err := b.storedBackups(ctx, databaseName)
+	if err != nil {
+		return err
+	}
+	for _, backup := range stored {
+		if backup.Encrypted {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(backupDir, databaseName, backup.ID+sqlite)); err == nil {
+			continue
+		}
+		keys = append(keys, backupKey(backup, ".json"), backupKey(backup, sqlite))
+	}
+
+	for _, key := range keys {
+		if err := b.store.Delete(ctx, key); err != nil {
+			return err
+		}
+		setShipped(key, -1)
+	}
+	return nil
+}
+
+// isSidecar reports whether a key is the metadata of a backup.
+func isSidecar(key string) bool {
+	return strings.Count(key, "/") == 1 && strings.HasSuffix(key, ".json")
+}
+
+// pruneStore removes the backups of a database the retention of the store
+// doesn't keep, and the WAL generations none of the kept ones needs.
+func (b backupStore) pruneStore(ctx context.Context, databaseName string) error {
+	if b.retention == (BackupRetention{}) {
+		return nil
+	}
+	backups, err := b.storedBackups(ctx, databaseName)
+	if err != nil {
+		return err
+	}
+	removed, oldest := retain(backups, b.retention)
+	for _, backup := range removed {
+		for _, key := range []string{backupKey(backup, sqlite), backupKey(backup, ".json")} {
+			if err := b.store.Delete(ctx, key); err != nil {
+				return err
+			}
+			setShipped(key, -1)
+		}
+	}
+
+	generations, err := walGenerations(ctx, b.store, databaseName)
+	if err != nil {
+		return err
+	}
+	for i, generation := range generations {
+		if i == len(generations)-1 || generation.last().After(oldest) {
+			// the newest generation may still be running
+			continue
+		}
+		for _, key := range generation.keys() {
+			if err := b.store.Delete(ctx, key); err != nil {
+				return err
+			}
+			setShipped(key, -1)
+		}
+	}
+	return nil
+}
+
+func backupKey(backup Backup, ext string) string {
+	return backup.Database + "/" + backup.ID + ext
+}
+
+// storedBackups lists the backups of a database in the store.
+func (b backupStore) storedBackups(ctx context.Context, databaseName string) ([]Backup, error) {
+	objects, err := b.store.List(ctx, databaseName+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []Backup
+	for _, object := range objects {
+		if !isSidecar(object.Key) {
+			continue
+		}
+		data, err := b.fetch(ctx, object.Key)
+		if err != nil {
+			return nil, err
+		}
+		var backup Backup
+		if err := json.Unmarshal(data, &backup); err != nil {
+			return nil, err
+		}
+		backups = append(backups, backup)
+	}
+	return backups, nil
+}
+
+// fetch reads a whole object of the backup store.
+func (b backupStore) fetch(ctx context.Context, key string) ([]byte, error) {
+	r, err := b.openStored(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// openStored opens an object of the backup store, decrypting it.
+func (b backupStore) openStored(ctx context.Context, key string) (io.ReadCloser, error) {
+	r, err := b.store.Get(ctx, key)
+	if err != nil || b.aead == nil {
+		return r, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{&opener{src: bufio.NewReader(r), key: key, aead: b.aead, keyID: b.keyID}, r}, nil
+}
+
+// openBackup opens a file under the backup directory, or its copy in the
+// backup store when the node lost it.
+func openBackup(ctx context.Context, key string) (io.ReadCloser, error) {
+	local, err := storage.NewDir(backupDir)
+	if err != nil {
+		return nil, err
+	}
+	r, err := local.Get(ctx, key)
+	if !errors.Is(err, storage.ErrNotExist) {
+		return r, err
+	}
+
+	b := currentStore()
+	if b.store == nil {
+		return nil, err
+	}
+	return b.openStored(ctx, key)
+}
+
+// storedSize is the size of a file of the given size once in the store.
+func (b backupStore) storedSize(size int64) int64 {
+	if b.aead == nil {
+		return size
+	}
+	chunks := max((size+storeChunk-1)/storeChunk, 1)
+	return int64(len(storeMagic)+keyIDSize) + size + chunks*int64(b.aead.NonceSize()+b.aead.Overhead())
+}
+
+// chunkData is what a chunk of an object is authenticated with, so chunks
+// can't be moved between objects, reordered or dropped from the end.
+func chunkData(key string, index uint64, final bool) []byte {
+	data := binary.BigEndian.AppendUint64([]byte(key), index)
+	if final {
+		return append(data, 1)
+	}
+	return append(data, 0)
+}
+
+// sealObject encrypts an object in chunks while it is read.
+func (b backupStore) sealObject(r io.Reader, key string) io.Reader {
+	if b.aead == nil {
+		return r
+	}
+	return &sealer{
+		src:     bufio.NewReaderSize(r, storeChunk),
+		key:     key,
+		aead:    b.aead,
+		pending: append(bytes.Clone(storeMagic), b.keyID[:]...),
+	}
+}
+
+type sealer struct {
+	src     *bufio.Reader
+	key     string
+	aead    cipher.AEAD
+	index   uint64
+	pending []byte
+	done    bool
+}
+
+func (s *sealer) Read(p []byte) (int, error) {
+	for len(s.pending) == 0 {
+		if s.done {
+			return 0, io.EOF
+		}
+		plain := make([]byte, storeChunk)
+		n, err := io.ReadFull(s.src, plain)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, err
+		}
+		if err == nil {
+			_, err = s.src.Peek(1)
+		}
+		s.done = err != nil
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, err
+		}
+
+		nonce := make([]byte, s.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return 0, err
+		}
+		s.pending = s.aead.Seal(nonce, nonce, plain[:n], chunkData(s.key, s.index, s.done))
+		s.index++
+	}
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+type opener struct {
+	src     *bufio.Reader
+	key     string
+	aead    cipher.AEAD
+	keyID   keyID
+	index   uint64
+	started bool
+	pending []byte
+	done    bool
+}
+
+func (o *opener) Read(p []byte) (int, error) {
+	for len(o.pending) == 0 {
+		if o.done {
+			return 0, io.EOF
+		}
+		if !o.started {
+			header := make([]byte, len(storeMagic)+keyIDSize)
+			if _, err := io.ReadFull(o.src, header); err != nil || !bytes.Equal(header[:len(storeMagic)], storeMagic) {
+				return 0, errors.New("backup object " + o.key + " isn't encrypted")
+			}
+			if !bytes.Equal(header[len(storeMagic):], o.keyID[:]) {
+				return 0, errors.New("backup object " + o.key + " is encrypted with another key")
+			}
+			o.started = true
+		}
+
+		aead := o.aead
+		sealed := make([]byte, aead.NonceSize()+storeChunk+aead.Overhead())
+		n, err := io.ReadFull(o.src, sealed)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, err
+		}
+		if err == nil {
+			_, err = o.src.Peek(1)
+		}
+		o.done = err != nil
+		if n < aead.NonceSize() {
+			return 0, errors.New("backup object " + o.key + " is truncated")
+		}
+		plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():n], chunkData(o.key, o.index, o.done))
+		if err != nil {
+			return 0, errors.New("backup object " + o.key + " is corrupt or truncated")
+		}
+		o.index++
+		o.pending = plain
+	}
+	n := copy(p, o.pending)
+	o.pending = o.pending[n:]
+	return n, nil
+}
+
+// walGeneration is a generation of archived WAL, by key.
+type walGeneration struct {
+	name     string
+	header   string
+	segments []walSegment
+}
+
+func (g walGeneration) last() time.Time {
+	if len(g.segments) == 0 {
+		return time.Time{}
+	}
+	return g.segments[len(g.segments)-1].time
+}
+
+func (g walGeneration) keys() []string {
+	keys := []string{g.header}
+	for _, segment := range g.segments {
+		keys = append(keys, segment.path)
+	}
+	return keys
+}
+
+// walGenerations lists the archived WAL generations of a database in a
+// store, oldest first. Segment paths are keys.
+func walGenerations(ctx context.Context, store storage.Store, databaseName string) ([]walGeneration, error) {
+	objects, err := store.List(ctx, databaseName+"/wal/")
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*walGeneration)
+	var generations []*walGeneration
+	for _, object := range objects {
+		parts := strings.Split(object.Key, "/")
+		if len(parts) != 4 {
+			continue
+		}
+		generation, ok := byName[parts[2]]
+		if !ok {
+			generation = &walGeneration{name: parts[2]}
+			byName[parts[2]] = generation
+			generations = append(generations, generation)
+		}
+		if parts[3] == "header" {
+			generation.header = object.Key
+		} else if segment, ok := parseSegment(object.Key); ok {
+			generation.segments = append(generation.segments, segment)
+		}
+	}
+
+	sort.Slice(generations, func(i, j int) bool { return generations[i].name < generations[j].name })
+	list := make([]walGeneration, 0, len(generations))
+	for _, generation := range generations {
+		if generation.header == "" {
+			continue
+		}
+		sort.Slice(generation.segments, func(i, j int) bool { return generation.segments[i].offset < generation.segments[j].offset })
+		list = append(list, *generation)
+	}
+	return list, nil
+}
+
+// parseSegment reads the offset and archive time in the name of a segment.
+func parseSegment(p string) (walSegment, bool) {
+	offset, nanos, ok := strings.Cut(strings.TrimSuffix(path.Base(p), ".frames"), "-")
+	if !ok || !strings.HasSuffix(p, ".frames") {
+		return walSegment{}, false
+	}
+	o, err := strconv.ParseInt(offset, 16, 64)
+	if err != nil {
+		return walSegment{}, false
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return walSegment{}, false
+	}
+	return walSegment{path: p, offset: o, time: time.Unix(0, n)}, true
+}
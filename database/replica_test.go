@@ -0,0 +1,374 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/trianglehasfoursides/bedroompop/storage"
+)
+
+// adminContext runs the statements of a test as an admin.
+func adminContext() context.Context {
+	return WithPrincipal(context.Background(), Principal{Name: "test", Role: AdminRole})
+}
+
+// useDirs points the data and backup directories to a fresh directory.
+func useDirs(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := SetDataDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetBackupDir(filepath.Join(dir, "backups")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// useStore ships backups to store, encrypted with key unless it is empty.
+func useStore(t *testing.T, store storage.Store, key string, retention BackupRetention) {
+	t.Helper()
+	keyFile := ""
+	if key != "" {
+		keyFile = filepath.Join(t.TempDir(), "store.key")
+		if err := os.WriteFile(keyFile, []byte(key), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := SetBackupStore(store, keyFile, retention); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		replica.Lock()
+		replica.backupStore = backupStore{}
+		replica.shipped = nil
+		replica.Unlock()
+	})
+}
+
+// exec runs statements on a database, failing the test on error.
+func exec(t *testing.T, databaseName string, query string) {
+	t.Helper()
+	if _, err := Exec(adminContext(), databaseName, query); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+}
+
+// count returns how many rows a table of a database has.
+func count(t *testing.T, databaseName string, table string) int64 {
+	t.Helper()
+	_, rows, err := QueryRows(adminContext(), databaseName, "SELECT count(*) FROM "+table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows[0][0].(int64)
+}
+
+// restore restores a backup the way the server does, through the files
+// emitted by ExportRecovery.
+func restore(t *testing.T, target string, backup Backup, until time.Time) error {
+	t.Helper()
+	dir := t.TempDir()
+	var snapshot string
+	var wals []string
+	err := ExportRecovery(adminContext(), backup, until, func(file string, r io.Reader) error {
+		path := filepath.Join(dir, strings.ReplaceAll(file, "/", "_"))
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		if file == "snapshot" {
+			snapshot = path
+		} else {
+			wals = append(wals, path)
+		}
+		return os.WriteFile(path, data, 0o600)
+	})
+	if err != nil {
+		return err
+	}
+	return RestoreFrom(adminContext(), target, snapshot, wals, backup)
+}
+
+// countingStore counts the objects put in a store, and holds puts while
+// held is locked.
+type countingStore struct {
+	storage.Store
+	held sync.RWMutex
+	mu   sync.Mutex
+	puts map[string]int
+}
+
+func (s *countingStore) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	s.held.RLock()
+	defer s.held.RUnlock()
+
+	s.mu.Lock()
+	if s.puts == nil {
+		s.puts = make(map[string]int)
+	}
+	s.puts[key]++
+	s.mu.Unlock()
+	return s.Store.Put(ctx, key, r, size)
+}
+
+func TestShipAndRestoreFromStore(t *testing.T) {
+	for _, key := range []string{"", strings.Repeat("ab", keySize)} {
+		name := "plain"
+		if key != "" {
+			name = "encrypted"
+		}
+		t.Run(name, func(t *testing.T) {
+			useDirs(t)
+			store := storage.NewMem()
+			useStore(t, store, key, BackupRetention{})
+
+			if err := Create(adminContext(), "orders", "CREATE TABLE items (name TEXT)"); err != nil {
+				t.Fatal(err)
+			}
+			exec(t, "orders", "INSERT INTO items VALUES ('widget'), ('gadget')")
+			backup, err := TakeBackup(adminContext(), "orders")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := ShipBackups(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, k := range []string{backupKey(backup, sqlite), backupKey(backup, ".json")} {
+				r, err := store.Get(context.Background(), k)
+				if err != nil {
+					t.Fatalf("%s wasn't shipped: %v", k, err)
+				}
+				data, _ := io.ReadAll(r)
+				if key != "" && (bytes.Contains(data, []byte("widget")) || bytes.Contains(data, []byte(backup.ID))) {
+					t.Errorf("%s is stored in the clear", k)
+				}
+			}
+
+			// lose the node: the database and every local backup
+			if err := purge("orders"); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.RemoveAll(backupDir); err != nil {
+				t.Fatal(err)
+			}
+
+			found, err := FindBackup(adminContext(), "orders", "", time.Time{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found.ID != backup.ID || found.SHA256 != backup.SHA256 {
+				t.Fatalf("found backup %+v, want %+v", found, backup)
+			}
+			if err := restore(t, "orders", found, time.Time{}); err != nil {
+				t.Fatal(err)
+			}
+			if n := count(t, "orders", "items"); n != 2 {
+				t.Errorf("restored %d rows, want 2", n)
+			}
+		})
+	}
+}
+
+func TestShipBackupsOnlyShipsWhatIsMissing(t *testing.T) {
+	useDirs(t)
+	store := &countingStore{Store: storage.NewMem()}
+	useStore(t, store, "", BackupRetention{})
+
+	if err := Create(adminContext(), "ledger", "CREATE TABLE entries (amount INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+	first, err := TakeBackup(adminContext(), "ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ShipBackups(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	second, err := TakeBackup(adminContext(), "ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ShipBackups(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, backup := range []Backup{first, second} {
+		for _, key := range []string{backupKey(backup, sqlite), backupKey(backup, ".json")} {
+			if n := store.puts[key]; n != 1 {
+				t.Errorf("%s was put %d times, want once", key, n)
+			}
+		}
+	}
+}
+
+func TestShipBackupsPrunesStore(t *testing.T) {
+	useDirs(t)
+	store := storage.NewMem()
+	useStore(t, store, "", BackupRetention{Keep: 1})
+
+	if err := Create(adminContext(), "metrics", "CREATE TABLE samples (value REAL)"); err != nil {
+		t.Fatal(err)
+	}
+	var backups []Backup
+	for range 3 {
+		backup, err := TakeBackup(adminContext(), "metrics")
+		if err != nil {
+			t.Fatal(err)
+		}
+		backups = append(backups, backup)
+		if err := ShipBackups(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stored, err := currentStore().storedBackups(context.Background(), "metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 1 || stored[0].ID != backups[2].ID {
+		t.Fatalf("store kept %+v, want only %s", stored, backups[2].ID)
+	}
+	if _, err := store.Get(context.Background(), backupKey(backups[0], sqlite)); !errors.Is(err, storage.ErrNotExist) {
+		t.Errorf("pruned backup is still stored: %v", err)
+	}
+}
+
+func TestShipBackupsDoesNotBlockListing(t *testing.T) {
+	useDirs(t)
+	store := &countingStore{Store: storage.NewMem()}
+	useStore(t, store, "", BackupRetention{})
+
+	if err := Create(adminContext(), "events", "CREATE TABLE log (message TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := TakeBackup(adminContext(), "events"); err != nil {
+		t.Fatal(err)
+	}
+
+	store.held.Lock()
+	shipped := make(chan error, 1)
+	go func() { shipped <- ShipBackups(context.Background()) }()
+
+	listed := make(chan error, 1)
+	go func() {
+		_, err := Backups(adminContext(), "events")
+		listed <- err
+	}()
+	select {
+	case err := <-listed:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("listing backups waited for the upload")
+	}
+
+	store.held.Unlock()
+	if err := <-shipped; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenStoredRejectsTampering(t *testing.T) {
+	useDirs(t)
+	store := storage.NewMem()
+	useStore(t, store, strings.Repeat("cd", keySize), BackupRetention{})
+	b := currentStore()
+
+	data := bytes.Repeat([]byte("page"), storeChunk/2) // two chunks
+	put := func(key string, data []byte) {
+		if err := store.Put(context.Background(), key, bytes.NewReader(data), int64(len(data))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sealed, err := io.ReadAll(b.sealObject(bytes.NewReader(data), "db/1.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(sealed)) != b.storedSize(int64(len(data))) {
+		t.Fatalf("sealed %d bytes, storedSize says %d", len(sealed), b.storedSize(int64(len(data))))
+	}
+
+	put("db/1.sqlite", sealed)
+	got, err := b.fetch(context.Background(), "db/1.sqlite")
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("fetch = %d bytes, %v", len(got), err)
+	}
+
+	for name, stored := range map[string][]byte{
+		"flipped":   append(bytes.Clone(sealed[:len(sealed)-1]), sealed[len(sealed)-1]^1),
+		"truncated": sealed[:len(sealed)-b.aead.Overhead()-1],
+		"plain":     data,
+	} {
+		put("db/1.sqlite", stored)
+		if _, err := b.fetch(context.Background(), "db/1.sqlite"); err == nil {
+			t.Errorf("%s object was read", name)
+		}
+	}
+
+	// an object sealed for another key can't be passed off as this one
+	put("db/2.sqlite", sealed)
+	if _, err := b.fetch(context.Background(), "db/2.sqlite"); err == nil {
+		t.Error("object moved to another key was read")
+	}
+}
+
+func TestSidecarShippedAfterSnapshot(t *testing.T) {
+	useDirs(t)
+	store := &orderStore{Store: storage.NewMem()}
+	useStore(t, store, "", BackupRetention{})
+
+	if err := Create(adminContext(), "orders", "CREATE TABLE items (name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	backup, err := TakeBackup(adminContext(), "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ShipBackups(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, sidecar := -1, -1
+	for i, key := range store.order {
+		switch key {
+		case backupKey(backup, sqlite):
+			snapshot = i
+		case backupKey(backup, ".json"):
+			sidecar = i
+		}
+	}
+	if snapshot < 0 || sidecar < snapshot {
+		t.Fatalf("shipped in order %v, the sidecar must follow its snapshot", store.order)
+	}
+
+	var meta Backup
+	data, err := currentStore().fetch(context.Background(), backupKey(backup, ".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &meta); err != nil || meta.ID != backup.ID {
+		t.Fatalf("sidecar %s: %v", data, err)
+	}
+}
+
+// orderStore records the order objects are put in.
+type orderStore struct {
+	storage.Store
+	order []string
+}
+
+func (s *orderStore) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	s.order = append(s.order, key)
+	return s.Store.Put(ctx, key, r, size)
+}
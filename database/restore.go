@@ -0,0 +1,203 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/trianglehasfoursides/bedroompop/storage"
+)
+
+// A restore builds a new database from a backup, rolled forward with the
+// archived WAL up to the moment asked for, next to the data directory and
+// only moves it in place once SQLite finds it intact.
+
+var (
+	ErrBackupNotFound = errors.New("backup not found")
+	ErrInvalidRestore = errors.New("invalid restore")
+)
+
+// FindBackup finds the backup of a database to restore by id, or without
+// one the newest taken at or before until. A zero until restores the backup
+// as taken.
+func FindBackup(ctx context.Context, databaseName string, id string, until time.Time) (Backup, error) {
+	backups, err := Backups(ctx, databaseName)
+	if err != nil {
+		return Backup{}, err
+	}
+	for _, backup := range backups {
+		if id != "" && backup.ID != id || id == "" && !until.IsZero() && backup.Created.After(until) {
+			continue
+		}
+		if !until.IsZero() {
+			if backup.Encrypted {
+				return Backup{}, fmt.Errorf("%w: encrypted databases can only be restored as backed up", ErrInvalidRestore)
+			}
+			if until.Before(backup.Created) {
+				return Backup{}, fmt.Errorf("%w: backup %s was taken after %s", ErrInvalidRestore, backup.ID, until.Format(time.RFC3339Nano))
+			}
+		}
+		return backup, nil
+	}
+	return Backup{}, ErrBackupNotFound
+}
+
+// ExportRecovery emits what restoring a backup needs: its file as
+// "snapshot", followed by every WAL generation to replay on it in order.
+func ExportRecovery(ctx context.Context, backup Backup, until time.Time, emit func(file string, r io.Reader) error) error {
+	file, err := openBackup(ctx, backupKey(backup, sqlite))
+	if errors.Is(err, storage.ErrNotExist) {
+		return ErrBackupNotFound
+	}
+	if err != nil {
+		return err
+	}
+	err = emit("snapshot", file)
+	file.Close()
+	if err != nil || until.IsZero() {
+		return err
+	}
+	return walSince(ctx, backup.Database, backup.Created, until, emit)
+}
+
+// RestoreFrom creates the database target from the file of a backup and the
+// WAL generations to replay on it, as emitted by ExportRecovery.
+func RestoreFrom(ctx context.Context, target string, snapshot string, wals []string, backup Backup) error {
+	targetPath, err := filePath(target)
+	if err != nil {
+		return err
+	}
+	if err := Get(target); err == nil {
+		return ErrExists
+	}
+	if err := verifyBackup(snapshot, backup); err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(filepath.Dir(snapshot), "restore"+sqlite)
+	defer func() {
+		for _, suffix := range []string{"", "-wal", "-shm"} {
+			os.Remove(tmp + suffix)
+		}
+	}()
+	if err := copyFile(snapshot, tmp); err != nil {
+		return err
+	}
+
+	if backup.Encrypted {
+		image, _, err := readSealed(tmp)
+		if err != nil {
+			return err
+		}
+		if err := checkIntegrity(ctx, ":memory:", image); err != nil {
+			return err
+		}
+	} else {
+		if err := rollForward(ctx, tmp, wals); err != nil {
+			return err
+		}
+		if err := checkIntegrity(ctx, tmp, nil); err != nil {
+			return err
+		}
+	}
+
+	unlock := lock(target, true)
+	defer unlock()
+
+	forgetWAL(target, targetPath)
+	// linking fails rather than replace a database created meanwhile
+	if err := os.Link(tmp, targetPath); errors.Is(err, os.ErrExist) {
+		return ErrExists
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// verifyBackup checks the file of a backup against its checksum.
+func verifyBackup(path string, backup Backup) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return err
+	}
+	if hex.EncodeToString(h.Sum(nil)) != backup.SHA256 {
+		return fmt.Errorf("backup %s doesn't match its checksum", backup.ID)
+	}
+	return nil
+}
+
+// rollForward replays WAL generations on a plain database, checkpointing
+// each into the file before the next one.
+func rollForward(ctx context.Context, path string, wals []string) error {
+	if err := execFile(path, `PRAGMA journal_mode=WAL`); err != nil {
+		return err
+	}
+	for _, wal := range wals {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		os.Remove(path + "-shm")
+		if err := copyFile(wal, path+"-wal"); err != nil {
+			return err
+		}
+		if err := execFile(path, `PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func execFile(path string, stmt string) error {
+	conn, err := (&sqlite3.SQLiteDriver{}).Open(path)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.(*sqlite3.SQLiteConn).Exec(stmt, nil)
+	return err
+}
+
+// checkIntegrity runs PRAGMA integrity_check on the database at path, or
+// on image when it is set.
+func checkIntegrity(ctx context.Context, path string, image []byte) error {
+	conn, err := (&sqlite3.SQLiteDriver{}).Open(path)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if image != nil {
+		if err := conn.(*sqlite3.SQLiteConn).Deserialize(image, "main"); err != nil {
+			return err
+		}
+	}
+
+	rows, err := conn.(*sqlite3.SQLiteConn).QueryContext(ctx, `PRAGMA integrity_check`, nil)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	dest := make([]driver.Value, 1)
+	if err := rows.Next(dest); err != nil {
+		return err
+	}
+	if result, _ := dest[0].(string); result != "ok" {
+		return fmt.Errorf("restored database is corrupt: %v", dest[0])
+	}
+	return nil
+}
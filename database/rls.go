@@ -0,0 +1,283 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Row-level security policies are stored in the tenant database itself, so
+// they travel with it. For principals without the admin role every protected
+// table is shadowed by a temporary view of the same name that only returns
+// the rows matching the policy. Unqualified names resolve to the temporary
+// schema first, so reads keep working unchanged. Writes are pointed back at
+// the real table, where temporary triggers skip the rows outside the policy
+// and reject new rows failing its check. Naming the real table through main
+// is rejected. Triggers are recursive on these connections, so the rows a
+// REPLACE conflict deletes go through the delete trigger too.
+//
+// Policies are SQL expressions over the columns of the table and may read the
+// session of the principal through current_user() and current_setting(name).
+
+// AdminRole bypasses row-level security and may manage policies.
+const AdminRole = "admin"
+
+const rlsTable = "_rls_policies"
+
+var ErrInvalidPolicy = errors.New("invalid row policy")
+
+// RowPolicy restricts the rows of a table. Check applies to written rows and defaults to Using.
+type RowPolicy struct {
+	Table string `json:"table"`
+	Using string `json:"using"`
+	Check string `json:"check"`
+}
+
+// registerSession makes the principal available to SQL.
+func registerSession(conn *sqlite3.SQLiteConn, principal Principal) error {
+	if err := conn.RegisterFunc("current_user", func() string {
+		return principal.Name
+	}, false); err != nil {
+		return err
+	}
+	return conn.RegisterFunc("current_setting", func(name string) any {
+		if v, ok := principal.Vars[name]; ok {
+			return v
+		}
+		return nil
+	}, false)
+}
+
+// rowPolicies reads the policies stored in the database of conn.
+func rowPolicies(conn *sqlite3.SQLiteConn) ([]RowPolicy, error) {
+	if _, err := tableColumns(conn, rlsTable); err != nil {
+		// a database without the policy table has no policies
+		return nil, nil
+	}
+
+	rows, err := conn.Query(`SELECT table_name, using_expr, check_expr FROM main.`+rlsTable, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var policies []RowPolicy
+	values := make([]driver.Value, 3)
+	for {
+		if err := rows.Next(values); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		policy := RowPolicy{}
+		policy.Table, _ = values[0].(string)
+		policy.Using, _ = values[1].(string)
+		policy.Check, _ = values[2].(string)
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+func tableColumns(conn *sqlite3.SQLiteConn, table string) ([]string, error) {
+	rows, err := conn.Query(`SELECT name FROM pragma_table_info(?, 'main')`, []driver.Value{table})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	values := make([]driver.Value, 1)
+	for {
+		if err := rows.Next(values); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		name, _ := values[0].(string)
+		columns = append(columns, name)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no such table: %s", table)
+	}
+	return columns, nil
+}
+
+// enforce shadows every protected table with its filtered view. It returns the protected tables.
+func enforce(conn *sqlite3.SQLiteConn) ([]string, error) {
+	policies, err := rowPolicies(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var tables []string
+	for _, policy := range policies {
+		columns, err := tableColumns(conn, policy.Table)
+		if err != nil {
+			// the table was dropped, its policy doesn't matter anymore
+			continue
+		}
+		for _, stmt := range shadow(policy, columns) {
+			if _, err := conn.Exec(stmt, nil); err != nil {
+				return nil, fmt.Errorf("can't enforce policy on %s: %w", policy.Table, err)
+			}
+		}
+		tables = append(tables, policy.Table)
+	}
+	return tables, nil
+}
+
+// shadow builds the view and triggers enforcing a policy.
+func shadow(policy RowPolicy, columns []string) []string {
+	table := quoteIdent(policy.Table)
+	check := policy.Check
+	if check == "" {
+		check = policy.Using
+	}
+	violation := quoteString("new row violates row-level security policy for table " + policy.Table)
+
+	// matches evaluates expr against the OLD or NEW row of a trigger
+	matches := func(row string, expr string) string {
+		var values []string
+		for _, c := range columns {
+			values = append(values, row+"."+quoteIdent(c)+" AS "+quoteIdent(c))
+		}
+		return `EXISTS (SELECT 1 FROM (SELECT ` + strings.Join(values, ", ") + `) AS ` + table + ` WHERE (` + expr + `))`
+	}
+	trigger := func(op string) string {
+		return quoteIdent("_rls_" + policy.Table + "_" + op)
+	}
+
+	return []string{
+		`CREATE TEMP VIEW ` + table + ` AS SELECT * FROM main.` + table + ` WHERE (` + policy.Using + `)`,
+		`CREATE TEMP TRIGGER ` + trigger("insert") + ` BEFORE INSERT ON main.` + table + ` BEGIN ` +
+			`SELECT RAISE(ABORT, ` + violation + `) WHERE NOT ` + matches("NEW", check) + `; END`,
+		`CREATE TEMP TRIGGER ` + trigger("update") + ` BEFORE UPDATE ON main.` + table + ` BEGIN ` +
+			`SELECT RAISE(IGNORE) WHERE NOT ` + matches("OLD", policy.Using) + `; ` +
+			`SELECT RAISE(ABORT, ` + violation + `) WHERE NOT ` + matches("NEW", check) + `; END`,
+		`CREATE TEMP TRIGGER ` + trigger("delete") + ` BEFORE DELETE ON main.` + table + ` BEGIN ` +
+			`SELECT RAISE(IGNORE) WHERE NOT ` + matches("OLD", policy.Using) + `; END`,
+	}
+}
+
+func quoteString(s string) string {
+	return `'` + strings.ReplaceAll(s, `'`, `''`) + `'`
+}
+
+// session registers the session functions on a new connection, applies the
+// limits of the policy and, unless the principal is an admin, shadows the
+// protected tables.
+func (a *authorizer) session(conn *sqlite3.SQLiteConn) (err error) {
+	if err := registerSession(conn, a.principal); err != nil {
+		return err
+	}
+
+	// a handle may be trusted as a whole, see internal
+	defer func(setup bool) { a.setup = setup }(a.setup)
+	a.setup = true
+	if err := a.limitSession(conn); err != nil {
+		return err
+	}
+	if a.principal.Role == AdminRole {
+		return nil
+	}
+	if a.protected, err = enforce(conn); err != nil || len(a.protected) == 0 {
+		return err
+	}
+	// the rows a REPLACE deletes only go through the delete triggers when
+	// triggers are recursive, otherwise it overwrites rows outside the policy
+	_, err = conn.Exec(`PRAGMA recursive_triggers = ON`, nil)
+	return err
+}
+
+// guard rejects statements that reach a protected table through main instead
+// of its view, and points writes to protected tables at the real table.
+func (a *authorizer) guard(query string) (string, error) {
+	for _, table := range a.protected {
+		if qualified(query, "main", table) {
+			Denials.Add("rls", 1)
+			return "", fmt.Errorf("%w: table %s is protected by row-level security", ErrDenied, table)
+		}
+	}
+	if len(a.protected) == 0 {
+		return query, nil
+	}
+	return retarget(query, "main", a.protected), nil
+}
+
+// SetRowPolicy attaches a policy to a table, replacing the previous one. Only admins may manage policies.
+func SetRowPolicy(ctx context.Context, databaseName string, policy RowPolicy) error {
+	if PrincipalFrom(ctx).Role != AdminRole {
+		return fmt.Errorf("%w: only admins can manage row-level security", ErrDenied)
+	}
+	if policy.Table == "" || policy.Using == "" {
+		return fmt.Errorf("%w: a policy needs a table and a using expression", ErrInvalidPolicy)
+	}
+	if err := Get(databaseName); err != nil {
+		return err
+	}
+
+	db, err := open(ctx, databaseName)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	txn, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	// make sure the table exists and the expressions compile against it
+	table := quoteIdent(policy.Table)
+	probe := `SELECT 1 FROM main.` + table + ` WHERE (` + policy.Using + `)`
+	if policy.Check != "" {
+		probe += ` AND (` + policy.Check + `)`
+	}
+	if _, err := txn.ExecContext(ctx, probe+` LIMIT 0`); err != nil {
+		return db.auth.check(err)
+	}
+
+	if _, err := txn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS main.`+rlsTable+
+		` (table_name TEXT PRIMARY KEY, using_expr TEXT NOT NULL, check_expr TEXT NOT NULL DEFAULT '')`); err != nil {
+		return err
+	}
+	if _, err := txn.ExecContext(ctx, `INSERT OR REPLACE INTO main.`+rlsTable+` VALUES (?, ?, ?)`,
+		policy.Table, policy.Using, policy.Check); err != nil {
+		return err
+	}
+
+	if err := txn.Commit(); err != nil {
+		return err
+	}
+	return db.persist(ctx)
+}
+
+// DropRowPolicy removes the policy of a table. Only admins may manage policies.
+func DropRowPolicy(ctx context.Context, databaseName string, table string) error {
+	if PrincipalFrom(ctx).Role != AdminRole {
+		return fmt.Errorf("%w: only admins can manage row-level security", ErrDenied)
+	}
+	if err := Get(databaseName); err != nil {
+		return err
+	}
+
+	db, err := open(ctx, databaseName)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, `DELETE FROM main.`+rlsTable+` WHERE table_name = ?`, table); err != nil {
+		if strings.Contains(err.Error(), "no such table") {
+			return nil
+		}
+		return err
+	}
+	return db.persist(ctx)
+}
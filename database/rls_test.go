@@ -0,0 +1,68 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// tenantContext runs statements as a tenant seeing only the rows of tenant 1.
+func tenantContext() context.Context {
+	return WithPrincipal(context.Background(), Principal{Name: "t1", Role: "user", Vars: map[string]string{"tenant_id": "1"}})
+}
+
+func TestRowPolicyHoldsOnConflicts(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		schema string
+		query  string
+	}{
+		{"insert or replace", "", "INSERT OR REPLACE INTO notes VALUES (2, 1, 'stolen')"},
+		{"replace into", "", "REPLACE INTO notes VALUES (2, 1, 'stolen')"},
+		{"replace constraint", " ON CONFLICT REPLACE", "INSERT INTO notes VALUES (2, 1, 'stolen')"},
+		{"upsert", "", "INSERT INTO notes VALUES (2, 1, 'stolen') ON CONFLICT (id) DO UPDATE SET tenant = 1, body = 'stolen'"},
+		{"update or replace", "", "UPDATE OR REPLACE notes SET id = 2 WHERE id = 1"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			useDirs(t)
+			if err := Create(adminContext(), "notes", "CREATE TABLE notes (id INTEGER PRIMARY KEY"+test.schema+", tenant INTEGER, body TEXT)"); err != nil {
+				t.Fatal(err)
+			}
+			exec(t, "notes", "INSERT INTO notes VALUES (1, 1, 'mine'), (2, 2, 'theirs')")
+			if err := SetRowPolicy(adminContext(), "notes", RowPolicy{Table: "notes", Using: "tenant = CAST(current_setting('tenant_id') AS INTEGER)"}); err != nil {
+				t.Fatal(err)
+			}
+
+			// the row of the other tenant is neither replaced nor updated,
+			// whether the statement fails or does nothing
+			Exec(tenantContext(), "notes", test.query)
+			want := [][]any{{int64(1), int64(1), "mine"}, {int64(2), int64(2), "theirs"}}
+			if got := rowsOf(t, "notes", "SELECT * FROM notes ORDER BY id"); !reflect.DeepEqual(got, want) {
+				t.Errorf("rows are %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestRowPolicyKeepsTriggersRecursive(t *testing.T) {
+	useDirs(t)
+	if err := Create(adminContext(), "notes", "CREATE TABLE notes (id INTEGER PRIMARY KEY, tenant INTEGER, body TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetRowPolicy(adminContext(), "notes", RowPolicy{Table: "notes", Using: "tenant = CAST(current_setting('tenant_id') AS INTEGER)"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Exec(tenantContext(), "notes", "PRAGMA recursive_triggers = OFF"); !errors.Is(err, ErrDenied) {
+		t.Errorf("turning recursive triggers off = %v, want ErrDenied", err)
+	}
+	// replacing a row of its own still works
+	exec(t, "notes", "INSERT INTO notes VALUES (1, 1, 'draft')")
+	if _, err := Exec(tenantContext(), "notes", "REPLACE INTO notes VALUES (1, 1, 'final')"); err != nil {
+		t.Fatal(err)
+	}
+	if got := rowsOf(t, "notes", "SELECT body FROM notes"); !reflect.DeepEqual(got, [][]any{{"final"}}) {
+		t.Errorf("rows are %v, want the replaced row", got)
+	}
+}
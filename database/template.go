@@ -0,0 +1,413 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Templates are database images new databases are created from by copying
+// the file instead of running a migration. A template is published under a
+// name and an immutable version, either as a SQLite file or as a migration,
+// which is run once into an empty database to build its image. Every node
+// keeps every template under templates/<name>/<version>.sqlite in the
+// template directory, next to a JSON sidecar describing it.
+
+// templateDir holds the templates, by template name. It is under the data
+// directory unless set otherwise.
+var templateDir = "templates"
+
+var (
+	ErrTemplateNotFound = errors.New("template not found")
+	ErrInvalidTemplate  = errors.New("invalid template")
+	ErrTemplateExists   = errors.New("template version already exists")
+)
+
+// Sources a template is built from.
+const (
+	TemplateImage     = "image"
+	TemplateMigration = "migration"
+)
+
+var versionRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// sqliteMagic starts every SQLite database file.
+var sqliteMagic = []byte("SQLite format 3\x00")
+
+// Template describes a version of a template.
+type Template struct {
+	Name    string    `json:"name"`
+	Version string    `json:"version"`
+	Source  string    `json:"source"`
+	Created time.Time `json:"created"`
+	Size    int64     `json:"size"`
+	SHA256  string    `json:"sha256"`
+}
+
+// SetTemplateDir creates dir if needed and keeps the templates there.
+func SetTemplateDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	templateDir = dir
+	return nil
+}
+
+// ParseTemplate splits a template reference, name@version or just the name
+// for its newest version.
+func ParseTemplate(ref string) (name string, version string, err error) {
+	name, version, _ = strings.Cut(ref, "@")
+	if err := validateTemplate(name, version); err != nil {
+		return "", "", err
+	}
+	return name, version, nil
+}
+
+func validateTemplate(name string, version string) error {
+	if err := ValidateName(name); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidTemplate, err.Error())
+	}
+	if version != "" && (len(version) > MaxNameLength || !versionRegexp.MatchString(version)) {
+		return fmt.Errorf("%w: version may only contain letters, digits, '.', '_' and '-'", ErrInvalidTemplate)
+	}
+	return nil
+}
+
+// BuildTemplate writes the image of a new template version to path, from r
+// holding either a SQLite file or a migration.
+func BuildTemplate(ctx context.Context, name string, version string, r io.Reader, path string) (Template, error) {
+	if PrincipalFrom(ctx).Role != AdminRole {
+		return Template{}, fmt.Errorf("%w: only admins can publish templates", ErrDenied)
+	}
+	if version == "" {
+		return Template{}, fmt.Errorf("%w: a template needs a version", ErrInvalidTemplate)
+	}
+	if err := validateTemplate(name, version); err != nil {
+		return Template{}, err
+	}
+	if _, err := FindTemplate(name, version); err == nil {
+		return Template{}, fmt.Errorf("%w: %s@%s", ErrTemplateExists, name, version)
+	}
+
+	template := Template{Name: name, Version: version, Source: TemplateImage}
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(len(sqliteMagic)); !bytes.Equal(magic, sqliteMagic) {
+		template.Source = TemplateMigration
+	}
+
+	if template.Source == TemplateImage {
+		file, err := os.Create(path)
+		if err != nil {
+			return Template{}, err
+		}
+		_, err = io.Copy(file, br)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			// images are kept out of WAL mode, so they can be copied alone
+			// and deserialized when databases are encrypted
+			err = execFile(path, `PRAGMA journal_mode=DELETE`)
+		}
+		if err != nil {
+			return Template{}, fmt.Errorf("%w: %s", ErrInvalidTemplate, err.Error())
+		}
+		if err := checkIntegrity(ctx, path, nil); err != nil {
+			return Template{}, fmt.Errorf("%w: %s", ErrInvalidTemplate, err.Error())
+		}
+	} else {
+		migration, err := io.ReadAll(br)
+		if err != nil {
+			return Template{}, err
+		}
+		image, err := buildImage(ctx, string(migration))
+		if err != nil {
+			return Template{}, err
+		}
+		if err := os.WriteFile(path, image, 0o600); err != nil {
+			return Template{}, err
+		}
+	}
+
+	template.Created = time.Now().UTC()
+	var err error
+	template.Size, template.SHA256, err = fileDigest(path)
+	return template, err
+}
+
+// buildImage runs a migration into an empty in-memory database under the
+// policy of the principal and returns the image it leaves.
+func buildImage(ctx context.Context, migration string) ([]byte, error) {
+	auth := &authorizer{
+		policy:    policyFor("", PrincipalFrom(ctx).Role),
+		principal: PrincipalFrom(ctx),
+	}
+	defer func() {
+		if auth.release != nil {
+			auth.release()
+		}
+	}()
+	db := sql.OpenDB(&connector{auth: auth, image: []byte{}})
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, migration); err != nil {
+		if err = auth.check(err); errors.Is(err, ErrDenied) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: can't run migration: %s", ErrInvalidTemplate, err.Error())
+	}
+	var image []byte
+	err = conn.Raw(func(dc any) (err error) {
+		image, err = dc.(*sqlite3.SQLiteConn).Serialize("main")
+		return
+	})
+	return image, err
+}
+
+func fileDigest(path string) (size int64, sum string, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if size, err = io.Copy(h, file); err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// PutTemplate keeps a template version on this node, reading its image from
+// r. Versions can't change once published, so putting one again only
+// succeeds with the same image.
+func PutTemplate(template Template, r io.Reader) error {
+	if template.Version == "" {
+		return fmt.Errorf("%w: a template needs a version", ErrInvalidTemplate)
+	}
+	if err := validateTemplate(template.Name, template.Version); err != nil {
+		return err
+	}
+	if existing, err := FindTemplate(template.Name, template.Version); err == nil {
+		if existing.SHA256 != template.SHA256 {
+			return fmt.Errorf("%w: %s@%s was published with another image", ErrTemplateExists, template.Name, template.Version)
+		}
+		return nil
+	}
+
+	dir := filepath.Join(templateDir, template.Name)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, template.Version+sqlite)
+	tmp := path + ".tmp"
+	defer os.Remove(tmp)
+
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, r)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if _, sum, err := fileDigest(tmp); err != nil {
+		return err
+	} else if sum != template.SHA256 {
+		return fmt.Errorf("%w: template %s@%s doesn't match its checksum", ErrInvalidTemplate, template.Name, template.Version)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	meta, _ := json.MarshalIndent(template, "", "  ")
+	if err := writeSynced(filepath.Join(dir, template.Version+".json"), meta); err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+// Templates lists the template versions kept on this node, by name and then
+// from the oldest version to the newest.
+func Templates() ([]Template, error) {
+	paths, err := filepath.Glob(filepath.Join(templateDir, "*", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	templates := []Template{}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var template Template
+		if err := json.Unmarshal(data, &template); err != nil {
+			return nil, fmt.Errorf("invalid template sidecar %s: %w", path, err)
+		}
+		templates = append(templates, template)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		if templates[i].Name != templates[j].Name {
+			return templates[i].Name < templates[j].Name
+		}
+		return templates[i].Created.Before(templates[j].Created)
+	})
+	return templates, nil
+}
+
+// FindTemplate finds a version of a template, or its newest one when the
+// version is empty.
+func FindTemplate(name string, version string) (Template, error) {
+	if err := validateTemplate(name, version); err != nil {
+		return Template{}, err
+	}
+	templates, err := Templates()
+	if err != nil {
+		return Template{}, err
+	}
+
+	found := false
+	var template Template
+	for _, t := range templates {
+		if t.Name == name && (version == "" || t.Version == version) {
+			template, found = t, true
+		}
+	}
+	if !found {
+		return Template{}, ErrTemplateNotFound
+	}
+	return template, nil
+}
+
+// OpenTemplate opens the image of a template version.
+func OpenTemplate(template Template) (*os.File, error) {
+	file, err := os.Open(filepath.Join(templateDir, template.Name, template.Version+sqlite))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrTemplateNotFound
+	}
+	return file, err
+}
+
+// DeleteTemplate removes a template version from this node. Databases
+// created from it are left alone.
+func DeleteTemplate(ctx context.Context, name string, version string) error {
+	if PrincipalFrom(ctx).Role != AdminRole {
+		return fmt.Errorf("%w: only admins can delete templates", ErrDenied)
+	}
+	if version == "" {
+		return fmt.Errorf("%w: a template needs a version", ErrInvalidTemplate)
+	}
+	template, err := FindTemplate(name, version)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(templateDir, template.Name)
+	if err := os.Remove(filepath.Join(dir, template.Version+".json")); err != nil {
+		return err
+	}
+	os.Remove(filepath.Join(dir, template.Version+sqlite))
+	os.Remove(dir) // only once its last version is gone
+	return nil
+}
+
+// CreateFromTemplate creates a database as a copy of the image of a
+// template, encrypted under a data key of its own when a master key is
+// loaded, and then runs the migration if there is one. The copy is written
+// next to the database and linked into place under its lock, so the
+// database is never seen half copied.
+func CreateFromTemplate(ctx context.Context, databaseName string, template Template, migration string) error {
+	databasePath, err := filePath(databaseName)
+	if err != nil {
+		return err
+	}
+	src, err := OpenTemplate(template)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(databasePath), filepath.Base(databasePath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if Encryption() {
+		tmp.Close()
+		err = sealTemplate(tmp.Name(), src)
+	} else {
+		_, err = io.Copy(tmp, src)
+		if err == nil {
+			err = tmp.Sync()
+		}
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	// a link fails if the database exists, where a rename would replace it
+	unlock := lock(databaseName, true)
+	err = os.Link(tmp.Name(), databasePath)
+	unlock()
+	if errors.Is(err, os.ErrExist) {
+		return ErrExists
+	}
+	if err != nil {
+		return err
+	}
+
+	if migration != "" {
+		if err := migrate(ctx, databaseName, migration); err != nil {
+			purge(databaseName)
+			return err
+		}
+	}
+	return nil
+}
+
+// sealTemplate writes the image of a template encrypted to path.
+func sealTemplate(path string, src io.Reader) error {
+	image, err := io.ReadAll(src)
+	if err != nil {
+		return err
+	}
+	dataKey, err := newDataKey()
+	if err != nil {
+		return err
+	}
+	return writeSealed(path, image, dataKey)
+}
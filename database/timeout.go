@@ -0,0 +1,28 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// maxQueryTimeout caps how long a statement may run on this node, zero for no cap.
+var maxQueryTimeout time.Duration
+
+// SetMaxQueryTimeout sets the longest a statement may run on this node. Zero removes the cap.
+func SetMaxQueryTimeout(timeout time.Duration) {
+	maxQueryTimeout = timeout
+}
+
+// WithTimeout bounds ctx by the timeout asked for a request, capped by the
+// maximum of the node. A zero timeout asks for the maximum. Statements run
+// under the returned context are interrupted once it is done, which also
+// happens when the client goes away.
+func WithTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 || maxQueryTimeout > 0 && timeout > maxQueryTimeout {
+		timeout = maxQueryTimeout
+	}
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
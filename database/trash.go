@@ -0,0 +1,316 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// Dropped databases are moved to the trash on the node owning them, under
+// trash/<database>/<id>/ in the trash directory next to a JSON sidecar, and
+// can be restored until the retention passes and they are purged. The WAL
+// of a plain database moves along with it, so nothing it committed is lost.
+// The trash has to be on the filesystem of the data directory, as files are
+// moved in and out of it by renaming them.
+
+// trashDir holds the dropped databases, by database name. It is under the
+// data directory unless set otherwise.
+var trashDir = "trash"
+
+// trashRetention is how long dropped databases are kept, 0 to delete them
+// when they are dropped.
+var trashRetention time.Duration
+
+var ErrTrashNotFound = errors.New("dropped database not found")
+
+// TrashEntry describes a dropped database.
+type TrashEntry struct {
+	Name    string    `json:"name"`
+	ID      string    `json:"id"`
+	Dropped time.Time `json:"dropped"`
+	Expires time.Time `json:"expires"`
+	Size    int64     `json:"size"`
+}
+
+// SetTrashDir creates dir if needed and keeps dropped databases there.
+func SetTrashDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	trashDir = dir
+	return nil
+}
+
+// SetTrashRetention sets how long dropped databases are kept.
+func SetTrashRetention(retention time.Duration) {
+	trashRetention = retention
+}
+
+// Drop moves a database to the trash, or deletes it when the trash keeps
+// nothing.
+func Drop(databaseName string) error {
+	if trashRetention <= 0 {
+		return purge(databaseName)
+	}
+	databasePath, err := filePath(databaseName)
+	if err != nil {
+		return err
+	}
+
+	closeCursors(databaseName)
+
+	unlock := lock(databaseName, true)
+	defer unlock()
+
+	info, err := os.Stat(databasePath)
+	if err != nil {
+		return ErrNotFound
+	}
+	entry, dir, err := newTrashEntry(databaseName, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	entry.Size = info.Size()
+
+	// the entry is written first, so a drop cut short leaves an entry
+	// behind that expires, never a database the trash doesn't know about
+	meta, _ := json.MarshalIndent(entry, "", "  ")
+	if err := writeSynced(filepath.Join(dir, "entry.json"), meta); err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+
+	// the WAL and the base of a branch go first, so a database is never
+	// left behind without them
+	wal := filepath.Join(dir, databaseName+sqlite+"-wal")
+	base := filepath.Join(dir, databaseName+sqlite+baseSuffix)
+	if err := os.Rename(databasePath+"-wal", wal); err != nil && !errors.Is(err, os.ErrNotExist) {
+		os.RemoveAll(dir)
+		return err
+	}
+	if err := os.Rename(databasePath+baseSuffix, base); err != nil && !errors.Is(err, os.ErrNotExist) {
+		os.Rename(wal, databasePath+"-wal")
+		os.RemoveAll(dir)
+		return err
+	}
+	if err := os.Rename(databasePath, filepath.Join(dir, databaseName+sqlite)); err != nil {
+		os.Rename(wal, databasePath+"-wal")
+		os.Rename(base, databasePath+baseSuffix)
+		os.RemoveAll(dir)
+		return err
+	}
+	forgetWAL(databaseName, databasePath)
+	return nil
+}
+
+// newTrashEntry makes the directory of a new entry in the trash. Its id is
+// the time of the drop, moved on by a nanosecond until no other entry has it,
+// so two drops never share a directory.
+func newTrashEntry(databaseName string, dropped time.Time) (TrashEntry, string, error) {
+	parent := filepath.Join(trashDir, databaseName)
+	for {
+		// made again each time, as expiring the last entry removes it
+		if err := os.MkdirAll(parent, 0o700); err != nil {
+			return TrashEntry{}, "", err
+		}
+		entry := TrashEntry{
+			Name:    databaseName,
+			ID:      dropped.Format("20060102T150405.000000000Z"),
+			Dropped: dropped,
+		}
+		dir := filepath.Join(parent, entry.ID)
+		err := os.Mkdir(dir, 0o700)
+		if err == nil {
+			return entry, dir, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return TrashEntry{}, "", err
+		}
+		dropped = dropped.Add(time.Nanosecond)
+	}
+}
+
+// purge deletes a database for good.
+func purge(databaseName string) error {
+	databasePath, err := filePath(databaseName)
+	if err != nil {
+		return err
+	}
+
+	closeCursors(databaseName)
+
+	unlock := lock(databaseName, true)
+	defer unlock()
+
+	if err := os.Remove(databasePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	os.Remove(databasePath + baseSuffix)
+	forgetWAL(databaseName, databasePath)
+	return nil
+}
+
+// Trash lists the dropped databases on this node, by name and then from the
+// oldest drop to the newest.
+func Trash() ([]TrashEntry, error) {
+	return listTrash(false)
+}
+
+// listTrash reads the entries of the trash. Entries without a database, left
+// by a drop that was cut short or still going on, are only listed when
+// incomplete is set, so they can expire.
+func listTrash(incomplete bool) ([]TrashEntry, error) {
+	paths, err := filepath.Glob(filepath.Join(trashDir, "*", "*", "entry.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []TrashEntry{}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var entry TrashEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil, fmt.Errorf("invalid trash sidecar %s: %w", path, err)
+		}
+		if !incomplete {
+			if _, err := os.Stat(filepath.Join(filepath.Dir(path), entry.Name+sqlite)); errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+		}
+		entry.Expires = entry.Dropped.Add(trashRetention)
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Name != entries[j].Name {
+			return entries[i].Name < entries[j].Name
+		}
+		return entries[i].Dropped.Before(entries[j].Dropped)
+	})
+	return entries, nil
+}
+
+// trashed finds the entries of a dropped database, or only the one with id
+// when it is set.
+func trashed(databaseName string, id string) ([]TrashEntry, error) {
+	if err := ValidateName(databaseName); err != nil {
+		return nil, err
+	}
+	entries, err := Trash()
+	if err != nil {
+		return nil, err
+	}
+	var found []TrashEntry
+	for _, entry := range entries {
+		if entry.Name == databaseName && (id == "" || entry.ID == id) {
+			found = append(found, entry)
+		}
+	}
+	if len(found) == 0 {
+		return nil, ErrTrashNotFound
+	}
+	return found, nil
+}
+
+// Undrop moves a dropped database back in place, the one dropped last unless
+// id names another. It fails if a database of the same name was created
+// since.
+func Undrop(ctx context.Context, databaseName string, id string) (TrashEntry, error) {
+	if PrincipalFrom(ctx).Role != AdminRole {
+		return TrashEntry{}, fmt.Errorf("%w: only admins can restore dropped databases", ErrDenied)
+	}
+	entries, err := trashed(databaseName, id)
+	if err != nil {
+		return TrashEntry{}, err
+	}
+	entry := entries[len(entries)-1]
+	databasePath, err := filePath(databaseName)
+	if err != nil {
+		return TrashEntry{}, err
+	}
+
+	unlock := lock(databaseName, true)
+	defer unlock()
+
+	dir := filepath.Join(trashDir, databaseName, entry.ID)
+	wal := filepath.Join(dir, databaseName+sqlite+"-wal")
+	base := filepath.Join(dir, databaseName+sqlite+baseSuffix)
+	if _, err := os.Stat(databasePath); err == nil {
+		return TrashEntry{}, ErrExists
+	}
+	forgetWAL(databaseName, databasePath)
+	if err := os.Rename(wal, databasePath+"-wal"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return TrashEntry{}, err
+	}
+	if err := os.Rename(base, databasePath+baseSuffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+		os.Rename(databasePath+"-wal", wal)
+		return TrashEntry{}, err
+	}
+	// linking fails rather than replace a database created meanwhile
+	if err := os.Link(filepath.Join(dir, databaseName+sqlite), databasePath); err != nil {
+		os.Rename(databasePath+"-wal", wal)
+		os.Rename(databasePath+baseSuffix, base)
+		if errors.Is(err, os.ErrExist) {
+			return TrashEntry{}, ErrExists
+		}
+		return TrashEntry{}, err
+	}
+	removeTrash(entry)
+	return entry, nil
+}
+
+// PurgeTrash deletes the dropped copies of a database for good, or only the
+// one with id when it is set.
+func PurgeTrash(ctx context.Context, databaseName string, id string) ([]TrashEntry, error) {
+	if PrincipalFrom(ctx).Role != AdminRole {
+		return nil, fmt.Errorf("%w: only admins can purge dropped databases", ErrDenied)
+	}
+	entries, err := trashed(databaseName, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if err := removeTrash(entry); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+func removeTrash(entry TrashEntry) error {
+	dir := filepath.Join(trashDir, entry.Name)
+	if err := os.RemoveAll(filepath.Join(dir, entry.ID)); err != nil {
+		return err
+	}
+	os.Remove(dir) // only once its last entry is gone
+	return nil
+}
+
+// ExpireTrash purges the dropped databases older than the retention once
+// per interval.
+func ExpireTrash(interval time.Duration) {
+	for range time.Tick(interval) {
+		entries, err := listTrash(true)
+		if err != nil {
+			log.Error("can't list trash", "err", err)
+			continue
+		}
+		for _, entry := range entries {
+			if time.Now().Before(entry.Expires) {
+				continue
+			}
+			if err := removeTrash(entry); err != nil {
+				log.Error("can't purge dropped database", "database", entry.Name, "id", entry.ID, "err", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDropKeepsEveryCopy(t *testing.T) {
+	useDirs(t)
+	SetTrashRetention(time.Hour)
+	t.Cleanup(func() { SetTrashRetention(0) })
+
+	const drops = 20
+	for range drops {
+		if err := Create(adminContext(), "scratch", "CREATE TABLE notes (body TEXT)"); err != nil {
+			t.Fatal(err)
+		}
+		if err := Drop("scratch"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := Trash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != drops {
+		t.Fatalf("trash holds %d copies, want %d", len(entries), drops)
+	}
+
+	// drops at the same time get entries of their own
+	dropped := time.Now().UTC()
+	first, firstDir, err := newTrashEntry("scratch", dropped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, secondDir, err := newTrashEntry("scratch", dropped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ID == second.ID || firstDir == secondDir {
+		t.Errorf("two drops share entry %s in %s", second.ID, secondDir)
+	}
+}
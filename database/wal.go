@@ -0,0 +1,565 @@
+package database
+
+/*
+typedef struct sqlite3 sqlite3;
+int sqlite3_wal_autocheckpoint(sqlite3*, int);
+int sqlite3_db_config(sqlite3*, int, ...);
+
+#define SQLITE_DBCONFIG_NO_CKPT_ON_CLOSE 1006
+
+// bedroompop_wal_options leaves checkpoints to the archiver.
+static int bedroompop_wal_options(void *db) {
+	sqlite3_wal_autocheckpoint((sqlite3*)db, 0);
+	return sqlite3_db_config((sqlite3*)db, SQLITE_DBCONFIG_NO_CKPT_ON_CLOSE, 1, (int*)0);
+}
+*/
+import "C"
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/mattn/go-sqlite3"
+	"github.com/trianglehasfoursides/bedroompop/storage"
+)
+
+// Plain databases run in WAL mode without automatic checkpoints. After every
+// transaction the committed frames appended to the WAL are archived as a
+// segment under backups/<database>/wal/, so a backup can be rolled forward to
+// any moment after it was taken. A generation holds the segments of one WAL
+// file, from the moment it is started until a checkpoint truncates it. Its
+// segments concatenated behind its header form a prefix of that WAL, which
+// SQLite recovers like the WAL of a crashed database.
+//
+// The WAL is only archived while something can use it, scheduled backups or
+// a backup store, see SetWALArchiving. Otherwise it is only checkpointed once
+// it grows past walCheckpointSize.
+
+const (
+	walHeaderSize = 32
+	walFrameSize  = 24 // header of a frame, followed by a page
+
+	// walCheckpointSize is how large the WAL may grow before it is checkpointed into the database.
+	walCheckpointSize = 4 << 20
+)
+
+// walState is how far the WAL of a database has been archived.
+type walState struct {
+	generation string // directory of the current generation
+	salt       [8]byte
+	offset     int64 // end of the last archived frame
+	checksum   [2]uint32
+}
+
+// walArchive guards the archiving of the WAL of a database, so databases
+// are archived independently of each other.
+type walArchive struct {
+	sync.Mutex
+	state *walState // nil until the WAL is read
+}
+
+// walArchiving is set when scheduled backups are taken.
+var walArchiving atomic.Bool
+
+// SetWALArchiving sets whether the WAL of plain databases is archived,
+// which it also is whenever a backup store is set.
+func SetWALArchiving(on bool) {
+	walArchiving.Store(on)
+}
+
+func archivingWAL() bool {
+	return walArchiving.Load() || currentStore().store != nil
+}
+
+var archives = struct {
+	sync.Mutex
+	m map[string]*walArchive
+}{m: make(map[string]*walArchive)}
+
+func archiveOf(databaseName string) *walArchive {
+	archives.Lock()
+	defer archives.Unlock()
+	a, ok := archives.m[databaseName]
+	if !ok {
+		a = new(walArchive)
+		archives.m[databaseName] = a
+	}
+	return a
+}
+
+func walDir(databaseName string) string {
+	return filepath.Join(backupDir, databaseName, "wal")
+}
+
+// archiveWAL archives the transactions committed to the WAL of a database
+// since the last call. Only the frames after those archived are read, so a
+// WAL that didn't change costs a header and a frame. It reports whether the
+// WAL should be checkpointed.
+func archiveWAL(databaseName string, databasePath string) (bool, error) {
+	if !archivingWAL() {
+		info, err := os.Stat(databasePath + "-wal")
+		return err == nil && info.Size() > walCheckpointSize, nil
+	}
+
+	a := archiveOf(databaseName)
+	a.Lock()
+	defer a.Unlock()
+
+	file, err := os.Open(databasePath + "-wal")
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	header := make([]byte, walHeaderSize)
+	if _, err := io.ReadFull(file, header); errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	order, ok := walByteOrder(header)
+	if !ok {
+		return false, errors.New("WAL has an unknown format")
+	}
+	var salt [8]byte
+	copy(salt[:], header[16:24])
+
+	state := a.state
+	if state == nil || state.salt != salt {
+		if state, err = resumeGeneration(databaseName, header, order); err != nil {
+			return false, err
+		}
+		a.state = state
+	}
+
+	pageSize := int64(binary.BigEndian.Uint32(header[8:12]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+
+	// only whole transactions with valid checksums are archived
+	r := bufio.NewReader(io.NewSectionReader(file, state.offset, math.MaxInt64-state.offset))
+	var frames bytes.Buffer
+	end, checksum := state.offset, state.checksum
+	s1, s2 := checksum[0], checksum[1]
+	frame := make([]byte, walFrameSize+pageSize)
+	for offset := state.offset; ; offset += walFrameSize + pageSize {
+		if _, err := io.ReadFull(r, frame); errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		} else if err != nil {
+			return false, err
+		}
+		if !bytes.Equal(frame[8:16], salt[:]) {
+			break
+		}
+		s1, s2 = walChecksum(order, frame[:8], s1, s2)
+		s1, s2 = walChecksum(order, frame[walFrameSize:], s1, s2)
+		if binary.BigEndian.Uint32(frame[16:20]) != s1 || binary.BigEndian.Uint32(frame[20:24]) != s2 {
+			break
+		}
+		frames.Write(frame)
+		if binary.BigEndian.Uint32(frame[4:8]) != 0 {
+			// a commit frame ends a transaction
+			end, checksum = offset+walFrameSize+pageSize, [2]uint32{s1, s2}
+		}
+	}
+	if end == state.offset {
+		return state.offset > walCheckpointSize, nil
+	}
+
+	name := fmt.Sprintf("%016x-%d.frames", state.offset, time.Now().UnixNano())
+	if err := writeSynced(filepath.Join(state.generation, name), frames.Bytes()[:end-state.offset]); err != nil {
+		return false, err
+	}
+	state.offset, state.checksum = end, checksum
+	wakeReplica()
+	return end > walCheckpointSize, nil
+}
+
+// resumeGeneration finds the generation of a WAL header on disk, or starts a new one.
+func resumeGeneration(databaseName string, header []byte, order binary.ByteOrder) (*walState, error) {
+	state := &walState{offset: walHeaderSize}
+	copy(state.salt[:], header[16:24])
+	state.checksum = [2]uint32{binary.BigEndian.Uint32(header[24:28]), binary.BigEndian.Uint32(header[28:32])}
+
+	generations, err := filepath.Glob(filepath.Join(walDir(databaseName), "*-"+fmt.Sprintf("%x", state.salt)))
+	if err != nil {
+		return nil, err
+	}
+	if len(generations) == 0 {
+		state.generation = filepath.Join(walDir(databaseName), fmt.Sprintf("%020d-%x", time.Now().UnixNano(), state.salt))
+		if err := os.MkdirAll(state.generation, 0o700); err != nil {
+			return nil, err
+		}
+		return state, writeSynced(filepath.Join(state.generation, "header"), header)
+	}
+
+	// the node restarted in the middle of a generation, replay its checksums
+	state.generation = generations[len(generations)-1]
+	segments, err := walSegments(state.generation)
+	if err != nil {
+		return nil, err
+	}
+	pageSize := int64(binary.BigEndian.Uint32(header[8:12]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	s1, s2 := state.checksum[0], state.checksum[1]
+	for _, segment := range segments {
+		data, err := os.ReadFile(segment.path)
+		if err != nil {
+			return nil, err
+		}
+		for offset := int64(0); offset+walFrameSize+pageSize <= int64(len(data)); offset += walFrameSize + pageSize {
+			s1, s2 = walChecksum(order, data[offset:offset+8], s1, s2)
+			s1, s2 = walChecksum(order, data[offset+walFrameSize:offset+walFrameSize+pageSize], s1, s2)
+		}
+		state.offset = segment.offset + int64(len(data))
+	}
+	state.checksum = [2]uint32{s1, s2}
+	return state, nil
+}
+
+// walByteOrder returns the byte order the checksums of a WAL read its
+// content in. Every integer of the WAL itself is big-endian.
+func walByteOrder(header []byte) (binary.ByteOrder, bool) {
+	switch binary.BigEndian.Uint32(header[:4]) {
+	case 0x377f0682:
+		return binary.LittleEndian, true
+	case 0x377f0683:
+		return binary.BigEndian, true
+	}
+	return nil, false
+}
+
+// walChecksum continues the checksum of a WAL over data.
+func walChecksum(order binary.ByteOrder, data []byte, s1, s2 uint32) (uint32, uint32) {
+	for i := 0; i+8 <= len(data); i += 8 {
+		s1 += order.Uint32(data[i:]) + s2
+		s2 += order.Uint32(data[i+4:]) + s1
+	}
+	return s1, s2
+}
+
+type walSegment struct {
+	path   string
+	offset int64
+	time   time.Time
+}
+
+// walSegments lists the segments of a generation in WAL order.
+func walSegments(generation string) ([]walSegment, error) {
+	paths, err := filepath.Glob(filepath.Join(generation, "*.frames"))
+	if err != nil {
+		return nil, err
+	}
+
+	segments := make([]walSegment, 0, len(paths))
+	for _, path := range paths {
+		if segment, ok := parseSegment(path); ok {
+			segments = append(segments, segment)
+		}
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].offset < segments[j].offset })
+	return segments, nil
+}
+
+// walSince writes the archived WAL of a database needed to roll a backup
+// taken at since forward to until: for every generation still running after
+// since, its header and the segments archived up to until. Generations are
+// read from the backup directory and the backup store together.
+func walSince(ctx context.Context, databaseName string, since time.Time, until time.Time, emit func(name string, r io.Reader) error) error {
+	generations, err := archivedGenerations(ctx, databaseName)
+	if err != nil {
+		return err
+	}
+
+	for _, generation := range generations {
+		if !generation.last().After(since) {
+			// everything in it was checkpointed before the backup
+			continue
+		}
+
+		var files []io.ReadCloser
+		readers := []io.Reader{}
+		for _, key := range generation.keys() {
+			if segment, ok := parseSegment(key); ok && segment.time.After(until) {
+				break
+			}
+			file, err := openBackup(ctx, key)
+			if err != nil {
+				for _, file := range files {
+					file.Close()
+				}
+				return err
+			}
+			readers = append(readers, file)
+			files = append(files, file)
+		}
+		err = nil
+		if len(files) > 1 {
+			err = emit(generation.name, io.MultiReader(readers...))
+		}
+		for _, file := range files {
+			file.Close()
+		}
+		if err != nil {
+			return err
+		}
+		if len(files)-1 < len(generation.segments) {
+			// reached until
+			return nil
+		}
+	}
+	return nil
+}
+
+// archivedGenerations merges the WAL generations of a database in the
+// backup directory with those in the backup store.
+func archivedGenerations(ctx context.Context, databaseName string) ([]walGeneration, error) {
+	local, err := storage.NewDir(backupDir)
+	if err != nil {
+		return nil, err
+	}
+	generations, err := walGenerations(ctx, local, databaseName)
+	if err != nil {
+		return nil, err
+	}
+
+	store := currentStore().store
+	if store == nil {
+		return generations, nil
+	}
+	stored, err := walGenerations(ctx, store, databaseName)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]int, len(generations))
+	for i, generation := range generations {
+		byName[generation.name] = i
+	}
+	for _, generation := range stored {
+		i, ok := byName[generation.name]
+		if !ok {
+			generations = append(generations, generation)
+			continue
+		}
+		have := make(map[string]bool)
+		for _, segment := range generations[i].segments {
+			have[segment.path] = true
+		}
+		for _, segment := range generation.segments {
+			if !have[segment.path] {
+				generations[i].segments = append(generations[i].segments, segment)
+			}
+		}
+		sort.Slice(generations[i].segments, func(a, b int) bool {
+			return generations[i].segments[a].offset < generations[i].segments[b].offset
+		})
+	}
+	sort.Slice(generations, func(i, j int) bool { return generations[i].name < generations[j].name })
+	return generations, nil
+}
+
+// pruneWAL removes the generations no backup kept can be rolled forward
+// with, those checkpointed before the oldest one was taken.
+func pruneWAL(databaseName string, oldest time.Time) error {
+	a := archiveOf(databaseName)
+	a.Lock()
+	defer a.Unlock()
+
+	generations, err := filepath.Glob(filepath.Join(walDir(databaseName), "*-*"))
+	if err != nil {
+		return err
+	}
+	for _, generation := range generations {
+		if a.state != nil && a.state.generation == generation {
+			continue
+		}
+		segments, err := walSegments(generation)
+		if err != nil {
+			return err
+		}
+		if len(segments) > 0 && segments[len(segments)-1].time.After(oldest) || !shippedAll(generation) {
+			continue
+		}
+		if err := os.RemoveAll(generation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shippedAll reports whether every file under dir made it to the backup
+// store, so removing it doesn't lose it. Without a store there is nothing to wait for.
+func shippedAll(dir string) bool {
+	b := currentStore()
+	if b.store == nil {
+		return true
+	}
+	shipped := true
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		key, err := filepath.Rel(backupDir, path)
+		if err != nil {
+			return err
+		}
+		if !isShipped(filepath.ToSlash(key), b.storedSize(info.Size())) {
+			shipped = false
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return err == nil && shipped
+}
+
+// PruneWAL removes the archived WAL no backup kept can be rolled forward
+// with once per interval, so it doesn't pile up between backups or when
+// backups are kept forever.
+func PruneWAL(interval time.Duration) {
+	for range time.Tick(interval) {
+		entries, err := os.ReadDir(backupDir)
+		if err != nil {
+			log.Error("can't list archived WAL", "err", err)
+			continue
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			if err := pruneArchive(entry.Name()); err != nil {
+				log.Error("can't prune archived WAL", "database", entry.Name(), "err", err)
+			}
+		}
+	}
+}
+
+// pruneArchive removes the WAL of a database older than its oldest backup
+// kept, or all but the current generation when it has no backup.
+func pruneArchive(databaseName string) error {
+	backups, err := localBackups(databaseName)
+	if err != nil {
+		return err
+	}
+	oldest := time.Now()
+	if len(backups) > 0 {
+		_, oldest = retain(backups, backupRetention)
+	}
+	return pruneWAL(databaseName, oldest)
+}
+
+// writeSynced writes a file under a temporary name and renames it once
+// synced, so it is never seen partially written.
+func writeSynced(path string, data []byte) error {
+	file, err := os.OpenFile(path+".tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// walOptions turns off the checkpoints SQLite runs by itself, when a
+// transaction grew the WAL or the last connection closes, so none can
+// truncate frames before they are archived.
+func walOptions(conn *sqlite3.SQLiteConn) error {
+	db, err := rawHandle(conn)
+	if err != nil {
+		return err
+	}
+	if rc := C.bedroompop_wal_options(db); rc != 0 {
+		return sqlite3.Error{Code: sqlite3.ErrNo(rc)}
+	}
+	return nil
+}
+
+// openPlain opens a connection to the file of a plain database.
+func openPlain(path string) (*sqlite3.SQLiteConn, error) {
+	conn, err := (&sqlite3.SQLiteDriver{}).Open(path + "?_journal_mode=WAL")
+	if err != nil {
+		return nil, err
+	}
+	if err := walOptions(conn.(*sqlite3.SQLiteConn)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn.(*sqlite3.SQLiteConn), nil
+}
+
+// checkpointWAL archives what is left in the WAL of a database and
+// truncates it into the database file. It waits until no request uses the
+// database, so no transaction can commit between the two.
+func checkpointWAL(databaseName string, databasePath string) {
+	unlock := lock(databaseName, true)
+	defer unlock()
+
+	if _, err := archiveWAL(databaseName, databasePath); err != nil {
+		log.Error("can't archive WAL", "database", databaseName, "err", err)
+		return
+	}
+	conn, err := openPlain(databasePath)
+	if err != nil {
+		log.Error("can't checkpoint WAL", "database", databaseName, "err", err)
+		return
+	}
+	defer conn.Close()
+	if _, err := conn.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`, nil); err != nil {
+		log.Error("can't checkpoint WAL", "database", databaseName, "err", err)
+	}
+}
+
+// forgetWAL drops the archiving state of a database whose file is gone.
+func forgetWAL(databaseName string, databasePath string) {
+	a := archiveOf(databaseName)
+	a.Lock()
+	defer a.Unlock()
+
+	a.state = nil
+	os.Remove(databasePath + "-wal")
+	os.Remove(databasePath + "-shm")
+}
+
+// dropWALArchive removes the archived WAL of a database.
+func dropWALArchive(databaseName string) error {
+	a := archiveOf(databaseName)
+	a.Lock()
+	defer a.Unlock()
+
+	a.state = nil
+	return os.RemoveAll(walDir(databaseName))
+}
@@ -0,0 +1,130 @@
+package database
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/trianglehasfoursides/bedroompop/storage"
+)
+
+// archiveWALs has the WAL of plain databases archived during a test.
+func archiveWALs(t *testing.T) {
+	t.Helper()
+	SetWALArchiving(true)
+	t.Cleanup(func() { SetWALArchiving(false) })
+}
+
+// generations lists the archived WAL generations of a database.
+func generations(t *testing.T, databaseName string) []string {
+	t.Helper()
+	dirs, err := filepath.Glob(filepath.Join(walDir(databaseName), "*-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dirs
+}
+
+func TestWALArchivedOnlyForBackups(t *testing.T) {
+	useDirs(t)
+	if err := Create(adminContext(), "notes", "CREATE TABLE notes (body TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	exec(t, "notes", "INSERT INTO notes VALUES ('unarchived')")
+	if _, err := os.Stat(walDir("notes")); !os.IsNotExist(err) {
+		t.Fatalf("WAL was archived without backups: %v", err)
+	}
+
+	archiveWALs(t)
+	exec(t, "notes", "INSERT INTO notes VALUES ('archived')")
+	if dirs := generations(t, "notes"); len(dirs) != 1 {
+		t.Fatalf("archived %d generations, want 1", len(dirs))
+	}
+
+	// a backup store archives the WAL too
+	SetWALArchiving(false)
+	useDirs(t)
+	useStore(t, storage.NewMem(), "", BackupRetention{})
+	if err := Create(adminContext(), "shipped", "CREATE TABLE notes (body TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	exec(t, "shipped", "INSERT INTO notes VALUES ('archived')")
+	if dirs := generations(t, "shipped"); len(dirs) != 1 {
+		t.Fatalf("archived %d generations with a backup store, want 1", len(dirs))
+	}
+}
+
+func TestPruneArchive(t *testing.T) {
+	useDirs(t)
+	archiveWALs(t)
+	if err := Create(adminContext(), "ledger", "CREATE TABLE entries (amount INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+	path, err := filePath("ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exec(t, "ledger", "INSERT INTO entries VALUES (1)")
+	checkpointWAL("ledger", path)
+	exec(t, "ledger", "INSERT INTO entries VALUES (2)")
+	if dirs := generations(t, "ledger"); len(dirs) != 2 {
+		t.Fatalf("archived %d generations, want 2", len(dirs))
+	}
+
+	// without a backup only the current generation is of any use
+	if err := pruneArchive("ledger"); err != nil {
+		t.Fatal(err)
+	}
+	if dirs := generations(t, "ledger"); len(dirs) != 1 {
+		t.Fatalf("kept %d generations without a backup, want 1", len(dirs))
+	}
+
+	// a generation older than the oldest backup goes, even when every
+	// backup is kept
+	checkpointWAL("ledger", path)
+	exec(t, "ledger", "INSERT INTO entries VALUES (3)")
+	if _, err := TakeBackup(adminContext(), "ledger"); err != nil {
+		t.Fatal(err)
+	}
+	exec(t, "ledger", "INSERT INTO entries VALUES (4)")
+	before := generations(t, "ledger")
+	if err := pruneArchive("ledger"); err != nil {
+		t.Fatal(err)
+	}
+	if dirs := generations(t, "ledger"); len(before) != 2 || len(dirs) != 1 || dirs[0] != before[1] {
+		t.Fatalf("pruned %v down to %v, want only the generation after the backup", before, dirs)
+	}
+}
+
+func TestPruneArchiveKeepsUnshippedWAL(t *testing.T) {
+	useDirs(t)
+	useStore(t, storage.NewMem(), "", BackupRetention{})
+	if err := Create(adminContext(), "ledger", "CREATE TABLE entries (amount INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+	path, err := filePath("ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exec(t, "ledger", "INSERT INTO entries VALUES (1)")
+	checkpointWAL("ledger", path)
+	exec(t, "ledger", "INSERT INTO entries VALUES (2)")
+
+	if err := pruneArchive("ledger"); err != nil {
+		t.Fatal(err)
+	}
+	if dirs := generations(t, "ledger"); len(dirs) != 2 {
+		t.Fatalf("kept %d generations before they were shipped, want 2", len(dirs))
+	}
+	if err := ShipBackups(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := pruneArchive("ledger"); err != nil {
+		t.Fatal(err)
+	}
+	if dirs := generations(t, "ledger"); len(dirs) != 1 {
+		t.Fatalf("kept %d generations once shipped, want 1", len(dirs))
+	}
+}
@@ -0,0 +1,426 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Webhooks are kept in the database they deliver the changes of, along with
+// how far each got, the log of their deliveries and their dead letters, so
+// they go wherever the database does. The server delivers them from the node
+// owning the database, following its changelog.
+
+const (
+	webhookTable    = "_webhooks"
+	deliveryTable   = "_webhook_deliveries"
+	deadLetterTable = "_webhook_dead"
+)
+
+// MaxWebhookBatch is the most changes a webhook is sent at once.
+const MaxWebhookBatch = 1000
+
+// maxDeliveries is how many deliveries are logged per webhook.
+const maxDeliveries = 1000
+
+var (
+	ErrWebhookNotFound = errors.New("webhook not found")
+	ErrInvalidWebhook  = errors.New("invalid webhook")
+)
+
+// Webhook posts the changes of a database to a URL.
+type Webhook struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Table     string    `json:"table,omitempty"` // every table when empty
+	Events    []string  `json:"events"`          // operations delivered
+	Secret    string    `json:"secret,omitempty"`
+	BatchSize int       `json:"batch_size"`
+	Created   time.Time `json:"created"`
+	Log       string    `json:"log"`    // changelog the offset is in
+	Offset    uint64    `json:"offset"` // last change delivered
+}
+
+// Matches reports whether a change is delivered by the webhook.
+func (w Webhook) Matches(change ChangeEvent) bool {
+	return (w.Table == "" || w.Table == change.Table) && slices.Contains(w.Events, change.Op)
+}
+
+// Delivery is an attempt to deliver changes to a webhook.
+type Delivery struct {
+	ID       int64         `json:"id"`
+	Webhook  string        `json:"webhook"`
+	Time     time.Time     `json:"time"`
+	Attempt  int           `json:"attempt"`
+	Status   int           `json:"status,omitempty"` // HTTP status of the response
+	Error    string        `json:"error,omitempty"`
+	First    uint64        `json:"first"`
+	Last     uint64        `json:"last"`
+	Events   int           `json:"events"`
+	Duration time.Duration `json:"duration"`
+}
+
+// DeadLetter holds changes a webhook failed to deliver.
+type DeadLetter struct {
+	ID       int64     `json:"id"`
+	Webhook  string    `json:"webhook"`
+	Created  time.Time `json:"created"`
+	First    uint64    `json:"first"`
+	Last     uint64    `json:"last"`
+	Events   int       `json:"events"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error"`
+	Payload  []byte    `json:"payload"` // the body that was posted
+}
+
+func webhookAdmin(ctx context.Context) error {
+	if PrincipalFrom(ctx).Role != AdminRole {
+		return fmt.Errorf("%w: only admins can manage webhooks", ErrDenied)
+	}
+	return nil
+}
+
+func noTable(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "no such table")
+}
+
+// validateWebhook checks a new webhook and fills in its defaults.
+func validateWebhook(hook *Webhook) error {
+	u, err := url.Parse(hook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: the url must be an absolute http or https URL", ErrInvalidWebhook)
+	}
+	if isInternalTable(hook.Table) || strings.HasPrefix(hook.Table, "sqlite_") {
+		return fmt.Errorf("%w: changes to %s aren't captured", ErrInvalidWebhook, hook.Table)
+	}
+	if len(hook.Events) == 0 {
+		hook.Events = []string{OpInsert, OpUpdate, OpDelete}
+	}
+	for _, event := range hook.Events {
+		if event != OpInsert && event != OpUpdate && event != OpDelete {
+			return fmt.Errorf("%w: unknown event %q, expected insert, update or delete", ErrInvalidWebhook, event)
+		}
+	}
+	switch {
+	case hook.BatchSize == 0:
+		hook.BatchSize = 100
+	case hook.BatchSize < 0 || hook.BatchSize > MaxWebhookBatch:
+		return fmt.Errorf("%w: the batch size must be between 1 and %d", ErrInvalidWebhook, MaxWebhookBatch)
+	}
+	return nil
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// CreateWebhook registers a webhook delivering the changes committed from now
+// on, drawing its secret unless it has one.
+func CreateWebhook(ctx context.Context, databaseName string, hook Webhook) (Webhook, error) {
+	if err := webhookAdmin(ctx); err != nil {
+		return Webhook{}, err
+	}
+	if err := validateWebhook(&hook); err != nil {
+		return Webhook{}, err
+	}
+	position, err := Changelog(ctx, databaseName)
+	if err != nil {
+		return Webhook{}, err
+	}
+	if hook.ID, err = randomHex(8); err != nil {
+		return Webhook{}, err
+	}
+	if hook.Secret == "" {
+		if hook.Secret, err = randomHex(32); err != nil {
+			return Webhook{}, err
+		}
+	}
+	hook.Created = time.Now().UTC()
+	hook.Log, hook.Offset = position.Log, position.Latest
+
+	db, err := open(ctx, databaseName)
+	if err != nil {
+		return Webhook{}, err
+	}
+	defer db.Close()
+
+	txn, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return Webhook{}, err
+	}
+	defer txn.Rollback()
+
+	if hook.Table != "" {
+		var n int
+		err := txn.QueryRowContext(ctx, `SELECT count(*) FROM main.sqlite_master WHERE type = 'table' AND name = ?`, hook.Table).Scan(&n)
+		if err != nil {
+			return Webhook{}, db.auth.check(err)
+		}
+		if n == 0 {
+			return Webhook{}, fmt.Errorf("%w: no such table: %s", ErrInvalidWebhook, hook.Table)
+		}
+	}
+	for _, stmt := range []string{
+		`CREATE TABLE IF NOT EXISTS main.` + webhookTable + ` (id TEXT PRIMARY KEY, url TEXT NOT NULL,
+			table_name TEXT NOT NULL, events TEXT NOT NULL, secret TEXT NOT NULL, batch_size INTEGER NOT NULL,
+			created TEXT NOT NULL, log TEXT NOT NULL, position INTEGER NOT NULL)`,
+		`CREATE TABLE IF NOT EXISTS main.` + deliveryTable + ` (id INTEGER PRIMARY KEY, webhook TEXT NOT NULL,
+			time TEXT NOT NULL, attempt INTEGER NOT NULL, status INTEGER NOT NULL, error TEXT NOT NULL,
+			first INTEGER NOT NULL, last INTEGER NOT NULL, events INTEGER NOT NULL, duration INTEGER NOT NULL)`,
+		`CREATE TABLE IF NOT EXISTS main.` + deadLetterTable + ` (id INTEGER PRIMARY KEY, webhook TEXT NOT NULL,
+			created TEXT NOT NULL, first INTEGER NOT NULL, last INTEGER NOT NULL, events INTEGER NOT NULL,
+			attempts INTEGER NOT NULL, error TEXT NOT NULL, payload BLOB NOT NULL)`,
+	} {
+		if _, err := txn.ExecContext(ctx, stmt); err != nil {
+			return Webhook{}, err
+		}
+	}
+	if _, err := txn.ExecContext(ctx, `INSERT INTO main.`+webhookTable+` VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		hook.ID, hook.URL, hook.Table, strings.Join(hook.Events, ","), hook.Secret, hook.BatchSize,
+		hook.Created.Format(time.RFC3339Nano), hook.Log, int64(hook.Offset)); err != nil {
+		return Webhook{}, err
+	}
+	if err := txn.Commit(); err != nil {
+		return Webhook{}, err
+	}
+	return hook, db.persist(ctx)
+}
+
+var webhookCache internalCache[Webhook]
+
+// Webhooks lists the webhooks of a database, in the order they were created.
+func Webhooks(ctx context.Context, databaseName string) ([]Webhook, error) {
+	if err := webhookAdmin(ctx); err != nil {
+		return nil, err
+	}
+	return webhookCache.get(ctx, databaseName, readWebhooks)
+}
+
+func readWebhooks(ctx context.Context, db *handle) ([]Webhook, error) {
+	rows, err := db.QueryContext(ctx, `SELECT id, url, table_name, events, secret, batch_size, created, log, position
+		FROM main.`+webhookTable+` ORDER BY created, id`)
+	if noTable(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, db.auth.check(err)
+	}
+	defer rows.Close()
+
+	var hooks []Webhook
+	for rows.Next() {
+		var hook Webhook
+		var events, created string
+		var offset int64
+		if err := rows.Scan(&hook.ID, &hook.URL, &hook.Table, &events, &hook.Secret, &hook.BatchSize,
+			&created, &hook.Log, &offset); err != nil {
+			return nil, err
+		}
+		hook.Events = strings.Split(events, ",")
+		hook.Created, _ = time.Parse(time.RFC3339Nano, created)
+		hook.Offset = uint64(offset)
+		hooks = append(hooks, hook)
+	}
+	return hooks, rows.Err()
+}
+
+// FindWebhook returns a webhook of a database.
+func FindWebhook(ctx context.Context, databaseName string, id string) (Webhook, error) {
+	hooks, err := Webhooks(ctx, databaseName)
+	if err != nil {
+		return Webhook{}, err
+	}
+	for _, hook := range hooks {
+		if hook.ID == id {
+			return hook, nil
+		}
+	}
+	return Webhook{}, ErrWebhookNotFound
+}
+
+// webhookExec runs statements on the webhook tables of a database in a
+// transaction, failing with ErrWebhookNotFound unless the webhook exists.
+func webhookExec(ctx context.Context, databaseName string, id string, run func(*sql.Tx) error) error {
+	if err := webhookAdmin(ctx); err != nil {
+		return err
+	}
+	if err := Get(databaseName); err != nil {
+		return err
+	}
+	db, err := open(ctx, databaseName)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	txn, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	var n int
+	err = txn.QueryRowContext(ctx, `SELECT count(*) FROM main.`+webhookTable+` WHERE id = ?`, id).Scan(&n)
+	if noTable(err) || (err == nil && n == 0) {
+		return ErrWebhookNotFound
+	}
+	if err != nil {
+		return db.auth.check(err)
+	}
+	if err := run(txn); err != nil {
+		return err
+	}
+	if err := txn.Commit(); err != nil {
+		return err
+	}
+	return db.persist(ctx)
+}
+
+// DeleteWebhook removes a webhook, with its deliveries and dead letters.
+func DeleteWebhook(ctx context.Context, databaseName string, id string) error {
+	return webhookExec(ctx, databaseName, id, func(txn *sql.Tx) error {
+		for _, table := range []string{deliveryTable, deadLetterTable} {
+			if _, err := txn.ExecContext(ctx, `DELETE FROM main.`+table+` WHERE webhook = ?`, id); err != nil {
+				return err
+			}
+		}
+		_, err := txn.ExecContext(ctx, `DELETE FROM main.`+webhookTable+` WHERE id = ?`, id)
+		return err
+	})
+}
+
+// AdvanceWebhook records that a webhook is done with the changes up to
+// offset in the changelog log, keeping them as a dead letter if it failed
+// to deliver them.
+func AdvanceWebhook(ctx context.Context, databaseName string, id string, log string, offset uint64, dead *DeadLetter) error {
+	return webhookExec(ctx, databaseName, id, func(txn *sql.Tx) error {
+		if _, err := txn.ExecContext(ctx, `UPDATE main.`+webhookTable+` SET log = ?, position = ? WHERE id = ?`,
+			log, int64(offset), id); err != nil {
+			return err
+		}
+		if dead == nil {
+			return nil
+		}
+		_, err := txn.ExecContext(ctx, `INSERT INTO main.`+deadLetterTable+
+			` (webhook, created, first, last, events, attempts, error, payload) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, time.Now().UTC().Format(time.RFC3339Nano), int64(dead.First), int64(dead.Last),
+			dead.Events, dead.Attempts, dead.Error, dead.Payload)
+		return err
+	})
+}
+
+// LogDelivery logs an attempt to deliver changes to a webhook, keeping the
+// last ones, and returns it with its id.
+func LogDelivery(ctx context.Context, databaseName string, delivery Delivery) (Delivery, error) {
+	err := webhookExec(ctx, databaseName, delivery.Webhook, func(txn *sql.Tx) error {
+		result, err := txn.ExecContext(ctx, `INSERT INTO main.`+deliveryTable+
+			` (webhook, time, attempt, status, error, first, last, events, duration) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			delivery.Webhook, delivery.Time.UTC().Format(time.RFC3339Nano), delivery.Attempt, delivery.Status,
+			delivery.Error, int64(delivery.First), int64(delivery.Last), delivery.Events, int64(delivery.Duration))
+		if err != nil {
+			return err
+		}
+		if delivery.ID, err = result.LastInsertId(); err != nil {
+			return err
+		}
+		_, err = txn.ExecContext(ctx, `DELETE FROM main.`+deliveryTable+` WHERE webhook = ? AND id <=
+			(SELECT id FROM main.`+deliveryTable+` WHERE webhook = ? ORDER BY id DESC LIMIT 1 OFFSET ?)`,
+			delivery.Webhook, delivery.Webhook, maxDeliveries)
+		return err
+	})
+	return delivery, err
+}
+
+// Deliveries lists the last deliveries of a webhook, most recent first.
+func Deliveries(ctx context.Context, databaseName string, id string) ([]Delivery, error) {
+	if _, err := FindWebhook(ctx, databaseName, id); err != nil {
+		return nil, err
+	}
+	db, err := open(ctx, databaseName)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, `SELECT id, webhook, time, attempt, status, error, first, last, events, duration
+		FROM main.`+deliveryTable+` WHERE webhook = ? ORDER BY id DESC`, id)
+	if err != nil {
+		return nil, db.auth.check(err)
+	}
+	defer rows.Close()
+
+	deliveries := []Delivery{}
+	for rows.Next() {
+		var d Delivery
+		var at string
+		var first, last, duration int64
+		if err := rows.Scan(&d.ID, &d.Webhook, &at, &d.Attempt, &d.Status, &d.Error, &first, &last,
+			&d.Events, &duration); err != nil {
+			return nil, err
+		}
+		d.Time, _ = time.Parse(time.RFC3339Nano, at)
+		d.First, d.Last, d.Duration = uint64(first), uint64(last), time.Duration(duration)
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// DeadLetters lists the dead letters of a webhook, oldest first, or only the
+// one with the id deadID unless it is 0.
+func DeadLetters(ctx context.Context, databaseName string, id string, deadID int64) ([]DeadLetter, error) {
+	if _, err := FindWebhook(ctx, databaseName, id); err != nil {
+		return nil, err
+	}
+	db, err := open(ctx, databaseName)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, `SELECT id, webhook, created, first, last, events, attempts, error, payload
+		FROM main.`+deadLetterTable+` WHERE webhook = ? AND (? = 0 OR id = ?) ORDER BY id`, id, deadID, deadID)
+	if err != nil {
+		return nil, db.auth.check(err)
+	}
+	defer rows.Close()
+
+	dead := []DeadLetter{}
+	for rows.Next() {
+		var d DeadLetter
+		var created string
+		var first, last int64
+		if err := rows.Scan(&d.ID, &d.Webhook, &created, &first, &last, &d.Events, &d.Attempts,
+			&d.Error, &d.Payload); err != nil {
+			return nil, err
+		}
+		d.Created, _ = time.Parse(time.RFC3339Nano, created)
+		d.First, d.Last = uint64(first), uint64(last)
+		dead = append(dead, d)
+	}
+	return dead, rows.Err()
+}
+
+// ResolveDeadLetter removes a dead letter once it was delivered or given up
+// on, or records another failed attempt to deliver it.
+func ResolveDeadLetter(ctx context.Context, databaseName string, id string, deadID int64, failure error) error {
+	return webhookExec(ctx, databaseName, id, func(txn *sql.Tx) error {
+		if failure != nil {
+			_, err := txn.ExecContext(ctx, `UPDATE main.`+deadLetterTable+
+				` SET attempts = attempts + 1, error = ? WHERE webhook = ? AND id = ?`, failure.Error(), id, deadID)
+			return err
+		}
+		_, err := txn.ExecContext(ctx, `DELETE FROM main.`+deadLetterTable+` WHERE webhook = ? AND id = ?`, id, deadID)
+		return err
+	})
+}
@@ -1,27 +1,186 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/charmbracelet/log"
+	"github.com/trianglehasfoursides/bedroompop/audit"
 	"github.com/trianglehasfoursides/bedroompop/config"
 	"github.com/trianglehasfoursides/bedroompop/consist"
+	"github.com/trianglehasfoursides/bedroompop/database"
+	"github.com/trianglehasfoursides/bedroompop/metering"
 	"github.com/trianglehasfoursides/bedroompop/server"
+	"github.com/trianglehasfoursides/bedroompop/storage"
 )
 
 func main() {
 	flag.StringVar(&config.Name, "name", "", "")
 	flag.StringVar(&config.HTTPAddr, "http-address", ":7000", "")
-	flag.StringVar(&config.GRPCAddr, "grpc-address", ":7070", "")
+	flag.StringVar(&config.GRPCAddr, "grpc-address", "127.0.0.1:7070", "address the nodes call each other on, reachable by the other nodes but not by clients")
 	flag.StringVar(&config.GossipAddr, "gossip-address", "localhost:7777", "")
 	flag.StringVar(&config.Join, "join", "", "")
+	flag.StringVar(&config.ClusterKeyFile, "cluster-key-file", "", "file with the key shared by the nodes to authenticate their calls to each other, required to join a cluster")
 	flag.StringVar(&config.Username, "username", "soy", "")
 	flag.StringVar(&config.Password, "password", "pablo", "")
+	flag.StringVar(&config.Role, "role", "admin", "role of the configured user")
+	flag.StringVar(&config.UsersFile, "users", "", "JSON file with the users of the gateway, replacing --username and --password")
+	flag.StringVar(&config.PolicyFile, "policy", "", "JSON file with per database and per role statement policies")
+	flag.StringVar(&config.RateLimits, "rate-limits", "", "JSON file with the request rate limits per principal, source address and database")
+	flag.StringVar(&config.DataDir, "data-dir", ".", "directory holding the database files")
+	flag.StringVar(&config.MasterKeyFile, "master-key-file", "", "keyfile of the master key, enables encryption of new databases")
+	flag.StringVar(&config.PreviousMasterKeyFiles, "previous-master-key-files", "", "comma separated keyfiles of rotated out master keys")
+	flag.StringVar(&config.AuditDir, "audit-dir", "", "directory of the audit log, defaults to audit under the data directory")
+	flag.Int64Var(&config.AuditMaxSize, "audit-max-size", 64<<20, "size in bytes at which the audit log is rotated")
+	flag.DurationVar(&config.CursorTTL, "cursor-ttl", time.Minute, "how long an idle query cursor is kept open")
+	flag.IntVar(&config.MaxCursors, "max-cursors", 1024, "how many query cursors may be open on this node")
+	flag.DurationVar(&config.MaxQueryTimeout, "max-query-timeout", 5*time.Minute, "longest a statement may run before it is interrupted, 0 for no limit")
+	flag.IntVar(&config.FanoutConcurrency, "fanout-concurrency", 8, "how many databases a node queries at once for a fanout query")
+	flag.StringVar(&config.UsageDir, "usage-dir", "", "directory of the usage log, defaults to usage under the data directory")
+	flag.DurationVar(&config.UsageWindow, "usage-window", time.Hour, "length of the windows usage is aggregated in, and quotas apply to")
+	flag.DurationVar(&config.UsageFlush, "usage-flush", 10*time.Second, "how often usage is written to disk")
+	flag.DurationVar(&config.UsageRetention, "usage-retention", 90*24*time.Hour, "how long usage is kept, 0 for ever")
+	flag.DurationVar(&config.QuotaThrottle, "quota-throttle", time.Second, "how long requests over a soft quota are held back")
+	flag.StringVar(&config.BackupDir, "backup-dir", "", "directory of the database backups, defaults to backups under the data directory")
+	flag.DurationVar(&config.BackupInterval, "backup-interval", 0, "how often every database this node owns is backed up, 0 to only back up on request")
+	flag.IntVar(&config.BackupKeep, "backup-keep", 0, "how many backups of a database are kept, 0 for all")
+	flag.DurationVar(&config.BackupMaxAge, "backup-max-age", 0, "how long backups are kept, 0 for no limit")
+	flag.StringVar(&config.TemplateDir, "template-dir", "", "directory of the database templates, defaults to templates under the data directory")
+	flag.StringVar(&config.TrashDir, "trash-dir", "", "directory dropped databases are kept in, defaults to trash under the data directory")
+	flag.DurationVar(&config.TrashRetention, "trash-retention", 7*24*time.Hour, "how long dropped databases can be restored, 0 to delete them when dropped")
+	flag.StringVar(&config.ChangelogDir, "changelog-dir", "", "directory of the changelogs subscribers follow, defaults to changelog under the data directory")
+	// changes can only be captured by a build with the preupdate hook
+	changelogRetention := time.Duration(0)
+	if database.CaptureAvailable {
+		changelogRetention = 24 * time.Hour
+	}
+	flag.DurationVar(&config.ChangelogRetention, "changelog-retention", changelogRetention, "how long captured changes are kept for subscribers, 0 to capture none")
+	flag.DurationVar(&config.WebhookTimeout, "webhook-timeout", 10*time.Second, "how long a webhook has to answer a delivery")
+	flag.IntVar(&config.WebhookAttempts, "webhook-attempts", 8, "how many times changes are posted to a webhook before they become a dead letter")
+	flag.StringVar(&config.BackupStore, "backup-store", "", "where backups are shipped off the node: s3://bucket/prefix?endpoint=...&region=..., file:///dir or mem://")
+	flag.StringVar(&config.BackupStoreKeyFile, "backup-store-key-file", "", "keyfile backups are encrypted with in the backup store")
+	flag.DurationVar(&config.BackupStoreSync, "backup-store-sync", time.Second, "how often backups and WAL missing from the backup store are shipped")
+	flag.IntVar(&config.BackupStoreKeep, "backup-store-keep", 0, "how many backups of a database the backup store keeps, 0 for all")
+	flag.DurationVar(&config.BackupStoreMaxAge, "backup-store-max-age", 0, "how long the backup store keeps backups, 0 for no limit")
 	flag.Parse()
 
+	if err := database.SetDataDir(config.DataDir); err != nil {
+		log.Fatal("can't use data directory", "err", err)
+	}
+
+	database.SetCursorOptions(config.CursorTTL, config.MaxCursors)
+	database.SetMaxQueryTimeout(config.MaxQueryTimeout)
+
+	if config.AuditDir == "" {
+		config.AuditDir = filepath.Join(config.DataDir, "audit")
+	}
+	if err := audit.Open(config.AuditDir, config.Name, config.AuditMaxSize); err != nil {
+		log.Fatal("can't open audit log", "err", err)
+	}
+
+	if config.UsageDir == "" {
+		config.UsageDir = filepath.Join(config.DataDir, "usage")
+	}
+	if err := metering.Open(config.UsageDir, config.UsageWindow, config.UsageFlush, config.UsageRetention); err != nil {
+		log.Fatal("can't open usage log", "err", err)
+	}
+	database.SetQuotaThrottle(config.QuotaThrottle)
+
+	if config.BackupDir == "" {
+		config.BackupDir = filepath.Join(config.DataDir, "backups")
+	}
+	if err := database.SetBackupDir(config.BackupDir); err != nil {
+		log.Fatal("can't use backup directory", "err", err)
+	}
+	database.SetBackupRetention(database.BackupRetention{Keep: config.BackupKeep, MaxAge: config.BackupMaxAge})
+	// the WAL is archived for scheduled backups, and for a backup store once set
+	database.SetWALArchiving(config.BackupInterval > 0)
+	go database.PruneWAL(time.Minute)
+
+	if config.TemplateDir == "" {
+		config.TemplateDir = filepath.Join(config.DataDir, "templates")
+	}
+	if err := database.SetTemplateDir(config.TemplateDir); err != nil {
+		log.Fatal("can't use template directory", "err", err)
+	}
+
+	if config.TrashDir == "" {
+		config.TrashDir = filepath.Join(config.DataDir, "trash")
+	}
+	if err := database.SetTrashDir(config.TrashDir); err != nil {
+		log.Fatal("can't use trash directory", "err", err)
+	}
+	database.SetTrashRetention(config.TrashRetention)
+	go database.ExpireTrash(time.Minute)
+
+	if config.ChangelogDir == "" {
+		config.ChangelogDir = filepath.Join(config.DataDir, "changelog")
+	}
+	if err := database.SetChangelogDir(config.ChangelogDir); err != nil {
+		log.Fatal("can't use changelog directory", "err", err)
+	}
+	if err := database.SetChangelogRetention(config.ChangelogRetention); err != nil {
+		log.Fatal("can't capture changes", "err", err)
+	}
+	if !database.CaptureAvailable {
+		log.Warn("this build can't capture changes, subscriptions and webhooks are refused; build with -tags sqlite_preupdate_hook")
+	}
+	go database.ExpireChangelog(time.Minute)
+
+	if config.BackupStore != "" {
+		store, err := storage.Open(config.BackupStore)
+		if err != nil {
+			log.Fatal("can't open backup store", "err", err)
+		}
+		retention := database.BackupRetention{Keep: config.BackupStoreKeep, MaxAge: config.BackupStoreMaxAge}
+		if err := database.SetBackupStore(store, config.BackupStoreKeyFile, retention); err != nil {
+			log.Fatal("can't use backup store", "err", err)
+		}
+		go database.Replicate(config.BackupStoreSync)
+	}
+
+	if config.MasterKeyFile != "" {
+		var previous []string
+		if config.PreviousMasterKeyFiles != "" {
+			previous = strings.Split(config.PreviousMasterKeyFiles, ",")
+		}
+		if err := database.LoadMasterKeys(config.MasterKeyFile, previous...); err != nil {
+			log.Fatal("can't load master key", "err", err)
+		}
+	}
+
+	if config.UsersFile != "" {
+		if err := server.LoadUsers(config.UsersFile); err != nil {
+			log.Fatal("can't load users", "err", err)
+		}
+	}
+
+	if config.PolicyFile != "" {
+		if err := database.LoadPolicies(config.PolicyFile); err != nil {
+			log.Fatal("can't load policies", "err", err)
+		}
+	}
+
+	if config.ClusterKeyFile != "" {
+		if err := server.LoadClusterKey(config.ClusterKeyFile); err != nil {
+			log.Fatal("can't load cluster key", "err", err)
+		}
+	} else if config.Join != "" {
+		log.Fatal("joining a cluster needs --cluster-key-file")
+	}
+
+	if config.RateLimits != "" {
+		if err := server.LoadRateLimits(config.RateLimits); err != nil {
+			log.Fatal("can't load rate limits", "err", err)
+		}
+	}
+
 	gossip, err := server.CreateGossip(config.GRPCAddr, config.GossipAddr, config.Name)
 	if err != nil {
 		return
@@ -35,10 +194,24 @@ func main() {
 	log.Info("starting Bedroompop")
 
 	ch := make(chan os.Signal, 1)
-	signal.Notify(ch)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
 	go server.Start(ch)
 	go server.GRPCStart(ch)
+	if config.BackupInterval > 0 {
+		go server.ScheduleBackups(config.BackupInterval)
+	}
+	if config.ChangelogRetention > 0 {
+		go server.DeliverWebhooks(10 * time.Second)
+	}
+	go server.RunJobs(10 * time.Second)
+	go server.CollectUsage(config.UsageFlush)
 
 	<-ch
 	log.Info("stoping Bedroompop")
+	if err := metering.Close(); err != nil {
+		log.Error("can't write usage log", "err", err)
+	}
+	if err := database.ShipBackups(context.Background()); err != nil {
+		log.Error("can't ship backups", "err", err)
+	}
 }
@@ -0,0 +1,366 @@
+package metering
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Usage is what a database used during a window. Storage is the size of the
+// database file when it was last used, the other fields are totals.
+type Usage struct {
+	StorageBytes int64 `json:"storage_bytes"`
+	RowsRead     int64 `json:"rows_read"`
+	RowsWritten  int64 `json:"rows_written"`
+	Queries      int64 `json:"queries"`
+	VMSteps      int64 `json:"vm_steps"` // virtual machine steps, counted a thousand at a time
+}
+
+// Add adds the totals of u to the usage and takes its storage if it was measured.
+func (usage *Usage) Add(u Usage) {
+	if u.StorageBytes > 0 {
+		usage.StorageBytes = u.StorageBytes
+	}
+	usage.RowsRead += u.RowsRead
+	usage.RowsWritten += u.RowsWritten
+	usage.Queries += u.Queries
+	usage.VMSteps += u.VMSteps
+}
+
+// Record is the usage of a database during the window starting at Window.
+type Record struct {
+	Database string    `json:"database"`
+	Window   time.Time `json:"window"`
+	Usage
+}
+
+// Filter selects records in Query. Zero values match everything.
+type Filter struct {
+	From     time.Time
+	To       time.Time
+	Database string
+}
+
+type key struct {
+	database string
+	window   int64 // unix seconds
+}
+
+const current = "usage.log"
+
+// Usage is kept in memory and appended to the log of the node as deltas,
+// so a crash loses at most one flush interval. The log is compacted into one
+// line per database and window when it is opened, and again when windows
+// past the retention are dropped.
+var meter = struct {
+	sync.Mutex
+	dir       string
+	window    time.Duration
+	retention time.Duration
+	file      *os.File
+	totals    map[key]*Usage
+	pending   map[key]*Usage
+	remote    map[key]Usage // counted by the other nodes, see SetRemote
+	done      chan struct{}
+}{window: time.Hour}
+
+// Open loads the usage recorded in dir, counts new usage in windows of the
+// given length and writes it back every flush interval. Windows that ended
+// longer than retention ago are dropped, none when it is 0.
+func Open(dir string, window time.Duration, flush time.Duration, retention time.Duration) error {
+	if window <= 0 {
+		return errors.New("usage window must be positive")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	meter.Lock()
+	defer meter.Unlock()
+
+	meter.dir = dir
+	meter.window = window
+	meter.retention = retention
+	meter.totals = make(map[key]*Usage)
+	meter.pending = make(map[key]*Usage)
+
+	records, err := read(filepath.Join(dir, current))
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		add(meter.totals, key{r.Database, r.Window.Unix()}, r.Usage)
+	}
+	expire()
+	if err := compact(); err != nil {
+		return err
+	}
+
+	meter.done = make(chan struct{})
+	go func(done chan struct{}) {
+		ticker := time.NewTicker(flush)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				Flush()
+				Expire()
+			case <-done:
+				return
+			}
+		}
+	}(meter.done)
+	return nil
+}
+
+// Close writes the pending usage and stops counting to disk.
+func Close() error {
+	meter.Lock()
+	defer meter.Unlock()
+
+	if meter.file == nil {
+		return nil
+	}
+	close(meter.done)
+	err := flush()
+	if cerr := meter.file.Close(); err == nil {
+		err = cerr
+	}
+	meter.file = nil
+	return err
+}
+
+// Add counts usage of a database in the current window. It only keeps it in
+// memory when the log hasn't been opened.
+func Add(databaseName string, u Usage) {
+	meter.Lock()
+	defer meter.Unlock()
+
+	if meter.totals == nil {
+		meter.totals = make(map[key]*Usage)
+		meter.pending = make(map[key]*Usage)
+	}
+	k := key{databaseName, windowOf(time.Now()).Unix()}
+	add(meter.totals, k, u)
+	add(meter.pending, k, u)
+}
+
+// Current returns the usage of a database in the current window, what the
+// other nodes counted included.
+func Current(databaseName string) Usage {
+	meter.Lock()
+	defer meter.Unlock()
+
+	k := key{databaseName, windowOf(time.Now()).Unix()}
+	var usage Usage
+	if u, ok := meter.totals[k]; ok {
+		usage = *u
+	}
+	if u, ok := meter.remote[k]; ok {
+		storage := usage.StorageBytes
+		usage.Add(u)
+		if storage > 0 {
+			// the file here is the database as it is now
+			usage.StorageBytes = storage
+		}
+	}
+	return usage
+}
+
+// SetRemote replaces the usage the other nodes of the cluster counted, as
+// last collected, so quotas hold wherever a database was used.
+func SetRemote(records []Record) {
+	meter.Lock()
+	defer meter.Unlock()
+
+	meter.remote = make(map[key]Usage, len(records))
+	for _, r := range records {
+		k := key{r.Database, r.Window.Unix()}
+		u := meter.remote[k]
+		u.Add(r.Usage)
+		meter.remote[k] = u
+	}
+}
+
+// Query returns the records of this node matching the filter, by window and database.
+func Query(filter Filter) []Record {
+	meter.Lock()
+	defer meter.Unlock()
+
+	var records []Record
+	for k, u := range meter.totals {
+		window := time.Unix(k.window, 0).UTC()
+		switch {
+		case !filter.From.IsZero() && window.Add(meter.window).Before(filter.From):
+		case !filter.To.IsZero() && window.After(filter.To):
+		case filter.Database != "" && k.database != filter.Database:
+		default:
+			records = append(records, Record{Database: k.database, Window: window, Usage: *u})
+		}
+	}
+	Sort(records)
+	return records
+}
+
+// Sort orders records by window, then database.
+func Sort(records []Record) {
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].Window.Equal(records[j].Window) {
+			return records[i].Window.Before(records[j].Window)
+		}
+		return records[i].Database < records[j].Database
+	})
+}
+
+// Expire drops the windows past the retention and compacts the log.
+func Expire() error {
+	meter.Lock()
+	defer meter.Unlock()
+
+	if meter.file == nil || !expire() {
+		return nil
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	if err := meter.file.Close(); err != nil {
+		return err
+	}
+	meter.file = nil
+	return compact()
+}
+
+// expire drops the windows past the retention from the totals and reports
+// whether there were any.
+func expire() bool {
+	if meter.retention <= 0 {
+		return false
+	}
+	oldest := windowOf(time.Now().Add(-meter.retention)).Unix()
+	expired := false
+	for k := range meter.totals {
+		if k.window < oldest {
+			delete(meter.totals, k)
+			delete(meter.pending, k)
+			expired = true
+		}
+	}
+	return expired
+}
+
+// Flush appends the usage counted since the last flush to the log.
+func Flush() error {
+	meter.Lock()
+	defer meter.Unlock()
+	return flush()
+}
+
+func flush() error {
+	if meter.file == nil || len(meter.pending) == 0 {
+		return nil
+	}
+
+	var lines []byte
+	for k, u := range meter.pending {
+		line, err := json.Marshal(Record{Database: k.database, Window: time.Unix(k.window, 0).UTC(), Usage: *u})
+		if err != nil {
+			return err
+		}
+		lines = append(append(lines, line...), '\n')
+	}
+	if _, err := meter.file.Write(lines); err != nil {
+		return err
+	}
+	if err := meter.file.Sync(); err != nil {
+		return err
+	}
+	clear(meter.pending)
+	return nil
+}
+
+// compact rewrites the log with the totals and opens it for appending.
+func compact() error {
+	records := make([]Record, 0, len(meter.totals))
+	for k, u := range meter.totals {
+		records = append(records, Record{Database: k.database, Window: time.Unix(k.window, 0).UTC(), Usage: *u})
+	}
+	Sort(records)
+
+	path := filepath.Join(meter.dir, current)
+	tmp, err := os.CreateTemp(meter.dir, current+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	meter.file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	return err
+}
+
+func read(path string) ([]Record, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			// a line cut short by a crash ends the log
+			if !scanner.Scan() {
+				break
+			}
+			return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+		records = append(records, r)
+	}
+	return records, scanner.Err()
+}
+
+func add(usages map[key]*Usage, k key, u Usage) {
+	usage, ok := usages[k]
+	if !ok {
+		usage = &Usage{}
+		usages[k] = usage
+	}
+	usage.Add(u)
+}
+
+func windowOf(t time.Time) time.Time {
+	return t.UTC().Truncate(meter.window)
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/trianglehasfoursides/bedroompop/config"
+	"github.com/trianglehasfoursides/bedroompop/consist"
+	"github.com/trianglehasfoursides/bedroompop/database"
+)
+
+// withAttached attaches the databases named by a query request to the
+// queries run under the returned context. Databases this node owns are read
+// in place, the others from a snapshot fetched from their owner, kept in a
+// temporary directory until done is called.
+func withAttached(c context.Context, req *RequestQueryExec) (_ context.Context, done func(), err error) {
+	if len(req.GetAttach()) == 0 {
+		return c, func() {}, nil
+	}
+	if req.GetPageSize() > 0 || req.GetCursor() != "" {
+		return nil, nil, fmt.Errorf("%w: queries attaching databases can't be paged", database.ErrInvalidAttach)
+	}
+
+	attached := make([]database.Attachment, 0, len(req.GetAttach()))
+	for alias, name := range req.GetAttach() {
+		attached = append(attached, database.Attachment{Alias: alias, Database: name})
+	}
+	sort.Slice(attached, func(i, j int) bool { return attached[i].Alias < attached[j].Alias })
+	if err := database.ValidateAttachments(req.GetName(), attached); err != nil {
+		return nil, nil, err
+	}
+
+	var dir string
+	done = func() {
+		if dir != "" {
+			os.RemoveAll(dir)
+		}
+	}
+	defer func() {
+		if err != nil {
+			done()
+		}
+	}()
+
+	// the principal can read any database, the snapshot is taken by the node
+	node := database.WithPrincipal(c, database.Principal{Name: config.Name, Role: database.AdminRole})
+	for i, at := range attached {
+		if consist.Consist.LocateKey([]byte(at.Database)).String() == config.GRPCAddr {
+			continue
+		}
+		if dir == "" {
+			if dir, err = os.MkdirTemp(config.DataDir, ".attach-"); err != nil {
+				return nil, nil, err
+			}
+		}
+		into := filepath.Join(dir, at.Alias)
+		if err := os.Mkdir(into, 0o700); err != nil {
+			return nil, nil, err
+		}
+		backup, files, err := fetchSnapshot(node, into, at.Database)
+		if err != nil {
+			return nil, nil, err
+		}
+		attached[i].Snapshot, attached[i].Backup = files[0], backupOf(backup)
+	}
+	return database.WithAttached(c, attached), done, nil
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/gin-gonic/gin"
+	"github.com/trianglehasfoursides/bedroompop/audit"
+	"github.com/trianglehasfoursides/bedroompop/config"
+	"github.com/trianglehasfoursides/bedroompop/consist"
+	"github.com/trianglehasfoursides/bedroompop/database"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// record appends a mutating call to the audit log of this node.
+func record(ctx context.Context, operation string, databaseName string, statement string, err error) {
+	principal := database.PrincipalFrom(ctx)
+	entry := audit.Entry{
+		Principal: principal.Name,
+		SourceIP:  principal.Addr,
+		Database:  databaseName,
+		Operation: operation,
+		Outcome:   audit.Outcome(err),
+	}
+	if statement != "" {
+		entry.StatementHash = audit.Hash(statement)
+	}
+
+	if err := audit.Record(entry); err != nil {
+		log.Error("can't write audit log", "err", err)
+	}
+}
+
+func (s *server) Audit(c context.Context, req *RequestAudit) (*ResponseAudit, error) {
+	if database.PrincipalFrom(c).Role != database.AdminRole {
+		return nil, status.Error(codes.PermissionDenied, "audit log is only available to admins")
+	}
+
+	filter := audit.Filter{
+		Principal: req.GetPrincipal(),
+		Database:  req.GetDatabase(),
+	}
+	if req.GetFrom() != 0 {
+		filter.From = time.Unix(0, req.GetFrom())
+	}
+	if req.GetTo() != 0 {
+		filter.To = time.Unix(0, req.GetTo())
+	}
+
+	entries, err := audit.Query(filter)
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	resp := &ResponseAudit{}
+	if err := audit.Verify(); err != nil {
+		resp.ChainError = err.Error()
+	}
+	for _, e := range entries {
+		resp.Entries = append(resp.Entries, &AuditEntry{
+			Time:          e.Time.UnixNano(),
+			Principal:     e.Principal,
+			SourceIp:      e.SourceIP,
+			Node:          e.Node,
+			Database:      e.Database,
+			Operation:     e.Operation,
+			StatementHash: e.StatementHash,
+			Outcome:       e.Outcome,
+			Prev:          e.Prev,
+			Hash:          e.Hash,
+		})
+	}
+	return resp, nil
+}
+
+// auditLog collects the audit log of every node in the cluster.
+func auditLog(ctx *gin.Context) {
+	if database.PrincipalFrom(ctx.Request.Context()).Role != database.AdminRole {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "audit log is only available to admins",
+		})
+		return
+	}
+
+	req := &RequestAudit{
+		Principal: ctx.Query("principal"),
+		Database:  ctx.Query("database"),
+	}
+	for param, bound := range map[string]*int64{"from": &req.From, "to": &req.To} {
+		if v := ctx.Query(param); v != "" {
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+					"error": param + " must be an RFC 3339 time",
+				})
+				return
+			}
+			*bound = t.UnixNano()
+		}
+	}
+
+	entries := []audit.Entry{}
+	nodes := make(map[string]string)
+	for _, member := range consist.Consist.GetMembers() {
+		address := member.String()
+
+		var resp *ResponseAudit
+		var err error
+		if address == config.GRPCAddr {
+			resp, err = local.Audit(ctx.Request.Context(), req)
+		} else {
+			client, conn, dialErr := dial(address)
+			if dialErr != nil {
+				nodes[address] = dialErr.Error()
+				continue
+			}
+			resp, err = client.Audit(outgoing(ctx.Request.Context()), req)
+			conn.Close()
+		}
+		if err != nil {
+			nodes[address] = status.Convert(err).Message()
+			continue
+		}
+
+		nodes[address] = "ok"
+		if resp.GetChainError() != "" {
+			nodes[address] = "chain broken: " + resp.GetChainError()
+		}
+		for _, e := range resp.GetEntries() {
+			entries = append(entries, audit.Entry{
+				Time:          time.Unix(0, e.GetTime()).UTC(),
+				Principal:     e.GetPrincipal(),
+				SourceIP:      e.GetSourceIp(),
+				Node:          e.GetNode(),
+				Database:      e.GetDatabase(),
+				Operation:     e.GetOperation(),
+				StatementHash: e.GetStatementHash(),
+				Outcome:       e.GetOutcome(),
+				Prev:          e.GetPrev(),
+				Hash:          e.GetHash(),
+			})
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+	ctx.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"nodes":   nodes,
+	})
+}
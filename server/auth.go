@@ -0,0 +1,83 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trianglehasfoursides/bedroompop/config"
+	"github.com/trianglehasfoursides/bedroompop/database"
+)
+
+// User is an account of the HTTP gateway. Vars become the session variables
+// of its statements, readable with current_setting(name).
+type User struct {
+	Name     string            `json:"name"`
+	Password string            `json:"password"`
+	Role     string            `json:"role"`
+	Vars     map[string]string `json:"vars"`
+}
+
+// users replaces the single --username/--password account when loaded.
+var users map[string]User
+
+// LoadUsers reads the accounts of the gateway from a JSON array of users.
+func LoadUsers(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var list []User
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+
+	users = make(map[string]User, len(list))
+	for _, user := range list {
+		// variables travel as gRPC metadata keys, which are lower case
+		vars := make(map[string]string, len(user.Vars))
+		for k, v := range user.Vars {
+			vars[strings.ToLower(k)] = v
+		}
+		user.Vars = vars
+		users[user.Name] = user
+	}
+	return nil
+}
+
+func lookup(username string, password string) (User, bool) {
+	user, ok := User{Name: config.Username, Password: config.Password, Role: config.Role}, username == config.Username
+	if users != nil {
+		user, ok = users[username]
+	}
+	if !ok || subtle.ConstantTimeCompare([]byte(user.Password), []byte(password)) != 1 {
+		return User{}, false
+	}
+	return user, true
+}
+
+func auth(ctx *gin.Context) {
+	username, password, ok := ctx.Request.BasicAuth()
+	if !ok {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": "can't authenticate",
+		})
+		return
+	}
+
+	user, isValid := lookup(username, password)
+	if !isValid {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": "not authorized",
+		})
+		return
+	}
+
+	principal := database.Principal{Name: user.Name, Role: user.Role, Addr: ctx.ClientIP(), Vars: user.Vars}
+	ctx.Request = ctx.Request.WithContext(database.WithPrincipal(ctx.Request.Context(), principal))
+	ctx.Next()
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/gin-gonic/gin"
+	"github.com/trianglehasfoursides/bedroompop/audit"
+	"github.com/trianglehasfoursides/bedroompop/config"
+	"github.com/trianglehasfoursides/bedroompop/consist"
+	"github.com/trianglehasfoursides/bedroompop/database"
+	"google.golang.org/grpc"
+)
+
+func backupMessage(backup database.Backup) *BackupInfo {
+	return &BackupInfo{
+		Id:        backup.ID,
+		Database:  backup.Database,
+		Created:   backup.Created.UnixNano(),
+		Size:      backup.Size,
+		Sha256:    backup.SHA256,
+		Encrypted: backup.Encrypted,
+	}
+}
+
+func backupOf(info *BackupInfo) database.Backup {
+	return database.Backup{
+		ID:        info.GetId(),
+		Database:  info.GetDatabase(),
+		Created:   time.Unix(0, info.GetCreated()).UTC(),
+		Size:      info.GetSize(),
+		SHA256:    info.GetSha256(),
+		Encrypted: info.GetEncrypted(),
+	}
+}
+
+func (s *server) Backup(c context.Context, req *RequestGetDrop) (*ResponseBackups, error) {
+	backup, err := database.TakeBackup(c, req.GetName())
+	record(c, audit.OpBackup, req.GetName(), "", err)
+	if err != nil {
+		return nil, err
+	}
+	return &ResponseBackups{Backups: []*BackupInfo{backupMessage(backup)}}, nil
+}
+
+func (s *server) ListBackups(c context.Context, req *RequestGetDrop) (*ResponseBackups, error) {
+	backups, err := database.Backups(c, req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	resp := &ResponseBackups{}
+	for _, backup := range backups {
+		resp.Backups = append(resp.Backups, backupMessage(backup))
+	}
+	return resp, nil
+}
+
+// backup snapshots a database on the node owning it.
+func backup(ctx *gin.Context) {
+	resp, ok := onOwner(ctx, local.Backup, PopServiceClient.Backup)
+	if !ok {
+		return
+	}
+	ctx.JSON(http.StatusCreated, backupOf(resp.GetBackups()[0]))
+}
+
+// listBackups lists the backups of a database kept by the node owning it.
+func listBackups(ctx *gin.Context) {
+	resp, ok := onOwner(ctx, local.ListBackups, PopServiceClient.ListBackups)
+	if !ok {
+		return
+	}
+	list := []database.Backup{}
+	for _, info := range resp.GetBackups() {
+		list = append(list, backupOf(info))
+	}
+	ctx.JSON(http.StatusOK, gin.H{"backups": list})
+}
+
+// onOwner calls the node owning the database named in the path, answering the request itself on failure.
+func onOwner[Resp any](ctx *gin.Context,
+	call func(context.Context, *RequestGetDrop) (Resp, error),
+	forward func(PopServiceClient, context.Context, *RequestGetDrop, ...grpc.CallOption) (Resp, error),
+) (resp Resp, ok bool) {
+	resp, err := ownerCall(ctx.Request.Context(), &RequestGetDrop{Name: ctx.Param("name")}, call, forward)
+	if err != nil {
+		abort(ctx, err)
+		return resp, false
+	}
+	return resp, true
+}
+
+// ownerCall runs a request about a database on the node owning it.
+func ownerCall[Req interface{ GetName() string }, Resp any](c context.Context, req Req,
+	call func(context.Context, Req) (Resp, error),
+	forward func(PopServiceClient, context.Context, Req, ...grpc.CallOption) (Resp, error),
+) (resp Resp, err error) {
+	if err := database.ValidateName(req.GetName()); err != nil {
+		return resp, err
+	}
+	address := consist.Consist.LocateKey([]byte(req.GetName())).String()
+	if address == config.GRPCAddr {
+		return call(c, req)
+	}
+	client, conn, err := dial(address)
+	if err != nil {
+		return resp, err
+	}
+	defer conn.Close()
+	return forward(client, outgoing(c), req)
+}
+
+// ScheduleBackups backs up every database this node owns once per interval.
+func ScheduleBackups(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		names, err := database.List()
+		if err != nil {
+			log.Error("can't list databases to back up", "err", err)
+			continue
+		}
+		ctx := database.WithPrincipal(context.Background(), database.Principal{Name: config.Name, Role: database.AdminRole})
+		for _, name := range names {
+			if consist.Consist.LocateKey([]byte(name)).String() != config.GRPCAddr {
+				continue
+			}
+			_, err := database.TakeBackup(ctx, name)
+			record(ctx, audit.OpBackup, name, "", err)
+			if err != nil {
+				log.Error("scheduled backup failed", "database", name, "err", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trianglehasfoursides/bedroompop/audit"
+	"github.com/trianglehasfoursides/bedroompop/config"
+	"github.com/trianglehasfoursides/bedroompop/consist"
+	"github.com/trianglehasfoursides/bedroompop/database"
+	"golang.org/x/net/websocket"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+func (s *server) Subscribe(req *RequestSubscribe, stream grpc.ServerStreamingServer[ChangeEvent]) error {
+	err := database.Subscribe(stream.Context(), req.GetName(), req.GetAfter(),
+		func(position database.Position) error {
+			return stream.Send(&ChangeEvent{Subscription: &Subscription{
+				Log:    position.Log,
+				After:  position.After,
+				Oldest: position.Oldest,
+				Latest: position.Latest,
+			}})
+		},
+		func(change database.ChangeEvent) error {
+			return stream.Send(changeMessage(change))
+		})
+	record(stream.Context(), audit.OpSubscribe, req.GetName(), "", err)
+	return err
+}
+
+func changeMessage(change database.ChangeEvent) *ChangeEvent {
+	msg := &ChangeEvent{
+		Offset:  change.Offset,
+		Commit:  change.Commit,
+		Time:    change.Time.UnixNano(),
+		Table:   change.Table,
+		Op:      change.Op,
+		Rowid:   change.RowID,
+		Columns: columnsMessage(change.Columns),
+	}
+	if change.Old != nil {
+		msg.Old = rowMessage(change.Old)
+	}
+	if change.New != nil {
+		msg.New = rowMessage(change.New)
+	}
+	return msg
+}
+
+// subscribeStream hands the changes of a local subscription straight to the response.
+type subscribeStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	send func(*ChangeEvent) error
+}
+
+func (s *subscribeStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *subscribeStream) Send(change *ChangeEvent) error {
+	return s.send(change)
+}
+
+// subscribe follows the changes of a database on the node owning it.
+func subscribe(c context.Context, req *RequestSubscribe, send func(*ChangeEvent) error) error {
+	address := consist.Consist.LocateKey([]byte(req.GetName())).String()
+	if address == config.GRPCAddr {
+		return local.Subscribe(req, &subscribeStream{ctx: c, send: send})
+	}
+
+	client, conn, err := dial(address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	stream, err := client.Subscribe(outgoing(c), req)
+	for err == nil {
+		var change *ChangeEvent
+		if change, err = stream.Recv(); err == nil {
+			err = send(change)
+		}
+	}
+	if errors.Is(err, io.EOF) || c.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// changeObject encodes a change as a JSON object, with its rows as objects,
+// or the position a subscription starts at.
+func changeObject(change *ChangeEvent) ([]byte, error) {
+	if sub := change.GetSubscription(); sub != nil {
+		return json.Marshal(database.Position{Log: sub.GetLog(), After: sub.GetAfter(), Oldest: sub.GetOldest(), Latest: sub.GetLatest()})
+	}
+
+	line := struct {
+		Offset uint64          `json:"offset"`
+		Commit uint64          `json:"commit"`
+		Time   time.Time       `json:"time"`
+		Table  string          `json:"table"`
+		Op     string          `json:"op"`
+		RowID  int64           `json:"rowid"`
+		Old    json.RawMessage `json:"old,omitempty"`
+		New    json.RawMessage `json:"new,omitempty"`
+	}{
+		Offset: change.GetOffset(),
+		Commit: change.GetCommit(),
+		Time:   time.Unix(0, change.GetTime()).UTC(),
+		Table:  change.GetTable(),
+		Op:     change.GetOp(),
+		RowID:  change.GetRowid(),
+	}
+
+	columns := columnsOf(change.GetColumns())
+	var err error
+	if change.GetOld() != nil {
+		if line.Old, err = objectRow(columns, rowOf(change.GetOld())); err != nil {
+			return nil, err
+		}
+	}
+	if change.GetNew() != nil {
+		if line.New, err = objectRow(columns, rowOf(change.GetNew())); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(line)
+}
+
+// changeFeed streams the changes of a database over WebSocket when the
+// request upgrades, and as server-sent events otherwise. The stream starts
+// after the offset in the after parameter, or in the Last-Event-ID header of
+// a reconnecting event source, with every change retained when neither is
+// set, or with new changes for after=latest. The first message holds the
+// position it starts at.
+func changeFeed(ctx *gin.Context) {
+	name := ctx.Param("name")
+	if err := database.ValidateName(name); err != nil {
+		abort(ctx, err)
+		return
+	}
+	after := ctx.Query("after")
+	if id := ctx.GetHeader("Last-Event-ID"); id != "" {
+		after = id
+	}
+	req := &RequestSubscribe{Name: name}
+	switch after {
+	case "":
+	case "latest":
+		req.After = -1
+	default:
+		offset, err := strconv.ParseUint(after, 10, 63)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "after must be an offset or latest",
+			})
+			return
+		}
+		req.After = int64(offset)
+	}
+
+	if strings.EqualFold(ctx.GetHeader("Upgrade"), "websocket") {
+		websocketChanges(ctx, req)
+		return
+	}
+	eventChanges(ctx, req)
+}
+
+// eventChanges sends the changes as server-sent events, with the offset as
+// their id so event sources resume where they left off.
+func eventChanges(ctx *gin.Context, req *RequestSubscribe) {
+	started := false
+	err := subscribe(ctx.Request.Context(), req, func(change *ChangeEvent) error {
+		data, err := changeObject(change)
+		if err != nil {
+			return err
+		}
+		event := "change"
+		if change.GetSubscription() != nil {
+			started = true
+			ctx.Header("Content-Type", "text/event-stream")
+			ctx.Header("Cache-Control", "no-cache")
+			ctx.Status(http.StatusOK)
+			event = "subscribed"
+			fmt.Fprintf(ctx.Writer, "event: %s\ndata: %s\n\n", event, data)
+		} else {
+			fmt.Fprintf(ctx.Writer, "id: %d\nevent: %s\ndata: %s\n\n", change.GetOffset(), event, data)
+		}
+		ctx.Writer.Flush()
+		return nil
+	})
+
+	switch {
+	case err != nil && !started:
+		abort(ctx, err)
+	case err != nil:
+		st := status.Convert(grpcError(err))
+		data, _ := json.Marshal(gin.H{"error": st.Message(), "code": st.Code().String()})
+		fmt.Fprintf(ctx.Writer, "event: error\ndata: %s\n\n", data)
+	}
+}
+
+// websocketChanges sends the changes as WebSocket text messages, until the
+// client closes the connection.
+func websocketChanges(ctx *gin.Context, req *RequestSubscribe) {
+	ws := websocket.Server{
+		// requests are authenticated like any other, whatever their origin
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(conn *websocket.Conn) {
+			c, cancel := context.WithCancel(ctx.Request.Context())
+			defer cancel()
+			go func() {
+				io.Copy(io.Discard, conn)
+				cancel()
+			}()
+
+			err := subscribe(c, req, func(change *ChangeEvent) error {
+				data, err := changeObject(change)
+				if err != nil {
+					return err
+				}
+				if change.GetSubscription() != nil {
+					data, _ = json.Marshal(gin.H{"subscribed": json.RawMessage(data)})
+				}
+				return websocket.Message.Send(conn, string(data))
+			})
+			if err != nil && c.Err() == nil {
+				st := status.Convert(grpcError(err))
+				data, _ := json.Marshal(gin.H{"error": st.Message(), "code": st.Code().String()})
+				websocket.Message.Send(conn, string(data))
+			}
+		},
+	}
+	ws.ServeHTTP(ctx.Writer, ctx.Request)
+}
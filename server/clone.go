@@ -0,0 +1,239 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trianglehasfoursides/bedroompop/audit"
+	"github.com/trianglehasfoursides/bedroompop/config"
+	"github.com/trianglehasfoursides/bedroompop/consist"
+	"github.com/trianglehasfoursides/bedroompop/database"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+func (s *server) ExportSnapshot(req *RequestGetDrop, stream grpc.ServerStreamingServer[RecoveryChunk]) error {
+	return database.ExportSnapshot(stream.Context(), req.GetName(), func(backup database.Backup, r io.Reader) error {
+		return sendFile(stream, backupMessage(backup), "snapshot", r, make([]byte, recoveryChunkSize))
+	})
+}
+
+func (s *server) Clone(c context.Context, req *RequestClone) (*DDLResponse, error) {
+	err := clone(c, req)
+	record(c, audit.OpClone, req.GetName(), "", err)
+	if err != nil {
+		return nil, err
+	}
+	return &DDLResponse{Msg: "sucess"}, nil
+}
+
+// clone fetches a snapshot of the source from the node owning it into a
+// temporary directory and creates the branch from it.
+func clone(c context.Context, req *RequestClone) error {
+	if err := database.ValidateName(req.GetSource()); err != nil {
+		return err
+	}
+	if err := database.Get(req.GetName()); err == nil {
+		return database.ErrExists
+	}
+
+	dir, err := os.MkdirTemp(config.DataDir, ".clone-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	backup, files, err := fetchSnapshot(c, dir, req.GetSource())
+	if err != nil {
+		return err
+	}
+	return database.Clone(c, req.GetName(), files[0], backupOf(backup))
+}
+
+// fetchSnapshot saves a snapshot of a database, taken on the node owning it, into dir.
+func fetchSnapshot(c context.Context, dir string, databaseName string) (*BackupInfo, []string, error) {
+	address := consist.Consist.LocateKey([]byte(databaseName)).String()
+	return fetchRecovery(c, dir, address, &RequestGetDrop{Name: databaseName}, local.ExportSnapshot, PopServiceClient.ExportSnapshot)
+}
+
+func (s *server) Diff(req *RequestGetDrop, stream grpc.ServerStreamingServer[RowChange]) error {
+	err := diff(stream.Context(), req.GetName(), stream.Send)
+	record(stream.Context(), audit.OpDiff, req.GetName(), "", err)
+	return err
+}
+
+// diff compares a branch with its parent as it was branched, sending the
+// branch point and then every change.
+func diff(c context.Context, name string, send func(*RowChange) error) error {
+	branch, err := database.BranchOf(c, name)
+	if err != nil {
+		return err
+	}
+
+	first := &RowChange{Branch: &BranchInfo{Parent: branch.Parent, Branched: branch.Branched.UnixNano()}}
+	if err := send(first); err != nil {
+		return err
+	}
+	return database.Diff(c, name, func(change database.Change) error {
+		msg := &RowChange{Table: change.Table, Op: change.Op, Columns: columnsMessage(change.Columns)}
+		if change.Parent != nil {
+			msg.Parent = rowMessage(change.Parent)
+		}
+		if change.Row != nil {
+			msg.Row = rowMessage(change.Row)
+		}
+		return send(msg)
+	})
+}
+
+// diffStream hands the changes of a local diff straight to the response.
+type diffStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	send func(*RowChange) error
+}
+
+func (s *diffStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *diffStream) Send(change *RowChange) error {
+	return s.send(change)
+}
+
+// cloneDatabase branches the database in the path into a new database on
+// the node owning the target name.
+func cloneDatabase(ctx *gin.Context) {
+	body := struct {
+		Target string `json:"target"`
+	}{}
+	if err := ctx.BindJSON(&body); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	req := &RequestClone{Name: body.Target, Source: ctx.Param("name")}
+	if err := database.ValidateName(req.GetName()); err != nil {
+		abort(ctx, err)
+		return
+	}
+
+	var err error
+	address := consist.Consist.LocateKey([]byte(req.GetName())).String()
+	if address == config.GRPCAddr {
+		_, err = local.Clone(ctx.Request.Context(), req)
+	} else {
+		client, conn, dialErr := dial(address)
+		if dialErr != nil {
+			abort(ctx, dialErr)
+			return
+		}
+		defer conn.Close()
+		_, err = client.Clone(outgoing(ctx.Request.Context()), req)
+	}
+	if err != nil {
+		abort(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"name": req.GetName(), "parent": req.GetSource()})
+}
+
+// diffBranch streams the rows that differ between the branch in the path
+// and its parent as NDJSON, after a line holding the branch point. Updates
+// hold both the parent and the branch row.
+func diffBranch(ctx *gin.Context) {
+	name := ctx.Param("name")
+	if err := database.ValidateName(name); err != nil {
+		abort(ctx, err)
+		return
+	}
+
+	started := false
+	send := func(change *RowChange) error {
+		var line []byte
+		var err error
+		if branch := change.GetBranch(); branch != nil {
+			line, err = json.Marshal(database.Branch{Parent: branch.GetParent(), Branched: time.Unix(0, branch.GetBranched()).UTC()})
+		} else {
+			line, err = changeLine(change)
+		}
+		if err != nil {
+			return err
+		}
+		if !started {
+			started = true
+			ctx.Header("Content-Type", "application/x-ndjson")
+			ctx.Status(http.StatusOK)
+		}
+		if _, err := ctx.Writer.Write(append(line, '\n')); err != nil {
+			return err
+		}
+		ctx.Writer.Flush()
+		return nil
+	}
+
+	var err error
+	address := consist.Consist.LocateKey([]byte(name)).String()
+	if address == config.GRPCAddr {
+		err = local.Diff(&RequestGetDrop{Name: name}, &diffStream{ctx: ctx.Request.Context(), send: send})
+	} else {
+		client, conn, dialErr := dial(address)
+		if dialErr != nil {
+			abort(ctx, dialErr)
+			return
+		}
+		defer conn.Close()
+
+		stream, streamErr := client.Diff(outgoing(ctx.Request.Context()), &RequestGetDrop{Name: name})
+		err = streamErr
+		for err == nil {
+			var change *RowChange
+			if change, err = stream.Recv(); err == nil {
+				err = send(change)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	}
+
+	switch {
+	case err != nil && !started:
+		abort(ctx, err)
+	case err != nil:
+		st := status.Convert(grpcError(err))
+		line, _ := json.Marshal(gin.H{"error": st.Message(), "code": st.Code().String()})
+		ctx.Writer.Write(append(line, '\n'))
+	}
+}
+
+// changeLine encodes a change as a JSON object, with its rows as objects.
+func changeLine(change *RowChange) ([]byte, error) {
+	line := struct {
+		Table  string          `json:"table"`
+		Op     string          `json:"op"`
+		Parent json.RawMessage `json:"parent,omitempty"`
+		Row    json.RawMessage `json:"row,omitempty"`
+	}{Table: change.GetTable(), Op: change.GetOp()}
+
+	columns := columnsOf(change.GetColumns())
+	var err error
+	if change.GetParent() != nil {
+		if line.Parent, err = objectRow(columns, rowOf(change.GetParent())); err != nil {
+			return nil, err
+		}
+	}
+	if change.GetRow() != nil {
+		if line.Row, err = objectRow(columns, rowOf(change.GetRow())); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(line)
+}
@@ -0,0 +1,431 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/gin-gonic/gin"
+	"github.com/trianglehasfoursides/bedroompop/database"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Media types query results can be encoded in.
+const (
+	mediaJSON    = "application/json"
+	mediaNDJSON  = "application/x-ndjson"
+	mediaCSV     = "text/csv"
+	mediaArrow   = "application/vnd.apache.arrow.stream"
+	mediaMsgpack = "application/msgpack"
+)
+
+// mediaTypes are offered to the client in order of preference.
+var mediaTypes = []string{mediaJSON, mediaNDJSON, mediaCSV, mediaArrow, mediaMsgpack, "application/x-msgpack"}
+
+var errNotAcceptable = errors.New("results can only be returned as " + strings.Join(mediaTypes, ", "))
+
+// encodingMedia is the media type produced for a gRPC encoding that returns bytes.
+var encodingMedia = map[Encoding]string{
+	Encoding_ENCODING_CSV:     mediaCSV,
+	Encoding_ENCODING_ARROW:   mediaArrow,
+	Encoding_ENCODING_MSGPACK: mediaMsgpack,
+}
+
+// encoder writes a query result as it is read: begin once with the columns,
+// write for every batch of rows and end after the last one.
+type encoder interface {
+	begin(columns []database.Column) error
+	write(rows [][]any) error
+	end() error
+}
+
+// newEncoder returns the encoder for a media type. format selects the shape of JSON results.
+func newEncoder(media string, format string, w io.Writer) (encoder, error) {
+	switch media {
+	case mediaJSON, mediaNDJSON:
+		switch format {
+		case formatObjects, formatRows, formatColumnar:
+			return &jsonEncoder{w: w, ndjson: media == mediaNDJSON, format: format}, nil
+		}
+		return nil, fmt.Errorf("format must be one of %s, %s or %s", formatObjects, formatRows, formatColumnar)
+	case mediaCSV:
+		return &csvEncoder{w: csv.NewWriter(w)}, nil
+	case mediaArrow:
+		return &arrowEncoder{w: w}, nil
+	case mediaMsgpack, "application/x-msgpack":
+		return &msgpackEncoder{enc: msgpack.NewEncoder(w)}, nil
+	}
+	return nil, fmt.Errorf("results can't be encoded as %s", media)
+}
+
+// Shapes of JSON results, picked with the format parameter.
+const (
+	formatObjects  = "objects"  // an array of objects keyed by column, the original form
+	formatRows     = "rows"     // the typed columns followed by an array of values per row
+	formatColumnar = "columnar" // the typed columns followed by batches holding an array per column
+)
+
+// jsonEncoder writes one JSON document, or one JSON value per line for NDJSON.
+type jsonEncoder struct {
+	w       io.Writer
+	ndjson  bool
+	format  string
+	columns []database.Column
+	items   int
+}
+
+func (e *jsonEncoder) begin(columns []database.Column) error {
+	e.columns = columns
+	if e.format == formatObjects {
+		return nil
+	}
+
+	type column struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	}
+	header := make([]column, len(columns))
+	for i, c := range columns {
+		header[i] = column{Name: c.Name, Type: c.Type}
+	}
+	data, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+
+	var prefix string
+	switch {
+	case e.ndjson:
+		prefix = `{"columns":` + string(data) + "}\n"
+	case e.format == formatRows:
+		prefix = `{"columns":` + string(data) + `,"rows":[`
+	default:
+		prefix = `{"columns":` + string(data) + `,"batches":[`
+	}
+	_, err = io.WriteString(e.w, prefix)
+	return err
+}
+
+func (e *jsonEncoder) write(rows [][]any) error {
+	var items [][]byte
+	switch e.format {
+	case formatObjects:
+		encoded, err := objectRows(e.columns, rows)
+		if err != nil {
+			return err
+		}
+		items = encoded
+	case formatRows:
+		for _, row := range rows {
+			data, err := json.Marshal(row)
+			if err != nil {
+				return err
+			}
+			items = append(items, data)
+		}
+	case formatColumnar:
+		if len(rows) == 0 {
+			break
+		}
+		data := make([][]any, len(e.columns))
+		for i := range e.columns {
+			data[i] = make([]any, len(rows))
+			for r, row := range rows {
+				data[i][r] = row[i]
+			}
+		}
+		encoded, err := json.Marshal(gin.H{"length": len(rows), "data": data})
+		if err != nil {
+			return err
+		}
+		items = append(items, encoded)
+	}
+
+	for _, item := range items {
+		var sep string
+		switch {
+		case e.ndjson:
+			item = append(item, '\n')
+		case e.items == 0 && e.format == formatObjects:
+			sep = "["
+		case e.items > 0:
+			sep = ","
+		}
+		if _, err := io.WriteString(e.w, sep); err != nil {
+			return err
+		}
+		if _, err := e.w.Write(item); err != nil {
+			return err
+		}
+		e.items++
+	}
+	return nil
+}
+
+func (e *jsonEncoder) end() error {
+	var suffix string
+	switch {
+	case e.ndjson:
+	case e.format != formatObjects:
+		suffix = "]}"
+	case e.items == 0:
+		// an empty result has always been answered with null
+		suffix = "null"
+	default:
+		suffix = "]"
+	}
+	_, err := io.WriteString(e.w, suffix)
+	return err
+}
+
+// csvEncoder writes a header with the column names and a record per row.
+// NULL is an empty field and blobs are base64.
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func (e *csvEncoder) begin(columns []database.Column) error {
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = c.Name
+	}
+	return e.w.Write(header)
+}
+
+func (e *csvEncoder) write(rows [][]any) error {
+	for _, row := range rows {
+		record := make([]string, len(row))
+		for i, v := range row {
+			record[i] = text(v)
+		}
+		if err := e.w.Write(record); err != nil {
+			return err
+		}
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvEncoder) end() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// text formats a value for text based formats.
+func text(v any) string {
+	switch v := v.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		return v
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	}
+	return ""
+}
+
+// msgpackEncoder writes a stream of MessagePack values: a map holding the
+// columns, then an array per row.
+type msgpackEncoder struct {
+	enc *msgpack.Encoder
+}
+
+func (e *msgpackEncoder) begin(columns []database.Column) error {
+	header := make([]map[string]string, len(columns))
+	for i, c := range columns {
+		header[i] = map[string]string{"name": c.Name, "type": c.Type}
+	}
+	return e.enc.Encode(map[string]any{"columns": header})
+}
+
+func (e *msgpackEncoder) write(rows [][]any) error {
+	for _, row := range rows {
+		if err := e.enc.Encode(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *msgpackEncoder) end() error {
+	return nil
+}
+
+// arrowEncoder writes an Arrow IPC stream with a record batch per batch of
+// rows. Arrow columns have a single type, taken from the affinity of the
+// declared type, or from the values of the first batch for expressions.
+// Values that can't be represented in the type of their column without loss
+// end the stream with an error, as SQLite lets a column mix types; casting
+// the column in the query picks a type that fits them all.
+type arrowEncoder struct {
+	w       io.Writer
+	columns []database.Column
+	schema  *arrow.Schema
+	writer  *ipc.Writer
+}
+
+func (e *arrowEncoder) begin(columns []database.Column) error {
+	e.columns = columns
+	return nil
+}
+
+func (e *arrowEncoder) start(rows [][]any) {
+	fields := make([]arrow.Field, len(e.columns))
+	for i, c := range e.columns {
+		fields[i] = arrow.Field{Name: c.Name, Type: arrowType(c.Type, rows, i), Nullable: true}
+	}
+	e.schema = arrow.NewSchema(fields, nil)
+	e.writer = ipc.NewWriter(e.w, ipc.WithSchema(e.schema))
+}
+
+func (e *arrowEncoder) write(rows [][]any) error {
+	if e.writer == nil {
+		e.start(rows)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	builder := array.NewRecordBuilder(memory.DefaultAllocator, e.schema)
+	defer builder.Release()
+	for _, row := range rows {
+		for i, v := range row {
+			if !appendArrow(builder.Field(i), v) {
+				return fmt.Errorf("column %s holds %s value that can't be written to an Arrow %s column, cast it in the query",
+					e.columns[i].Name, storageClass(v), e.schema.Field(i).Type)
+			}
+		}
+	}
+
+	record := builder.NewRecord()
+	defer record.Release()
+	return e.writer.Write(record)
+}
+
+func (e *arrowEncoder) end() error {
+	if e.writer == nil {
+		e.start(nil)
+	}
+	return e.writer.Close()
+}
+
+// arrowType follows the affinity rules of SQLite for a declared type and
+// falls back to the values in column i of rows.
+func arrowType(declared string, rows [][]any, i int) arrow.DataType {
+	declared = strings.ToUpper(declared)
+	switch {
+	case strings.Contains(declared, "INT"):
+		return arrow.PrimitiveTypes.Int64
+	case strings.Contains(declared, "CHAR"), strings.Contains(declared, "CLOB"), strings.Contains(declared, "TEXT"):
+		return arrow.BinaryTypes.String
+	case strings.Contains(declared, "BLOB"):
+		return arrow.BinaryTypes.Binary
+	case strings.Contains(declared, "REAL"), strings.Contains(declared, "FLOA"), strings.Contains(declared, "DOUB"):
+		return arrow.PrimitiveTypes.Float64
+	}
+
+	var kind arrow.DataType
+	for _, row := range rows {
+		var t arrow.DataType
+		switch row[i].(type) {
+		case nil:
+			continue
+		case int64:
+			t = arrow.PrimitiveTypes.Int64
+		case float64:
+			t = arrow.PrimitiveTypes.Float64
+		case []byte:
+			t = arrow.BinaryTypes.Binary
+		default:
+			t = arrow.BinaryTypes.String
+		}
+		switch {
+		case kind == nil:
+			kind = t
+		case arrow.TypeEqual(kind, t):
+		case arrow.IsInteger(kind.ID()) && arrow.IsFloating(t.ID()), arrow.IsFloating(kind.ID()) && arrow.IsInteger(t.ID()):
+			kind = arrow.PrimitiveTypes.Float64
+		default:
+			return arrow.BinaryTypes.String
+		}
+	}
+	if kind == nil {
+		return arrow.BinaryTypes.String
+	}
+	return kind
+}
+
+// appendArrow appends v to a column builder, reporting false when the
+// column can't hold it as it is.
+func appendArrow(b array.Builder, v any) bool {
+	if v == nil {
+		b.AppendNull()
+		return true
+	}
+	switch b := b.(type) {
+	case *array.Int64Builder:
+		switch v := v.(type) {
+		case int64:
+			b.Append(v)
+			return true
+		case float64:
+			if v == math.Trunc(v) && v >= math.MinInt64 && v < math.MaxInt64 {
+				b.Append(int64(v))
+				return true
+			}
+		}
+	case *array.Float64Builder:
+		switch v := v.(type) {
+		case int64:
+			// only integers a float64 holds exactly
+			if f := float64(v); f >= math.MinInt64 && f < math.MaxInt64 && int64(f) == v {
+				b.Append(f)
+				return true
+			}
+		case float64:
+			b.Append(v)
+			return true
+		}
+	case *array.StringBuilder:
+		switch v.(type) {
+		case string, int64, float64:
+			b.Append(text(v))
+			return true
+		}
+	case *array.BinaryBuilder:
+		switch v := v.(type) {
+		case []byte:
+			b.Append(v)
+			return true
+		case string:
+			b.Append([]byte(v))
+			return true
+		}
+	}
+	return false
+}
+
+// storageClass names the SQLite storage class of a value, with its article.
+func storageClass(v any) string {
+	switch v.(type) {
+	case int64:
+		return "an integer"
+	case float64:
+		return "a real"
+	case []byte:
+		return "a blob"
+	}
+	return "a text"
+}
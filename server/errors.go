@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mattn/go-sqlite3"
+	"github.com/trianglehasfoursides/bedroompop/database"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// grpcError converts errors of the database package into gRPC status errors.
+func grpcError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	var sqliteErr sqlite3.Error
+	switch {
+	case errors.Is(err, database.ErrNotFound), errors.Is(err, database.ErrCursorNotFound),
+		errors.Is(err, database.ErrBackupNotFound), errors.Is(err, database.ErrTemplateNotFound),
+		errors.Is(err, database.ErrTrashNotFound), errors.Is(err, database.ErrWebhookNotFound),
+		errors.Is(err, database.ErrJobNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, database.ErrExists), errors.Is(err, database.ErrTemplateExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, database.ErrDenied):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, database.ErrInvalidName), errors.Is(err, database.ErrOutsideDataDir),
+		errors.Is(err, database.ErrInvalidPolicy), errors.Is(err, database.ErrInvalidRestore),
+		errors.Is(err, database.ErrInvalidFormat), errors.Is(err, database.ErrInvalidTemplate),
+		errors.Is(err, database.ErrInvalidWebhook), errors.Is(err, database.ErrInvalidJob),
+		errors.Is(err, database.ErrInvalidPattern), errors.Is(err, database.ErrInvalidAttach):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, database.ErrOffsetExpired):
+		return status.Error(codes.OutOfRange, err.Error())
+	case errors.Is(err, database.ErrTooManyCursors), errors.Is(err, database.ErrLimitExceeded),
+		errors.Is(err, database.ErrQuotaExceeded):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, database.ErrNoMasterKey), errors.Is(err, database.ErrNotBranch), errors.Is(err, database.ErrNoBranchBase),
+		errors.Is(err, database.ErrNoChangelog), errors.Is(err, database.ErrNoCapture):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	case errors.As(err, &sqliteErr):
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return status.Error(codes.Unknown, err.Error())
+}
+
+// httpStatus maps a gRPC code onto the HTTP status returned by the gateway.
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists:
+		return http.StatusConflict
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionFailed
+	case codes.OutOfRange:
+		return http.StatusGone
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// abort answers the request with the error, whether it came from the local
+// database or from the node the request was forwarded to. The gRPC code is
+// included so clients see the same code on both APIs.
+func abort(ctx *gin.Context, err error) {
+	if ctxErr := ctx.Request.Context().Err(); ctxErr != nil {
+		// a forwarded call ended by the deadline of the request reports the transport error instead
+		err = ctxErr
+	}
+	st := status.Convert(grpcError(err))
+	retryAfter(ctx, st)
+	ctx.AbortWithStatusJSON(httpStatus(st.Code()), gin.H{
+		"error": st.Message(),
+		"code":  st.Code().String(),
+	})
+}
@@ -0,0 +1,368 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trianglehasfoursides/bedroompop/audit"
+	"github.com/trianglehasfoursides/bedroompop/config"
+	"github.com/trianglehasfoursides/bedroompop/consist"
+	"github.com/trianglehasfoursides/bedroompop/database"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// dataChunkSize is how much an export or import chunk carries.
+const dataChunkSize = 1 << 20
+
+func (s *server) Export(req *RequestExport, stream grpc.ServerStreamingServer[DataChunk]) error {
+	w := bufio.NewWriterSize(chunkWriter(func(data []byte) error {
+		return stream.Send(&DataChunk{Data: data})
+	}), dataChunkSize)
+	err := database.Export(stream.Context(), req.GetName(), req.GetFormat(), w)
+	if err == nil {
+		err = w.Flush()
+	}
+	record(stream.Context(), audit.OpExport, req.GetName(), "", err)
+	return err
+}
+
+func (s *server) Import(stream grpc.ClientStreamingServer[ImportChunk, ResponseImport]) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	r := &chunkReader{data: first.GetData(), next: func() ([]byte, error) {
+		chunk, err := stream.Recv()
+		return chunk.GetData(), err
+	}}
+	var progress func(database.ImportStats)
+	if p, ok := stream.(interface{ progress(database.ImportStats) }); ok {
+		// a local import reports its progress to the HTTP client
+		progress = p.progress
+	}
+	stats, err := database.Import(stream.Context(), first.GetName(), first.GetFormat(), r, progress)
+	record(stream.Context(), audit.OpImport, first.GetName(), "", err)
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(&ResponseImport{Bytes: stats.Bytes, Statements: stats.Statements, Rows: stats.Rows})
+}
+
+// chunkWriter sends every write as a chunk.
+type chunkWriter func([]byte) error
+
+func (w chunkWriter) Write(p []byte) (int, error) {
+	if err := w(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// chunkReader reads the data of chunks as they arrive.
+type chunkReader struct {
+	data []byte
+	next func() ([]byte, error)
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.data) == 0 {
+		data, err := r.next()
+		if err != nil {
+			return 0, err
+		}
+		r.data = data
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+// exportStream hands the chunks of a local export straight to the response.
+type exportStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	send func([]byte) error
+}
+
+func (s *exportStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *exportStream) Send(chunk *DataChunk) error {
+	return s.send(chunk.GetData())
+}
+
+// exportTypes are the content types of the export formats.
+var exportTypes = map[string]string{
+	database.FormatSQL:    "application/sql",
+	database.FormatCSV:    "application/x-tar",
+	database.FormatNDJSON: "application/x-ndjson",
+}
+
+// exportExtensions are the file extensions of the export formats.
+var exportExtensions = map[string]string{
+	database.FormatSQL:    ".sql",
+	database.FormatCSV:    ".tar",
+	database.FormatNDJSON: ".ndjson",
+}
+
+// exportDatabase streams the database in the path as a SQL dump, a tar of
+// CSV files or NDJSON, picked by the format query. An error once the export
+// has started is reported in the X-Export-Error trailer.
+func exportDatabase(ctx *gin.Context) {
+	name := ctx.Param("name")
+	req := &RequestExport{Name: name, Format: ctx.DefaultQuery("format", database.FormatSQL)}
+	contentType, ok := exportTypes[req.GetFormat()]
+	if !ok {
+		abort(ctx, database.ErrInvalidFormat)
+		return
+	}
+
+	started := false
+	send := func(data []byte) error {
+		if !started {
+			started = true
+			ctx.Header("Trailer", "X-Export-Error")
+			ctx.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+				"filename": name + exportExtensions[req.GetFormat()],
+			}))
+			ctx.Data(http.StatusOK, contentType, data)
+		} else if _, err := ctx.Writer.Write(data); err != nil {
+			return err
+		}
+		ctx.Writer.Flush()
+		return nil
+	}
+
+	var err error
+	address := consist.Consist.LocateKey([]byte(name)).String()
+	if address == config.GRPCAddr {
+		err = local.Export(req, &exportStream{ctx: ctx.Request.Context(), send: send})
+	} else {
+		client, conn, dialErr := dial(address)
+		if dialErr != nil {
+			abort(ctx, dialErr)
+			return
+		}
+		defer conn.Close()
+
+		stream, streamErr := client.Export(outgoing(ctx.Request.Context()), req)
+		err = streamErr
+		for err == nil {
+			var chunk *DataChunk
+			if chunk, err = stream.Recv(); err == nil {
+				err = send(chunk.GetData())
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	}
+
+	switch {
+	case err != nil && !started:
+		abort(ctx, err)
+	case err != nil:
+		slog.Warn("export failed", "database", name, "error", err)
+		ctx.Writer.Header().Set("X-Export-Error", err.Error())
+	case !started:
+		// an empty export, such as a tar or NDJSON of a database without tables
+		send(nil)
+	}
+}
+
+// importFormats are the formats uploaded files are taken to be in by their
+// extension, when the format query doesn't say.
+var importFormats = map[string]string{
+	".sql":     database.FormatSQL,
+	".tar":     database.FormatCSV,
+	".ndjson":  database.FormatNDJSON,
+	".jsonl":   database.FormatNDJSON,
+	".sqlite":  database.FormatSQLite,
+	".sqlite3": database.FormatSQLite,
+	".db":      database.FormatSQLite,
+}
+
+// importDatabase loads the request body, or the file of a multipart upload,
+// into the database in the path on the node owning it, in one transaction.
+// Progress is streamed back as NDJSON lines once the import takes a while,
+// ending with a line holding either the totals or the error.
+func importDatabase(ctx *gin.Context) {
+	name := ctx.Param("name")
+	if err := database.ValidateName(name); err != nil {
+		abort(ctx, err)
+		return
+	}
+
+	format := ctx.Query("format")
+	var body io.Reader = ctx.Request.Body
+	if mediaType, _, _ := mime.ParseMediaType(ctx.GetHeader("Content-Type")); mediaType == "multipart/form-data" {
+		reader, err := ctx.Request.MultipartReader()
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		part, err := reader.NextPart()
+		for err == nil && part.FileName() == "" {
+			part, err = reader.NextPart()
+		}
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "the upload holds no file"})
+			return
+		}
+		if format == "" {
+			format = importFormats[strings.ToLower(filepath.Ext(part.FileName()))]
+		}
+		body = part
+	}
+	if format == "" {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "format must be sql, csv, ndjson or sqlite"})
+		return
+	}
+
+	started := false
+	report := func(line any) {
+		if !started {
+			started = true
+			ctx.Header("Content-Type", "application/x-ndjson")
+			ctx.Status(http.StatusOK)
+		}
+		data, _ := json.Marshal(line)
+		ctx.Writer.Write(append(data, '\n'))
+		ctx.Writer.Flush()
+	}
+
+	var stats database.ImportStats
+	var err error
+	address := consist.Consist.LocateKey([]byte(name)).String()
+	if address == config.GRPCAddr {
+		stream := &importStream{ctx: ctx.Request.Context(), body: body, name: name, format: format, report: func(stats database.ImportStats) {
+			report(stats)
+		}}
+		err = local.Import(stream)
+		if stream.response != nil {
+			stats = importStats(stream.response)
+		}
+	} else {
+		stats, err = forwardImport(ctx.Request.Context(), address, name, format, body, func(stats database.ImportStats) {
+			report(stats)
+		})
+	}
+
+	switch {
+	case err != nil && !started:
+		abort(ctx, err)
+	case err != nil:
+		st := status.Convert(grpcError(err))
+		report(gin.H{"error": st.Message(), "code": st.Code().String()})
+	case !started:
+		ctx.JSON(http.StatusOK, gin.H{"name": name, "done": true, "bytes": stats.Bytes, "statements": stats.Statements, "rows": stats.Rows})
+	default:
+		report(gin.H{"name": name, "done": true, "bytes": stats.Bytes, "statements": stats.Statements, "rows": stats.Rows})
+	}
+}
+
+// forwardImport streams an upload to the node owning the database,
+// reporting how much of it was sent now and then.
+func forwardImport(c context.Context, address string, name string, format string, body io.Reader, progress func(database.ImportStats)) (database.ImportStats, error) {
+	client, conn, err := dial(address)
+	if err != nil {
+		return database.ImportStats{}, err
+	}
+	defer conn.Close()
+
+	stream, err := client.Import(outgoing(c))
+	if err != nil {
+		return database.ImportStats{}, err
+	}
+
+	var sent database.ImportStats
+	reported := time.Now()
+	chunk := &ImportChunk{Name: name, Format: format}
+	buf := make([]byte, dataChunkSize)
+	for {
+		n, readErr := io.ReadFull(body, buf)
+		if n > 0 || chunk.Name != "" {
+			chunk.Data = buf[:n]
+			if err := stream.Send(chunk); err != nil {
+				// the node stopped reading, its error is in CloseAndRecv
+				break
+			}
+			chunk = &ImportChunk{}
+			sent.Bytes += int64(n)
+			if time.Since(reported) >= time.Second {
+				reported = time.Now()
+				progress(sent)
+			}
+		}
+		if errors.Is(readErr, io.EOF) || errors.Is(readErr, io.ErrUnexpectedEOF) {
+			break
+		}
+		if readErr != nil {
+			stream.CloseSend()
+			return sent, readErr
+		}
+	}
+
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		return sent, err
+	}
+	return importStats(resp), nil
+}
+
+func importStats(resp *ResponseImport) database.ImportStats {
+	return database.ImportStats{Bytes: resp.GetBytes(), Statements: resp.GetStatements(), Rows: resp.GetRows()}
+}
+
+// importStream feeds a local import straight from the request body.
+type importStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	body     io.Reader
+	name     string
+	format   string
+	buf      []byte
+	report   func(database.ImportStats)
+	response *ResponseImport
+}
+
+func (s *importStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *importStream) Recv() (*ImportChunk, error) {
+	if s.buf == nil {
+		s.buf = make([]byte, dataChunkSize)
+		return &ImportChunk{Name: s.name, Format: s.format}, nil
+	}
+	for {
+		n, err := s.body.Read(s.buf)
+		if n > 0 {
+			return &ImportChunk{Data: s.buf[:n]}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (s *importStream) progress(stats database.ImportStats) {
+	s.report(stats)
+}
+
+func (s *importStream) SendAndClose(resp *ResponseImport) error {
+	s.response = resp
+	return nil
+}
@@ -0,0 +1,204 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trianglehasfoursides/bedroompop/config"
+	"github.com/trianglehasfoursides/bedroompop/consist"
+	"github.com/trianglehasfoursides/bedroompop/database"
+	"google.golang.org/grpc"
+)
+
+// testCluster is a node serving gRPC in process, owning every database, and
+// an HTTP router in front of it. The router takes requests as this node, or
+// as a gateway forwarding them to it.
+type testCluster struct {
+	owner  string
+	router *gin.Engine
+}
+
+func newTestCluster(t *testing.T, forward bool) *testCluster {
+	t.Helper()
+	if err := database.SetDataDir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.SetBackupDir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := grpc.NewServer(grpc.UnaryInterceptor(unaryInterceptor), grpc.StreamInterceptor(streamInterceptor))
+	RegisterPopServiceServer(node, &server{})
+	go node.Serve(listener)
+	t.Cleanup(node.Stop)
+
+	c := &testCluster{owner: listener.Addr().String()}
+	consist.Consist.Add(consist.Member(c.owner))
+	t.Cleanup(func() { consist.Consist.Remove(c.owner) })
+
+	addr := config.GRPCAddr
+	t.Cleanup(func() { config.GRPCAddr = addr })
+	config.GRPCAddr = c.owner
+	if forward {
+		// a node outside the ring, so nothing is found on it
+		config.GRPCAddr = "127.0.0.1:1"
+	}
+
+	gin.SetMode(gin.TestMode)
+	c.router = gin.New()
+	c.router.Use(func(ctx *gin.Context) {
+		principal := database.Principal{Name: "test", Role: database.AdminRole, Addr: ctx.ClientIP()}
+		ctx.Request = ctx.Request.WithContext(database.WithPrincipal(ctx.Request.Context(), principal))
+	})
+	c.router.POST("/:name/import", importDatabase)
+	c.router.GET("/:name/export", exportDatabase)
+	return c
+}
+
+// do sends a request to the router.
+func (c *testCluster) do(method string, target string, contentType string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, bytes.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	w := httptest.NewRecorder()
+	c.router.ServeHTTP(w, req)
+	return w
+}
+
+// lastLine decodes the last NDJSON line of an import response.
+func lastLine(t *testing.T, w *httptest.ResponseRecorder) map[string]any {
+	t.Helper()
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	var line map[string]any
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &line); err != nil {
+		t.Fatalf("response %q: %v", w.Body.String(), err)
+	}
+	return line
+}
+
+// upload builds a multipart form holding a file.
+func upload(t *testing.T, filename string, data []byte) (string, []byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	mw.WriteField("comment", "fields before the file are skipped")
+	part, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return mw.FormDataContentType(), buf.Bytes()
+}
+
+func rowCount(t *testing.T, databaseName string, table string) int64 {
+	t.Helper()
+	ctx := database.WithPrincipal(context.Background(), database.Principal{Name: "test", Role: database.AdminRole})
+	_, rows, err := database.QueryRows(ctx, databaseName, "SELECT count(*) FROM "+table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows[0][0].(int64)
+}
+
+func TestImportDatabase(t *testing.T) {
+	// a dump over several chunks, so forwarding streams it
+	var dump strings.Builder
+	dump.WriteString("CREATE TABLE lines (n INTEGER, text TEXT);\n")
+	for n := 0; dump.Len() < 3*dataChunkSize; n++ {
+		fmt.Fprintf(&dump, "INSERT INTO lines VALUES (%d, '%s');\n", n, strings.Repeat("x", 200))
+	}
+	rows := int64(strings.Count(dump.String(), "INSERT"))
+
+	for _, forward := range []bool{false, true} {
+		t.Run(map[bool]string{false: "local", true: "forwarded"}[forward], func(t *testing.T) {
+			c := newTestCluster(t, forward)
+
+			w := c.do(http.MethodPost, "/big/import?format=sql", "application/sql", []byte(dump.String()))
+			if w.Code != http.StatusOK {
+				t.Fatalf("import = %d %s", w.Code, w.Body)
+			}
+			line := lastLine(t, w)
+			if line["done"] != true || line["rows"] != float64(rows) {
+				t.Errorf("import ended with %v, want %d rows", line, rows)
+			}
+			if line["bytes"] != float64(dump.Len()) {
+				t.Errorf("import read %v bytes, want %d", line["bytes"], dump.Len())
+			}
+			if n := rowCount(t, "big", "lines"); n != rows {
+				t.Errorf("imported %d rows, want %d", n, rows)
+			}
+
+			// the format of an upload comes from the extension of its file
+			contentType, body := upload(t, "events.jsonl", []byte(`{"table":"events","row":{"kind":"signup"}}`+"\n"+`{"table":"events","row":{"kind":"login"}}`))
+			w = c.do(http.MethodPost, "/uploaded/import", contentType, body)
+			if w.Code != http.StatusOK || lastLine(t, w)["rows"] != float64(2) {
+				t.Fatalf("upload = %d %s", w.Code, w.Body)
+			}
+			if n := rowCount(t, "uploaded", "events"); n != 2 {
+				t.Errorf("uploaded %d rows, want 2", n)
+			}
+
+			// an export imports back into another database
+			w = c.do(http.MethodGet, "/uploaded/export?format=ndjson", "", nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("export = %d %s", w.Code, w.Body)
+			}
+			w = c.do(http.MethodPost, "/copy/import?format=ndjson", "application/x-ndjson", w.Body.Bytes())
+			if w.Code != http.StatusOK {
+				t.Fatalf("import of the export = %d %s", w.Code, w.Body)
+			}
+			if n := rowCount(t, "copy", "events"); n != 2 {
+				t.Errorf("copied %d rows, want 2", n)
+			}
+		})
+	}
+}
+
+func TestImportDatabaseErrors(t *testing.T) {
+	for _, forward := range []bool{false, true} {
+		t.Run(map[bool]string{false: "local", true: "forwarded"}[forward], func(t *testing.T) {
+			c := newTestCluster(t, forward)
+
+			if w := c.do(http.MethodPost, "/db/import", "application/sql", []byte("CREATE TABLE t (x);")); w.Code != http.StatusBadRequest {
+				t.Errorf("import without a format = %d %s", w.Code, w.Body)
+			}
+			contentType, body := upload(t, "rows.xml", []byte("<rows/>"))
+			if w := c.do(http.MethodPost, "/db/import", contentType, body); w.Code != http.StatusBadRequest {
+				t.Errorf("upload of an unknown extension = %d %s", w.Code, w.Body)
+			}
+			if w := c.do(http.MethodPost, "/db/import?format=sql", "multipart/form-data; boundary=x", []byte("--x--\r\n")); w.Code != http.StatusBadRequest {
+				t.Errorf("upload without a file = %d %s", w.Code, w.Body)
+			}
+
+			w := c.do(http.MethodPost, "/db/import?format=sqlite", "application/octet-stream", []byte("CREATE TABLE t (x);"))
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "not a SQLite database file") {
+				t.Errorf("import of a dump as a database file = %d %s", w.Code, w.Body)
+			}
+			if err := database.Get("db"); err == nil {
+				t.Error("a failed import left the database it created")
+			}
+
+			w = c.do(http.MethodPost, "/db/import?format=sql", "application/sql", []byte("CREATE TABLE t (x);\nINSERT INTO nowhere VALUES (1);\n"))
+			if w.Code == http.StatusOK && !strings.Contains(w.Body.String(), `"error"`) || !strings.Contains(w.Body.String(), "nowhere") {
+				t.Errorf("import of a failing dump = %d %s", w.Code, w.Body)
+			}
+		})
+	}
+}
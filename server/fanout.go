@@ -0,0 +1,437 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trianglehasfoursides/bedroompop/audit"
+	"github.com/trianglehasfoursides/bedroompop/config"
+	"github.com/trianglehasfoursides/bedroompop/consist"
+	"github.com/trianglehasfoursides/bedroompop/database"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// A fanout query runs one read-only query on every database whose name
+// matches a pattern. The gateway asks every node for the databases it owns
+// and merges their results as they stream in, tagged with the database they
+// come from. A database failing doesn't stop the others.
+
+// FanoutQuery streams the results of the query on the matching databases this
+// node owns, a few databases at a time.
+func (s *server) FanoutQuery(req *RequestFanout, stream grpc.ServerStreamingServer[FanoutBatch]) error {
+	if database.PrincipalFrom(stream.Context()).Role != database.AdminRole {
+		return status.Error(codes.PermissionDenied, "fanout queries are only available to admins")
+	}
+	names, err := database.Match(req.GetPattern())
+	if err != nil {
+		return err
+	}
+	limit := int(req.GetConcurrency())
+	if limit <= 0 || limit > config.FanoutConcurrency {
+		limit = max(config.FanoutConcurrency, 1)
+	}
+
+	// batches of several databases share the stream
+	var mtx sync.Mutex
+	send := func(batch *FanoutBatch) error {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return stream.Send(batch)
+	}
+
+	c, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	slots := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for _, name := range names {
+		if consist.Consist.LocateKey([]byte(name)).String() != config.GRPCAddr {
+			continue
+		}
+		select {
+		case slots <- struct{}{}:
+		case <-c.Done():
+		}
+		if c.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			if err := fanoutDatabase(c, req, name, send); err != nil {
+				// the gateway is gone
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+	return stream.Context().Err()
+}
+
+// fanoutDatabase sends the result of the query on a database, or the error
+// it failed with, and then marks it done. It only fails when sending does.
+func fanoutDatabase(c context.Context, req *RequestFanout, name string, send func(*FanoutBatch) error) error {
+	c, cancel := database.WithTimeout(c, time.Duration(req.GetTimeoutMs())*time.Millisecond)
+	defer cancel()
+
+	var sendErr error
+	first := true
+	err := database.QueryStream(c, name, req.GetQuery(), int(req.GetBatchSize()), func(columns []database.Column, rows [][]any) error {
+		sendErr = send(&FanoutBatch{Database: name, Result: resultSet(columns, rows, first)})
+		first = false
+		return sendErr
+	})
+	record(c, audit.OpFanout, name, req.GetQuery(), err)
+	if sendErr != nil {
+		return sendErr
+	}
+	if err != nil {
+		st := status.Convert(grpcError(err))
+		return send(&FanoutBatch{Database: name, Error: st.Message(), Code: st.Code().String(), Done: true})
+	}
+	return send(&FanoutBatch{Database: name, Done: true})
+}
+
+// fanoutStream hands the batches of a local fanout query to send.
+type fanoutStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	send func(*FanoutBatch) error
+}
+
+func (s *fanoutStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fanoutStream) Send(batch *FanoutBatch) error {
+	return s.send(batch)
+}
+
+// fanout runs req on every node and passes the batches they stream to emit,
+// one at a time. A node that fails is passed as a batch without database.
+func fanout(c context.Context, req *RequestFanout, emit func(node string, batch *FanoutBatch)) {
+	var mtx sync.Mutex
+	var wg sync.WaitGroup
+	for _, member := range consist.Consist.GetMembers() {
+		address := member.String()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := fanoutNode(c, address, req, func(batch *FanoutBatch) error {
+				mtx.Lock()
+				defer mtx.Unlock()
+				emit(address, batch)
+				return nil
+			})
+			if err != nil {
+				st := status.Convert(grpcError(err))
+				mtx.Lock()
+				defer mtx.Unlock()
+				emit(address, &FanoutBatch{Error: st.Message(), Code: st.Code().String()})
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func fanoutNode(c context.Context, address string, req *RequestFanout, send func(*FanoutBatch) error) error {
+	if address == config.GRPCAddr {
+		return local.FanoutQuery(req, &fanoutStream{ctx: c, send: send})
+	}
+
+	client, conn, err := dial(address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	stream, err := client.FanoutQuery(outgoing(c), req)
+	if err != nil {
+		return err
+	}
+	for {
+		batch, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := send(batch); err != nil {
+			return err
+		}
+	}
+}
+
+// fanoutQuery runs a query on the databases matching a pattern across the
+// cluster. Rows stream back as NDJSON lines tagged with their database, the
+// errors of databases and nodes as lines of their own, and a last line counts
+// the databases answered. With aggregate the rows are folded into groups
+// instead, returned as one JSON document.
+func fanoutQuery(ctx *gin.Context) {
+	body := struct {
+		Pattern     string     `json:"pattern"`
+		Query       string     `json:"query"`
+		Concurrency int32      `json:"concurrency"`
+		TimeoutMs   int32      `json:"timeout_ms"`
+		Aggregate   *aggregate `json:"aggregate"`
+	}{}
+	if err := ctx.BindJSON(&body); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if body.Pattern == "" || body.Query == "" {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "pattern and query can't be empty",
+		})
+		return
+	}
+	if body.Aggregate != nil && !body.Aggregate.Count && len(body.Aggregate.Sum) == 0 {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "aggregate needs count or sum",
+		})
+		return
+	}
+	if database.PrincipalFrom(ctx.Request.Context()).Role != database.AdminRole {
+		abort(ctx, status.Error(codes.PermissionDenied, "fanout queries are only available to admins"))
+		return
+	}
+	if _, err := database.Match(body.Pattern); err != nil {
+		abort(ctx, err)
+		return
+	}
+
+	req := &RequestFanout{
+		Pattern:     body.Pattern,
+		Query:       body.Query,
+		Concurrency: body.Concurrency,
+		TimeoutMs:   body.TimeoutMs,
+	}
+	if body.Aggregate != nil {
+		fanoutAggregate(ctx, req, body.Aggregate)
+		return
+	}
+
+	ctx.Header("Content-Type", mediaNDJSON)
+	ctx.Status(http.StatusOK)
+	line := func(v any) {
+		data, _ := json.Marshal(v)
+		ctx.Writer.Write(append(data, '\n'))
+	}
+
+	columns := make(map[string][]database.Column)
+	answered, failed := 0, 0
+	fanout(ctx.Request.Context(), req, func(node string, batch *FanoutBatch) {
+		name := batch.GetDatabase()
+		switch {
+		case name == "":
+			line(gin.H{"node": node, "error": batch.GetError(), "code": batch.GetCode()})
+		case batch.GetError() != "":
+			failed++
+			delete(columns, name)
+			line(gin.H{"database": name, "error": batch.GetError(), "code": batch.GetCode()})
+		case batch.GetDone():
+			answered++
+			delete(columns, name)
+		default:
+			if cols := batch.GetResult().GetColumns(); len(cols) > 0 {
+				columns[name] = columnsOf(cols)
+			}
+			for _, row := range rowsOf(batch.GetResult()) {
+				data, err := objectRow(columns[name], row)
+				if err != nil {
+					continue
+				}
+				line(gin.H{"database": name, "row": json.RawMessage(data)})
+			}
+		}
+		ctx.Writer.Flush()
+	})
+	line(gin.H{"databases": answered, "failed": failed})
+}
+
+// aggregate folds the rows of a fanout query into a row per group of equal
+// group_by columns, counting them and summing the sum columns.
+type aggregate struct {
+	GroupBy []string `json:"group_by"`
+	Count   bool     `json:"count"`
+	Sum     []string `json:"sum"`
+}
+
+type group struct {
+	key   []any
+	count int64
+	sums  []any // int64 while the values are integers, nil until one isn't NULL
+}
+
+// groups are the groups of an aggregate by their encoded key.
+type groups map[string]*group
+
+// add folds the rows of a batch into the groups, the column indexes holding
+// the group_by columns first and then the sum columns.
+func (g groups) add(indexes []int, keys int, rows [][]any) {
+	for _, row := range rows {
+		key := make([]any, keys)
+		for i := range key {
+			key[i] = row[indexes[i]]
+			if b, ok := key[i].([]byte); ok {
+				key[i] = string(b)
+			}
+		}
+		encoded, _ := json.Marshal(key)
+		grp, ok := g[string(encoded)]
+		if !ok {
+			grp = &group{key: key, sums: make([]any, len(indexes)-keys)}
+			g[string(encoded)] = grp
+		}
+		grp.count++
+		for i := range grp.sums {
+			grp.sums[i] = addValue(grp.sums[i], row[indexes[keys+i]])
+		}
+	}
+}
+
+// merge folds other groups into g.
+func (g groups) merge(other groups) {
+	for encoded, grp := range other {
+		into, ok := g[encoded]
+		if !ok {
+			g[encoded] = grp
+			continue
+		}
+		into.count += grp.count
+		for i, sum := range grp.sums {
+			into.sums[i] = addValue(into.sums[i], sum)
+		}
+	}
+}
+
+// addValue adds a value to a sum. NULL, text and blobs are left out.
+func addValue(sum any, v any) any {
+	switch v := v.(type) {
+	case int64:
+		switch s := sum.(type) {
+		case nil:
+			return v
+		case int64:
+			return s + v
+		case float64:
+			return s + float64(v)
+		}
+	case float64:
+		switch s := sum.(type) {
+		case nil:
+			return v
+		case int64:
+			return float64(s) + v
+		case float64:
+			return s + v
+		}
+	}
+	return sum
+}
+
+// fanoutAggregate answers a fanout query with the groups of its rows. The
+// rows of a database only count once its result is complete, so a database
+// failing halfway leaves nothing behind.
+func fanoutAggregate(ctx *gin.Context, req *RequestFanout, agg *aggregate) {
+	total := make(groups)
+	partial := make(map[string]groups)
+	indexes := make(map[string][]int)
+	failed := make(map[string]bool)
+	failures := []gin.H{}
+	answered := 0
+	fanout(ctx.Request.Context(), req, func(node string, batch *FanoutBatch) {
+		name := batch.GetDatabase()
+		switch {
+		case name == "":
+			failures = append(failures, gin.H{"node": node, "error": batch.GetError(), "code": batch.GetCode()})
+		case batch.GetError() != "":
+			if !failed[name] {
+				failures = append(failures, gin.H{"database": name, "error": batch.GetError(), "code": batch.GetCode()})
+			}
+			delete(partial, name)
+			delete(indexes, name)
+			delete(failed, name)
+		case batch.GetDone():
+			if !failed[name] {
+				total.merge(partial[name])
+				answered++
+			}
+			delete(partial, name)
+			delete(indexes, name)
+			delete(failed, name)
+		case failed[name]:
+		default:
+			if cols := batch.GetResult().GetColumns(); len(cols) > 0 {
+				idx, err := aggregateColumns(agg, columnsOf(cols))
+				if err != nil {
+					failed[name] = true
+					failures = append(failures, gin.H{"database": name, "error": err.Error(), "code": codes.InvalidArgument.String()})
+					return
+				}
+				indexes[name], partial[name] = idx, make(groups)
+			}
+			if grps, ok := partial[name]; ok {
+				grps.add(indexes[name], len(agg.GroupBy), rowsOf(batch.GetResult()))
+			}
+		}
+	})
+
+	keys := make([]string, 0, len(total))
+	for key := range total {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	rows := make([]gin.H, 0, len(total))
+	for _, key := range keys {
+		grp := total[key]
+		row := gin.H{}
+		for i, column := range agg.GroupBy {
+			row[column] = grp.key[i]
+		}
+		if agg.Count {
+			row["count"] = grp.count
+		}
+		for i, column := range agg.Sum {
+			row["sum("+column+")"] = grp.sums[i]
+		}
+		rows = append(rows, row)
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"rows":      rows,
+		"databases": answered,
+		"errors":    failures,
+	})
+}
+
+// aggregateColumns finds the group_by and then the sum columns of an
+// aggregate in a result.
+func aggregateColumns(agg *aggregate, columns []database.Column) ([]int, error) {
+	var indexes []int
+	for _, name := range append(append([]string{}, agg.GroupBy...), agg.Sum...) {
+		found := -1
+		for i, column := range columns {
+			if column.Name == name {
+				found = i
+				break
+			}
+		}
+		if found < 0 {
+			return nil, fmt.Errorf("column %s is not in the result", name)
+		}
+		indexes = append(indexes, found)
+	}
+	return indexes, nil
+}
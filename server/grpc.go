@@ -4,24 +4,44 @@ import (
 	"context"
 	"net"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/trianglehasfoursides/bedroompop/audit"
 	"github.com/trianglehasfoursides/bedroompop/config"
 	"github.com/trianglehasfoursides/bedroompop/database"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 )
 
 type server struct{}
 
+// local serves the requests the HTTP gateway doesn't have to forward.
+var local = &server{}
+
 func (s *server) Create(c context.Context, req *RequestCreate) (*DDLResponse, error) {
-	if err := database.Create(req.Name, req.Migration); err != nil {
+	var err error
+	if req.GetTemplate() != "" {
+		err = createFromTemplate(c, req)
+	} else {
+		err = database.Create(c, req.Name, req.Migration)
+	}
+	record(c, audit.OpCreate, req.Name, "", err)
+	if req.Migration != "" {
+		record(c, audit.OpMigration, req.Name, req.Migration, err)
+	}
+	if err != nil {
 		return nil, err
 	}
 	return &DDLResponse{Msg: "sucess"}, nil
 }
 
 func (s *server) Drop(c context.Context, req *RequestGetDrop) (*DDLResponse, error) {
-	if err := database.Drop(req.GetName()); err != nil {
+	err := database.Drop(req.GetName())
+	record(c, audit.OpDrop, req.GetName(), "", err)
+	if err != nil {
 		return nil, err
 	}
 	return &DDLResponse{Msg: "sucess"}, nil
@@ -35,34 +55,225 @@ func (s *server) Get(c context.Context, req *RequestGetDrop) (*DDLResponse, erro
 }
 
 func (s *server) Query(c context.Context, req *RequestQueryExec) (*ResponseQuery, error) {
-	result, err := database.Query(req.GetName(), req.GetQuery())
+	c, cancel := requestTimeout(c, req)
+	defer cancel()
+	c, done, err := withAttached(c, req)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	if req.GetPageSize() > 0 || req.GetCursor() != "" {
+		return page(c, req)
+	}
+	if req.GetEncoding() == Encoding_ENCODING_JSON {
+		result, err := database.Query(c, req.GetName(), req.GetQuery())
+		if err != nil {
+			return nil, err
+		}
+		return &ResponseQuery{Result: result}, nil
+	}
+
+	columns, rows, err := database.QueryRows(c, req.GetName(), req.GetQuery())
+	if err != nil {
+		return nil, err
+	}
+	return queryResponse(req.GetEncoding(), columns, rows)
+}
+
+// requestTimeout bounds a call by the timeout in the request and the maximum of the node.
+func requestTimeout(c context.Context, req *RequestQueryExec) (context.Context, context.CancelFunc) {
+	return database.WithTimeout(c, time.Duration(req.GetTimeoutMs())*time.Millisecond)
+}
+
+// page answers a query with a page of its result, or closes the cursor it names.
+func page(c context.Context, req *RequestQueryExec) (*ResponseQuery, error) {
+	if req.GetCloseCursor() {
+		if err := database.CloseCursor(c, req.GetName(), req.GetCursor()); err != nil {
+			return nil, err
+		}
+		return &ResponseQuery{}, nil
+	}
+
+	var p database.Page
+	var err error
+	if req.GetCursor() != "" {
+		p, err = database.NextPage(c, req.GetName(), req.GetCursor(), int(req.GetPageSize()))
+	} else {
+		p, err = database.QueryPage(c, req.GetName(), req.GetQuery(), int(req.GetPageSize()))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := queryResponse(req.GetEncoding(), p.Columns, p.Rows)
+	if err != nil {
+		return nil, err
+	}
+	resp.Cursor = p.Cursor
+	return resp, nil
+}
+
+func queryResponse(encoding Encoding, columns []database.Column, rows [][]any) (*ResponseQuery, error) {
+	switch encoding {
+	case Encoding_ENCODING_ROWS:
+		return &ResponseQuery{Rows: resultSet(columns, rows, true)}, nil
+	case Encoding_ENCODING_COLUMNAR:
+		return &ResponseQuery{Columnar: columnarBatch(columns, rows, true)}, nil
+	}
+
+	media, ok := encodingMedia[encoding]
+	if !ok {
+		media = mediaJSON
+	}
+	data, err := encode(media, formatObjects, columns, rows)
 	if err != nil {
 		return nil, err
 	}
-	return &ResponseQuery{Result: result}, nil
+	if media == mediaJSON {
+		return &ResponseQuery{Result: data}, nil
+	}
+	return &ResponseQuery{Data: data}, nil
 }
 
 func (s *server) Exec(c context.Context, req *RequestQueryExec) (*ResponseExec, error) {
-	result, err := database.Exec(req.GetName(), req.GetQuery())
+	c, cancel := requestTimeout(c, req)
+	defer cancel()
+
+	result, err := database.Exec(c, req.GetName(), req.GetQuery())
+	record(c, audit.OpExec, req.GetName(), req.GetQuery(), err)
 	if err != nil {
 		return nil, err
 	}
 	return &ResponseExec{Result: result}, nil
 }
 
+func (s *server) RotateKey(c context.Context, req *RequestGetDrop) (*DDLResponse, error) {
+	err := database.RotateKey(req.GetName())
+	record(c, audit.OpRotateKey, req.GetName(), "", err)
+	if err != nil {
+		return nil, err
+	}
+	return &DDLResponse{Msg: "sucess"}, nil
+}
+
+func (s *server) SetRowPolicy(c context.Context, req *RequestRowPolicy) (*DDLResponse, error) {
+	var err error
+	if req.GetDrop() {
+		err = database.DropRowPolicy(c, req.GetName(), req.GetTable())
+	} else {
+		err = database.SetRowPolicy(c, req.GetName(), database.RowPolicy{
+			Table: req.GetTable(),
+			Using: req.GetUsing(),
+			Check: req.GetCheck(),
+		})
+	}
+	record(c, audit.OpPolicy, req.GetName(), req.GetTable()+": "+req.GetUsing()+" / "+req.GetCheck(), err)
+	if err != nil {
+		return nil, err
+	}
+	return &DDLResponse{Msg: "sucess"}, nil
+}
+
 func (s *server) mustEmbedUnimplementedPopServiceServer() {}
 
+// outgoing attaches the principal of the HTTP request to a forwarded call.
+func outgoing(ctx context.Context) context.Context {
+	principal := database.PrincipalFrom(ctx)
+	kv := []string{"principal", principal.Name, "role", principal.Role, "addr", principal.Addr}
+	for k, v := range principal.Vars {
+		kv = append(kv, "var-"+k, v)
+	}
+	return signed(metadata.AppendToOutgoingContext(ctx, kv...), principal)
+}
+
+// incoming restores the principal sent by the forwarding node, once the
+// call is known to come from a node of the cluster.
+func incoming(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	principal := database.Principal{}
+	if v := md.Get("principal"); len(v) > 0 {
+		principal.Name = v[0]
+	}
+	if v := md.Get("role"); len(v) > 0 {
+		principal.Role = v[0]
+	}
+	if v := md.Get("addr"); len(v) > 0 {
+		principal.Addr = v[0]
+	}
+	for k, v := range md {
+		if name, ok := strings.CutPrefix(k, "var-"); ok && len(v) > 0 {
+			if principal.Vars == nil {
+				principal.Vars = make(map[string]string)
+			}
+			principal.Vars[name] = v[0]
+		}
+	}
+	if err := verifyCall(md, principal); err != nil {
+		return nil, err
+	}
+	if principal.Addr == "" {
+		if p, ok := peer.FromContext(ctx); ok {
+			principal.Addr = p.Addr.String()
+			if host, _, err := net.SplitHostPort(principal.Addr); err == nil {
+				principal.Addr = host
+			}
+		}
+	}
+	return database.WithPrincipal(ctx, principal), nil
+}
+
+func unaryInterceptor(c context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	c, err := incoming(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := rateLimitRequest(c, req); err != nil {
+		return nil, err
+	}
+	resp, err := handler(c, req)
+	return resp, grpcError(err)
+}
+
+// serverStream carries the context restored by incoming into a stream handler.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// RecvMsg rate limits the request of a stream once it is read.
+func (s *serverStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return rateLimitRequest(s.ctx, m)
+}
+
+func streamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	c, err := incoming(ss.Context())
+	if err != nil {
+		return err
+	}
+	return grpcError(handler(srv, &serverStream{ServerStream: ss, ctx: c}))
+}
+
 func GRPCStart(ch chan os.Signal) {
 	listener, err := net.Listen("tcp", config.GRPCAddr)
 	if err != nil {
 		zap.L().Sugar().Panic(err.Error())
 	}
-	popServer := grpc.NewServer()
+	popServer := grpc.NewServer(grpc.UnaryInterceptor(unaryInterceptor), grpc.StreamInterceptor(streamInterceptor))
 	popService := &server{}
 	RegisterPopServiceServer(popServer, popService)
 
-	<-ch
-	popServer.GracefulStop()
+	go func() {
+		<-ch
+		popServer.GracefulStop()
+	}()
 
 	if err := popServer.Serve(listener); err != nil {
 		zap.L().Sugar().Panic(err.Error())
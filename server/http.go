@@ -1,10 +1,13 @@
 package server
 
 import (
+	"expvar"
+	"io"
 	"net/http"
 
 	"context"
 	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/trianglehasfoursides/bedroompop/config"
@@ -16,35 +19,51 @@ import (
 	"go.uber.org/zap"
 )
 
-func auth(ctx *gin.Context) {
-	username, password, ok := ctx.Request.BasicAuth()
-	if !ok {
-		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-			"error": "can't authenticate",
-		})
-	}
-
-	isValid := (username == config.Username) && (password == config.Password)
-	if !isValid {
-		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-			"error": "not authorized",
-		})
-		return
-	}
-
-	ctx.Next()
-}
-
 func Start(ch chan os.Signal) {
 	// router
 	router := gin.Default()
 
-	router.Use(auth)
+	router.Use(auth, rateLimited)
+	router.GET("/debug/vars", gin.WrapH(expvar.Handler()))
 	router.POST("/", create)
 	router.GET("/:name", get)
 	router.DELETE("/:name", drop)
 	router.PUT("query/:name", query)
 	router.PUT("exec/:name", exec)
+	router.POST("/:name/rotate-key", rotateKey)
+	router.POST("/:name/backup", backup)
+	router.GET("/:name/backups", listBackups)
+	router.POST("/:name/restore", restoreBackup)
+	router.GET("/:name/export", exportDatabase)
+	router.POST("/:name/import", importDatabase)
+	router.POST("/:name/clone", cloneDatabase)
+	router.GET("/:name/diff", diffBranch)
+	router.GET("/:name/changes", changeFeed)
+	router.POST("/:name/webhooks", createWebhook)
+	router.GET("/:name/webhooks", listWebhooks)
+	router.DELETE("/:name/webhooks/:id", deleteWebhook)
+	router.GET("/:name/webhooks/:id/deliveries", listDeliveries)
+	router.GET("/:name/webhooks/:id/dead", listDeadLetters)
+	router.POST("/:name/webhooks/:id/dead/redeliver", redeliverDeadLetters)
+	router.DELETE("/:name/webhooks/:id/dead", discardDeadLetters)
+	router.PUT("/:name/jobs/:job", putJob)
+	router.GET("/:name/jobs", listJobs)
+	router.DELETE("/:name/jobs/:job", deleteJob)
+	router.GET("/:name/jobs/:job/runs", listJobRuns)
+	router.POST("/:name/jobs/:job/run", runJobNow)
+	router.GET("/templates", listTemplates)
+	router.PUT("/fanout/query", fanoutQuery)
+	router.PUT("/templates/:name/:version", publishTemplate)
+	router.DELETE("/templates/:name/:version", deleteTemplate)
+	router.GET("/trash", listTrash)
+	router.POST("/trash/:name/restore", restoreTrash)
+	router.DELETE("/trash/:name", purgeTrash)
+	router.GET("/audit", auditLog)
+	router.GET("/usage", usage)
+	router.GET("/ratelimits", getRateLimits)
+	router.PUT("/ratelimits", putRateLimits)
+	router.PUT("/:name/policies", setRowPolicy)
+	router.DELETE("/:name/policies/:table", dropRowPolicy)
 
 	// HTTP server
 	server := &http.Server{
@@ -62,203 +81,296 @@ func Start(ch chan os.Signal) {
 	}
 }
 
+// dial opens a client to the node at address.
+func dial(address string) (PopServiceClient, *grpc.ClientConn, error) {
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewPopServiceClient(conn), conn, nil
+}
+
 func create(ctx *gin.Context) {
 	req := struct {
 		Name      string `json:"name"`
 		Migration string `json:"migration"`
+		Template  string `json:"template"`
 	}{}
 
-	if err := ctx.BindJSON(req); err != nil {
+	if err := ctx.BindJSON(&req); err != nil {
 		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
 			"error": err.Error(),
 		})
+		return
 	}
 
-	if req.Name == "" {
-		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"error": "name can't be empty",
-		})
+	if err := database.ValidateName(req.Name); err != nil {
+		abort(ctx, err)
+		return
 	}
 
 	address := consist.Consist.LocateKey([]byte(req.Name)).String()
 	if address == config.GRPCAddr {
-		if err := database.Create(req.Name, req.Migration); err != nil {
-			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-				"error": err.Error(),
-			})
+		if _, err := local.Create(ctx.Request.Context(), &RequestCreate{
+			Name:      req.Name,
+			Migration: req.Migration,
+			Template:  req.Template,
+		}); err != nil {
+			abort(ctx, err)
+			return
 		}
+		ctx.JSON(http.StatusCreated, gin.H{"msg": "sucess"})
+		return
 	}
 
-	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	client, conn, err := dial(address)
 	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+		abort(ctx, err)
 		return
 	}
 	defer conn.Close()
-	client := NewPopServiceClient(conn)
 
-	if _, err := client.Create(ctx, &RequestCreate{
+	if _, err := client.Create(outgoing(ctx.Request.Context()), &RequestCreate{
 		Name:      req.Name,
 		Migration: req.Migration,
+		Template:  req.Template,
 	}); err != nil {
-		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+		abort(ctx, err)
+		return
 	}
+	ctx.JSON(http.StatusCreated, gin.H{"msg": "sucess"})
 }
 
 func get(ctx *gin.Context) {
 	name := ctx.Param("name")
-	if name == "" {
-		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"error": "name can't be empty",
-		})
+	if err := database.ValidateName(name); err != nil {
+		abort(ctx, err)
 		return
 	}
 
 	address := consist.Consist.LocateKey([]byte(name)).String()
 	if address == config.GRPCAddr {
 		if err := database.Get(name); err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-				"error": err.Error(),
-			})
+			abort(ctx, err)
 			return
 		}
+		ctx.JSON(http.StatusOK, gin.H{"msg": "sucess"})
+		return
 	}
 
-	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	client, conn, err := dial(address)
 	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+		abort(ctx, err)
 		return
 	}
 	defer conn.Close()
-	client := NewPopServiceClient(conn)
 
-	if _, err := client.Get(ctx, &RequestGetDrop{
+	if _, err := client.Get(outgoing(ctx.Request.Context()), &RequestGetDrop{
 		Name: name,
 	}); err != nil {
-		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		abort(ctx, err)
+		return
 	}
+	ctx.JSON(http.StatusOK, gin.H{"msg": "sucess"})
 }
 
 func drop(ctx *gin.Context) {
 	name := ctx.Param("name")
-	if name == "" {
-		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"error": "name can't be empty",
-		})
+	if err := database.ValidateName(name); err != nil {
+		abort(ctx, err)
 		return
 	}
 
 	address := consist.Consist.LocateKey([]byte(name)).String()
 	if address == config.GRPCAddr {
-		if err := database.Drop(name); err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-				"error": err.Error(),
-			})
+		if _, err := local.Drop(ctx.Request.Context(), &RequestGetDrop{Name: name}); err != nil {
+			abort(ctx, err)
 			return
 		}
+		ctx.JSON(http.StatusOK, gin.H{"msg": "sucess"})
+		return
 	}
 
-	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	client, conn, err := dial(address)
 	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+		abort(ctx, err)
 		return
 	}
 	defer conn.Close()
-	client := NewPopServiceClient(conn)
 
-	if _, err := client.Drop(ctx, &RequestGetDrop{
+	if _, err := client.Drop(outgoing(ctx.Request.Context()), &RequestGetDrop{
 		Name: name,
 	}); err != nil {
-		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		abort(ctx, err)
+		return
 	}
+	ctx.JSON(http.StatusOK, gin.H{"msg": "sucess"})
 }
 
 func query(ctx *gin.Context) {
 	name := ctx.Param("name")
 	req := struct {
-		Query string `json:"query"`
+		Query     string            `json:"query"`
+		PageSize  int32             `json:"page_size"`
+		Cursor    string            `json:"cursor"`
+		Close     bool              `json:"close"`
+		TimeoutMs int32             `json:"timeout_ms"`
+		Attach    map[string]string `json:"attach"` // alias to database
 	}{}
 
-	if err := ctx.BindJSON(req); err != nil {
+	if err := ctx.BindJSON(&req); err != nil {
 		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
 
-	if name == "" {
-		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"error": "name can't be empty",
-		})
+	if err := database.ValidateName(name); err != nil {
+		abort(ctx, err)
 		return
 	}
 
-	if req.Query == "" {
+	if req.Query == "" && req.Cursor == "" {
 		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
 			"error": "query can't be empty",
 		})
 		return
 	}
 
+	cancel := withTimeout(ctx, req.TimeoutMs)
+	defer cancel()
+
+	w, code, err := newRowWriter(ctx)
+	if err != nil {
+		ctx.AbortWithStatusJSON(code, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
 	address := consist.Consist.LocateKey([]byte(name)).String()
+	if req.PageSize > 0 || req.Cursor != "" {
+		queryPage(ctx, w, address, &RequestQueryExec{
+			Name:        name,
+			Query:       req.Query,
+			Encoding:    Encoding_ENCODING_ROWS,
+			PageSize:    req.PageSize,
+			Cursor:      req.Cursor,
+			CloseCursor: req.Close,
+			TimeoutMs:   req.TimeoutMs,
+			Attach:      req.Attach,
+		})
+		return
+	}
+
 	if address == config.GRPCAddr {
-		if _, err := database.Query(name, req.Query); err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-				"error": err.Error(),
-			})
+		c, done, err := withAttached(ctx.Request.Context(), &RequestQueryExec{Name: name, Attach: req.Attach})
+		if err != nil {
+			abort(ctx, err)
 			return
 		}
+		defer done()
+		err = database.QueryStream(c, name, req.Query, database.DefaultBatchSize, w.write)
+		w.finish(err)
+		return
 	}
 
-	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	client, conn, err := dial(address)
 	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+		abort(ctx, err)
 		return
 	}
 	defer conn.Close()
-	client := NewPopServiceClient(conn)
 
-	if _, err := client.Query(ctx, &RequestQueryExec{
-		Name:  name,
-		Query: req.Query,
-	}); err != nil {
-		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+	stream, err := client.QueryStream(outgoing(ctx.Request.Context()), &RequestQueryExec{
+		Name:      name,
+		Query:     req.Query,
+		Encoding:  Encoding_ENCODING_ROWS,
+		TimeoutMs: req.TimeoutMs,
+		Attach:    req.Attach,
+	})
+	if err != nil {
+		abort(ctx, err)
+		return
 	}
+	var columns []database.Column
+	for {
+		batch, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err == nil {
+			if batch.GetResult().GetColumns() != nil {
+				columns = columnsOf(batch.GetResult().GetColumns())
+			}
+			err = w.write(columns, rowsOf(batch.GetResult()))
+		}
+		if err != nil {
+			// returning cancels the request context and with it the stream
+			w.finish(err)
+			return
+		}
+	}
+	w.finish(nil)
+}
+
+// withTimeout bounds the request by the timeout the client asked for and the
+// maximum of the node. Forwarded calls carry the deadline along.
+func withTimeout(ctx *gin.Context, timeoutMs int32) context.CancelFunc {
+	c, cancel := database.WithTimeout(ctx.Request.Context(), time.Duration(timeoutMs)*time.Millisecond)
+	ctx.Request = ctx.Request.WithContext(c)
+	return cancel
+}
+
+// cursorHeader carries the cursor of the next page, so results in any format can be paged.
+const cursorHeader = "X-Cursor"
+
+// queryPage answers a paged query from the node owning the database, which keeps the cursors.
+func queryPage(ctx *gin.Context, w *rowWriter, address string, req *RequestQueryExec) {
+	var resp *ResponseQuery
+	var err error
+	if address == config.GRPCAddr {
+		resp, err = local.Query(ctx.Request.Context(), req)
+	} else {
+		client, conn, dialErr := dial(address)
+		if dialErr != nil {
+			abort(ctx, dialErr)
+			return
+		}
+		defer conn.Close()
+		resp, err = client.Query(outgoing(ctx.Request.Context()), req)
+	}
+	if err != nil {
+		abort(ctx, err)
+		return
+	}
+
+	if req.GetCloseCursor() {
+		ctx.JSON(http.StatusOK, gin.H{"msg": "sucess"})
+		return
+	}
+	if resp.GetCursor() != "" {
+		ctx.Header(cursorHeader, resp.GetCursor())
+	}
+	w.finish(w.write(columnsOf(resp.GetRows().GetColumns()), rowsOf(resp.GetRows())))
 }
 
 func exec(ctx *gin.Context) {
 	name := ctx.Param("name")
 	req := struct {
-		Query string `json:"query"`
+		Query     string `json:"query"`
+		TimeoutMs int32  `json:"timeout_ms"`
 	}{}
 
-	if err := ctx.BindJSON(req); err != nil {
+	if err := ctx.BindJSON(&req); err != nil {
 		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
 
-	if name == "" {
-		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"error": "name can't be empty",
-		})
+	if err := database.ValidateName(name); err != nil {
+		abort(ctx, err)
 		return
 	}
 
@@ -269,32 +381,133 @@ func exec(ctx *gin.Context) {
 		return
 	}
 
+	cancel := withTimeout(ctx, req.TimeoutMs)
+	defer cancel()
+
 	address := consist.Consist.LocateKey([]byte(name)).String()
 	if address == config.GRPCAddr {
-		if _, err := database.Exec(name, req.Query); err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-				"error": err.Error(),
-			})
+		resp, err := local.Exec(ctx.Request.Context(), &RequestQueryExec{
+			Name:      name,
+			Query:     req.Query,
+			TimeoutMs: req.TimeoutMs,
+		})
+		if err != nil {
+			abort(ctx, err)
 			return
 		}
+		ctx.JSON(http.StatusOK, gin.H{"result": resp.GetResult()})
+		return
 	}
 
-	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	client, conn, err := dial(address)
 	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+		abort(ctx, err)
 		return
 	}
 	defer conn.Close()
-	client := NewPopServiceClient(conn)
 
-	if _, err := client.Exec(ctx, &RequestQueryExec{
-		Name:  name,
-		Query: req.Query,
+	resp, err := client.Exec(outgoing(ctx.Request.Context()), &RequestQueryExec{
+		Name:      name,
+		Query:     req.Query,
+		TimeoutMs: req.TimeoutMs,
+	})
+	if err != nil {
+		abort(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"result": resp.GetResult()})
+}
+
+func rotateKey(ctx *gin.Context) {
+	name := ctx.Param("name")
+	if err := database.ValidateName(name); err != nil {
+		abort(ctx, err)
+		return
+	}
+
+	address := consist.Consist.LocateKey([]byte(name)).String()
+	if address == config.GRPCAddr {
+		if _, err := local.RotateKey(ctx.Request.Context(), &RequestGetDrop{Name: name}); err != nil {
+			abort(ctx, err)
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"msg": "sucess"})
+		return
+	}
+
+	client, conn, err := dial(address)
+	if err != nil {
+		abort(ctx, err)
+		return
+	}
+	defer conn.Close()
+
+	if _, err := client.RotateKey(outgoing(ctx.Request.Context()), &RequestGetDrop{
+		Name: name,
 	}); err != nil {
-		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+		abort(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"msg": "sucess"})
+}
+
+func setRowPolicy(ctx *gin.Context) {
+	name := ctx.Param("name")
+	req := struct {
+		Table string `json:"table"`
+		Using string `json:"using"`
+		Check string `json:"check"`
+	}{}
+
+	if err := ctx.BindJSON(&req); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
 			"error": err.Error(),
 		})
+		return
+	}
+
+	rowPolicy(ctx, &RequestRowPolicy{
+		Name:  name,
+		Table: req.Table,
+		Using: req.Using,
+		Check: req.Check,
+	})
+}
+
+func dropRowPolicy(ctx *gin.Context) {
+	rowPolicy(ctx, &RequestRowPolicy{
+		Name:  ctx.Param("name"),
+		Table: ctx.Param("table"),
+		Drop:  true,
+	})
+}
+
+func rowPolicy(ctx *gin.Context, req *RequestRowPolicy) {
+	if err := database.ValidateName(req.Name); err != nil {
+		abort(ctx, err)
+		return
+	}
+
+	address := consist.Consist.LocateKey([]byte(req.Name)).String()
+	if address == config.GRPCAddr {
+		if _, err := local.SetRowPolicy(ctx.Request.Context(), req); err != nil {
+			abort(ctx, err)
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"msg": "sucess"})
+		return
+	}
+
+	client, conn, err := dial(address)
+	if err != nil {
+		abort(ctx, err)
+		return
+	}
+	defer conn.Close()
+
+	if _, err := client.SetRowPolicy(outgoing(ctx.Request.Context()), req); err != nil {
+		abort(ctx, err)
+		return
 	}
+	ctx.JSON(http.StatusOK, gin.H{"msg": "sucess"})
 }
@@ -0,0 +1,368 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/gin-gonic/gin"
+	"github.com/trianglehasfoursides/bedroompop/audit"
+	"github.com/trianglehasfoursides/bedroompop/config"
+	"github.com/trianglehasfoursides/bedroompop/database"
+)
+
+// Jobs are run by the node owning their database, as the principal who
+// scheduled them, through database.Exec like any other statement. A run due
+// while the previous one is still going is skipped. Failed runs are logged,
+// counted in job_runs and posted to the alert URL of the job.
+
+// JobRuns counts the runs of jobs on this node by outcome.
+var JobRuns = expvar.NewMap("job_runs")
+
+// jobChanges wakes up RunJobs when jobs were scheduled or removed.
+var jobChanges = make(chan struct{}, 1)
+
+func jobsChanged() {
+	select {
+	case jobChanges <- struct{}{}:
+	default:
+	}
+}
+
+// runningJobs holds the jobs running on this node, by database and name.
+var runningJobs = struct {
+	sync.Mutex
+	m map[string]bool
+}{m: make(map[string]bool)}
+
+func jobMessage(job database.Job) *Job {
+	msg := &Job{
+		Name:     job.Name,
+		Schedule: job.Schedule,
+		Timezone: job.Timezone,
+		Sql:      job.SQL,
+		AlertUrl: job.AlertURL,
+		Owner:    job.Owner.Name,
+		Created:  job.Created.UnixNano(),
+		Updated:  job.Updated.UnixNano(),
+	}
+	if next := job.Next(time.Now()); !next.IsZero() {
+		msg.Next = next.UnixNano()
+	}
+	return msg
+}
+
+// jobObject is a job as the gateway shows it.
+type jobObject struct {
+	database.Job
+	Owner string     `json:"owner"`
+	Next  *time.Time `json:"next,omitempty"`
+}
+
+func jobsOf(resp *ResponseJobs) []jobObject {
+	jobs := []jobObject{}
+	for _, msg := range resp.GetJobs() {
+		job := jobObject{
+			Job: database.Job{
+				Name:     msg.GetName(),
+				Schedule: msg.GetSchedule(),
+				Timezone: msg.GetTimezone(),
+				SQL:      msg.GetSql(),
+				AlertURL: msg.GetAlertUrl(),
+				Created:  time.Unix(0, msg.GetCreated()).UTC(),
+				Updated:  time.Unix(0, msg.GetUpdated()).UTC(),
+			},
+			Owner: msg.GetOwner(),
+		}
+		if msg.GetNext() != 0 {
+			next := time.Unix(0, msg.GetNext()).UTC()
+			if location, err := time.LoadLocation(msg.GetTimezone()); err == nil {
+				next = next.In(location)
+			}
+			job.Next = &next
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+func jobRunsMessage(runs []database.JobRun) *ResponseJobRuns {
+	resp := &ResponseJobRuns{}
+	for _, run := range runs {
+		msg := &JobRun{
+			Id:       run.ID,
+			Job:      run.Job,
+			Started:  run.Started.UnixNano(),
+			Duration: int64(run.Duration),
+			Status:   run.Status,
+			Error:    run.Error,
+			Rows:     run.Rows,
+		}
+		if !run.Scheduled.IsZero() {
+			msg.Scheduled = run.Scheduled.UnixNano()
+		}
+		resp.Runs = append(resp.Runs, msg)
+	}
+	return resp
+}
+
+func jobRunsOf(resp *ResponseJobRuns) []database.JobRun {
+	runs := []database.JobRun{}
+	for _, msg := range resp.GetRuns() {
+		run := database.JobRun{
+			ID:       msg.GetId(),
+			Job:      msg.GetJob(),
+			Started:  time.Unix(0, msg.GetStarted()).UTC(),
+			Duration: time.Duration(msg.GetDuration()),
+			Status:   msg.GetStatus(),
+			Error:    msg.GetError(),
+			Rows:     msg.GetRows(),
+		}
+		if msg.GetScheduled() != 0 {
+			run.Scheduled = time.Unix(0, msg.GetScheduled()).UTC()
+		}
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+func (s *server) PutJob(c context.Context, req *RequestJob) (*ResponseJobs, error) {
+	def := req.GetDefinition()
+	job, err := database.PutJob(c, req.GetName(), database.Job{
+		Name:     req.GetJob(),
+		Schedule: def.GetSchedule(),
+		Timezone: def.GetTimezone(),
+		SQL:      def.GetSql(),
+		AlertURL: def.GetAlertUrl(),
+	})
+	record(c, audit.OpSchedule, req.GetName(), req.GetJob()+" "+def.GetSchedule()+": "+def.GetSql(), err)
+	if err != nil {
+		return nil, err
+	}
+	jobsChanged()
+	return &ResponseJobs{Jobs: []*Job{jobMessage(job)}}, nil
+}
+
+func (s *server) ListJobs(c context.Context, req *RequestJob) (*ResponseJobs, error) {
+	jobs, err := database.Jobs(c, req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	resp := &ResponseJobs{}
+	for _, job := range jobs {
+		resp.Jobs = append(resp.Jobs, jobMessage(job))
+	}
+	return resp, nil
+}
+
+func (s *server) DeleteJob(c context.Context, req *RequestJob) (*ResponseJobs, error) {
+	err := database.DeleteJob(c, req.GetName(), req.GetJob())
+	record(c, audit.OpUnschedule, req.GetName(), req.GetJob(), err)
+	if err != nil {
+		return nil, err
+	}
+	jobsChanged()
+	return &ResponseJobs{Jobs: []*Job{{Name: req.GetJob()}}}, nil
+}
+
+func (s *server) ListJobRuns(c context.Context, req *RequestJob) (*ResponseJobRuns, error) {
+	runs, err := database.JobRuns(c, req.GetName(), req.GetJob())
+	if err != nil {
+		return nil, err
+	}
+	return jobRunsMessage(runs), nil
+}
+
+// RunJob runs a job now, out of its schedule, and returns the run.
+func (s *server) RunJob(c context.Context, req *RequestJob) (*ResponseJobRuns, error) {
+	job, err := database.FindJob(c, req.GetName(), req.GetJob())
+	if err != nil {
+		return nil, err
+	}
+	run, err := runJob(context.WithoutCancel(c), req.GetName(), job, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	return jobRunsMessage([]database.JobRun{run}), nil
+}
+
+// putJob schedules the job named in the path from a JSON body holding its
+// schedule, sql, and optionally its timezone and alert_url.
+func putJob(ctx *gin.Context) {
+	body := struct {
+		Schedule string `json:"schedule"`
+		Timezone string `json:"timezone"`
+		SQL      string `json:"sql"`
+		AlertURL string `json:"alert_url"`
+	}{}
+	if err := ctx.BindJSON(&body); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	req := &RequestJob{Name: ctx.Param("name"), Job: ctx.Param("job"), Definition: &Job{
+		Schedule: body.Schedule,
+		Timezone: body.Timezone,
+		Sql:      body.SQL,
+		AlertUrl: body.AlertURL,
+	}}
+	resp, err := ownerCall(ctx.Request.Context(), req, local.PutJob, PopServiceClient.PutJob)
+	if err != nil {
+		abort(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"job": jobsOf(resp)[0]})
+}
+
+func listJobs(ctx *gin.Context) {
+	req := &RequestJob{Name: ctx.Param("name")}
+	resp, err := ownerCall(ctx.Request.Context(), req, local.ListJobs, PopServiceClient.ListJobs)
+	if err != nil {
+		abort(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"jobs": jobsOf(resp)})
+}
+
+func deleteJob(ctx *gin.Context) {
+	req := &RequestJob{Name: ctx.Param("name"), Job: ctx.Param("job")}
+	if _, err := ownerCall(ctx.Request.Context(), req, local.DeleteJob, PopServiceClient.DeleteJob); err != nil {
+		abort(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"deleted": req.GetJob()})
+}
+
+// listJobRuns returns the last runs of a job, most recent first.
+func listJobRuns(ctx *gin.Context) {
+	req := &RequestJob{Name: ctx.Param("name"), Job: ctx.Param("job")}
+	resp, err := ownerCall(ctx.Request.Context(), req, local.ListJobRuns, PopServiceClient.ListJobRuns)
+	if err != nil {
+		abort(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"runs": jobRunsOf(resp)})
+}
+
+func runJobNow(ctx *gin.Context) {
+	req := &RequestJob{Name: ctx.Param("name"), Job: ctx.Param("job")}
+	resp, err := ownerCall(ctx.Request.Context(), req, local.RunJob, PopServiceClient.RunJob)
+	if err != nil {
+		abort(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"run": jobRunsOf(resp)[0]})
+}
+
+// RunJobs keeps the jobs of the databases this node owns on schedule,
+// looking for jobs scheduled or removed and for databases changing owners
+// once per interval.
+func RunJobs(interval time.Duration) {
+	supervise(interval, jobChanges, "jobs", func(ctx context.Context, databaseName string) (map[string]func(context.Context), error) {
+		jobs, err := database.Jobs(ctx, databaseName)
+		tasks := make(map[string]func(context.Context))
+		for _, job := range jobs {
+			// a job scheduled again starts over with its new schedule
+			key := job.Name + "@" + strconv.FormatInt(job.Updated.UnixNano(), 10)
+			tasks[key] = func(c context.Context) { scheduleJob(c, databaseName, job) }
+		}
+		return tasks, err
+	})
+}
+
+// scheduleJob starts the runs of a job when they are due, until ctx is done.
+// Runs already started carry on.
+func scheduleJob(ctx context.Context, databaseName string, job database.Job) {
+	for {
+		next := job.Next(time.Now())
+		if next.IsZero() {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
+		}
+		go func() {
+			if _, err := runJob(context.WithoutCancel(ctx), databaseName, job, next); err != nil {
+				log.Error("can't record job run", "database", databaseName, "job", job.Name, "err", err)
+			}
+		}()
+	}
+}
+
+// runJob runs a job unless it is running already, records the run in its
+// history and raises the alarm if it failed.
+func runJob(ctx context.Context, databaseName string, job database.Job, scheduled time.Time) (database.JobRun, error) {
+	run := database.JobRun{Job: job.Name, Scheduled: scheduled, Started: time.Now().UTC()}
+
+	key := databaseName + "/" + job.Name
+	runningJobs.Lock()
+	skipped := runningJobs.m[key]
+	runningJobs.m[key] = true
+	runningJobs.Unlock()
+
+	if skipped {
+		run.Status = database.RunSkipped
+		run.Error = "the previous run is still going"
+	} else {
+		c := database.WithPrincipal(ctx, job.Owner)
+		rows, err := database.Exec(c, databaseName, job.SQL)
+		record(c, audit.OpJob, databaseName, job.Name+": "+job.SQL, err)
+		run.Duration, run.Rows = time.Since(run.Started), rows
+		run.Status = database.RunSucceeded
+		if err != nil {
+			run.Status, run.Error = database.RunFailed, err.Error()
+		}
+
+		runningJobs.Lock()
+		delete(runningJobs.m, key)
+		runningJobs.Unlock()
+	}
+	JobRuns.Add(run.Status, 1)
+
+	run, err := database.RecordRun(ctx, databaseName, run)
+	if run.Status == database.RunFailed {
+		log.Error("job failed", "database", databaseName, "job", job.Name, "err", run.Error)
+		if job.AlertURL != "" {
+			alertJob(ctx, databaseName, job, run)
+		}
+	}
+	return run, err
+}
+
+// alertJob posts a failed run to the alert URL of its job.
+func alertJob(ctx context.Context, databaseName string, job database.Job, run database.JobRun) {
+	body, err := json.Marshal(gin.H{"database": databaseName, "job": job.Name, "run": run})
+	if err != nil {
+		return
+	}
+	c, cancel := context.WithTimeout(ctx, config.WebhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(c, http.MethodPost, job.AlertURL, bytes.NewReader(body))
+	if err != nil {
+		log.Error("can't alert of failed job", "database", databaseName, "job", job.Name, "err", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Bedroompop-Job")
+	resp, err := http.DefaultClient.Do(req)
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			err = fmt.Errorf("alert answered %s", resp.Status)
+		}
+	}
+	if err != nil {
+		log.Error("can't alert of failed job", "database", databaseName, "job", job.Name, "err", err)
+	}
+}
@@ -23,10 +23,126 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// Encoding selects how query results are returned.
+type Encoding int32
+
+const (
+	Encoding_ENCODING_JSON     Encoding = 0 // JSON objects in result or rows, the original form
+	Encoding_ENCODING_ROWS     Encoding = 1 // typed rows in ResultSet
+	Encoding_ENCODING_COLUMNAR Encoding = 2 // typed columns in ColumnarBatch
+	Encoding_ENCODING_CSV      Encoding = 3 // text/csv in data
+	Encoding_ENCODING_ARROW    Encoding = 4 // an Arrow IPC stream in data
+	Encoding_ENCODING_MSGPACK  Encoding = 5 // a stream of MessagePack values in data
+)
+
+// Enum value maps for Encoding.
+var (
+	Encoding_name = map[int32]string{
+		0: "ENCODING_JSON",
+		1: "ENCODING_ROWS",
+		2: "ENCODING_COLUMNAR",
+		3: "ENCODING_CSV",
+		4: "ENCODING_ARROW",
+		5: "ENCODING_MSGPACK",
+	}
+	Encoding_value = map[string]int32{
+		"ENCODING_JSON":     0,
+		"ENCODING_ROWS":     1,
+		"ENCODING_COLUMNAR": 2,
+		"ENCODING_CSV":      3,
+		"ENCODING_ARROW":    4,
+		"ENCODING_MSGPACK":  5,
+	}
+)
+
+func (x Encoding) Enum() *Encoding {
+	p := new(Encoding)
+	*p = x
+	return p
+}
+
+func (x Encoding) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Encoding) Descriptor() protoreflect.EnumDescriptor {
+	return file_message_proto_enumTypes[0].Descriptor()
+}
+
+func (Encoding) Type() protoreflect.EnumType {
+	return &file_message_proto_enumTypes[0]
+}
+
+func (x Encoding) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Encoding.Descriptor instead.
+func (Encoding) EnumDescriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{0}
+}
+
+// Kind is the storage class of a value in a ColumnVector.
+type Kind int32
+
+const (
+	Kind_KIND_NULL    Kind = 0
+	Kind_KIND_INTEGER Kind = 1
+	Kind_KIND_REAL    Kind = 2
+	Kind_KIND_TEXT    Kind = 3
+	Kind_KIND_BLOB    Kind = 4
+)
+
+// Enum value maps for Kind.
+var (
+	Kind_name = map[int32]string{
+		0: "KIND_NULL",
+		1: "KIND_INTEGER",
+		2: "KIND_REAL",
+		3: "KIND_TEXT",
+		4: "KIND_BLOB",
+	}
+	Kind_value = map[string]int32{
+		"KIND_NULL":    0,
+		"KIND_INTEGER": 1,
+		"KIND_REAL":    2,
+		"KIND_TEXT":    3,
+		"KIND_BLOB":    4,
+	}
+)
+
+func (x Kind) Enum() *Kind {
+	p := new(Kind)
+	*p = x
+	return p
+}
+
+func (x Kind) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Kind) Descriptor() protoreflect.EnumDescriptor {
+	return file_message_proto_enumTypes[1].Descriptor()
+}
+
+func (Kind) Type() protoreflect.EnumType {
+	return &file_message_proto_enumTypes[1]
+}
+
+func (x Kind) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Kind.Descriptor instead.
+func (Kind) EnumDescriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{1}
+}
+
 type RequestCreate struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
 	Migration     string                 `protobuf:"bytes,2,opt,name=migration,proto3" json:"migration,omitempty"`
+	Template      string                 `protobuf:"bytes,3,opt,name=template,proto3" json:"template,omitempty"` // name@version, or the name alone for its newest version
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -75,6 +191,13 @@ func (x *RequestCreate) GetMigration() string {
 	return ""
 }
 
+func (x *RequestCreate) GetTemplate() string {
+	if x != nil {
+		return x.Template
+	}
+	return ""
+}
+
 type RequestGetDrop struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
@@ -124,6 +247,13 @@ type RequestQueryExec struct {
 	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
 	Query         string                 `protobuf:"bytes,2,opt,name=query,proto3" json:"query,omitempty"`
 	Args          []*anypb.Any           `protobuf:"bytes,3,rep,name=args,proto3" json:"args,omitempty"`
+	BatchSize     int32                  `protobuf:"varint,4,opt,name=batch_size,json=batchSize,proto3" json:"batch_size,omitempty"` // rows per QueryStream message, 0 for the default
+	Encoding      Encoding               `protobuf:"varint,5,opt,name=encoding,proto3,enum=message.Encoding" json:"encoding,omitempty"`
+	PageSize      int32                  `protobuf:"varint,6,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`                                                       // when set Query returns the first page and a cursor
+	Cursor        string                 `protobuf:"bytes,7,opt,name=cursor,proto3" json:"cursor,omitempty"`                                                                            // fetches the next page of an earlier Query
+	CloseCursor   bool                   `protobuf:"varint,8,opt,name=close_cursor,json=closeCursor,proto3" json:"close_cursor,omitempty"`                                              // releases cursor instead of fetching from it
+	TimeoutMs     int32                  `protobuf:"varint,9,opt,name=timeout_ms,json=timeoutMs,proto3" json:"timeout_ms,omitempty"`                                                    // interrupts the statement after this long, capped by the node maximum
+	Attach        map[string]string      `protobuf:"bytes,10,rep,name=attach,proto3" json:"attach,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // alias to the name of a database the query also reads
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -179,27 +309,77 @@ func (x *RequestQueryExec) GetArgs() []*anypb.Any {
 	return nil
 }
 
-type DDLResponse struct {
+func (x *RequestQueryExec) GetBatchSize() int32 {
+	if x != nil {
+		return x.BatchSize
+	}
+	return 0
+}
+
+func (x *RequestQueryExec) GetEncoding() Encoding {
+	if x != nil {
+		return x.Encoding
+	}
+	return Encoding_ENCODING_JSON
+}
+
+func (x *RequestQueryExec) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *RequestQueryExec) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+func (x *RequestQueryExec) GetCloseCursor() bool {
+	if x != nil {
+		return x.CloseCursor
+	}
+	return false
+}
+
+func (x *RequestQueryExec) GetTimeoutMs() int32 {
+	if x != nil {
+		return x.TimeoutMs
+	}
+	return 0
+}
+
+func (x *RequestQueryExec) GetAttach() map[string]string {
+	if x != nil {
+		return x.Attach
+	}
+	return nil
+}
+
+type Column struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Msg           string                 `protobuf:"bytes,1,opt,name=msg,proto3" json:"msg,omitempty"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	DeclType      string                 `protobuf:"bytes,2,opt,name=decl_type,json=declType,proto3" json:"decl_type,omitempty"` // declared type of the source column, empty for expressions
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *DDLResponse) Reset() {
-	*x = DDLResponse{}
+func (x *Column) Reset() {
+	*x = Column{}
 	mi := &file_message_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *DDLResponse) String() string {
+func (x *Column) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*DDLResponse) ProtoMessage() {}
+func (*Column) ProtoMessage() {}
 
-func (x *DDLResponse) ProtoReflect() protoreflect.Message {
+func (x *Column) ProtoReflect() protoreflect.Message {
 	mi := &file_message_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -211,39 +391,53 @@ func (x *DDLResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use DDLResponse.ProtoReflect.Descriptor instead.
-func (*DDLResponse) Descriptor() ([]byte, []int) {
+// Deprecated: Use Column.ProtoReflect.Descriptor instead.
+func (*Column) Descriptor() ([]byte, []int) {
 	return file_message_proto_rawDescGZIP(), []int{3}
 }
 
-func (x *DDLResponse) GetMsg() string {
+func (x *Column) GetName() string {
 	if x != nil {
-		return x.Msg
+		return x.Name
 	}
 	return ""
 }
 
-type ResponseQuery struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Result        []byte                 `protobuf:"bytes,1,opt,name=result,proto3" json:"result,omitempty"`
+func (x *Column) GetDeclType() string {
+	if x != nil {
+		return x.DeclType
+	}
+	return ""
+}
+
+type Value struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Kind:
+	//
+	//	*Value_Integer
+	//	*Value_Real
+	//	*Value_Text
+	//	*Value_Blob
+	//	*Value_Null
+	Kind          isValue_Kind `protobuf_oneof:"kind"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ResponseQuery) Reset() {
-	*x = ResponseQuery{}
+func (x *Value) Reset() {
+	*x = Value{}
 	mi := &file_message_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ResponseQuery) String() string {
+func (x *Value) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ResponseQuery) ProtoMessage() {}
+func (*Value) ProtoMessage() {}
 
-func (x *ResponseQuery) ProtoReflect() protoreflect.Message {
+func (x *Value) ProtoReflect() protoreflect.Message {
 	mi := &file_message_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -255,39 +449,118 @@ func (x *ResponseQuery) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ResponseQuery.ProtoReflect.Descriptor instead.
-func (*ResponseQuery) Descriptor() ([]byte, []int) {
+// Deprecated: Use Value.ProtoReflect.Descriptor instead.
+func (*Value) Descriptor() ([]byte, []int) {
 	return file_message_proto_rawDescGZIP(), []int{4}
 }
 
-func (x *ResponseQuery) GetResult() []byte {
+func (x *Value) GetKind() isValue_Kind {
 	if x != nil {
-		return x.Result
+		return x.Kind
 	}
 	return nil
 }
 
-type ResponseExec struct {
+func (x *Value) GetInteger() int64 {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_Integer); ok {
+			return x.Integer
+		}
+	}
+	return 0
+}
+
+func (x *Value) GetReal() float64 {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_Real); ok {
+			return x.Real
+		}
+	}
+	return 0
+}
+
+func (x *Value) GetText() string {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_Text); ok {
+			return x.Text
+		}
+	}
+	return ""
+}
+
+func (x *Value) GetBlob() []byte {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_Blob); ok {
+			return x.Blob
+		}
+	}
+	return nil
+}
+
+func (x *Value) GetNull() bool {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_Null); ok {
+			return x.Null
+		}
+	}
+	return false
+}
+
+type isValue_Kind interface {
+	isValue_Kind()
+}
+
+type Value_Integer struct {
+	Integer int64 `protobuf:"varint,1,opt,name=integer,proto3,oneof"`
+}
+
+type Value_Real struct {
+	Real float64 `protobuf:"fixed64,2,opt,name=real,proto3,oneof"`
+}
+
+type Value_Text struct {
+	Text string `protobuf:"bytes,3,opt,name=text,proto3,oneof"`
+}
+
+type Value_Blob struct {
+	Blob []byte `protobuf:"bytes,4,opt,name=blob,proto3,oneof"`
+}
+
+type Value_Null struct {
+	Null bool `protobuf:"varint,5,opt,name=null,proto3,oneof"`
+}
+
+func (*Value_Integer) isValue_Kind() {}
+
+func (*Value_Real) isValue_Kind() {}
+
+func (*Value_Text) isValue_Kind() {}
+
+func (*Value_Blob) isValue_Kind() {}
+
+func (*Value_Null) isValue_Kind() {}
+
+type Row struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Result        int64                  `protobuf:"varint,1,opt,name=result,proto3" json:"result,omitempty"`
+	Values        []*Value               `protobuf:"bytes,1,rep,name=values,proto3" json:"values,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ResponseExec) Reset() {
-	*x = ResponseExec{}
+func (x *Row) Reset() {
+	*x = Row{}
 	mi := &file_message_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ResponseExec) String() string {
+func (x *Row) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ResponseExec) ProtoMessage() {}
+func (*Row) ProtoMessage() {}
 
-func (x *ResponseExec) ProtoReflect() protoreflect.Message {
+func (x *Row) ProtoReflect() protoreflect.Message {
 	mi := &file_message_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -299,104 +572,3901 @@ func (x *ResponseExec) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ResponseExec.ProtoReflect.Descriptor instead.
-func (*ResponseExec) Descriptor() ([]byte, []int) {
+// Deprecated: Use Row.ProtoReflect.Descriptor instead.
+func (*Row) Descriptor() ([]byte, []int) {
 	return file_message_proto_rawDescGZIP(), []int{5}
 }
 
-func (x *ResponseExec) GetResult() int64 {
+func (x *Row) GetValues() []*Value {
 	if x != nil {
-		return x.Result
+		return x.Values
 	}
-	return 0
+	return nil
 }
 
-var File_message_proto protoreflect.FileDescriptor
+type ResultSet struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Columns       []*Column              `protobuf:"bytes,1,rep,name=columns,proto3" json:"columns,omitempty"`
+	Rows          []*Row                 `protobuf:"bytes,2,rep,name=rows,proto3" json:"rows,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
 
-const file_message_proto_rawDesc = "" +
-	"\n" +
-	"\rmessage.proto\x12\amessage\x1a\x19google/protobuf/any.proto\"A\n" +
-	"\rRequestCreate\x12\x12\n" +
-	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1c\n" +
-	"\tmigration\x18\x02 \x01(\tR\tmigration\"$\n" +
-	"\x0eRequestGetDrop\x12\x12\n" +
-	"\x04name\x18\x01 \x01(\tR\x04name\"f\n" +
-	"\x10RequestQueryExec\x12\x12\n" +
-	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
-	"\x05query\x18\x02 \x01(\tR\x05query\x12(\n" +
-	"\x04args\x18\x03 \x03(\v2\x14.google.protobuf.AnyR\x04args\"\x1f\n" +
-	"\vDDLResponse\x12\x10\n" +
-	"\x03msg\x18\x01 \x01(\tR\x03msg\"'\n" +
-	"\rResponseQuery\x12\x16\n" +
-	"\x06result\x18\x01 \x01(\fR\x06result\"&\n" +
-	"\fResponseExec\x12\x16\n" +
-	"\x06result\x18\x01 \x01(\x03R\x06result2\xb1\x02\n" +
-	"\n" +
-	"PopService\x128\n" +
-	"\x06Create\x12\x16.message.RequestCreate\x1a\x14.message.DDLResponse\"\x00\x126\n" +
-	"\x03Get\x12\x17.message.RequestGetDrop\x1a\x14.message.DDLResponse\"\x00\x127\n" +
-	"\x04Drop\x12\x17.message.RequestGetDrop\x1a\x14.message.DDLResponse\"\x00\x12<\n" +
-	"\x05Query\x12\x19.message.RequestQueryExec\x1a\x16.message.ResponseQuery\"\x00\x12:\n" +
-	"\x04Exec\x12\x19.message.RequestQueryExec\x1a\x15.message.ResponseExec\"\x00B\x10Z\x0ebedroompop/popb\x06proto3"
+func (x *ResultSet) Reset() {
+	*x = ResultSet{}
+	mi := &file_message_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
 
-var (
-	file_message_proto_rawDescOnce sync.Once
-	file_message_proto_rawDescData []byte
-)
+func (x *ResultSet) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
 
-func file_message_proto_rawDescGZIP() []byte {
-	file_message_proto_rawDescOnce.Do(func() {
-		file_message_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_message_proto_rawDesc), len(file_message_proto_rawDesc)))
-	})
-	return file_message_proto_rawDescData
+func (*ResultSet) ProtoMessage() {}
+
+func (x *ResultSet) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
 }
 
-var file_message_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
-var file_message_proto_goTypes = []any{
-	(*RequestCreate)(nil),    // 0: message.RequestCreate
-	(*RequestGetDrop)(nil),   // 1: message.RequestGetDrop
-	(*RequestQueryExec)(nil), // 2: message.RequestQueryExec
-	(*DDLResponse)(nil),      // 3: message.DDLResponse
-	(*ResponseQuery)(nil),    // 4: message.ResponseQuery
-	(*ResponseExec)(nil),     // 5: message.ResponseExec
-	(*anypb.Any)(nil),        // 6: google.protobuf.Any
+// Deprecated: Use ResultSet.ProtoReflect.Descriptor instead.
+func (*ResultSet) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{6}
 }
-var file_message_proto_depIdxs = []int32{
-	6, // 0: message.RequestQueryExec.args:type_name -> google.protobuf.Any
-	0, // 1: message.PopService.Create:input_type -> message.RequestCreate
-	1, // 2: message.PopService.Get:input_type -> message.RequestGetDrop
-	1, // 3: message.PopService.Drop:input_type -> message.RequestGetDrop
-	2, // 4: message.PopService.Query:input_type -> message.RequestQueryExec
-	2, // 5: message.PopService.Exec:input_type -> message.RequestQueryExec
-	3, // 6: message.PopService.Create:output_type -> message.DDLResponse
-	3, // 7: message.PopService.Get:output_type -> message.DDLResponse
-	3, // 8: message.PopService.Drop:output_type -> message.DDLResponse
-	4, // 9: message.PopService.Query:output_type -> message.ResponseQuery
-	5, // 10: message.PopService.Exec:output_type -> message.ResponseExec
-	6, // [6:11] is the sub-list for method output_type
-	1, // [1:6] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+
+func (x *ResultSet) GetColumns() []*Column {
+	if x != nil {
+		return x.Columns
+	}
+	return nil
 }
 
-func init() { file_message_proto_init() }
-func file_message_proto_init() {
-	if File_message_proto != nil {
-		return
+func (x *ResultSet) GetRows() []*Row {
+	if x != nil {
+		return x.Rows
+	}
+	return nil
+}
+
+// ColumnVector holds one column of a batch. SQLite columns aren't bound to a
+// single type, so kinds has one Kind per row and the values of each kind are
+// packed in row order in the matching list. Nulls have no value.
+type ColumnVector struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Kinds         []byte                 `protobuf:"bytes,1,opt,name=kinds,proto3" json:"kinds,omitempty"`
+	Integers      []int64                `protobuf:"varint,2,rep,packed,name=integers,proto3" json:"integers,omitempty"`
+	Reals         []float64              `protobuf:"fixed64,3,rep,packed,name=reals,proto3" json:"reals,omitempty"`
+	Texts         []string               `protobuf:"bytes,4,rep,name=texts,proto3" json:"texts,omitempty"`
+	Blobs         [][]byte               `protobuf:"bytes,5,rep,name=blobs,proto3" json:"blobs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ColumnVector) Reset() {
+	*x = ColumnVector{}
+	mi := &file_message_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ColumnVector) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ColumnVector) ProtoMessage() {}
+
+func (x *ColumnVector) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ColumnVector.ProtoReflect.Descriptor instead.
+func (*ColumnVector) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ColumnVector) GetKinds() []byte {
+	if x != nil {
+		return x.Kinds
+	}
+	return nil
+}
+
+func (x *ColumnVector) GetIntegers() []int64 {
+	if x != nil {
+		return x.Integers
+	}
+	return nil
+}
+
+func (x *ColumnVector) GetReals() []float64 {
+	if x != nil {
+		return x.Reals
+	}
+	return nil
+}
+
+func (x *ColumnVector) GetTexts() []string {
+	if x != nil {
+		return x.Texts
+	}
+	return nil
+}
+
+func (x *ColumnVector) GetBlobs() [][]byte {
+	if x != nil {
+		return x.Blobs
+	}
+	return nil
+}
+
+type ColumnarBatch struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Columns       []*Column              `protobuf:"bytes,1,rep,name=columns,proto3" json:"columns,omitempty"`
+	Length        int64                  `protobuf:"varint,2,opt,name=length,proto3" json:"length,omitempty"`
+	Vectors       []*ColumnVector        `protobuf:"bytes,3,rep,name=vectors,proto3" json:"vectors,omitempty"` // one per column, in column order
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ColumnarBatch) Reset() {
+	*x = ColumnarBatch{}
+	mi := &file_message_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ColumnarBatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ColumnarBatch) ProtoMessage() {}
+
+func (x *ColumnarBatch) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ColumnarBatch.ProtoReflect.Descriptor instead.
+func (*ColumnarBatch) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ColumnarBatch) GetColumns() []*Column {
+	if x != nil {
+		return x.Columns
+	}
+	return nil
+}
+
+func (x *ColumnarBatch) GetLength() int64 {
+	if x != nil {
+		return x.Length
+	}
+	return 0
+}
+
+func (x *ColumnarBatch) GetVectors() []*ColumnVector {
+	if x != nil {
+		return x.Vectors
+	}
+	return nil
+}
+
+type DDLResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Msg           string                 `protobuf:"bytes,1,opt,name=msg,proto3" json:"msg,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DDLResponse) Reset() {
+	*x = DDLResponse{}
+	mi := &file_message_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DDLResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DDLResponse) ProtoMessage() {}
+
+func (x *DDLResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DDLResponse.ProtoReflect.Descriptor instead.
+func (*DDLResponse) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *DDLResponse) GetMsg() string {
+	if x != nil {
+		return x.Msg
+	}
+	return ""
+}
+
+type ResponseQuery struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Result        []byte                 `protobuf:"bytes,1,opt,name=result,proto3" json:"result,omitempty"`     // ENCODING_JSON
+	Rows          *ResultSet             `protobuf:"bytes,2,opt,name=rows,proto3" json:"rows,omitempty"`         // ENCODING_ROWS
+	Columnar      *ColumnarBatch         `protobuf:"bytes,3,opt,name=columnar,proto3" json:"columnar,omitempty"` // ENCODING_COLUMNAR
+	Data          []byte                 `protobuf:"bytes,4,opt,name=data,proto3" json:"data,omitempty"`         // ENCODING_CSV, ENCODING_ARROW and ENCODING_MSGPACK
+	Cursor        string                 `protobuf:"bytes,5,opt,name=cursor,proto3" json:"cursor,omitempty"`     // the next page, empty once the result is exhausted
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResponseQuery) Reset() {
+	*x = ResponseQuery{}
+	mi := &file_message_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResponseQuery) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResponseQuery) ProtoMessage() {}
+
+func (x *ResponseQuery) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResponseQuery.ProtoReflect.Descriptor instead.
+func (*ResponseQuery) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ResponseQuery) GetResult() []byte {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+func (x *ResponseQuery) GetRows() *ResultSet {
+	if x != nil {
+		return x.Rows
+	}
+	return nil
+}
+
+func (x *ResponseQuery) GetColumnar() *ColumnarBatch {
+	if x != nil {
+		return x.Columnar
+	}
+	return nil
+}
+
+func (x *ResponseQuery) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *ResponseQuery) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+// ResponseQueryBatch carries the rows in the form asked for. Column lists are
+// only set on the first batch.
+type ResponseQueryBatch struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Columns       []string               `protobuf:"bytes,1,rep,name=columns,proto3" json:"columns,omitempty"`
+	Rows          [][]byte               `protobuf:"bytes,2,rep,name=rows,proto3" json:"rows,omitempty"`         // ENCODING_JSON, one JSON object per row
+	Result        *ResultSet             `protobuf:"bytes,3,opt,name=result,proto3" json:"result,omitempty"`     // ENCODING_ROWS
+	Columnar      *ColumnarBatch         `protobuf:"bytes,4,opt,name=columnar,proto3" json:"columnar,omitempty"` // ENCODING_COLUMNAR
+	Data          []byte                 `protobuf:"bytes,5,opt,name=data,proto3" json:"data,omitempty"`         // ENCODING_CSV, ENCODING_ARROW and ENCODING_MSGPACK, chunks of one document
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResponseQueryBatch) Reset() {
+	*x = ResponseQueryBatch{}
+	mi := &file_message_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResponseQueryBatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResponseQueryBatch) ProtoMessage() {}
+
+func (x *ResponseQueryBatch) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResponseQueryBatch.ProtoReflect.Descriptor instead.
+func (*ResponseQueryBatch) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ResponseQueryBatch) GetColumns() []string {
+	if x != nil {
+		return x.Columns
+	}
+	return nil
+}
+
+func (x *ResponseQueryBatch) GetRows() [][]byte {
+	if x != nil {
+		return x.Rows
+	}
+	return nil
+}
+
+func (x *ResponseQueryBatch) GetResult() *ResultSet {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+func (x *ResponseQueryBatch) GetColumnar() *ColumnarBatch {
+	if x != nil {
+		return x.Columnar
+	}
+	return nil
+}
+
+func (x *ResponseQueryBatch) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type ResponseExec struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Result        int64                  `protobuf:"varint,1,opt,name=result,proto3" json:"result,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResponseExec) Reset() {
+	*x = ResponseExec{}
+	mi := &file_message_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResponseExec) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResponseExec) ProtoMessage() {}
+
+func (x *ResponseExec) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResponseExec.ProtoReflect.Descriptor instead.
+func (*ResponseExec) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ResponseExec) GetResult() int64 {
+	if x != nil {
+		return x.Result
+	}
+	return 0
+}
+
+type RequestRowPolicy struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Table         string                 `protobuf:"bytes,2,opt,name=table,proto3" json:"table,omitempty"`
+	Using         string                 `protobuf:"bytes,3,opt,name=using,proto3" json:"using,omitempty"`
+	Check         string                 `protobuf:"bytes,4,opt,name=check,proto3" json:"check,omitempty"`
+	Drop          bool                   `protobuf:"varint,5,opt,name=drop,proto3" json:"drop,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RequestRowPolicy) Reset() {
+	*x = RequestRowPolicy{}
+	mi := &file_message_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RequestRowPolicy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RequestRowPolicy) ProtoMessage() {}
+
+func (x *RequestRowPolicy) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RequestRowPolicy.ProtoReflect.Descriptor instead.
+func (*RequestRowPolicy) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *RequestRowPolicy) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *RequestRowPolicy) GetTable() string {
+	if x != nil {
+		return x.Table
+	}
+	return ""
+}
+
+func (x *RequestRowPolicy) GetUsing() string {
+	if x != nil {
+		return x.Using
+	}
+	return ""
+}
+
+func (x *RequestRowPolicy) GetCheck() string {
+	if x != nil {
+		return x.Check
+	}
+	return ""
+}
+
+func (x *RequestRowPolicy) GetDrop() bool {
+	if x != nil {
+		return x.Drop
+	}
+	return false
+}
+
+type RequestAudit struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	From          int64                  `protobuf:"varint,1,opt,name=from,proto3" json:"from,omitempty"` // unix nanoseconds, 0 for no bound
+	To            int64                  `protobuf:"varint,2,opt,name=to,proto3" json:"to,omitempty"`
+	Principal     string                 `protobuf:"bytes,3,opt,name=principal,proto3" json:"principal,omitempty"`
+	Database      string                 `protobuf:"bytes,4,opt,name=database,proto3" json:"database,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RequestAudit) Reset() {
+	*x = RequestAudit{}
+	mi := &file_message_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RequestAudit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RequestAudit) ProtoMessage() {}
+
+func (x *RequestAudit) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RequestAudit.ProtoReflect.Descriptor instead.
+func (*RequestAudit) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *RequestAudit) GetFrom() int64 {
+	if x != nil {
+		return x.From
+	}
+	return 0
+}
+
+func (x *RequestAudit) GetTo() int64 {
+	if x != nil {
+		return x.To
+	}
+	return 0
+}
+
+func (x *RequestAudit) GetPrincipal() string {
+	if x != nil {
+		return x.Principal
+	}
+	return ""
+}
+
+func (x *RequestAudit) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+type AuditEntry struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Time          int64                  `protobuf:"varint,1,opt,name=time,proto3" json:"time,omitempty"`
+	Principal     string                 `protobuf:"bytes,2,opt,name=principal,proto3" json:"principal,omitempty"`
+	SourceIp      string                 `protobuf:"bytes,3,opt,name=source_ip,json=sourceIp,proto3" json:"source_ip,omitempty"`
+	Node          string                 `protobuf:"bytes,4,opt,name=node,proto3" json:"node,omitempty"`
+	Database      string                 `protobuf:"bytes,5,opt,name=database,proto3" json:"database,omitempty"`
+	Operation     string                 `protobuf:"bytes,6,opt,name=operation,proto3" json:"operation,omitempty"`
+	StatementHash string                 `protobuf:"bytes,7,opt,name=statement_hash,json=statementHash,proto3" json:"statement_hash,omitempty"`
+	Outcome       string                 `protobuf:"bytes,8,opt,name=outcome,proto3" json:"outcome,omitempty"`
+	Prev          string                 `protobuf:"bytes,9,opt,name=prev,proto3" json:"prev,omitempty"`
+	Hash          string                 `protobuf:"bytes,10,opt,name=hash,proto3" json:"hash,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AuditEntry) Reset() {
+	*x = AuditEntry{}
+	mi := &file_message_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AuditEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuditEntry) ProtoMessage() {}
+
+func (x *AuditEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AuditEntry.ProtoReflect.Descriptor instead.
+func (*AuditEntry) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *AuditEntry) GetTime() int64 {
+	if x != nil {
+		return x.Time
+	}
+	return 0
+}
+
+func (x *AuditEntry) GetPrincipal() string {
+	if x != nil {
+		return x.Principal
+	}
+	return ""
+}
+
+func (x *AuditEntry) GetSourceIp() string {
+	if x != nil {
+		return x.SourceIp
+	}
+	return ""
+}
+
+func (x *AuditEntry) GetNode() string {
+	if x != nil {
+		return x.Node
+	}
+	return ""
+}
+
+func (x *AuditEntry) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+func (x *AuditEntry) GetOperation() string {
+	if x != nil {
+		return x.Operation
+	}
+	return ""
+}
+
+func (x *AuditEntry) GetStatementHash() string {
+	if x != nil {
+		return x.StatementHash
+	}
+	return ""
+}
+
+func (x *AuditEntry) GetOutcome() string {
+	if x != nil {
+		return x.Outcome
+	}
+	return ""
+}
+
+func (x *AuditEntry) GetPrev() string {
+	if x != nil {
+		return x.Prev
+	}
+	return ""
+}
+
+func (x *AuditEntry) GetHash() string {
+	if x != nil {
+		return x.Hash
+	}
+	return ""
+}
+
+type ResponseAudit struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Entries       []*AuditEntry          `protobuf:"bytes,1,rep,name=entries,proto3" json:"entries,omitempty"`
+	ChainError    string                 `protobuf:"bytes,2,opt,name=chain_error,json=chainError,proto3" json:"chain_error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResponseAudit) Reset() {
+	*x = ResponseAudit{}
+	mi := &file_message_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResponseAudit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResponseAudit) ProtoMessage() {}
+
+func (x *ResponseAudit) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResponseAudit.ProtoReflect.Descriptor instead.
+func (*ResponseAudit) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ResponseAudit) GetEntries() []*AuditEntry {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+func (x *ResponseAudit) GetChainError() string {
+	if x != nil {
+		return x.ChainError
+	}
+	return ""
+}
+
+type RequestUsage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	From          int64                  `protobuf:"varint,1,opt,name=from,proto3" json:"from,omitempty"` // unix nanoseconds, 0 for no bound
+	To            int64                  `protobuf:"varint,2,opt,name=to,proto3" json:"to,omitempty"`
+	Database      string                 `protobuf:"bytes,3,opt,name=database,proto3" json:"database,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RequestUsage) Reset() {
+	*x = RequestUsage{}
+	mi := &file_message_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RequestUsage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RequestUsage) ProtoMessage() {}
+
+func (x *RequestUsage) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RequestUsage.ProtoReflect.Descriptor instead.
+func (*RequestUsage) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *RequestUsage) GetFrom() int64 {
+	if x != nil {
+		return x.From
+	}
+	return 0
+}
+
+func (x *RequestUsage) GetTo() int64 {
+	if x != nil {
+		return x.To
+	}
+	return 0
+}
+
+func (x *RequestUsage) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+type UsageRecord struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Database      string                 `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
+	Window        int64                  `protobuf:"varint,2,opt,name=window,proto3" json:"window,omitempty"` // unix nanoseconds of the start of the window
+	StorageBytes  int64                  `protobuf:"varint,3,opt,name=storage_bytes,json=storageBytes,proto3" json:"storage_bytes,omitempty"`
+	RowsRead      int64                  `protobuf:"varint,4,opt,name=rows_read,json=rowsRead,proto3" json:"rows_read,omitempty"`
+	RowsWritten   int64                  `protobuf:"varint,5,opt,name=rows_written,json=rowsWritten,proto3" json:"rows_written,omitempty"`
+	Queries       int64                  `protobuf:"varint,6,opt,name=queries,proto3" json:"queries,omitempty"`
+	VmSteps       int64                  `protobuf:"varint,7,opt,name=vm_steps,json=vmSteps,proto3" json:"vm_steps,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UsageRecord) Reset() {
+	*x = UsageRecord{}
+	mi := &file_message_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UsageRecord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UsageRecord) ProtoMessage() {}
+
+func (x *UsageRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UsageRecord.ProtoReflect.Descriptor instead.
+func (*UsageRecord) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *UsageRecord) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+func (x *UsageRecord) GetWindow() int64 {
+	if x != nil {
+		return x.Window
+	}
+	return 0
+}
+
+func (x *UsageRecord) GetStorageBytes() int64 {
+	if x != nil {
+		return x.StorageBytes
+	}
+	return 0
+}
+
+func (x *UsageRecord) GetRowsRead() int64 {
+	if x != nil {
+		return x.RowsRead
+	}
+	return 0
+}
+
+func (x *UsageRecord) GetRowsWritten() int64 {
+	if x != nil {
+		return x.RowsWritten
+	}
+	return 0
+}
+
+func (x *UsageRecord) GetQueries() int64 {
+	if x != nil {
+		return x.Queries
+	}
+	return 0
+}
+
+func (x *UsageRecord) GetVmSteps() int64 {
+	if x != nil {
+		return x.VmSteps
+	}
+	return 0
+}
+
+type ResponseUsage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Records       []*UsageRecord         `protobuf:"bytes,1,rep,name=records,proto3" json:"records,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResponseUsage) Reset() {
+	*x = ResponseUsage{}
+	mi := &file_message_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResponseUsage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResponseUsage) ProtoMessage() {}
+
+func (x *ResponseUsage) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResponseUsage.ProtoReflect.Descriptor instead.
+func (*ResponseUsage) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *ResponseUsage) GetRecords() []*UsageRecord {
+	if x != nil {
+		return x.Records
+	}
+	return nil
+}
+
+type RequestRateLimits struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Limits        []byte                 `protobuf:"bytes,1,opt,name=limits,proto3" json:"limits,omitempty"` // JSON encoded rate limits
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RequestRateLimits) Reset() {
+	*x = RequestRateLimits{}
+	mi := &file_message_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RequestRateLimits) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RequestRateLimits) ProtoMessage() {}
+
+func (x *RequestRateLimits) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RequestRateLimits.ProtoReflect.Descriptor instead.
+func (*RequestRateLimits) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *RequestRateLimits) GetLimits() []byte {
+	if x != nil {
+		return x.Limits
+	}
+	return nil
+}
+
+type BackupInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Database      string                 `protobuf:"bytes,2,opt,name=database,proto3" json:"database,omitempty"`
+	Created       int64                  `protobuf:"varint,3,opt,name=created,proto3" json:"created,omitempty"` // unix nanoseconds
+	Size          int64                  `protobuf:"varint,4,opt,name=size,proto3" json:"size,omitempty"`
+	Sha256        string                 `protobuf:"bytes,5,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	Encrypted     bool                   `protobuf:"varint,6,opt,name=encrypted,proto3" json:"encrypted,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BackupInfo) Reset() {
+	*x = BackupInfo{}
+	mi := &file_message_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BackupInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BackupInfo) ProtoMessage() {}
+
+func (x *BackupInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BackupInfo.ProtoReflect.Descriptor instead.
+func (*BackupInfo) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *BackupInfo) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *BackupInfo) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+func (x *BackupInfo) GetCreated() int64 {
+	if x != nil {
+		return x.Created
+	}
+	return 0
+}
+
+func (x *BackupInfo) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *BackupInfo) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+func (x *BackupInfo) GetEncrypted() bool {
+	if x != nil {
+		return x.Encrypted
+	}
+	return false
+}
+
+type ResponseBackups struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Backups       []*BackupInfo          `protobuf:"bytes,1,rep,name=backups,proto3" json:"backups,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResponseBackups) Reset() {
+	*x = ResponseBackups{}
+	mi := &file_message_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResponseBackups) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResponseBackups) ProtoMessage() {}
+
+func (x *ResponseBackups) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResponseBackups.ProtoReflect.Descriptor instead.
+func (*ResponseBackups) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *ResponseBackups) GetBackups() []*BackupInfo {
+	if x != nil {
+		return x.Backups
+	}
+	return nil
+}
+
+type RequestRestore struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`                         // database to create
+	Source        string                 `protobuf:"bytes,2,opt,name=source,proto3" json:"source,omitempty"`                     // database the backup was taken of
+	BackupId      string                 `protobuf:"bytes,3,opt,name=backup_id,json=backupId,proto3" json:"backup_id,omitempty"` // newest backup before until when empty
+	Until         int64                  `protobuf:"varint,4,opt,name=until,proto3" json:"until,omitempty"`                      // unix nanoseconds to roll the backup forward to, 0 for none
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RequestRestore) Reset() {
+	*x = RequestRestore{}
+	mi := &file_message_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RequestRestore) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RequestRestore) ProtoMessage() {}
+
+func (x *RequestRestore) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RequestRestore.ProtoReflect.Descriptor instead.
+func (*RequestRestore) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *RequestRestore) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *RequestRestore) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *RequestRestore) GetBackupId() string {
+	if x != nil {
+		return x.BackupId
+	}
+	return ""
+}
+
+func (x *RequestRestore) GetUntil() int64 {
+	if x != nil {
+		return x.Until
+	}
+	return 0
+}
+
+type RecoveryChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Backup        *BackupInfo            `protobuf:"bytes,1,opt,name=backup,proto3" json:"backup,omitempty"` // set on the first chunk
+	File          string                 `protobuf:"bytes,2,opt,name=file,proto3" json:"file,omitempty"`     // set on the first chunk of every file
+	Data          []byte                 `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RecoveryChunk) Reset() {
+	*x = RecoveryChunk{}
+	mi := &file_message_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RecoveryChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RecoveryChunk) ProtoMessage() {}
+
+func (x *RecoveryChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RecoveryChunk.ProtoReflect.Descriptor instead.
+func (*RecoveryChunk) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *RecoveryChunk) GetBackup() *BackupInfo {
+	if x != nil {
+		return x.Backup
+	}
+	return nil
+}
+
+func (x *RecoveryChunk) GetFile() string {
+	if x != nil {
+		return x.File
+	}
+	return ""
+}
+
+func (x *RecoveryChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type RequestExport struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Format        string                 `protobuf:"bytes,2,opt,name=format,proto3" json:"format,omitempty"` // sql, csv or ndjson
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RequestExport) Reset() {
+	*x = RequestExport{}
+	mi := &file_message_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RequestExport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RequestExport) ProtoMessage() {}
+
+func (x *RequestExport) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RequestExport.ProtoReflect.Descriptor instead.
+func (*RequestExport) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *RequestExport) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *RequestExport) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+type DataChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Data          []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DataChunk) Reset() {
+	*x = DataChunk{}
+	mi := &file_message_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DataChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DataChunk) ProtoMessage() {}
+
+func (x *DataChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DataChunk.ProtoReflect.Descriptor instead.
+func (*DataChunk) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *DataChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type ImportChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`     // set on the first chunk
+	Format        string                 `protobuf:"bytes,2,opt,name=format,proto3" json:"format,omitempty"` // set on the first chunk: sql, csv, ndjson or sqlite
+	Data          []byte                 `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ImportChunk) Reset() {
+	*x = ImportChunk{}
+	mi := &file_message_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ImportChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImportChunk) ProtoMessage() {}
+
+func (x *ImportChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImportChunk.ProtoReflect.Descriptor instead.
+func (*ImportChunk) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *ImportChunk) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ImportChunk) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+func (x *ImportChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type ResponseImport struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Bytes         int64                  `protobuf:"varint,1,opt,name=bytes,proto3" json:"bytes,omitempty"`
+	Statements    int64                  `protobuf:"varint,2,opt,name=statements,proto3" json:"statements,omitempty"`
+	Rows          int64                  `protobuf:"varint,3,opt,name=rows,proto3" json:"rows,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResponseImport) Reset() {
+	*x = ResponseImport{}
+	mi := &file_message_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResponseImport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResponseImport) ProtoMessage() {}
+
+func (x *ResponseImport) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResponseImport.ProtoReflect.Descriptor instead.
+func (*ResponseImport) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *ResponseImport) GetBytes() int64 {
+	if x != nil {
+		return x.Bytes
+	}
+	return 0
+}
+
+func (x *ResponseImport) GetStatements() int64 {
+	if x != nil {
+		return x.Statements
+	}
+	return 0
+}
+
+func (x *ResponseImport) GetRows() int64 {
+	if x != nil {
+		return x.Rows
+	}
+	return 0
+}
+
+type RequestClone struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`     // database to create
+	Source        string                 `protobuf:"bytes,2,opt,name=source,proto3" json:"source,omitempty"` // database to branch from
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RequestClone) Reset() {
+	*x = RequestClone{}
+	mi := &file_message_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RequestClone) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RequestClone) ProtoMessage() {}
+
+func (x *RequestClone) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RequestClone.ProtoReflect.Descriptor instead.
+func (*RequestClone) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *RequestClone) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *RequestClone) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+type BranchInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Parent        string                 `protobuf:"bytes,1,opt,name=parent,proto3" json:"parent,omitempty"`
+	Branched      int64                  `protobuf:"varint,2,opt,name=branched,proto3" json:"branched,omitempty"` // unix nanoseconds
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BranchInfo) Reset() {
+	*x = BranchInfo{}
+	mi := &file_message_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BranchInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BranchInfo) ProtoMessage() {}
+
+func (x *BranchInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BranchInfo.ProtoReflect.Descriptor instead.
+func (*BranchInfo) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *BranchInfo) GetParent() string {
+	if x != nil {
+		return x.Parent
+	}
+	return ""
+}
+
+func (x *BranchInfo) GetBranched() int64 {
+	if x != nil {
+		return x.Branched
+	}
+	return 0
+}
+
+type RowChange struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Branch        *BranchInfo            `protobuf:"bytes,1,opt,name=branch,proto3" json:"branch,omitempty"` // set on the first message only, which holds no change
+	Table         string                 `protobuf:"bytes,2,opt,name=table,proto3" json:"table,omitempty"`
+	Op            string                 `protobuf:"bytes,3,opt,name=op,proto3" json:"op,omitempty"` // insert, update, delete, or schema when the columns of the table differ
+	Columns       []*Column              `protobuf:"bytes,4,rep,name=columns,proto3" json:"columns,omitempty"`
+	Parent        *Row                   `protobuf:"bytes,5,opt,name=parent,proto3" json:"parent,omitempty"` // the row in the parent, unless inserted
+	Row           *Row                   `protobuf:"bytes,6,opt,name=row,proto3" json:"row,omitempty"`       // the row in the branch, unless deleted
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RowChange) Reset() {
+	*x = RowChange{}
+	mi := &file_message_proto_msgTypes[31]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RowChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RowChange) ProtoMessage() {}
+
+func (x *RowChange) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[31]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RowChange.ProtoReflect.Descriptor instead.
+func (*RowChange) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{31}
+}
+
+func (x *RowChange) GetBranch() *BranchInfo {
+	if x != nil {
+		return x.Branch
+	}
+	return nil
+}
+
+func (x *RowChange) GetTable() string {
+	if x != nil {
+		return x.Table
+	}
+	return ""
+}
+
+func (x *RowChange) GetOp() string {
+	if x != nil {
+		return x.Op
+	}
+	return ""
+}
+
+func (x *RowChange) GetColumns() []*Column {
+	if x != nil {
+		return x.Columns
+	}
+	return nil
+}
+
+func (x *RowChange) GetParent() *Row {
+	if x != nil {
+		return x.Parent
+	}
+	return nil
+}
+
+func (x *RowChange) GetRow() *Row {
+	if x != nil {
+		return x.Row
+	}
+	return nil
+}
+
+type TemplateInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Version       string                 `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`
+	Source        string                 `protobuf:"bytes,3,opt,name=source,proto3" json:"source,omitempty"`    // image or migration
+	Created       int64                  `protobuf:"varint,4,opt,name=created,proto3" json:"created,omitempty"` // unix nanoseconds
+	Size          int64                  `protobuf:"varint,5,opt,name=size,proto3" json:"size,omitempty"`
+	Sha256        string                 `protobuf:"bytes,6,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TemplateInfo) Reset() {
+	*x = TemplateInfo{}
+	mi := &file_message_proto_msgTypes[32]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TemplateInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TemplateInfo) ProtoMessage() {}
+
+func (x *TemplateInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[32]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TemplateInfo.ProtoReflect.Descriptor instead.
+func (*TemplateInfo) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{32}
+}
+
+func (x *TemplateInfo) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *TemplateInfo) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *TemplateInfo) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *TemplateInfo) GetCreated() int64 {
+	if x != nil {
+		return x.Created
+	}
+	return 0
+}
+
+func (x *TemplateInfo) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *TemplateInfo) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+type TemplateChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Template      *TemplateInfo          `protobuf:"bytes,1,opt,name=template,proto3" json:"template,omitempty"` // set on the first chunk
+	Data          []byte                 `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TemplateChunk) Reset() {
+	*x = TemplateChunk{}
+	mi := &file_message_proto_msgTypes[33]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TemplateChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TemplateChunk) ProtoMessage() {}
+
+func (x *TemplateChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[33]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TemplateChunk.ProtoReflect.Descriptor instead.
+func (*TemplateChunk) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{33}
+}
+
+func (x *TemplateChunk) GetTemplate() *TemplateInfo {
+	if x != nil {
+		return x.Template
+	}
+	return nil
+}
+
+func (x *TemplateChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type RequestTemplate struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Version       string                 `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"` // the newest version when empty
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RequestTemplate) Reset() {
+	*x = RequestTemplate{}
+	mi := &file_message_proto_msgTypes[34]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RequestTemplate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RequestTemplate) ProtoMessage() {}
+
+func (x *RequestTemplate) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[34]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RequestTemplate.ProtoReflect.Descriptor instead.
+func (*RequestTemplate) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{34}
+}
+
+func (x *RequestTemplate) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *RequestTemplate) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+type ResponseTemplates struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Templates     []*TemplateInfo        `protobuf:"bytes,1,rep,name=templates,proto3" json:"templates,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResponseTemplates) Reset() {
+	*x = ResponseTemplates{}
+	mi := &file_message_proto_msgTypes[35]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResponseTemplates) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResponseTemplates) ProtoMessage() {}
+
+func (x *ResponseTemplates) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[35]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResponseTemplates.ProtoReflect.Descriptor instead.
+func (*ResponseTemplates) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{35}
+}
+
+func (x *ResponseTemplates) GetTemplates() []*TemplateInfo {
+	if x != nil {
+		return x.Templates
+	}
+	return nil
+}
+
+type TrashEntry struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Id            string                 `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	Dropped       int64                  `protobuf:"varint,3,opt,name=dropped,proto3" json:"dropped,omitempty"` // unix nanoseconds
+	Expires       int64                  `protobuf:"varint,4,opt,name=expires,proto3" json:"expires,omitempty"` // unix nanoseconds
+	Size          int64                  `protobuf:"varint,5,opt,name=size,proto3" json:"size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TrashEntry) Reset() {
+	*x = TrashEntry{}
+	mi := &file_message_proto_msgTypes[36]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TrashEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TrashEntry) ProtoMessage() {}
+
+func (x *TrashEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[36]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TrashEntry.ProtoReflect.Descriptor instead.
+func (*TrashEntry) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{36}
+}
+
+func (x *TrashEntry) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *TrashEntry) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *TrashEntry) GetDropped() int64 {
+	if x != nil {
+		return x.Dropped
+	}
+	return 0
+}
+
+func (x *TrashEntry) GetExpires() int64 {
+	if x != nil {
+		return x.Expires
+	}
+	return 0
+}
+
+func (x *TrashEntry) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+type RequestTrash struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Id            string                 `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`            // the last drop, or every drop when purging, when empty
+	Confirm       bool                   `protobuf:"varint,3,opt,name=confirm,proto3" json:"confirm,omitempty"` // purging deletes for good, so it has to be confirmed
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RequestTrash) Reset() {
+	*x = RequestTrash{}
+	mi := &file_message_proto_msgTypes[37]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RequestTrash) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RequestTrash) ProtoMessage() {}
+
+func (x *RequestTrash) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[37]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RequestTrash.ProtoReflect.Descriptor instead.
+func (*RequestTrash) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{37}
+}
+
+func (x *RequestTrash) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *RequestTrash) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *RequestTrash) GetConfirm() bool {
+	if x != nil {
+		return x.Confirm
+	}
+	return false
+}
+
+type ResponseTrash struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Entries       []*TrashEntry          `protobuf:"bytes,1,rep,name=entries,proto3" json:"entries,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResponseTrash) Reset() {
+	*x = ResponseTrash{}
+	mi := &file_message_proto_msgTypes[38]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResponseTrash) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResponseTrash) ProtoMessage() {}
+
+func (x *ResponseTrash) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[38]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResponseTrash.ProtoReflect.Descriptor instead.
+func (*ResponseTrash) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{38}
+}
+
+func (x *ResponseTrash) GetEntries() []*TrashEntry {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+type RequestSubscribe struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	After         int64                  `protobuf:"varint,2,opt,name=after,proto3" json:"after,omitempty"` // offset of the last change seen, 0 for every change retained and -1 for new ones
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RequestSubscribe) Reset() {
+	*x = RequestSubscribe{}
+	mi := &file_message_proto_msgTypes[39]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RequestSubscribe) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RequestSubscribe) ProtoMessage() {}
+
+func (x *RequestSubscribe) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[39]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RequestSubscribe.ProtoReflect.Descriptor instead.
+func (*RequestSubscribe) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{39}
+}
+
+func (x *RequestSubscribe) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *RequestSubscribe) GetAfter() int64 {
+	if x != nil {
+		return x.After
+	}
+	return 0
+}
+
+type Subscription struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	After         uint64                 `protobuf:"varint,1,opt,name=after,proto3" json:"after,omitempty"`   // the changes sent start after this offset
+	Oldest        uint64                 `protobuf:"varint,2,opt,name=oldest,proto3" json:"oldest,omitempty"` // oldest offset retained
+	Latest        uint64                 `protobuf:"varint,3,opt,name=latest,proto3" json:"latest,omitempty"` // last offset logged when subscribing
+	Log           string                 `protobuf:"bytes,4,opt,name=log,proto3" json:"log,omitempty"`        // id of the changelog the offsets are in
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Subscription) Reset() {
+	*x = Subscription{}
+	mi := &file_message_proto_msgTypes[40]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Subscription) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Subscription) ProtoMessage() {}
+
+func (x *Subscription) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[40]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Subscription.ProtoReflect.Descriptor instead.
+func (*Subscription) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{40}
+}
+
+func (x *Subscription) GetAfter() uint64 {
+	if x != nil {
+		return x.After
+	}
+	return 0
+}
+
+func (x *Subscription) GetOldest() uint64 {
+	if x != nil {
+		return x.Oldest
+	}
+	return 0
+}
+
+func (x *Subscription) GetLatest() uint64 {
+	if x != nil {
+		return x.Latest
+	}
+	return 0
+}
+
+func (x *Subscription) GetLog() string {
+	if x != nil {
+		return x.Log
+	}
+	return ""
+}
+
+type ChangeEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Subscription  *Subscription          `protobuf:"bytes,1,opt,name=subscription,proto3" json:"subscription,omitempty"` // set on the first message alone
+	Offset        uint64                 `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
+	Commit        uint64                 `protobuf:"varint,3,opt,name=commit,proto3" json:"commit,omitempty"` // sequence number of the transaction
+	Time          int64                  `protobuf:"varint,4,opt,name=time,proto3" json:"time,omitempty"`     // unix nanoseconds
+	Table         string                 `protobuf:"bytes,5,opt,name=table,proto3" json:"table,omitempty"`
+	Op            string                 `protobuf:"bytes,6,opt,name=op,proto3" json:"op,omitempty"` // insert, update or delete
+	Rowid         int64                  `protobuf:"varint,7,opt,name=rowid,proto3" json:"rowid,omitempty"`
+	Columns       []*Column              `protobuf:"bytes,8,rep,name=columns,proto3" json:"columns,omitempty"`
+	Old           *Row                   `protobuf:"bytes,9,opt,name=old,proto3" json:"old,omitempty"`  // unless inserted, or captured without the old values
+	New           *Row                   `protobuf:"bytes,10,opt,name=new,proto3" json:"new,omitempty"` // unless deleted
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChangeEvent) Reset() {
+	*x = ChangeEvent{}
+	mi := &file_message_proto_msgTypes[41]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChangeEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChangeEvent) ProtoMessage() {}
+
+func (x *ChangeEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[41]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChangeEvent.ProtoReflect.Descriptor instead.
+func (*ChangeEvent) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{41}
+}
+
+func (x *ChangeEvent) GetSubscription() *Subscription {
+	if x != nil {
+		return x.Subscription
+	}
+	return nil
+}
+
+func (x *ChangeEvent) GetOffset() uint64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *ChangeEvent) GetCommit() uint64 {
+	if x != nil {
+		return x.Commit
+	}
+	return 0
+}
+
+func (x *ChangeEvent) GetTime() int64 {
+	if x != nil {
+		return x.Time
+	}
+	return 0
+}
+
+func (x *ChangeEvent) GetTable() string {
+	if x != nil {
+		return x.Table
+	}
+	return ""
+}
+
+func (x *ChangeEvent) GetOp() string {
+	if x != nil {
+		return x.Op
+	}
+	return ""
+}
+
+func (x *ChangeEvent) GetRowid() int64 {
+	if x != nil {
+		return x.Rowid
+	}
+	return 0
+}
+
+func (x *ChangeEvent) GetColumns() []*Column {
+	if x != nil {
+		return x.Columns
+	}
+	return nil
+}
+
+func (x *ChangeEvent) GetOld() *Row {
+	if x != nil {
+		return x.Old
+	}
+	return nil
+}
+
+func (x *ChangeEvent) GetNew() *Row {
+	if x != nil {
+		return x.New
+	}
+	return nil
+}
+
+type Webhook struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Url           string                 `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
+	Table         string                 `protobuf:"bytes,3,opt,name=table,proto3" json:"table,omitempty"`   // every table when empty
+	Events        []string               `protobuf:"bytes,4,rep,name=events,proto3" json:"events,omitempty"` // insert, update or delete, every one when empty
+	Secret        string                 `protobuf:"bytes,5,opt,name=secret,proto3" json:"secret,omitempty"` // signs the deliveries, drawn when empty
+	BatchSize     int32                  `protobuf:"varint,6,opt,name=batch_size,json=batchSize,proto3" json:"batch_size,omitempty"`
+	Created       int64                  `protobuf:"varint,7,opt,name=created,proto3" json:"created,omitempty"` // unix nanoseconds
+	Log           string                 `protobuf:"bytes,8,opt,name=log,proto3" json:"log,omitempty"`          // changelog the offset is in
+	Offset        uint64                 `protobuf:"varint,9,opt,name=offset,proto3" json:"offset,omitempty"`   // last change delivered
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Webhook) Reset() {
+	*x = Webhook{}
+	mi := &file_message_proto_msgTypes[42]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Webhook) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Webhook) ProtoMessage() {}
+
+func (x *Webhook) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[42]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Webhook.ProtoReflect.Descriptor instead.
+func (*Webhook) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{42}
+}
+
+func (x *Webhook) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Webhook) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *Webhook) GetTable() string {
+	if x != nil {
+		return x.Table
+	}
+	return ""
+}
+
+func (x *Webhook) GetEvents() []string {
+	if x != nil {
+		return x.Events
+	}
+	return nil
+}
+
+func (x *Webhook) GetSecret() string {
+	if x != nil {
+		return x.Secret
+	}
+	return ""
+}
+
+func (x *Webhook) GetBatchSize() int32 {
+	if x != nil {
+		return x.BatchSize
+	}
+	return 0
+}
+
+func (x *Webhook) GetCreated() int64 {
+	if x != nil {
+		return x.Created
+	}
+	return 0
+}
+
+func (x *Webhook) GetLog() string {
+	if x != nil {
+		return x.Log
+	}
+	return ""
+}
+
+func (x *Webhook) GetOffset() uint64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+type RequestWebhook struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Id            string                 `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	Webhook       *Webhook               `protobuf:"bytes,3,opt,name=webhook,proto3" json:"webhook,omitempty"` // to create
+	Dead          int64                  `protobuf:"varint,4,opt,name=dead,proto3" json:"dead,omitempty"`      // a single dead letter, all of them when 0
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RequestWebhook) Reset() {
+	*x = RequestWebhook{}
+	mi := &file_message_proto_msgTypes[43]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RequestWebhook) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RequestWebhook) ProtoMessage() {}
+
+func (x *RequestWebhook) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[43]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RequestWebhook.ProtoReflect.Descriptor instead.
+func (*RequestWebhook) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{43}
+}
+
+func (x *RequestWebhook) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *RequestWebhook) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *RequestWebhook) GetWebhook() *Webhook {
+	if x != nil {
+		return x.Webhook
+	}
+	return nil
+}
+
+func (x *RequestWebhook) GetDead() int64 {
+	if x != nil {
+		return x.Dead
+	}
+	return 0
+}
+
+type ResponseWebhooks struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Webhooks      []*Webhook             `protobuf:"bytes,1,rep,name=webhooks,proto3" json:"webhooks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResponseWebhooks) Reset() {
+	*x = ResponseWebhooks{}
+	mi := &file_message_proto_msgTypes[44]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResponseWebhooks) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResponseWebhooks) ProtoMessage() {}
+
+func (x *ResponseWebhooks) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[44]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResponseWebhooks.ProtoReflect.Descriptor instead.
+func (*ResponseWebhooks) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{44}
+}
+
+func (x *ResponseWebhooks) GetWebhooks() []*Webhook {
+	if x != nil {
+		return x.Webhooks
+	}
+	return nil
+}
+
+type Delivery struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Webhook       string                 `protobuf:"bytes,2,opt,name=webhook,proto3" json:"webhook,omitempty"`
+	Time          int64                  `protobuf:"varint,3,opt,name=time,proto3" json:"time,omitempty"` // unix nanoseconds
+	Attempt       int32                  `protobuf:"varint,4,opt,name=attempt,proto3" json:"attempt,omitempty"`
+	Status        int32                  `protobuf:"varint,5,opt,name=status,proto3" json:"status,omitempty"` // HTTP status of the response, 0 without one
+	Error         string                 `protobuf:"bytes,6,opt,name=error,proto3" json:"error,omitempty"`
+	First         uint64                 `protobuf:"varint,7,opt,name=first,proto3" json:"first,omitempty"`
+	Last          uint64                 `protobuf:"varint,8,opt,name=last,proto3" json:"last,omitempty"`
+	Events        int32                  `protobuf:"varint,9,opt,name=events,proto3" json:"events,omitempty"`
+	Duration      int64                  `protobuf:"varint,10,opt,name=duration,proto3" json:"duration,omitempty"` // nanoseconds
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Delivery) Reset() {
+	*x = Delivery{}
+	mi := &file_message_proto_msgTypes[45]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Delivery) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Delivery) ProtoMessage() {}
+
+func (x *Delivery) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[45]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Delivery.ProtoReflect.Descriptor instead.
+func (*Delivery) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{45}
+}
+
+func (x *Delivery) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Delivery) GetWebhook() string {
+	if x != nil {
+		return x.Webhook
+	}
+	return ""
+}
+
+func (x *Delivery) GetTime() int64 {
+	if x != nil {
+		return x.Time
+	}
+	return 0
+}
+
+func (x *Delivery) GetAttempt() int32 {
+	if x != nil {
+		return x.Attempt
+	}
+	return 0
+}
+
+func (x *Delivery) GetStatus() int32 {
+	if x != nil {
+		return x.Status
+	}
+	return 0
+}
+
+func (x *Delivery) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *Delivery) GetFirst() uint64 {
+	if x != nil {
+		return x.First
+	}
+	return 0
+}
+
+func (x *Delivery) GetLast() uint64 {
+	if x != nil {
+		return x.Last
+	}
+	return 0
+}
+
+func (x *Delivery) GetEvents() int32 {
+	if x != nil {
+		return x.Events
+	}
+	return 0
+}
+
+func (x *Delivery) GetDuration() int64 {
+	if x != nil {
+		return x.Duration
+	}
+	return 0
+}
+
+type DeadLetter struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Webhook       string                 `protobuf:"bytes,2,opt,name=webhook,proto3" json:"webhook,omitempty"`
+	Created       int64                  `protobuf:"varint,3,opt,name=created,proto3" json:"created,omitempty"` // unix nanoseconds
+	First         uint64                 `protobuf:"varint,4,opt,name=first,proto3" json:"first,omitempty"`
+	Last          uint64                 `protobuf:"varint,5,opt,name=last,proto3" json:"last,omitempty"`
+	Events        int32                  `protobuf:"varint,6,opt,name=events,proto3" json:"events,omitempty"`
+	Attempts      int32                  `protobuf:"varint,7,opt,name=attempts,proto3" json:"attempts,omitempty"`
+	Error         string                 `protobuf:"bytes,8,opt,name=error,proto3" json:"error,omitempty"`
+	Payload       []byte                 `protobuf:"bytes,9,opt,name=payload,proto3" json:"payload,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeadLetter) Reset() {
+	*x = DeadLetter{}
+	mi := &file_message_proto_msgTypes[46]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeadLetter) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeadLetter) ProtoMessage() {}
+
+func (x *DeadLetter) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[46]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeadLetter.ProtoReflect.Descriptor instead.
+func (*DeadLetter) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{46}
+}
+
+func (x *DeadLetter) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *DeadLetter) GetWebhook() string {
+	if x != nil {
+		return x.Webhook
+	}
+	return ""
+}
+
+func (x *DeadLetter) GetCreated() int64 {
+	if x != nil {
+		return x.Created
+	}
+	return 0
+}
+
+func (x *DeadLetter) GetFirst() uint64 {
+	if x != nil {
+		return x.First
+	}
+	return 0
+}
+
+func (x *DeadLetter) GetLast() uint64 {
+	if x != nil {
+		return x.Last
+	}
+	return 0
+}
+
+func (x *DeadLetter) GetEvents() int32 {
+	if x != nil {
+		return x.Events
+	}
+	return 0
+}
+
+func (x *DeadLetter) GetAttempts() int32 {
+	if x != nil {
+		return x.Attempts
+	}
+	return 0
+}
+
+func (x *DeadLetter) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *DeadLetter) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+type ResponseDeliveries struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Deliveries    []*Delivery            `protobuf:"bytes,1,rep,name=deliveries,proto3" json:"deliveries,omitempty"`
+	Dead          []*DeadLetter          `protobuf:"bytes,2,rep,name=dead,proto3" json:"dead,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResponseDeliveries) Reset() {
+	*x = ResponseDeliveries{}
+	mi := &file_message_proto_msgTypes[47]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResponseDeliveries) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResponseDeliveries) ProtoMessage() {}
+
+func (x *ResponseDeliveries) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[47]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResponseDeliveries.ProtoReflect.Descriptor instead.
+func (*ResponseDeliveries) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{47}
+}
+
+func (x *ResponseDeliveries) GetDeliveries() []*Delivery {
+	if x != nil {
+		return x.Deliveries
+	}
+	return nil
+}
+
+func (x *ResponseDeliveries) GetDead() []*DeadLetter {
+	if x != nil {
+		return x.Dead
+	}
+	return nil
+}
+
+type Job struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Schedule      string                 `protobuf:"bytes,2,opt,name=schedule,proto3" json:"schedule,omitempty"` // cron expression
+	Timezone      string                 `protobuf:"bytes,3,opt,name=timezone,proto3" json:"timezone,omitempty"`
+	Sql           string                 `protobuf:"bytes,4,opt,name=sql,proto3" json:"sql,omitempty"`
+	AlertUrl      string                 `protobuf:"bytes,5,opt,name=alert_url,json=alertUrl,proto3" json:"alert_url,omitempty"` // failed runs are posted to
+	Owner         string                 `protobuf:"bytes,6,opt,name=owner,proto3" json:"owner,omitempty"`                       // the job runs as
+	Created       int64                  `protobuf:"varint,7,opt,name=created,proto3" json:"created,omitempty"`                  // unix nanoseconds
+	Updated       int64                  `protobuf:"varint,8,opt,name=updated,proto3" json:"updated,omitempty"`
+	Next          int64                  `protobuf:"varint,9,opt,name=next,proto3" json:"next,omitempty"` // when it runs next, 0 if never
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Job) Reset() {
+	*x = Job{}
+	mi := &file_message_proto_msgTypes[48]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Job) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Job) ProtoMessage() {}
+
+func (x *Job) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[48]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Job.ProtoReflect.Descriptor instead.
+func (*Job) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{48}
+}
+
+func (x *Job) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Job) GetSchedule() string {
+	if x != nil {
+		return x.Schedule
+	}
+	return ""
+}
+
+func (x *Job) GetTimezone() string {
+	if x != nil {
+		return x.Timezone
+	}
+	return ""
+}
+
+func (x *Job) GetSql() string {
+	if x != nil {
+		return x.Sql
+	}
+	return ""
+}
+
+func (x *Job) GetAlertUrl() string {
+	if x != nil {
+		return x.AlertUrl
+	}
+	return ""
+}
+
+func (x *Job) GetOwner() string {
+	if x != nil {
+		return x.Owner
+	}
+	return ""
+}
+
+func (x *Job) GetCreated() int64 {
+	if x != nil {
+		return x.Created
+	}
+	return 0
+}
+
+func (x *Job) GetUpdated() int64 {
+	if x != nil {
+		return x.Updated
+	}
+	return 0
+}
+
+func (x *Job) GetNext() int64 {
+	if x != nil {
+		return x.Next
+	}
+	return 0
+}
+
+type RequestJob struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Job           string                 `protobuf:"bytes,2,opt,name=job,proto3" json:"job,omitempty"`
+	Definition    *Job                   `protobuf:"bytes,3,opt,name=definition,proto3" json:"definition,omitempty"` // to schedule
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RequestJob) Reset() {
+	*x = RequestJob{}
+	mi := &file_message_proto_msgTypes[49]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RequestJob) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RequestJob) ProtoMessage() {}
+
+func (x *RequestJob) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[49]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RequestJob.ProtoReflect.Descriptor instead.
+func (*RequestJob) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{49}
+}
+
+func (x *RequestJob) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *RequestJob) GetJob() string {
+	if x != nil {
+		return x.Job
+	}
+	return ""
+}
+
+func (x *RequestJob) GetDefinition() *Job {
+	if x != nil {
+		return x.Definition
+	}
+	return nil
+}
+
+type ResponseJobs struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Jobs          []*Job                 `protobuf:"bytes,1,rep,name=jobs,proto3" json:"jobs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResponseJobs) Reset() {
+	*x = ResponseJobs{}
+	mi := &file_message_proto_msgTypes[50]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResponseJobs) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResponseJobs) ProtoMessage() {}
+
+func (x *ResponseJobs) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[50]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResponseJobs.ProtoReflect.Descriptor instead.
+func (*ResponseJobs) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{50}
+}
+
+func (x *ResponseJobs) GetJobs() []*Job {
+	if x != nil {
+		return x.Jobs
+	}
+	return nil
+}
+
+type JobRun struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Job           string                 `protobuf:"bytes,2,opt,name=job,proto3" json:"job,omitempty"`
+	Scheduled     int64                  `protobuf:"varint,3,opt,name=scheduled,proto3" json:"scheduled,omitempty"` // unix nanoseconds, 0 for runs on request
+	Started       int64                  `protobuf:"varint,4,opt,name=started,proto3" json:"started,omitempty"`
+	Duration      int64                  `protobuf:"varint,5,opt,name=duration,proto3" json:"duration,omitempty"` // nanoseconds
+	Status        string                 `protobuf:"bytes,6,opt,name=status,proto3" json:"status,omitempty"`      // succeeded, failed or skipped
+	Error         string                 `protobuf:"bytes,7,opt,name=error,proto3" json:"error,omitempty"`
+	Rows          int64                  `protobuf:"varint,8,opt,name=rows,proto3" json:"rows,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *JobRun) Reset() {
+	*x = JobRun{}
+	mi := &file_message_proto_msgTypes[51]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *JobRun) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JobRun) ProtoMessage() {}
+
+func (x *JobRun) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[51]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JobRun.ProtoReflect.Descriptor instead.
+func (*JobRun) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{51}
+}
+
+func (x *JobRun) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *JobRun) GetJob() string {
+	if x != nil {
+		return x.Job
+	}
+	return ""
+}
+
+func (x *JobRun) GetScheduled() int64 {
+	if x != nil {
+		return x.Scheduled
+	}
+	return 0
+}
+
+func (x *JobRun) GetStarted() int64 {
+	if x != nil {
+		return x.Started
+	}
+	return 0
+}
+
+func (x *JobRun) GetDuration() int64 {
+	if x != nil {
+		return x.Duration
+	}
+	return 0
+}
+
+func (x *JobRun) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *JobRun) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *JobRun) GetRows() int64 {
+	if x != nil {
+		return x.Rows
+	}
+	return 0
+}
+
+type ResponseJobRuns struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Runs          []*JobRun              `protobuf:"bytes,1,rep,name=runs,proto3" json:"runs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResponseJobRuns) Reset() {
+	*x = ResponseJobRuns{}
+	mi := &file_message_proto_msgTypes[52]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResponseJobRuns) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResponseJobRuns) ProtoMessage() {}
+
+func (x *ResponseJobRuns) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[52]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResponseJobRuns.ProtoReflect.Descriptor instead.
+func (*ResponseJobRuns) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{52}
+}
+
+func (x *ResponseJobRuns) GetRuns() []*JobRun {
+	if x != nil {
+		return x.Runs
+	}
+	return nil
+}
+
+// RequestFanout runs a read-only query on every database of a node whose
+// name matches pattern.
+type RequestFanout struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Pattern       string                 `protobuf:"bytes,1,opt,name=pattern,proto3" json:"pattern,omitempty"` // * matches any characters, ? any single one
+	Query         string                 `protobuf:"bytes,2,opt,name=query,proto3" json:"query,omitempty"`
+	Concurrency   int32                  `protobuf:"varint,3,opt,name=concurrency,proto3" json:"concurrency,omitempty"`              // databases queried at once, 0 for the node maximum
+	TimeoutMs     int32                  `protobuf:"varint,4,opt,name=timeout_ms,json=timeoutMs,proto3" json:"timeout_ms,omitempty"` // per database, capped by the node maximum
+	BatchSize     int32                  `protobuf:"varint,5,opt,name=batch_size,json=batchSize,proto3" json:"batch_size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RequestFanout) Reset() {
+	*x = RequestFanout{}
+	mi := &file_message_proto_msgTypes[53]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RequestFanout) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RequestFanout) ProtoMessage() {}
+
+func (x *RequestFanout) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[53]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RequestFanout.ProtoReflect.Descriptor instead.
+func (*RequestFanout) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{53}
+}
+
+func (x *RequestFanout) GetPattern() string {
+	if x != nil {
+		return x.Pattern
+	}
+	return ""
+}
+
+func (x *RequestFanout) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *RequestFanout) GetConcurrency() int32 {
+	if x != nil {
+		return x.Concurrency
+	}
+	return 0
+}
+
+func (x *RequestFanout) GetTimeoutMs() int32 {
+	if x != nil {
+		return x.TimeoutMs
+	}
+	return 0
+}
+
+func (x *RequestFanout) GetBatchSize() int32 {
+	if x != nil {
+		return x.BatchSize
+	}
+	return 0
+}
+
+// FanoutBatch carries rows of one database, the error it failed with, or
+// the end of its result.
+type FanoutBatch struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Database      string                 `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
+	Result        *ResultSet             `protobuf:"bytes,2,opt,name=result,proto3" json:"result,omitempty"` // columns are only set on the first batch of a database
+	Error         string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	Code          string                 `protobuf:"bytes,4,opt,name=code,proto3" json:"code,omitempty"`  // gRPC code of error
+	Done          bool                   `protobuf:"varint,5,opt,name=done,proto3" json:"done,omitempty"` // the result of the database is complete
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FanoutBatch) Reset() {
+	*x = FanoutBatch{}
+	mi := &file_message_proto_msgTypes[54]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FanoutBatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FanoutBatch) ProtoMessage() {}
+
+func (x *FanoutBatch) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[54]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FanoutBatch.ProtoReflect.Descriptor instead.
+func (*FanoutBatch) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{54}
+}
+
+func (x *FanoutBatch) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+func (x *FanoutBatch) GetResult() *ResultSet {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+func (x *FanoutBatch) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *FanoutBatch) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *FanoutBatch) GetDone() bool {
+	if x != nil {
+		return x.Done
+	}
+	return false
+}
+
+var File_message_proto protoreflect.FileDescriptor
+
+const file_message_proto_rawDesc = "" +
+	"\n" +
+	"\rmessage.proto\x12\amessage\x1a\x19google/protobuf/any.proto\"]\n" +
+	"\rRequestCreate\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1c\n" +
+	"\tmigration\x18\x02 \x01(\tR\tmigration\x12\x1a\n" +
+	"\btemplate\x18\x03 \x01(\tR\btemplate\"$\n" +
+	"\x0eRequestGetDrop\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"\xa5\x03\n" +
+	"\x10RequestQueryExec\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05query\x18\x02 \x01(\tR\x05query\x12(\n" +
+	"\x04args\x18\x03 \x03(\v2\x14.google.protobuf.AnyR\x04args\x12\x1d\n" +
+	"\n" +
+	"batch_size\x18\x04 \x01(\x05R\tbatchSize\x12-\n" +
+	"\bencoding\x18\x05 \x01(\x0e2\x11.message.EncodingR\bencoding\x12\x1b\n" +
+	"\tpage_size\x18\x06 \x01(\x05R\bpageSize\x12\x16\n" +
+	"\x06cursor\x18\a \x01(\tR\x06cursor\x12!\n" +
+	"\fclose_cursor\x18\b \x01(\bR\vcloseCursor\x12\x1d\n" +
+	"\n" +
+	"timeout_ms\x18\t \x01(\x05R\ttimeoutMs\x12=\n" +
+	"\x06attach\x18\n" +
+	" \x03(\v2%.message.RequestQueryExec.AttachEntryR\x06attach\x1a9\n" +
+	"\vAttachEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"9\n" +
+	"\x06Column\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1b\n" +
+	"\tdecl_type\x18\x02 \x01(\tR\bdeclType\"\x83\x01\n" +
+	"\x05Value\x12\x1a\n" +
+	"\ainteger\x18\x01 \x01(\x03H\x00R\ainteger\x12\x14\n" +
+	"\x04real\x18\x02 \x01(\x01H\x00R\x04real\x12\x14\n" +
+	"\x04text\x18\x03 \x01(\tH\x00R\x04text\x12\x14\n" +
+	"\x04blob\x18\x04 \x01(\fH\x00R\x04blob\x12\x14\n" +
+	"\x04null\x18\x05 \x01(\bH\x00R\x04nullB\x06\n" +
+	"\x04kind\"-\n" +
+	"\x03Row\x12&\n" +
+	"\x06values\x18\x01 \x03(\v2\x0e.message.ValueR\x06values\"X\n" +
+	"\tResultSet\x12)\n" +
+	"\acolumns\x18\x01 \x03(\v2\x0f.message.ColumnR\acolumns\x12 \n" +
+	"\x04rows\x18\x02 \x03(\v2\f.message.RowR\x04rows\"\x82\x01\n" +
+	"\fColumnVector\x12\x14\n" +
+	"\x05kinds\x18\x01 \x01(\fR\x05kinds\x12\x1a\n" +
+	"\bintegers\x18\x02 \x03(\x03R\bintegers\x12\x14\n" +
+	"\x05reals\x18\x03 \x03(\x01R\x05reals\x12\x14\n" +
+	"\x05texts\x18\x04 \x03(\tR\x05texts\x12\x14\n" +
+	"\x05blobs\x18\x05 \x03(\fR\x05blobs\"\x83\x01\n" +
+	"\rColumnarBatch\x12)\n" +
+	"\acolumns\x18\x01 \x03(\v2\x0f.message.ColumnR\acolumns\x12\x16\n" +
+	"\x06length\x18\x02 \x01(\x03R\x06length\x12/\n" +
+	"\avectors\x18\x03 \x03(\v2\x15.message.ColumnVectorR\avectors\"\x1f\n" +
+	"\vDDLResponse\x12\x10\n" +
+	"\x03msg\x18\x01 \x01(\tR\x03msg\"\xaf\x01\n" +
+	"\rResponseQuery\x12\x16\n" +
+	"\x06result\x18\x01 \x01(\fR\x06result\x12&\n" +
+	"\x04rows\x18\x02 \x01(\v2\x12.message.ResultSetR\x04rows\x122\n" +
+	"\bcolumnar\x18\x03 \x01(\v2\x16.message.ColumnarBatchR\bcolumnar\x12\x12\n" +
+	"\x04data\x18\x04 \x01(\fR\x04data\x12\x16\n" +
+	"\x06cursor\x18\x05 \x01(\tR\x06cursor\"\xb6\x01\n" +
+	"\x12ResponseQueryBatch\x12\x18\n" +
+	"\acolumns\x18\x01 \x03(\tR\acolumns\x12\x12\n" +
+	"\x04rows\x18\x02 \x03(\fR\x04rows\x12*\n" +
+	"\x06result\x18\x03 \x01(\v2\x12.message.ResultSetR\x06result\x122\n" +
+	"\bcolumnar\x18\x04 \x01(\v2\x16.message.ColumnarBatchR\bcolumnar\x12\x12\n" +
+	"\x04data\x18\x05 \x01(\fR\x04data\"&\n" +
+	"\fResponseExec\x12\x16\n" +
+	"\x06result\x18\x01 \x01(\x03R\x06result\"|\n" +
+	"\x10RequestRowPolicy\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05table\x18\x02 \x01(\tR\x05table\x12\x14\n" +
+	"\x05using\x18\x03 \x01(\tR\x05using\x12\x14\n" +
+	"\x05check\x18\x04 \x01(\tR\x05check\x12\x12\n" +
+	"\x04drop\x18\x05 \x01(\bR\x04drop\"l\n" +
+	"\fRequestAudit\x12\x12\n" +
+	"\x04from\x18\x01 \x01(\x03R\x04from\x12\x0e\n" +
+	"\x02to\x18\x02 \x01(\x03R\x02to\x12\x1c\n" +
+	"\tprincipal\x18\x03 \x01(\tR\tprincipal\x12\x1a\n" +
+	"\bdatabase\x18\x04 \x01(\tR\bdatabase\"\x92\x02\n" +
+	"\n" +
+	"AuditEntry\x12\x12\n" +
+	"\x04time\x18\x01 \x01(\x03R\x04time\x12\x1c\n" +
+	"\tprincipal\x18\x02 \x01(\tR\tprincipal\x12\x1b\n" +
+	"\tsource_ip\x18\x03 \x01(\tR\bsourceIp\x12\x12\n" +
+	"\x04node\x18\x04 \x01(\tR\x04node\x12\x1a\n" +
+	"\bdatabase\x18\x05 \x01(\tR\bdatabase\x12\x1c\n" +
+	"\toperation\x18\x06 \x01(\tR\toperation\x12%\n" +
+	"\x0estatement_hash\x18\a \x01(\tR\rstatementHash\x12\x18\n" +
+	"\aoutcome\x18\b \x01(\tR\aoutcome\x12\x12\n" +
+	"\x04prev\x18\t \x01(\tR\x04prev\x12\x12\n" +
+	"\x04hash\x18\n" +
+	" \x01(\tR\x04hash\"_\n" +
+	"\rResponseAudit\x12-\n" +
+	"\aentries\x18\x01 \x03(\v2\x13.message.AuditEntryR\aentries\x12\x1f\n" +
+	"\vchain_error\x18\x02 \x01(\tR\n" +
+	"chainError\"N\n" +
+	"\fRequestUsage\x12\x12\n" +
+	"\x04from\x18\x01 \x01(\x03R\x04from\x12\x0e\n" +
+	"\x02to\x18\x02 \x01(\x03R\x02to\x12\x1a\n" +
+	"\bdatabase\x18\x03 \x01(\tR\bdatabase\"\xdb\x01\n" +
+	"\vUsageRecord\x12\x1a\n" +
+	"\bdatabase\x18\x01 \x01(\tR\bdatabase\x12\x16\n" +
+	"\x06window\x18\x02 \x01(\x03R\x06window\x12#\n" +
+	"\rstorage_bytes\x18\x03 \x01(\x03R\fstorageBytes\x12\x1b\n" +
+	"\trows_read\x18\x04 \x01(\x03R\browsRead\x12!\n" +
+	"\frows_written\x18\x05 \x01(\x03R\vrowsWritten\x12\x18\n" +
+	"\aqueries\x18\x06 \x01(\x03R\aqueries\x12\x19\n" +
+	"\bvm_steps\x18\a \x01(\x03R\avmSteps\"?\n" +
+	"\rResponseUsage\x12.\n" +
+	"\arecords\x18\x01 \x03(\v2\x14.message.UsageRecordR\arecords\"+\n" +
+	"\x11RequestRateLimits\x12\x16\n" +
+	"\x06limits\x18\x01 \x01(\fR\x06limits\"\x9c\x01\n" +
+	"\n" +
+	"BackupInfo\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1a\n" +
+	"\bdatabase\x18\x02 \x01(\tR\bdatabase\x12\x18\n" +
+	"\acreated\x18\x03 \x01(\x03R\acreated\x12\x12\n" +
+	"\x04size\x18\x04 \x01(\x03R\x04size\x12\x16\n" +
+	"\x06sha256\x18\x05 \x01(\tR\x06sha256\x12\x1c\n" +
+	"\tencrypted\x18\x06 \x01(\bR\tencrypted\"@\n" +
+	"\x0fResponseBackups\x12-\n" +
+	"\abackups\x18\x01 \x03(\v2\x13.message.BackupInfoR\abackups\"o\n" +
+	"\x0eRequestRestore\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x16\n" +
+	"\x06source\x18\x02 \x01(\tR\x06source\x12\x1b\n" +
+	"\tbackup_id\x18\x03 \x01(\tR\bbackupId\x12\x14\n" +
+	"\x05until\x18\x04 \x01(\x03R\x05until\"d\n" +
+	"\rRecoveryChunk\x12+\n" +
+	"\x06backup\x18\x01 \x01(\v2\x13.message.BackupInfoR\x06backup\x12\x12\n" +
+	"\x04file\x18\x02 \x01(\tR\x04file\x12\x12\n" +
+	"\x04data\x18\x03 \x01(\fR\x04data\";\n" +
+	"\rRequestExport\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x16\n" +
+	"\x06format\x18\x02 \x01(\tR\x06format\"\x1f\n" +
+	"\tDataChunk\x12\x12\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data\"M\n" +
+	"\vImportChunk\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x16\n" +
+	"\x06format\x18\x02 \x01(\tR\x06format\x12\x12\n" +
+	"\x04data\x18\x03 \x01(\fR\x04data\"Z\n" +
+	"\x0eResponseImport\x12\x14\n" +
+	"\x05bytes\x18\x01 \x01(\x03R\x05bytes\x12\x1e\n" +
+	"\n" +
+	"statements\x18\x02 \x01(\x03R\n" +
+	"statements\x12\x12\n" +
+	"\x04rows\x18\x03 \x01(\x03R\x04rows\":\n" +
+	"\fRequestClone\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x16\n" +
+	"\x06source\x18\x02 \x01(\tR\x06source\"@\n" +
+	"\n" +
+	"BranchInfo\x12\x16\n" +
+	"\x06parent\x18\x01 \x01(\tR\x06parent\x12\x1a\n" +
+	"\bbranched\x18\x02 \x01(\x03R\bbranched\"\xcf\x01\n" +
+	"\tRowChange\x12+\n" +
+	"\x06branch\x18\x01 \x01(\v2\x13.message.BranchInfoR\x06branch\x12\x14\n" +
+	"\x05table\x18\x02 \x01(\tR\x05table\x12\x0e\n" +
+	"\x02op\x18\x03 \x01(\tR\x02op\x12)\n" +
+	"\acolumns\x18\x04 \x03(\v2\x0f.message.ColumnR\acolumns\x12$\n" +
+	"\x06parent\x18\x05 \x01(\v2\f.message.RowR\x06parent\x12\x1e\n" +
+	"\x03row\x18\x06 \x01(\v2\f.message.RowR\x03row\"\x9a\x01\n" +
+	"\fTemplateInfo\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x18\n" +
+	"\aversion\x18\x02 \x01(\tR\aversion\x12\x16\n" +
+	"\x06source\x18\x03 \x01(\tR\x06source\x12\x18\n" +
+	"\acreated\x18\x04 \x01(\x03R\acreated\x12\x12\n" +
+	"\x04size\x18\x05 \x01(\x03R\x04size\x12\x16\n" +
+	"\x06sha256\x18\x06 \x01(\tR\x06sha256\"V\n" +
+	"\rTemplateChunk\x121\n" +
+	"\btemplate\x18\x01 \x01(\v2\x15.message.TemplateInfoR\btemplate\x12\x12\n" +
+	"\x04data\x18\x02 \x01(\fR\x04data\"?\n" +
+	"\x0fRequestTemplate\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x18\n" +
+	"\aversion\x18\x02 \x01(\tR\aversion\"H\n" +
+	"\x11ResponseTemplates\x123\n" +
+	"\ttemplates\x18\x01 \x03(\v2\x15.message.TemplateInfoR\ttemplates\"x\n" +
+	"\n" +
+	"TrashEntry\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\x12\x18\n" +
+	"\adropped\x18\x03 \x01(\x03R\adropped\x12\x18\n" +
+	"\aexpires\x18\x04 \x01(\x03R\aexpires\x12\x12\n" +
+	"\x04size\x18\x05 \x01(\x03R\x04size\"L\n" +
+	"\fRequestTrash\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\x12\x18\n" +
+	"\aconfirm\x18\x03 \x01(\bR\aconfirm\">\n" +
+	"\rResponseTrash\x12-\n" +
+	"\aentries\x18\x01 \x03(\v2\x13.message.TrashEntryR\aentries\"<\n" +
+	"\x10RequestSubscribe\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05after\x18\x02 \x01(\x03R\x05after\"f\n" +
+	"\fSubscription\x12\x14\n" +
+	"\x05after\x18\x01 \x01(\x04R\x05after\x12\x16\n" +
+	"\x06oldest\x18\x02 \x01(\x04R\x06oldest\x12\x16\n" +
+	"\x06latest\x18\x03 \x01(\x04R\x06latest\x12\x10\n" +
+	"\x03log\x18\x04 \x01(\tR\x03log\"\xb3\x02\n" +
+	"\vChangeEvent\x129\n" +
+	"\fsubscription\x18\x01 \x01(\v2\x15.message.SubscriptionR\fsubscription\x12\x16\n" +
+	"\x06offset\x18\x02 \x01(\x04R\x06offset\x12\x16\n" +
+	"\x06commit\x18\x03 \x01(\x04R\x06commit\x12\x12\n" +
+	"\x04time\x18\x04 \x01(\x03R\x04time\x12\x14\n" +
+	"\x05table\x18\x05 \x01(\tR\x05table\x12\x0e\n" +
+	"\x02op\x18\x06 \x01(\tR\x02op\x12\x14\n" +
+	"\x05rowid\x18\a \x01(\x03R\x05rowid\x12)\n" +
+	"\acolumns\x18\b \x03(\v2\x0f.message.ColumnR\acolumns\x12\x1e\n" +
+	"\x03old\x18\t \x01(\v2\f.message.RowR\x03old\x12\x1e\n" +
+	"\x03new\x18\n" +
+	" \x01(\v2\f.message.RowR\x03new\"\xd4\x01\n" +
+	"\aWebhook\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x10\n" +
+	"\x03url\x18\x02 \x01(\tR\x03url\x12\x14\n" +
+	"\x05table\x18\x03 \x01(\tR\x05table\x12\x16\n" +
+	"\x06events\x18\x04 \x03(\tR\x06events\x12\x16\n" +
+	"\x06secret\x18\x05 \x01(\tR\x06secret\x12\x1d\n" +
+	"\n" +
+	"batch_size\x18\x06 \x01(\x05R\tbatchSize\x12\x18\n" +
+	"\acreated\x18\a \x01(\x03R\acreated\x12\x10\n" +
+	"\x03log\x18\b \x01(\tR\x03log\x12\x16\n" +
+	"\x06offset\x18\t \x01(\x04R\x06offset\"t\n" +
+	"\x0eRequestWebhook\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\x12*\n" +
+	"\awebhook\x18\x03 \x01(\v2\x10.message.WebhookR\awebhook\x12\x12\n" +
+	"\x04dead\x18\x04 \x01(\x03R\x04dead\"@\n" +
+	"\x10ResponseWebhooks\x12,\n" +
+	"\bwebhooks\x18\x01 \x03(\v2\x10.message.WebhookR\bwebhooks\"\xee\x01\n" +
+	"\bDelivery\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x18\n" +
+	"\awebhook\x18\x02 \x01(\tR\awebhook\x12\x12\n" +
+	"\x04time\x18\x03 \x01(\x03R\x04time\x12\x18\n" +
+	"\aattempt\x18\x04 \x01(\x05R\aattempt\x12\x16\n" +
+	"\x06status\x18\x05 \x01(\x05R\x06status\x12\x14\n" +
+	"\x05error\x18\x06 \x01(\tR\x05error\x12\x14\n" +
+	"\x05first\x18\a \x01(\x04R\x05first\x12\x12\n" +
+	"\x04last\x18\b \x01(\x04R\x04last\x12\x16\n" +
+	"\x06events\x18\t \x01(\x05R\x06events\x12\x1a\n" +
+	"\bduration\x18\n" +
+	" \x01(\x03R\bduration\"\xde\x01\n" +
+	"\n" +
+	"DeadLetter\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x18\n" +
+	"\awebhook\x18\x02 \x01(\tR\awebhook\x12\x18\n" +
+	"\acreated\x18\x03 \x01(\x03R\acreated\x12\x14\n" +
+	"\x05first\x18\x04 \x01(\x04R\x05first\x12\x12\n" +
+	"\x04last\x18\x05 \x01(\x04R\x04last\x12\x16\n" +
+	"\x06events\x18\x06 \x01(\x05R\x06events\x12\x1a\n" +
+	"\battempts\x18\a \x01(\x05R\battempts\x12\x14\n" +
+	"\x05error\x18\b \x01(\tR\x05error\x12\x18\n" +
+	"\apayload\x18\t \x01(\fR\apayload\"p\n" +
+	"\x12ResponseDeliveries\x121\n" +
+	"\n" +
+	"deliveries\x18\x01 \x03(\v2\x11.message.DeliveryR\n" +
+	"deliveries\x12'\n" +
+	"\x04dead\x18\x02 \x03(\v2\x13.message.DeadLetterR\x04dead\"\xde\x01\n" +
+	"\x03Job\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1a\n" +
+	"\bschedule\x18\x02 \x01(\tR\bschedule\x12\x1a\n" +
+	"\btimezone\x18\x03 \x01(\tR\btimezone\x12\x10\n" +
+	"\x03sql\x18\x04 \x01(\tR\x03sql\x12\x1b\n" +
+	"\talert_url\x18\x05 \x01(\tR\balertUrl\x12\x14\n" +
+	"\x05owner\x18\x06 \x01(\tR\x05owner\x12\x18\n" +
+	"\acreated\x18\a \x01(\x03R\acreated\x12\x18\n" +
+	"\aupdated\x18\b \x01(\x03R\aupdated\x12\x12\n" +
+	"\x04next\x18\t \x01(\x03R\x04next\"`\n" +
+	"\n" +
+	"RequestJob\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x10\n" +
+	"\x03job\x18\x02 \x01(\tR\x03job\x12,\n" +
+	"\n" +
+	"definition\x18\x03 \x01(\v2\f.message.JobR\n" +
+	"definition\"0\n" +
+	"\fResponseJobs\x12 \n" +
+	"\x04jobs\x18\x01 \x03(\v2\f.message.JobR\x04jobs\"\xc0\x01\n" +
+	"\x06JobRun\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x10\n" +
+	"\x03job\x18\x02 \x01(\tR\x03job\x12\x1c\n" +
+	"\tscheduled\x18\x03 \x01(\x03R\tscheduled\x12\x18\n" +
+	"\astarted\x18\x04 \x01(\x03R\astarted\x12\x1a\n" +
+	"\bduration\x18\x05 \x01(\x03R\bduration\x12\x16\n" +
+	"\x06status\x18\x06 \x01(\tR\x06status\x12\x14\n" +
+	"\x05error\x18\a \x01(\tR\x05error\x12\x12\n" +
+	"\x04rows\x18\b \x01(\x03R\x04rows\"6\n" +
+	"\x0fResponseJobRuns\x12#\n" +
+	"\x04runs\x18\x01 \x03(\v2\x0f.message.JobRunR\x04runs\"\x9f\x01\n" +
+	"\rRequestFanout\x12\x18\n" +
+	"\apattern\x18\x01 \x01(\tR\apattern\x12\x14\n" +
+	"\x05query\x18\x02 \x01(\tR\x05query\x12 \n" +
+	"\vconcurrency\x18\x03 \x01(\x05R\vconcurrency\x12\x1d\n" +
+	"\n" +
+	"timeout_ms\x18\x04 \x01(\x05R\ttimeoutMs\x12\x1d\n" +
+	"\n" +
+	"batch_size\x18\x05 \x01(\x05R\tbatchSize\"\x93\x01\n" +
+	"\vFanoutBatch\x12\x1a\n" +
+	"\bdatabase\x18\x01 \x01(\tR\bdatabase\x12*\n" +
+	"\x06result\x18\x02 \x01(\v2\x12.message.ResultSetR\x06result\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\x12\x12\n" +
+	"\x04code\x18\x04 \x01(\tR\x04code\x12\x12\n" +
+	"\x04done\x18\x05 \x01(\bR\x04done*\x83\x01\n" +
+	"\bEncoding\x12\x11\n" +
+	"\rENCODING_JSON\x10\x00\x12\x11\n" +
+	"\rENCODING_ROWS\x10\x01\x12\x15\n" +
+	"\x11ENCODING_COLUMNAR\x10\x02\x12\x10\n" +
+	"\fENCODING_CSV\x10\x03\x12\x12\n" +
+	"\x0eENCODING_ARROW\x10\x04\x12\x14\n" +
+	"\x10ENCODING_MSGPACK\x10\x05*T\n" +
+	"\x04Kind\x12\r\n" +
+	"\tKIND_NULL\x10\x00\x12\x10\n" +
+	"\fKIND_INTEGER\x10\x01\x12\r\n" +
+	"\tKIND_REAL\x10\x02\x12\r\n" +
+	"\tKIND_TEXT\x10\x03\x12\r\n" +
+	"\tKIND_BLOB\x10\x042\xf1\x14\n" +
+	"\n" +
+	"PopService\x128\n" +
+	"\x06Create\x12\x16.message.RequestCreate\x1a\x14.message.DDLResponse\"\x00\x126\n" +
+	"\x03Get\x12\x17.message.RequestGetDrop\x1a\x14.message.DDLResponse\"\x00\x127\n" +
+	"\x04Drop\x12\x17.message.RequestGetDrop\x1a\x14.message.DDLResponse\"\x00\x12<\n" +
+	"\x05Query\x12\x19.message.RequestQueryExec\x1a\x16.message.ResponseQuery\"\x00\x12I\n" +
+	"\vQueryStream\x12\x19.message.RequestQueryExec\x1a\x1b.message.ResponseQueryBatch\"\x000\x01\x12:\n" +
+	"\x04Exec\x12\x19.message.RequestQueryExec\x1a\x15.message.ResponseExec\"\x00\x12<\n" +
+	"\tRotateKey\x12\x17.message.RequestGetDrop\x1a\x14.message.DDLResponse\"\x00\x128\n" +
+	"\x05Audit\x12\x15.message.RequestAudit\x1a\x16.message.ResponseAudit\"\x00\x12A\n" +
+	"\fSetRowPolicy\x12\x19.message.RequestRowPolicy\x1a\x14.message.DDLResponse\"\x00\x128\n" +
+	"\x05Usage\x12\x15.message.RequestUsage\x1a\x16.message.ResponseUsage\"\x00\x12C\n" +
+	"\rSetRateLimits\x12\x1a.message.RequestRateLimits\x1a\x14.message.DDLResponse\"\x00\x12=\n" +
+	"\x06Backup\x12\x17.message.RequestGetDrop\x1a\x18.message.ResponseBackups\"\x00\x12B\n" +
+	"\vListBackups\x12\x17.message.RequestGetDrop\x1a\x18.message.ResponseBackups\"\x00\x12:\n" +
+	"\aRestore\x12\x17.message.RequestRestore\x1a\x14.message.DDLResponse\"\x00\x12E\n" +
+	"\x0eExportRecovery\x12\x17.message.RequestRestore\x1a\x16.message.RecoveryChunk\"\x000\x01\x128\n" +
+	"\x06Export\x12\x16.message.RequestExport\x1a\x12.message.DataChunk\"\x000\x01\x12;\n" +
+	"\x06Import\x12\x14.message.ImportChunk\x1a\x17.message.ResponseImport\"\x00(\x01\x12E\n" +
+	"\x0eExportSnapshot\x12\x17.message.RequestGetDrop\x1a\x16.message.RecoveryChunk\"\x000\x01\x126\n" +
+	"\x05Clone\x12\x15.message.RequestClone\x1a\x14.message.DDLResponse\"\x00\x127\n" +
+	"\x04Diff\x12\x17.message.RequestGetDrop\x1a\x12.message.RowChange\"\x000\x01\x12?\n" +
+	"\vPutTemplate\x12\x16.message.TemplateChunk\x1a\x14.message.DDLResponse\"\x00(\x01\x12F\n" +
+	"\x0eExportTemplate\x12\x18.message.RequestTemplate\x1a\x16.message.TemplateChunk\"\x000\x01\x12B\n" +
+	"\x0eDeleteTemplate\x12\x18.message.RequestTemplate\x1a\x14.message.DDLResponse\"\x00\x12G\n" +
+	"\rListTemplates\x12\x18.message.RequestTemplate\x1a\x1a.message.ResponseTemplates\"\x00\x12<\n" +
+	"\tListTrash\x12\x15.message.RequestTrash\x1a\x16.message.ResponseTrash\"\x00\x12?\n" +
+	"\fRestoreTrash\x12\x15.message.RequestTrash\x1a\x16.message.ResponseTrash\"\x00\x12=\n" +
+	"\n" +
+	"PurgeTrash\x12\x15.message.RequestTrash\x1a\x16.message.ResponseTrash\"\x00\x12@\n" +
+	"\tSubscribe\x12\x19.message.RequestSubscribe\x1a\x14.message.ChangeEvent\"\x000\x01\x12E\n" +
+	"\rCreateWebhook\x12\x17.message.RequestWebhook\x1a\x19.message.ResponseWebhooks\"\x00\x12D\n" +
+	"\fListWebhooks\x12\x17.message.RequestWebhook\x1a\x19.message.ResponseWebhooks\"\x00\x12E\n" +
+	"\rDeleteWebhook\x12\x17.message.RequestWebhook\x1a\x19.message.ResponseWebhooks\"\x00\x12H\n" +
+	"\x0eListDeliveries\x12\x17.message.RequestWebhook\x1a\x1b.message.ResponseDeliveries\"\x00\x12I\n" +
+	"\x0fListDeadLetters\x12\x17.message.RequestWebhook\x1a\x1b.message.ResponseDeliveries\"\x00\x12N\n" +
+	"\x14RedeliverDeadLetters\x12\x17.message.RequestWebhook\x1a\x1b.message.ResponseDeliveries\"\x00\x12L\n" +
+	"\x12DiscardDeadLetters\x12\x17.message.RequestWebhook\x1a\x1b.message.ResponseDeliveries\"\x00\x126\n" +
+	"\x06PutJob\x12\x13.message.RequestJob\x1a\x15.message.ResponseJobs\"\x00\x128\n" +
+	"\bListJobs\x12\x13.message.RequestJob\x1a\x15.message.ResponseJobs\"\x00\x129\n" +
+	"\tDeleteJob\x12\x13.message.RequestJob\x1a\x15.message.ResponseJobs\"\x00\x12>\n" +
+	"\vListJobRuns\x12\x13.message.RequestJob\x1a\x18.message.ResponseJobRuns\"\x00\x129\n" +
+	"\x06RunJob\x12\x13.message.RequestJob\x1a\x18.message.ResponseJobRuns\"\x00\x12?\n" +
+	"\vFanoutQuery\x12\x16.message.RequestFanout\x1a\x14.message.FanoutBatch\"\x000\x01B\x13Z\x11bedroompop/serverb\x06proto3"
+
+var (
+	file_message_proto_rawDescOnce sync.Once
+	file_message_proto_rawDescData []byte
+)
+
+func file_message_proto_rawDescGZIP() []byte {
+	file_message_proto_rawDescOnce.Do(func() {
+		file_message_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_message_proto_rawDesc), len(file_message_proto_rawDesc)))
+	})
+	return file_message_proto_rawDescData
+}
+
+var file_message_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_message_proto_msgTypes = make([]protoimpl.MessageInfo, 56)
+var file_message_proto_goTypes = []any{
+	(Encoding)(0),              // 0: message.Encoding
+	(Kind)(0),                  // 1: message.Kind
+	(*RequestCreate)(nil),      // 2: message.RequestCreate
+	(*RequestGetDrop)(nil),     // 3: message.RequestGetDrop
+	(*RequestQueryExec)(nil),   // 4: message.RequestQueryExec
+	(*Column)(nil),             // 5: message.Column
+	(*Value)(nil),              // 6: message.Value
+	(*Row)(nil),                // 7: message.Row
+	(*ResultSet)(nil),          // 8: message.ResultSet
+	(*ColumnVector)(nil),       // 9: message.ColumnVector
+	(*ColumnarBatch)(nil),      // 10: message.ColumnarBatch
+	(*DDLResponse)(nil),        // 11: message.DDLResponse
+	(*ResponseQuery)(nil),      // 12: message.ResponseQuery
+	(*ResponseQueryBatch)(nil), // 13: message.ResponseQueryBatch
+	(*ResponseExec)(nil),       // 14: message.ResponseExec
+	(*RequestRowPolicy)(nil),   // 15: message.RequestRowPolicy
+	(*RequestAudit)(nil),       // 16: message.RequestAudit
+	(*AuditEntry)(nil),         // 17: message.AuditEntry
+	(*ResponseAudit)(nil),      // 18: message.ResponseAudit
+	(*RequestUsage)(nil),       // 19: message.RequestUsage
+	(*UsageRecord)(nil),        // 20: message.UsageRecord
+	(*ResponseUsage)(nil),      // 21: message.ResponseUsage
+	(*RequestRateLimits)(nil),  // 22: message.RequestRateLimits
+	(*BackupInfo)(nil),         // 23: message.BackupInfo
+	(*ResponseBackups)(nil),    // 24: message.ResponseBackups
+	(*RequestRestore)(nil),     // 25: message.RequestRestore
+	(*RecoveryChunk)(nil),      // 26: message.RecoveryChunk
+	(*RequestExport)(nil),      // 27: message.RequestExport
+	(*DataChunk)(nil),          // 28: message.DataChunk
+	(*ImportChunk)(nil),        // 29: message.ImportChunk
+	(*ResponseImport)(nil),     // 30: message.ResponseImport
+	(*RequestClone)(nil),       // 31: message.RequestClone
+	(*BranchInfo)(nil),         // 32: message.BranchInfo
+	(*RowChange)(nil),          // 33: message.RowChange
+	(*TemplateInfo)(nil),       // 34: message.TemplateInfo
+	(*TemplateChunk)(nil),      // 35: message.TemplateChunk
+	(*RequestTemplate)(nil),    // 36: message.RequestTemplate
+	(*ResponseTemplates)(nil),  // 37: message.ResponseTemplates
+	(*TrashEntry)(nil),         // 38: message.TrashEntry
+	(*RequestTrash)(nil),       // 39: message.RequestTrash
+	(*ResponseTrash)(nil),      // 40: message.ResponseTrash
+	(*RequestSubscribe)(nil),   // 41: message.RequestSubscribe
+	(*Subscription)(nil),       // 42: message.Subscription
+	(*ChangeEvent)(nil),        // 43: message.ChangeEvent
+	(*Webhook)(nil),            // 44: message.Webhook
+	(*RequestWebhook)(nil),     // 45: message.RequestWebhook
+	(*ResponseWebhooks)(nil),   // 46: message.ResponseWebhooks
+	(*Delivery)(nil),           // 47: message.Delivery
+	(*DeadLetter)(nil),         // 48: message.DeadLetter
+	(*ResponseDeliveries)(nil), // 49: message.ResponseDeliveries
+	(*Job)(nil),                // 50: message.Job
+	(*RequestJob)(nil),         // 51: message.RequestJob
+	(*ResponseJobs)(nil),       // 52: message.ResponseJobs
+	(*JobRun)(nil),             // 53: message.JobRun
+	(*ResponseJobRuns)(nil),    // 54: message.ResponseJobRuns
+	(*RequestFanout)(nil),      // 55: message.RequestFanout
+	(*FanoutBatch)(nil),        // 56: message.FanoutBatch
+	nil,                        // 57: message.RequestQueryExec.AttachEntry
+	(*anypb.Any)(nil),          // 58: google.protobuf.Any
+}
+var file_message_proto_depIdxs = []int32{
+	58, // 0: message.RequestQueryExec.args:type_name -> google.protobuf.Any
+	0,  // 1: message.RequestQueryExec.encoding:type_name -> message.Encoding
+	57, // 2: message.RequestQueryExec.attach:type_name -> message.RequestQueryExec.AttachEntry
+	6,  // 3: message.Row.values:type_name -> message.Value
+	5,  // 4: message.ResultSet.columns:type_name -> message.Column
+	7,  // 5: message.ResultSet.rows:type_name -> message.Row
+	5,  // 6: message.ColumnarBatch.columns:type_name -> message.Column
+	9,  // 7: message.ColumnarBatch.vectors:type_name -> message.ColumnVector
+	8,  // 8: message.ResponseQuery.rows:type_name -> message.ResultSet
+	10, // 9: message.ResponseQuery.columnar:type_name -> message.ColumnarBatch
+	8,  // 10: message.ResponseQueryBatch.result:type_name -> message.ResultSet
+	10, // 11: message.ResponseQueryBatch.columnar:type_name -> message.ColumnarBatch
+	17, // 12: message.ResponseAudit.entries:type_name -> message.AuditEntry
+	20, // 13: message.ResponseUsage.records:type_name -> message.UsageRecord
+	23, // 14: message.ResponseBackups.backups:type_name -> message.BackupInfo
+	23, // 15: message.RecoveryChunk.backup:type_name -> message.BackupInfo
+	32, // 16: message.RowChange.branch:type_name -> message.BranchInfo
+	5,  // 17: message.RowChange.columns:type_name -> message.Column
+	7,  // 18: message.RowChange.parent:type_name -> message.Row
+	7,  // 19: message.RowChange.row:type_name -> message.Row
+	34, // 20: message.TemplateChunk.template:type_name -> message.TemplateInfo
+	34, // 21: message.ResponseTemplates.templates:type_name -> message.TemplateInfo
+	38, // 22: message.ResponseTrash.entries:type_name -> message.TrashEntry
+	42, // 23: message.ChangeEvent.subscription:type_name -> message.Subscription
+	5,  // 24: message.ChangeEvent.columns:type_name -> message.Column
+	7,  // 25: message.ChangeEvent.old:type_name -> message.Row
+	7,  // 26: message.ChangeEvent.new:type_name -> message.Row
+	44, // 27: message.RequestWebhook.webhook:type_name -> message.Webhook
+	44, // 28: message.ResponseWebhooks.webhooks:type_name -> message.Webhook
+	47, // 29: message.ResponseDeliveries.deliveries:type_name -> message.Delivery
+	48, // 30: message.ResponseDeliveries.dead:type_name -> message.DeadLetter
+	50, // 31: message.RequestJob.definition:type_name -> message.Job
+	50, // 32: message.ResponseJobs.jobs:type_name -> message.Job
+	53, // 33: message.ResponseJobRuns.runs:type_name -> message.JobRun
+	8,  // 34: message.FanoutBatch.result:type_name -> message.ResultSet
+	2,  // 35: message.PopService.Create:input_type -> message.RequestCreate
+	3,  // 36: message.PopService.Get:input_type -> message.RequestGetDrop
+	3,  // 37: message.PopService.Drop:input_type -> message.RequestGetDrop
+	4,  // 38: message.PopService.Query:input_type -> message.RequestQueryExec
+	4,  // 39: message.PopService.QueryStream:input_type -> message.RequestQueryExec
+	4,  // 40: message.PopService.Exec:input_type -> message.RequestQueryExec
+	3,  // 41: message.PopService.RotateKey:input_type -> message.RequestGetDrop
+	16, // 42: message.PopService.Audit:input_type -> message.RequestAudit
+	15, // 43: message.PopService.SetRowPolicy:input_type -> message.RequestRowPolicy
+	19, // 44: message.PopService.Usage:input_type -> message.RequestUsage
+	22, // 45: message.PopService.SetRateLimits:input_type -> message.RequestRateLimits
+	3,  // 46: message.PopService.Backup:input_type -> message.RequestGetDrop
+	3,  // 47: message.PopService.ListBackups:input_type -> message.RequestGetDrop
+	25, // 48: message.PopService.Restore:input_type -> message.RequestRestore
+	25, // 49: message.PopService.ExportRecovery:input_type -> message.RequestRestore
+	27, // 50: message.PopService.Export:input_type -> message.RequestExport
+	29, // 51: message.PopService.Import:input_type -> message.ImportChunk
+	3,  // 52: message.PopService.ExportSnapshot:input_type -> message.RequestGetDrop
+	31, // 53: message.PopService.Clone:input_type -> message.RequestClone
+	3,  // 54: message.PopService.Diff:input_type -> message.RequestGetDrop
+	35, // 55: message.PopService.PutTemplate:input_type -> message.TemplateChunk
+	36, // 56: message.PopService.ExportTemplate:input_type -> message.RequestTemplate
+	36, // 57: message.PopService.DeleteTemplate:input_type -> message.RequestTemplate
+	36, // 58: message.PopService.ListTemplates:input_type -> message.RequestTemplate
+	39, // 59: message.PopService.ListTrash:input_type -> message.RequestTrash
+	39, // 60: message.PopService.RestoreTrash:input_type -> message.RequestTrash
+	39, // 61: message.PopService.PurgeTrash:input_type -> message.RequestTrash
+	41, // 62: message.PopService.Subscribe:input_type -> message.RequestSubscribe
+	45, // 63: message.PopService.CreateWebhook:input_type -> message.RequestWebhook
+	45, // 64: message.PopService.ListWebhooks:input_type -> message.RequestWebhook
+	45, // 65: message.PopService.DeleteWebhook:input_type -> message.RequestWebhook
+	45, // 66: message.PopService.ListDeliveries:input_type -> message.RequestWebhook
+	45, // 67: message.PopService.ListDeadLetters:input_type -> message.RequestWebhook
+	45, // 68: message.PopService.RedeliverDeadLetters:input_type -> message.RequestWebhook
+	45, // 69: message.PopService.DiscardDeadLetters:input_type -> message.RequestWebhook
+	51, // 70: message.PopService.PutJob:input_type -> message.RequestJob
+	51, // 71: message.PopService.ListJobs:input_type -> message.RequestJob
+	51, // 72: message.PopService.DeleteJob:input_type -> message.RequestJob
+	51, // 73: message.PopService.ListJobRuns:input_type -> message.RequestJob
+	51, // 74: message.PopService.RunJob:input_type -> message.RequestJob
+	55, // 75: message.PopService.FanoutQuery:input_type -> message.RequestFanout
+	11, // 76: message.PopService.Create:output_type -> message.DDLResponse
+	11, // 77: message.PopService.Get:output_type -> message.DDLResponse
+	11, // 78: message.PopService.Drop:output_type -> message.DDLResponse
+	12, // 79: message.PopService.Query:output_type -> message.ResponseQuery
+	13, // 80: message.PopService.QueryStream:output_type -> message.ResponseQueryBatch
+	14, // 81: message.PopService.Exec:output_type -> message.ResponseExec
+	11, // 82: message.PopService.RotateKey:output_type -> message.DDLResponse
+	18, // 83: message.PopService.Audit:output_type -> message.ResponseAudit
+	11, // 84: message.PopService.SetRowPolicy:output_type -> message.DDLResponse
+	21, // 85: message.PopService.Usage:output_type -> message.ResponseUsage
+	11, // 86: message.PopService.SetRateLimits:output_type -> message.DDLResponse
+	24, // 87: message.PopService.Backup:output_type -> message.ResponseBackups
+	24, // 88: message.PopService.ListBackups:output_type -> message.ResponseBackups
+	11, // 89: message.PopService.Restore:output_type -> message.DDLResponse
+	26, // 90: message.PopService.ExportRecovery:output_type -> message.RecoveryChunk
+	28, // 91: message.PopService.Export:output_type -> message.DataChunk
+	30, // 92: message.PopService.Import:output_type -> message.ResponseImport
+	26, // 93: message.PopService.ExportSnapshot:output_type -> message.RecoveryChunk
+	11, // 94: message.PopService.Clone:output_type -> message.DDLResponse
+	33, // 95: message.PopService.Diff:output_type -> message.RowChange
+	11, // 96: message.PopService.PutTemplate:output_type -> message.DDLResponse
+	35, // 97: message.PopService.ExportTemplate:output_type -> message.TemplateChunk
+	11, // 98: message.PopService.DeleteTemplate:output_type -> message.DDLResponse
+	37, // 99: message.PopService.ListTemplates:output_type -> message.ResponseTemplates
+	40, // 100: message.PopService.ListTrash:output_type -> message.ResponseTrash
+	40, // 101: message.PopService.RestoreTrash:output_type -> message.ResponseTrash
+	40, // 102: message.PopService.PurgeTrash:output_type -> message.ResponseTrash
+	43, // 103: message.PopService.Subscribe:output_type -> message.ChangeEvent
+	46, // 104: message.PopService.CreateWebhook:output_type -> message.ResponseWebhooks
+	46, // 105: message.PopService.ListWebhooks:output_type -> message.ResponseWebhooks
+	46, // 106: message.PopService.DeleteWebhook:output_type -> message.ResponseWebhooks
+	49, // 107: message.PopService.ListDeliveries:output_type -> message.ResponseDeliveries
+	49, // 108: message.PopService.ListDeadLetters:output_type -> message.ResponseDeliveries
+	49, // 109: message.PopService.RedeliverDeadLetters:output_type -> message.ResponseDeliveries
+	49, // 110: message.PopService.DiscardDeadLetters:output_type -> message.ResponseDeliveries
+	52, // 111: message.PopService.PutJob:output_type -> message.ResponseJobs
+	52, // 112: message.PopService.ListJobs:output_type -> message.ResponseJobs
+	52, // 113: message.PopService.DeleteJob:output_type -> message.ResponseJobs
+	54, // 114: message.PopService.ListJobRuns:output_type -> message.ResponseJobRuns
+	54, // 115: message.PopService.RunJob:output_type -> message.ResponseJobRuns
+	56, // 116: message.PopService.FanoutQuery:output_type -> message.FanoutBatch
+	76, // [76:117] is the sub-list for method output_type
+	35, // [35:76] is the sub-list for method input_type
+	35, // [35:35] is the sub-list for extension type_name
+	35, // [35:35] is the sub-list for extension extendee
+	0,  // [0:35] is the sub-list for field type_name
+}
+
+func init() { file_message_proto_init() }
+func file_message_proto_init() {
+	if File_message_proto != nil {
+		return
+	}
+	file_message_proto_msgTypes[4].OneofWrappers = []any{
+		(*Value_Integer)(nil),
+		(*Value_Real)(nil),
+		(*Value_Text)(nil),
+		(*Value_Blob)(nil),
+		(*Value_Null)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_message_proto_rawDesc), len(file_message_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   6,
+			NumEnums:      2,
+			NumMessages:   56,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_message_proto_goTypes,
 		DependencyIndexes: file_message_proto_depIdxs,
+		EnumInfos:         file_message_proto_enumTypes,
 		MessageInfos:      file_message_proto_msgTypes,
 	}.Build()
 	File_message_proto = out.File
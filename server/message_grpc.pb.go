@@ -20,11 +20,47 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	PopService_Create_FullMethodName = "/message.PopService/Create"
-	PopService_Get_FullMethodName    = "/message.PopService/Get"
-	PopService_Drop_FullMethodName   = "/message.PopService/Drop"
-	PopService_Query_FullMethodName  = "/message.PopService/Query"
-	PopService_Exec_FullMethodName   = "/message.PopService/Exec"
+	PopService_Create_FullMethodName               = "/message.PopService/Create"
+	PopService_Get_FullMethodName                  = "/message.PopService/Get"
+	PopService_Drop_FullMethodName                 = "/message.PopService/Drop"
+	PopService_Query_FullMethodName                = "/message.PopService/Query"
+	PopService_QueryStream_FullMethodName          = "/message.PopService/QueryStream"
+	PopService_Exec_FullMethodName                 = "/message.PopService/Exec"
+	PopService_RotateKey_FullMethodName            = "/message.PopService/RotateKey"
+	PopService_Audit_FullMethodName                = "/message.PopService/Audit"
+	PopService_SetRowPolicy_FullMethodName         = "/message.PopService/SetRowPolicy"
+	PopService_Usage_FullMethodName                = "/message.PopService/Usage"
+	PopService_SetRateLimits_FullMethodName        = "/message.PopService/SetRateLimits"
+	PopService_Backup_FullMethodName               = "/message.PopService/Backup"
+	PopService_ListBackups_FullMethodName          = "/message.PopService/ListBackups"
+	PopService_Restore_FullMethodName              = "/message.PopService/Restore"
+	PopService_ExportRecovery_FullMethodName       = "/message.PopService/ExportRecovery"
+	PopService_Export_FullMethodName               = "/message.PopService/Export"
+	PopService_Import_FullMethodName               = "/message.PopService/Import"
+	PopService_ExportSnapshot_FullMethodName       = "/message.PopService/ExportSnapshot"
+	PopService_Clone_FullMethodName                = "/message.PopService/Clone"
+	PopService_Diff_FullMethodName                 = "/message.PopService/Diff"
+	PopService_PutTemplate_FullMethodName          = "/message.PopService/PutTemplate"
+	PopService_ExportTemplate_FullMethodName       = "/message.PopService/ExportTemplate"
+	PopService_DeleteTemplate_FullMethodName       = "/message.PopService/DeleteTemplate"
+	PopService_ListTemplates_FullMethodName        = "/message.PopService/ListTemplates"
+	PopService_ListTrash_FullMethodName            = "/message.PopService/ListTrash"
+	PopService_RestoreTrash_FullMethodName         = "/message.PopService/RestoreTrash"
+	PopService_PurgeTrash_FullMethodName           = "/message.PopService/PurgeTrash"
+	PopService_Subscribe_FullMethodName            = "/message.PopService/Subscribe"
+	PopService_CreateWebhook_FullMethodName        = "/message.PopService/CreateWebhook"
+	PopService_ListWebhooks_FullMethodName         = "/message.PopService/ListWebhooks"
+	PopService_DeleteWebhook_FullMethodName        = "/message.PopService/DeleteWebhook"
+	PopService_ListDeliveries_FullMethodName       = "/message.PopService/ListDeliveries"
+	PopService_ListDeadLetters_FullMethodName      = "/message.PopService/ListDeadLetters"
+	PopService_RedeliverDeadLetters_FullMethodName = "/message.PopService/RedeliverDeadLetters"
+	PopService_DiscardDeadLetters_FullMethodName   = "/message.PopService/DiscardDeadLetters"
+	PopService_PutJob_FullMethodName               = "/message.PopService/PutJob"
+	PopService_ListJobs_FullMethodName             = "/message.PopService/ListJobs"
+	PopService_DeleteJob_FullMethodName            = "/message.PopService/DeleteJob"
+	PopService_ListJobRuns_FullMethodName          = "/message.PopService/ListJobRuns"
+	PopService_RunJob_FullMethodName               = "/message.PopService/RunJob"
+	PopService_FanoutQuery_FullMethodName          = "/message.PopService/FanoutQuery"
 )
 
 // PopServiceClient is the client API for PopService service.
@@ -35,7 +71,43 @@ type PopServiceClient interface {
 	Get(ctx context.Context, in *RequestGetDrop, opts ...grpc.CallOption) (*DDLResponse, error)
 	Drop(ctx context.Context, in *RequestGetDrop, opts ...grpc.CallOption) (*DDLResponse, error)
 	Query(ctx context.Context, in *RequestQueryExec, opts ...grpc.CallOption) (*ResponseQuery, error)
+	QueryStream(ctx context.Context, in *RequestQueryExec, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ResponseQueryBatch], error)
 	Exec(ctx context.Context, in *RequestQueryExec, opts ...grpc.CallOption) (*ResponseExec, error)
+	RotateKey(ctx context.Context, in *RequestGetDrop, opts ...grpc.CallOption) (*DDLResponse, error)
+	Audit(ctx context.Context, in *RequestAudit, opts ...grpc.CallOption) (*ResponseAudit, error)
+	SetRowPolicy(ctx context.Context, in *RequestRowPolicy, opts ...grpc.CallOption) (*DDLResponse, error)
+	Usage(ctx context.Context, in *RequestUsage, opts ...grpc.CallOption) (*ResponseUsage, error)
+	SetRateLimits(ctx context.Context, in *RequestRateLimits, opts ...grpc.CallOption) (*DDLResponse, error)
+	Backup(ctx context.Context, in *RequestGetDrop, opts ...grpc.CallOption) (*ResponseBackups, error)
+	ListBackups(ctx context.Context, in *RequestGetDrop, opts ...grpc.CallOption) (*ResponseBackups, error)
+	Restore(ctx context.Context, in *RequestRestore, opts ...grpc.CallOption) (*DDLResponse, error)
+	ExportRecovery(ctx context.Context, in *RequestRestore, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RecoveryChunk], error)
+	Export(ctx context.Context, in *RequestExport, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DataChunk], error)
+	Import(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ImportChunk, ResponseImport], error)
+	ExportSnapshot(ctx context.Context, in *RequestGetDrop, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RecoveryChunk], error)
+	Clone(ctx context.Context, in *RequestClone, opts ...grpc.CallOption) (*DDLResponse, error)
+	Diff(ctx context.Context, in *RequestGetDrop, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RowChange], error)
+	PutTemplate(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[TemplateChunk, DDLResponse], error)
+	ExportTemplate(ctx context.Context, in *RequestTemplate, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TemplateChunk], error)
+	DeleteTemplate(ctx context.Context, in *RequestTemplate, opts ...grpc.CallOption) (*DDLResponse, error)
+	ListTemplates(ctx context.Context, in *RequestTemplate, opts ...grpc.CallOption) (*ResponseTemplates, error)
+	ListTrash(ctx context.Context, in *RequestTrash, opts ...grpc.CallOption) (*ResponseTrash, error)
+	RestoreTrash(ctx context.Context, in *RequestTrash, opts ...grpc.CallOption) (*ResponseTrash, error)
+	PurgeTrash(ctx context.Context, in *RequestTrash, opts ...grpc.CallOption) (*ResponseTrash, error)
+	Subscribe(ctx context.Context, in *RequestSubscribe, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ChangeEvent], error)
+	CreateWebhook(ctx context.Context, in *RequestWebhook, opts ...grpc.CallOption) (*ResponseWebhooks, error)
+	ListWebhooks(ctx context.Context, in *RequestWebhook, opts ...grpc.CallOption) (*ResponseWebhooks, error)
+	DeleteWebhook(ctx context.Context, in *RequestWebhook, opts ...grpc.CallOption) (*ResponseWebhooks, error)
+	ListDeliveries(ctx context.Context, in *RequestWebhook, opts ...grpc.CallOption) (*ResponseDeliveries, error)
+	ListDeadLetters(ctx context.Context, in *RequestWebhook, opts ...grpc.CallOption) (*ResponseDeliveries, error)
+	RedeliverDeadLetters(ctx context.Context, in *RequestWebhook, opts ...grpc.CallOption) (*ResponseDeliveries, error)
+	DiscardDeadLetters(ctx context.Context, in *RequestWebhook, opts ...grpc.CallOption) (*ResponseDeliveries, error)
+	PutJob(ctx context.Context, in *RequestJob, opts ...grpc.CallOption) (*ResponseJobs, error)
+	ListJobs(ctx context.Context, in *RequestJob, opts ...grpc.CallOption) (*ResponseJobs, error)
+	DeleteJob(ctx context.Context, in *RequestJob, opts ...grpc.CallOption) (*ResponseJobs, error)
+	ListJobRuns(ctx context.Context, in *RequestJob, opts ...grpc.CallOption) (*ResponseJobRuns, error)
+	RunJob(ctx context.Context, in *RequestJob, opts ...grpc.CallOption) (*ResponseJobRuns, error)
+	FanoutQuery(ctx context.Context, in *RequestFanout, opts ...grpc.CallOption) (grpc.ServerStreamingClient[FanoutBatch], error)
 }
 
 type popServiceClient struct {
@@ -86,6 +158,25 @@ func (c *popServiceClient) Query(ctx context.Context, in *RequestQueryExec, opts
 	return out, nil
 }
 
+func (c *popServiceClient) QueryStream(ctx context.Context, in *RequestQueryExec, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ResponseQueryBatch], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PopService_ServiceDesc.Streams[0], PopService_QueryStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[RequestQueryExec, ResponseQueryBatch]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PopService_QueryStreamClient = grpc.ServerStreamingClient[ResponseQueryBatch]
+
 func (c *popServiceClient) Exec(ctx context.Context, in *RequestQueryExec, opts ...grpc.CallOption) (*ResponseExec, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(ResponseExec)
@@ -93,154 +184,1287 @@ func (c *popServiceClient) Exec(ctx context.Context, in *RequestQueryExec, opts
 	if err != nil {
 		return nil, err
 	}
-	return out, nil
+	return out, nil
+}
+
+func (c *popServiceClient) RotateKey(ctx context.Context, in *RequestGetDrop, opts ...grpc.CallOption) (*DDLResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DDLResponse)
+	err := c.cc.Invoke(ctx, PopService_RotateKey_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *popServiceClient) Audit(ctx context.Context, in *RequestAudit, opts ...grpc.CallOption) (*ResponseAudit, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResponseAudit)
+	err := c.cc.Invoke(ctx, PopService_Audit_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *popServiceClient) SetRowPolicy(ctx context.Context, in *RequestRowPolicy, opts ...grpc.CallOption) (*DDLResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DDLResponse)
+	err := c.cc.Invoke(ctx, PopService_SetRowPolicy_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *popServiceClient) Usage(ctx context.Context, in *RequestUsage, opts ...grpc.CallOption) (*ResponseUsage, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResponseUsage)
+	err := c.cc.Invoke(ctx, PopService_Usage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *popServiceClient) SetRateLimits(ctx context.Context, in *RequestRateLimits, opts ...grpc.CallOption) (*DDLResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DDLResponse)
+	err := c.cc.Invoke(ctx, PopService_SetRateLimits_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *popServiceClient) Backup(ctx context.Context, in *RequestGetDrop, opts ...grpc.CallOption) (*ResponseBackups, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResponseBackups)
+	err := c.cc.Invoke(ctx, PopService_Backup_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *popServiceClient) ListBackups(ctx context.Context, in *RequestGetDrop, opts ...grpc.CallOption) (*ResponseBackups, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResponseBackups)
+	err := c.cc.Invoke(ctx, PopService_ListBackups_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *popServiceClient) Restore(ctx context.Context, in *RequestRestore, opts ...grpc.CallOption) (*DDLResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DDLResponse)
+	err := c.cc.Invoke(ctx, PopService_Restore_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *popServiceClient) ExportRecovery(ctx context.Context, in *RequestRestore, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RecoveryChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PopService_ServiceDesc.Streams[1], PopService_ExportRecovery_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[RequestRestore, RecoveryChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PopService_ExportRecoveryClient = grpc.ServerStreamingClient[RecoveryChunk]
+
+func (c *popServiceClient) Export(ctx context.Context, in *RequestExport, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DataChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PopService_ServiceDesc.Streams[2], PopService_Export_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[RequestExport, DataChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PopService_ExportClient = grpc.ServerStreamingClient[DataChunk]
+
+func (c *popServiceClient) Import(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ImportChunk, ResponseImport], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PopService_ServiceDesc.Streams[3], PopService_Import_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ImportChunk, ResponseImport]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PopService_ImportClient = grpc.ClientStreamingClient[ImportChunk, ResponseImport]
+
+func (c *popServiceClient) ExportSnapshot(ctx context.Context, in *RequestGetDrop, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RecoveryChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PopService_ServiceDesc.Streams[4], PopService_ExportSnapshot_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[RequestGetDrop, RecoveryChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PopService_ExportSnapshotClient = grpc.ServerStreamingClient[RecoveryChunk]
+
+func (c *popServiceClient) Clone(ctx context.Context, in *RequestClone, opts ...grpc.CallOption) (*DDLResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DDLResponse)
+	err := c.cc.Invoke(ctx, PopService_Clone_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *popServiceClient) Diff(ctx context.Context, in *RequestGetDrop, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RowChange], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PopService_ServiceDesc.Streams[5], PopService_Diff_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[RequestGetDrop, RowChange]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PopService_DiffClient = grpc.ServerStreamingClient[RowChange]
+
+func (c *popServiceClient) PutTemplate(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[TemplateChunk, DDLResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PopService_ServiceDesc.Streams[6], PopService_PutTemplate_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[TemplateChunk, DDLResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PopService_PutTemplateClient = grpc.ClientStreamingClient[TemplateChunk, DDLResponse]
+
+func (c *popServiceClient) ExportTemplate(ctx context.Context, in *RequestTemplate, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TemplateChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PopService_ServiceDesc.Streams[7], PopService_ExportTemplate_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[RequestTemplate, TemplateChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PopService_ExportTemplateClient = grpc.ServerStreamingClient[TemplateChunk]
+
+func (c *popServiceClient) DeleteTemplate(ctx context.Context, in *RequestTemplate, opts ...grpc.CallOption) (*DDLResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DDLResponse)
+	err := c.cc.Invoke(ctx, PopService_DeleteTemplate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *popServiceClient) ListTemplates(ctx context.Context, in *RequestTemplate, opts ...grpc.CallOption) (*ResponseTemplates, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResponseTemplates)
+	err := c.cc.Invoke(ctx, PopService_ListTemplates_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *popServiceClient) ListTrash(ctx context.Context, in *RequestTrash, opts ...grpc.CallOption) (*ResponseTrash, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResponseTrash)
+	err := c.cc.Invoke(ctx, PopService_ListTrash_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *popServiceClient) RestoreTrash(ctx context.Context, in *RequestTrash, opts ...grpc.CallOption) (*ResponseTrash, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResponseTrash)
+	err := c.cc.Invoke(ctx, PopService_RestoreTrash_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *popServiceClient) PurgeTrash(ctx context.Context, in *RequestTrash, opts ...grpc.CallOption) (*ResponseTrash, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResponseTrash)
+	err := c.cc.Invoke(ctx, PopService_PurgeTrash_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *popServiceClient) Subscribe(ctx context.Context, in *RequestSubscribe, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ChangeEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PopService_ServiceDesc.Streams[8], PopService_Subscribe_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[RequestSubscribe, ChangeEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PopService_SubscribeClient = grpc.ServerStreamingClient[ChangeEvent]
+
+func (c *popServiceClient) CreateWebhook(ctx context.Context, in *RequestWebhook, opts ...grpc.CallOption) (*ResponseWebhooks, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResponseWebhooks)
+	err := c.cc.Invoke(ctx, PopService_CreateWebhook_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *popServiceClient) ListWebhooks(ctx context.Context, in *RequestWebhook, opts ...grpc.CallOption) (*ResponseWebhooks, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResponseWebhooks)
+	err := c.cc.Invoke(ctx, PopService_ListWebhooks_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *popServiceClient) DeleteWebhook(ctx context.Context, in *RequestWebhook, opts ...grpc.CallOption) (*ResponseWebhooks, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResponseWebhooks)
+	err := c.cc.Invoke(ctx, PopService_DeleteWebhook_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *popServiceClient) ListDeliveries(ctx context.Context, in *RequestWebhook, opts ...grpc.CallOption) (*ResponseDeliveries, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResponseDeliveries)
+	err := c.cc.Invoke(ctx, PopService_ListDeliveries_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *popServiceClient) ListDeadLetters(ctx context.Context, in *RequestWebhook, opts ...grpc.CallOption) (*ResponseDeliveries, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResponseDeliveries)
+	err := c.cc.Invoke(ctx, PopService_ListDeadLetters_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *popServiceClient) RedeliverDeadLetters(ctx context.Context, in *RequestWebhook, opts ...grpc.CallOption) (*ResponseDeliveries, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResponseDeliveries)
+	err := c.cc.Invoke(ctx, PopService_RedeliverDeadLetters_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *popServiceClient) DiscardDeadLetters(ctx context.Context, in *RequestWebhook, opts ...grpc.CallOption) (*ResponseDeliveries, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResponseDeliveries)
+	err := c.cc.Invoke(ctx, PopService_DiscardDeadLetters_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *popServiceClient) PutJob(ctx context.Context, in *RequestJob, opts ...grpc.CallOption) (*ResponseJobs, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResponseJobs)
+	err := c.cc.Invoke(ctx, PopService_PutJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *popServiceClient) ListJobs(ctx context.Context, in *RequestJob, opts ...grpc.CallOption) (*ResponseJobs, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResponseJobs)
+	err := c.cc.Invoke(ctx, PopService_ListJobs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *popServiceClient) DeleteJob(ctx context.Context, in *RequestJob, opts ...grpc.CallOption) (*ResponseJobs, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResponseJobs)
+	err := c.cc.Invoke(ctx, PopService_DeleteJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *popServiceClient) ListJobRuns(ctx context.Context, in *RequestJob, opts ...grpc.CallOption) (*ResponseJobRuns, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResponseJobRuns)
+	err := c.cc.Invoke(ctx, PopService_ListJobRuns_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *popServiceClient) RunJob(ctx context.Context, in *RequestJob, opts ...grpc.CallOption) (*ResponseJobRuns, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResponseJobRuns)
+	err := c.cc.Invoke(ctx, PopService_RunJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *popServiceClient) FanoutQuery(ctx context.Context, in *RequestFanout, opts ...grpc.CallOption) (grpc.ServerStreamingClient[FanoutBatch], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PopService_ServiceDesc.Streams[9], PopService_FanoutQuery_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[RequestFanout, FanoutBatch]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PopService_FanoutQueryClient = grpc.ServerStreamingClient[FanoutBatch]
+
+// PopServiceServer is the server API for PopService service.
+// All implementations must embed UnimplementedPopServiceServer
+// for forward compatibility.
+type PopServiceServer interface {
+	Create(context.Context, *RequestCreate) (*DDLResponse, error)
+	Get(context.Context, *RequestGetDrop) (*DDLResponse, error)
+	Drop(context.Context, *RequestGetDrop) (*DDLResponse, error)
+	Query(context.Context, *RequestQueryExec) (*ResponseQuery, error)
+	QueryStream(*RequestQueryExec, grpc.ServerStreamingServer[ResponseQueryBatch]) error
+	Exec(context.Context, *RequestQueryExec) (*ResponseExec, error)
+	RotateKey(context.Context, *RequestGetDrop) (*DDLResponse, error)
+	Audit(context.Context, *RequestAudit) (*ResponseAudit, error)
+	SetRowPolicy(context.Context, *RequestRowPolicy) (*DDLResponse, error)
+	Usage(context.Context, *RequestUsage) (*ResponseUsage, error)
+	SetRateLimits(context.Context, *RequestRateLimits) (*DDLResponse, error)
+	Backup(context.Context, *RequestGetDrop) (*ResponseBackups, error)
+	ListBackups(context.Context, *RequestGetDrop) (*ResponseBackups, error)
+	Restore(context.Context, *RequestRestore) (*DDLResponse, error)
+	ExportRecovery(*RequestRestore, grpc.ServerStreamingServer[RecoveryChunk]) error
+	Export(*RequestExport, grpc.ServerStreamingServer[DataChunk]) error
+	Import(grpc.ClientStreamingServer[ImportChunk, ResponseImport]) error
+	ExportSnapshot(*RequestGetDrop, grpc.ServerStreamingServer[RecoveryChunk]) error
+	Clone(context.Context, *RequestClone) (*DDLResponse, error)
+	Diff(*RequestGetDrop, grpc.ServerStreamingServer[RowChange]) error
+	PutTemplate(grpc.ClientStreamingServer[TemplateChunk, DDLResponse]) error
+	ExportTemplate(*RequestTemplate, grpc.ServerStreamingServer[TemplateChunk]) error
+	DeleteTemplate(context.Context, *RequestTemplate) (*DDLResponse, error)
+	ListTemplates(context.Context, *RequestTemplate) (*ResponseTemplates, error)
+	ListTrash(context.Context, *RequestTrash) (*ResponseTrash, error)
+	RestoreTrash(context.Context, *RequestTrash) (*ResponseTrash, error)
+	PurgeTrash(context.Context, *RequestTrash) (*ResponseTrash, error)
+	Subscribe(*RequestSubscribe, grpc.ServerStreamingServer[ChangeEvent]) error
+	CreateWebhook(context.Context, *RequestWebhook) (*ResponseWebhooks, error)
+	ListWebhooks(context.Context, *RequestWebhook) (*ResponseWebhooks, error)
+	DeleteWebhook(context.Context, *RequestWebhook) (*ResponseWebhooks, error)
+	ListDeliveries(context.Context, *RequestWebhook) (*ResponseDeliveries, error)
+	ListDeadLetters(context.Context, *RequestWebhook) (*ResponseDeliveries, error)
+	RedeliverDeadLetters(context.Context, *RequestWebhook) (*ResponseDeliveries, error)
+	DiscardDeadLetters(context.Context, *RequestWebhook) (*ResponseDeliveries, error)
+	PutJob(context.Context, *RequestJob) (*ResponseJobs, error)
+	ListJobs(context.Context, *RequestJob) (*ResponseJobs, error)
+	DeleteJob(context.Context, *RequestJob) (*ResponseJobs, error)
+	ListJobRuns(context.Context, *RequestJob) (*ResponseJobRuns, error)
+	RunJob(context.Context, *RequestJob) (*ResponseJobRuns, error)
+	FanoutQuery(*RequestFanout, grpc.ServerStreamingServer[FanoutBatch]) error
+	mustEmbedUnimplementedPopServiceServer()
+}
+
+// UnimplementedPopServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedPopServiceServer struct{}
+
+func (UnimplementedPopServiceServer) Create(context.Context, *RequestCreate) (*DDLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Create not implemented")
+}
+func (UnimplementedPopServiceServer) Get(context.Context, *RequestGetDrop) (*DDLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedPopServiceServer) Drop(context.Context, *RequestGetDrop) (*DDLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Drop not implemented")
+}
+func (UnimplementedPopServiceServer) Query(context.Context, *RequestQueryExec) (*ResponseQuery, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Query not implemented")
+}
+func (UnimplementedPopServiceServer) QueryStream(*RequestQueryExec, grpc.ServerStreamingServer[ResponseQueryBatch]) error {
+	return status.Errorf(codes.Unimplemented, "method QueryStream not implemented")
+}
+func (UnimplementedPopServiceServer) Exec(context.Context, *RequestQueryExec) (*ResponseExec, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Exec not implemented")
+}
+func (UnimplementedPopServiceServer) RotateKey(context.Context, *RequestGetDrop) (*DDLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RotateKey not implemented")
+}
+func (UnimplementedPopServiceServer) Audit(context.Context, *RequestAudit) (*ResponseAudit, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Audit not implemented")
+}
+func (UnimplementedPopServiceServer) SetRowPolicy(context.Context, *RequestRowPolicy) (*DDLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetRowPolicy not implemented")
+}
+func (UnimplementedPopServiceServer) Usage(context.Context, *RequestUsage) (*ResponseUsage, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Usage not implemented")
+}
+func (UnimplementedPopServiceServer) SetRateLimits(context.Context, *RequestRateLimits) (*DDLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetRateLimits not implemented")
+}
+func (UnimplementedPopServiceServer) Backup(context.Context, *RequestGetDrop) (*ResponseBackups, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Backup not implemented")
+}
+func (UnimplementedPopServiceServer) ListBackups(context.Context, *RequestGetDrop) (*ResponseBackups, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListBackups not implemented")
+}
+func (UnimplementedPopServiceServer) Restore(context.Context, *RequestRestore) (*DDLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Restore not implemented")
+}
+func (UnimplementedPopServiceServer) ExportRecovery(*RequestRestore, grpc.ServerStreamingServer[RecoveryChunk]) error {
+	return status.Errorf(codes.Unimplemented, "method ExportRecovery not implemented")
+}
+func (UnimplementedPopServiceServer) Export(*RequestExport, grpc.ServerStreamingServer[DataChunk]) error {
+	return status.Errorf(codes.Unimplemented, "method Export not implemented")
+}
+func (UnimplementedPopServiceServer) Import(grpc.ClientStreamingServer[ImportChunk, ResponseImport]) error {
+	return status.Errorf(codes.Unimplemented, "method Import not implemented")
+}
+func (UnimplementedPopServiceServer) ExportSnapshot(*RequestGetDrop, grpc.ServerStreamingServer[RecoveryChunk]) error {
+	return status.Errorf(codes.Unimplemented, "method ExportSnapshot not implemented")
+}
+func (UnimplementedPopServiceServer) Clone(context.Context, *RequestClone) (*DDLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Clone not implemented")
+}
+func (UnimplementedPopServiceServer) Diff(*RequestGetDrop, grpc.ServerStreamingServer[RowChange]) error {
+	return status.Errorf(codes.Unimplemented, "method Diff not implemented")
+}
+func (UnimplementedPopServiceServer) PutTemplate(grpc.ClientStreamingServer[TemplateChunk, DDLResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method PutTemplate not implemented")
+}
+func (UnimplementedPopServiceServer) ExportTemplate(*RequestTemplate, grpc.ServerStreamingServer[TemplateChunk]) error {
+	return status.Errorf(codes.Unimplemented, "method ExportTemplate not implemented")
+}
+func (UnimplementedPopServiceServer) DeleteTemplate(context.Context, *RequestTemplate) (*DDLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteTemplate not implemented")
+}
+func (UnimplementedPopServiceServer) ListTemplates(context.Context, *RequestTemplate) (*ResponseTemplates, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTemplates not implemented")
+}
+func (UnimplementedPopServiceServer) ListTrash(context.Context, *RequestTrash) (*ResponseTrash, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTrash not implemented")
+}
+func (UnimplementedPopServiceServer) RestoreTrash(context.Context, *RequestTrash) (*ResponseTrash, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreTrash not implemented")
+}
+func (UnimplementedPopServiceServer) PurgeTrash(context.Context, *RequestTrash) (*ResponseTrash, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PurgeTrash not implemented")
+}
+func (UnimplementedPopServiceServer) Subscribe(*RequestSubscribe, grpc.ServerStreamingServer[ChangeEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedPopServiceServer) CreateWebhook(context.Context, *RequestWebhook) (*ResponseWebhooks, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateWebhook not implemented")
+}
+func (UnimplementedPopServiceServer) ListWebhooks(context.Context, *RequestWebhook) (*ResponseWebhooks, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListWebhooks not implemented")
+}
+func (UnimplementedPopServiceServer) DeleteWebhook(context.Context, *RequestWebhook) (*ResponseWebhooks, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteWebhook not implemented")
+}
+func (UnimplementedPopServiceServer) ListDeliveries(context.Context, *RequestWebhook) (*ResponseDeliveries, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDeliveries not implemented")
+}
+func (UnimplementedPopServiceServer) ListDeadLetters(context.Context, *RequestWebhook) (*ResponseDeliveries, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDeadLetters not implemented")
+}
+func (UnimplementedPopServiceServer) RedeliverDeadLetters(context.Context, *RequestWebhook) (*ResponseDeliveries, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RedeliverDeadLetters not implemented")
+}
+func (UnimplementedPopServiceServer) DiscardDeadLetters(context.Context, *RequestWebhook) (*ResponseDeliveries, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DiscardDeadLetters not implemented")
+}
+func (UnimplementedPopServiceServer) PutJob(context.Context, *RequestJob) (*ResponseJobs, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PutJob not implemented")
+}
+func (UnimplementedPopServiceServer) ListJobs(context.Context, *RequestJob) (*ResponseJobs, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListJobs not implemented")
+}
+func (UnimplementedPopServiceServer) DeleteJob(context.Context, *RequestJob) (*ResponseJobs, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteJob not implemented")
+}
+func (UnimplementedPopServiceServer) ListJobRuns(context.Context, *RequestJob) (*ResponseJobRuns, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListJobRuns not implemented")
+}
+func (UnimplementedPopServiceServer) RunJob(context.Context, *RequestJob) (*ResponseJobRuns, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RunJob not implemented")
+}
+func (UnimplementedPopServiceServer) FanoutQuery(*RequestFanout, grpc.ServerStreamingServer[FanoutBatch]) error {
+	return status.Errorf(codes.Unimplemented, "method FanoutQuery not implemented")
+}
+func (UnimplementedPopServiceServer) mustEmbedUnimplementedPopServiceServer() {}
+func (UnimplementedPopServiceServer) testEmbeddedByValue()                    {}
+
+// UnsafePopServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PopServiceServer will
+// result in compilation errors.
+type UnsafePopServiceServer interface {
+	mustEmbedUnimplementedPopServiceServer()
+}
+
+func RegisterPopServiceServer(s grpc.ServiceRegistrar, srv PopServiceServer) {
+	// If the following call pancis, it indicates UnimplementedPopServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&PopService_ServiceDesc, srv)
+}
+
+func _PopService_Create_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestCreate)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PopServiceServer).Create(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PopService_Create_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PopServiceServer).Create(ctx, req.(*RequestCreate))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PopService_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestGetDrop)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PopServiceServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PopService_Get_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PopServiceServer).Get(ctx, req.(*RequestGetDrop))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PopService_Drop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestGetDrop)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PopServiceServer).Drop(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PopService_Drop_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PopServiceServer).Drop(ctx, req.(*RequestGetDrop))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PopService_Query_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestQueryExec)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PopServiceServer).Query(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PopService_Query_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PopServiceServer).Query(ctx, req.(*RequestQueryExec))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PopService_QueryStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RequestQueryExec)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PopServiceServer).QueryStream(m, &grpc.GenericServerStream[RequestQueryExec, ResponseQueryBatch]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PopService_QueryStreamServer = grpc.ServerStreamingServer[ResponseQueryBatch]
+
+func _PopService_Exec_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestQueryExec)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PopServiceServer).Exec(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PopService_Exec_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PopServiceServer).Exec(ctx, req.(*RequestQueryExec))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PopService_RotateKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestGetDrop)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PopServiceServer).RotateKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PopService_RotateKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PopServiceServer).RotateKey(ctx, req.(*RequestGetDrop))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PopService_Audit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestAudit)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PopServiceServer).Audit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PopService_Audit_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PopServiceServer).Audit(ctx, req.(*RequestAudit))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PopService_SetRowPolicy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestRowPolicy)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PopServiceServer).SetRowPolicy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PopService_SetRowPolicy_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PopServiceServer).SetRowPolicy(ctx, req.(*RequestRowPolicy))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PopService_Usage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestUsage)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PopServiceServer).Usage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PopService_Usage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PopServiceServer).Usage(ctx, req.(*RequestUsage))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PopService_SetRateLimits_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestRateLimits)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PopServiceServer).SetRateLimits(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PopService_SetRateLimits_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PopServiceServer).SetRateLimits(ctx, req.(*RequestRateLimits))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PopService_Backup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestGetDrop)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PopServiceServer).Backup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PopService_Backup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PopServiceServer).Backup(ctx, req.(*RequestGetDrop))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PopService_ListBackups_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestGetDrop)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PopServiceServer).ListBackups(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PopService_ListBackups_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PopServiceServer).ListBackups(ctx, req.(*RequestGetDrop))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PopService_Restore_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestRestore)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PopServiceServer).Restore(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PopService_Restore_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PopServiceServer).Restore(ctx, req.(*RequestRestore))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PopService_ExportRecovery_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RequestRestore)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PopServiceServer).ExportRecovery(m, &grpc.GenericServerStream[RequestRestore, RecoveryChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PopService_ExportRecoveryServer = grpc.ServerStreamingServer[RecoveryChunk]
+
+func _PopService_Export_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RequestExport)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PopServiceServer).Export(m, &grpc.GenericServerStream[RequestExport, DataChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PopService_ExportServer = grpc.ServerStreamingServer[DataChunk]
+
+func _PopService_Import_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(PopServiceServer).Import(&grpc.GenericServerStream[ImportChunk, ResponseImport]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PopService_ImportServer = grpc.ClientStreamingServer[ImportChunk, ResponseImport]
+
+func _PopService_ExportSnapshot_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RequestGetDrop)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PopServiceServer).ExportSnapshot(m, &grpc.GenericServerStream[RequestGetDrop, RecoveryChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PopService_ExportSnapshotServer = grpc.ServerStreamingServer[RecoveryChunk]
+
+func _PopService_Clone_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestClone)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PopServiceServer).Clone(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PopService_Clone_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PopServiceServer).Clone(ctx, req.(*RequestClone))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PopService_Diff_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RequestGetDrop)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PopServiceServer).Diff(m, &grpc.GenericServerStream[RequestGetDrop, RowChange]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PopService_DiffServer = grpc.ServerStreamingServer[RowChange]
+
+func _PopService_PutTemplate_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(PopServiceServer).PutTemplate(&grpc.GenericServerStream[TemplateChunk, DDLResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PopService_PutTemplateServer = grpc.ClientStreamingServer[TemplateChunk, DDLResponse]
+
+func _PopService_ExportTemplate_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RequestTemplate)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PopServiceServer).ExportTemplate(m, &grpc.GenericServerStream[RequestTemplate, TemplateChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PopService_ExportTemplateServer = grpc.ServerStreamingServer[TemplateChunk]
+
+func _PopService_DeleteTemplate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestTemplate)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PopServiceServer).DeleteTemplate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PopService_DeleteTemplate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PopServiceServer).DeleteTemplate(ctx, req.(*RequestTemplate))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PopService_ListTemplates_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestTemplate)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PopServiceServer).ListTemplates(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PopService_ListTemplates_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PopServiceServer).ListTemplates(ctx, req.(*RequestTemplate))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PopService_ListTrash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestTrash)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PopServiceServer).ListTrash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PopService_ListTrash_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PopServiceServer).ListTrash(ctx, req.(*RequestTrash))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PopService_RestoreTrash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestTrash)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PopServiceServer).RestoreTrash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PopService_RestoreTrash_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PopServiceServer).RestoreTrash(ctx, req.(*RequestTrash))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-// PopServiceServer is the server API for PopService service.
-// All implementations must embed UnimplementedPopServiceServer
-// for forward compatibility.
-type PopServiceServer interface {
-	Create(context.Context, *RequestCreate) (*DDLResponse, error)
-	Get(context.Context, *RequestGetDrop) (*DDLResponse, error)
-	Drop(context.Context, *RequestGetDrop) (*DDLResponse, error)
-	Query(context.Context, *RequestQueryExec) (*ResponseQuery, error)
-	Exec(context.Context, *RequestQueryExec) (*ResponseExec, error)
-	mustEmbedUnimplementedPopServiceServer()
+func _PopService_PurgeTrash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestTrash)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PopServiceServer).PurgeTrash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PopService_PurgeTrash_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PopServiceServer).PurgeTrash(ctx, req.(*RequestTrash))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-// UnimplementedPopServiceServer must be embedded to have
-// forward compatible implementations.
-//
-// NOTE: this should be embedded by value instead of pointer to avoid a nil
-// pointer dereference when methods are called.
-type UnimplementedPopServiceServer struct{}
+func _PopService_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RequestSubscribe)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PopServiceServer).Subscribe(m, &grpc.GenericServerStream[RequestSubscribe, ChangeEvent]{ServerStream: stream})
+}
 
-func (UnimplementedPopServiceServer) Create(context.Context, *RequestCreate) (*DDLResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method Create not implemented")
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PopService_SubscribeServer = grpc.ServerStreamingServer[ChangeEvent]
+
+func _PopService_CreateWebhook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestWebhook)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PopServiceServer).CreateWebhook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PopService_CreateWebhook_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PopServiceServer).CreateWebhook(ctx, req.(*RequestWebhook))
+	}
+	return interceptor(ctx, in, info, handler)
 }
-func (UnimplementedPopServiceServer) Get(context.Context, *RequestGetDrop) (*DDLResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+
+func _PopService_ListWebhooks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestWebhook)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PopServiceServer).ListWebhooks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PopService_ListWebhooks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PopServiceServer).ListWebhooks(ctx, req.(*RequestWebhook))
+	}
+	return interceptor(ctx, in, info, handler)
 }
-func (UnimplementedPopServiceServer) Drop(context.Context, *RequestGetDrop) (*DDLResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method Drop not implemented")
+
+func _PopService_DeleteWebhook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestWebhook)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PopServiceServer).DeleteWebhook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PopService_DeleteWebhook_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PopServiceServer).DeleteWebhook(ctx, req.(*RequestWebhook))
+	}
+	return interceptor(ctx, in, info, handler)
 }
-func (UnimplementedPopServiceServer) Query(context.Context, *RequestQueryExec) (*ResponseQuery, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method Query not implemented")
+
+func _PopService_ListDeliveries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestWebhook)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PopServiceServer).ListDeliveries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PopService_ListDeliveries_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PopServiceServer).ListDeliveries(ctx, req.(*RequestWebhook))
+	}
+	return interceptor(ctx, in, info, handler)
 }
-func (UnimplementedPopServiceServer) Exec(context.Context, *RequestQueryExec) (*ResponseExec, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method Exec not implemented")
+
+func _PopService_ListDeadLetters_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestWebhook)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PopServiceServer).ListDeadLetters(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PopService_ListDeadLetters_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PopServiceServer).ListDeadLetters(ctx, req.(*RequestWebhook))
+	}
+	return interceptor(ctx, in, info, handler)
 }
-func (UnimplementedPopServiceServer) mustEmbedUnimplementedPopServiceServer() {}
-func (UnimplementedPopServiceServer) testEmbeddedByValue()                    {}
 
-// UnsafePopServiceServer may be embedded to opt out of forward compatibility for this service.
-// Use of this interface is not recommended, as added methods to PopServiceServer will
-// result in compilation errors.
-type UnsafePopServiceServer interface {
-	mustEmbedUnimplementedPopServiceServer()
+func _PopService_RedeliverDeadLetters_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestWebhook)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PopServiceServer).RedeliverDeadLetters(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PopService_RedeliverDeadLetters_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PopServiceServer).RedeliverDeadLetters(ctx, req.(*RequestWebhook))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func RegisterPopServiceServer(s grpc.ServiceRegistrar, srv PopServiceServer) {
-	// If the following call pancis, it indicates UnimplementedPopServiceServer was
-	// embedded by pointer and is nil.  This will cause panics if an
-	// unimplemented method is ever invoked, so we test this at initialization
-	// time to prevent it from happening at runtime later due to I/O.
-	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
-		t.testEmbeddedByValue()
+func _PopService_DiscardDeadLetters_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestWebhook)
+	if err := dec(in); err != nil {
+		return nil, err
 	}
-	s.RegisterService(&PopService_ServiceDesc, srv)
+	if interceptor == nil {
+		return srv.(PopServiceServer).DiscardDeadLetters(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PopService_DiscardDeadLetters_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PopServiceServer).DiscardDeadLetters(ctx, req.(*RequestWebhook))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func _PopService_Create_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(RequestCreate)
+func _PopService_PutJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestJob)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(PopServiceServer).Create(ctx, in)
+		return srv.(PopServiceServer).PutJob(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: PopService_Create_FullMethodName,
+		FullMethod: PopService_PutJob_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(PopServiceServer).Create(ctx, req.(*RequestCreate))
+		return srv.(PopServiceServer).PutJob(ctx, req.(*RequestJob))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _PopService_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(RequestGetDrop)
+func _PopService_ListJobs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestJob)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(PopServiceServer).Get(ctx, in)
+		return srv.(PopServiceServer).ListJobs(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: PopService_Get_FullMethodName,
+		FullMethod: PopService_ListJobs_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(PopServiceServer).Get(ctx, req.(*RequestGetDrop))
+		return srv.(PopServiceServer).ListJobs(ctx, req.(*RequestJob))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _PopService_Drop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(RequestGetDrop)
+func _PopService_DeleteJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestJob)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(PopServiceServer).Drop(ctx, in)
+		return srv.(PopServiceServer).DeleteJob(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: PopService_Drop_FullMethodName,
+		FullMethod: PopService_DeleteJob_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(PopServiceServer).Drop(ctx, req.(*RequestGetDrop))
+		return srv.(PopServiceServer).DeleteJob(ctx, req.(*RequestJob))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _PopService_Query_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(RequestQueryExec)
+func _PopService_ListJobRuns_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestJob)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(PopServiceServer).Query(ctx, in)
+		return srv.(PopServiceServer).ListJobRuns(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: PopService_Query_FullMethodName,
+		FullMethod: PopService_ListJobRuns_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(PopServiceServer).Query(ctx, req.(*RequestQueryExec))
+		return srv.(PopServiceServer).ListJobRuns(ctx, req.(*RequestJob))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _PopService_Exec_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(RequestQueryExec)
+func _PopService_RunJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestJob)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(PopServiceServer).Exec(ctx, in)
+		return srv.(PopServiceServer).RunJob(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: PopService_Exec_FullMethodName,
+		FullMethod: PopService_RunJob_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(PopServiceServer).Exec(ctx, req.(*RequestQueryExec))
+		return srv.(PopServiceServer).RunJob(ctx, req.(*RequestJob))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
+func _PopService_FanoutQuery_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RequestFanout)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PopServiceServer).FanoutQuery(m, &grpc.GenericServerStream[RequestFanout, FanoutBatch]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PopService_FanoutQueryServer = grpc.ServerStreamingServer[FanoutBatch]
+
 // PopService_ServiceDesc is the grpc.ServiceDesc for PopService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -268,7 +1492,162 @@ var PopService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Exec",
 			Handler:    _PopService_Exec_Handler,
 		},
+		{
+			MethodName: "RotateKey",
+			Handler:    _PopService_RotateKey_Handler,
+		},
+		{
+			MethodName: "Audit",
+			Handler:    _PopService_Audit_Handler,
+		},
+		{
+			MethodName: "SetRowPolicy",
+			Handler:    _PopService_SetRowPolicy_Handler,
+		},
+		{
+			MethodName: "Usage",
+			Handler:    _PopService_Usage_Handler,
+		},
+		{
+			MethodName: "SetRateLimits",
+			Handler:    _PopService_SetRateLimits_Handler,
+		},
+		{
+			MethodName: "Backup",
+			Handler:    _PopService_Backup_Handler,
+		},
+		{
+			MethodName: "ListBackups",
+			Handler:    _PopService_ListBackups_Handler,
+		},
+		{
+			MethodName: "Restore",
+			Handler:    _PopService_Restore_Handler,
+		},
+		{
+			MethodName: "Clone",
+			Handler:    _PopService_Clone_Handler,
+		},
+		{
+			MethodName: "DeleteTemplate",
+			Handler:    _PopService_DeleteTemplate_Handler,
+		},
+		{
+			MethodName: "ListTemplates",
+			Handler:    _PopService_ListTemplates_Handler,
+		},
+		{
+			MethodName: "ListTrash",
+			Handler:    _PopService_ListTrash_Handler,
+		},
+		{
+			MethodName: "RestoreTrash",
+			Handler:    _PopService_RestoreTrash_Handler,
+		},
+		{
+			MethodName: "PurgeTrash",
+			Handler:    _PopService_PurgeTrash_Handler,
+		},
+		{
+			MethodName: "CreateWebhook",
+			Handler:    _PopService_CreateWebhook_Handler,
+		},
+		{
+			MethodName: "ListWebhooks",
+			Handler:    _PopService_ListWebhooks_Handler,
+		},
+		{
+			MethodName: "DeleteWebhook",
+			Handler:    _PopService_DeleteWebhook_Handler,
+		},
+		{
+			MethodName: "ListDeliveries",
+			Handler:    _PopService_ListDeliveries_Handler,
+		},
+		{
+			MethodName: "ListDeadLetters",
+			Handler:    _PopService_ListDeadLetters_Handler,
+		},
+		{
+			MethodName: "RedeliverDeadLetters",
+			Handler:    _PopService_RedeliverDeadLetters_Handler,
+		},
+		{
+			MethodName: "DiscardDeadLetters",
+			Handler:    _PopService_DiscardDeadLetters_Handler,
+		},
+		{
+			MethodName: "PutJob",
+			Handler:    _PopService_PutJob_Handler,
+		},
+		{
+			MethodName: "ListJobs",
+			Handler:    _PopService_ListJobs_Handler,
+		},
+		{
+			MethodName: "DeleteJob",
+			Handler:    _PopService_DeleteJob_Handler,
+		},
+		{
+			MethodName: "ListJobRuns",
+			Handler:    _PopService_ListJobRuns_Handler,
+		},
+		{
+			MethodName: "RunJob",
+			Handler:    _PopService_RunJob_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "QueryStream",
+			Handler:       _PopService_QueryStream_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ExportRecovery",
+			Handler:       _PopService_ExportRecovery_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Export",
+			Handler:       _PopService_Export_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Import",
+			Handler:       _PopService_Import_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "ExportSnapshot",
+			Handler:       _PopService_ExportSnapshot_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Diff",
+			Handler:       _PopService_Diff_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "PutTemplate",
+			Handler:       _PopService_PutTemplate_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "ExportTemplate",
+			Handler:       _PopService_ExportTemplate_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Subscribe",
+			Handler:       _PopService_Subscribe_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "FanoutQuery",
+			Handler:       _PopService_FanoutQuery_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "message.proto",
 }
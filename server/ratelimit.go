@@ -0,0 +1,282 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trianglehasfoursides/bedroompop/config"
+	"github.com/trianglehasfoursides/bedroompop/consist"
+	"github.com/trianglehasfoursides/bedroompop/database"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Requests are rate limited by token buckets. The buckets of principals and
+// source addresses are kept by the node a request comes in at, which limits
+// every route. The bucket of a database is kept by the node owning it, so
+// the limit of a database holds across the cluster wherever the requests
+// come in. A request is only let through when each of its buckets has a token.
+
+// Rate fills a bucket with PerSecond tokens up to Burst. A zero rate is unlimited.
+type Rate struct {
+	PerSecond float64 `json:"per_second"`
+	Burst     int     `json:"burst"`
+}
+
+// RateLimits are the default rates per principal, source address and
+// database, with overrides for single principals and databases.
+type RateLimits struct {
+	Principal  Rate            `json:"principal"`
+	IP         Rate            `json:"ip"`
+	Database   Rate            `json:"database"`
+	Principals map[string]Rate `json:"principals"`
+	Databases  map[string]Rate `json:"databases"`
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time // when the bucket is full again if left alone
+}
+
+var limiter = struct {
+	sync.Mutex
+	limits  RateLimits
+	buckets map[string]*bucket
+	swept   time.Time
+}{buckets: make(map[string]*bucket)}
+
+// LoadRateLimits reads the rate limits of this node from a JSON file.
+func LoadRateLimits(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var limits RateLimits
+	if err := json.Unmarshal(data, &limits); err != nil {
+		return err
+	}
+	setRateLimits(limits)
+	return nil
+}
+
+// setRateLimits replaces the rate limits of this node and refills every bucket.
+func setRateLimits(limits RateLimits) {
+	limiter.Lock()
+	defer limiter.Unlock()
+
+	limiter.limits = limits
+	clear(limiter.buckets)
+}
+
+// refill adds the tokens earned since the bucket was last used.
+func (b *bucket) refill(r Rate, now time.Time) {
+	b.tokens = min(b.tokens+now.Sub(b.last).Seconds()*r.PerSecond, float64(max(r.Burst, 1)))
+	b.last = now
+}
+
+// take removes a token from the bucket.
+func (b *bucket) take(r Rate) {
+	b.tokens--
+	b.full = b.last.Add(time.Duration((float64(max(r.Burst, 1)) - b.tokens) / r.PerSecond * float64(time.Second)))
+}
+
+// allow takes a token for a request of principal to a database. A nil
+// principal or an empty database name leaves their buckets out. When one of
+// the buckets is empty nothing is taken and the wait until it has a token is returned.
+func allow(principal *database.Principal, databaseName string) (bool, time.Duration) {
+	limiter.Lock()
+	defer limiter.Unlock()
+
+	type take struct {
+		key  string
+		rate Rate
+	}
+	var takes []take
+	limits := limiter.limits
+	if principal != nil {
+		principalRate, ok := limits.Principals[principal.Name]
+		if !ok {
+			principalRate = limits.Principal
+		}
+		takes = append(takes,
+			take{"principal:" + principal.Name, principalRate},
+			take{"ip:" + principal.Addr, limits.IP},
+		)
+	}
+	if databaseName != "" {
+		databaseRate, ok := limits.Databases[databaseName]
+		if !ok {
+			databaseRate = limits.Database
+		}
+		takes = append(takes, take{"database:" + databaseName, databaseRate})
+	}
+	takes = slices.DeleteFunc(takes, func(t take) bool { return t.rate.PerSecond <= 0 })
+
+	now := time.Now()
+	var wait time.Duration
+	for _, t := range takes {
+		b, ok := limiter.buckets[t.key]
+		if !ok {
+			b = &bucket{tokens: float64(max(t.rate.Burst, 1)), last: now}
+			limiter.buckets[t.key] = b
+		}
+		b.refill(t.rate, now)
+		if b.tokens < 1 {
+			wait = max(wait, time.Duration((1-b.tokens)/t.rate.PerSecond*float64(time.Second)))
+		}
+	}
+	if wait > 0 {
+		return false, wait
+	}
+	for _, t := range takes {
+		limiter.buckets[t.key].take(t.rate)
+	}
+
+	// forget the buckets that refilled, so idle clients don't pile up
+	if now.Sub(limiter.swept) > time.Minute {
+		limiter.swept = now
+		for key, b := range limiter.buckets {
+			if now.After(b.full) {
+				delete(limiter.buckets, key)
+			}
+		}
+	}
+	return true, 0
+}
+
+// rateLimit rejects a request over its rate with the wait attached as RetryInfo.
+func rateLimit(principal *database.Principal, databaseName string) error {
+	ok, wait := allow(principal, databaseName)
+	if ok {
+		return nil
+	}
+	msg := "rate limit exceeded"
+	if databaseName != "" {
+		msg += " for database " + databaseName
+	}
+	st, err := status.New(codes.ResourceExhausted, msg).
+		WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(wait)})
+	if err != nil {
+		return status.Error(codes.ResourceExhausted, msg)
+	}
+	return st.Err()
+}
+
+// rateLimitRequest limits a gRPC request naming a database by the bucket of
+// the database. The node the request came in at already limited its principal.
+func rateLimitRequest(ctx context.Context, req any) error {
+	named, ok := req.(interface{ GetName() string })
+	if !ok || named.GetName() == "" {
+		return nil
+	}
+	return rateLimit(nil, named.GetName())
+}
+
+// rateLimited limits every request coming in at this node by its principal
+// and source address, and by its database when this node owns it. Requests
+// for databases owned by other nodes are limited there by their database
+// once forwarded.
+func rateLimited(ctx *gin.Context) {
+	principal := database.PrincipalFrom(ctx.Request.Context())
+	name := ctx.Param("name")
+	if name != "" && consist.Consist.LocateKey([]byte(name)).String() != config.GRPCAddr {
+		name = ""
+	}
+	if err := rateLimit(&principal, name); err != nil {
+		abort(ctx, err)
+	}
+}
+
+// retryAfter sets the Retry-After header from the RetryInfo of a status.
+func retryAfter(ctx *gin.Context, st *status.Status) {
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			seconds := math.Ceil(info.GetRetryDelay().AsDuration().Seconds())
+			ctx.Header("Retry-After", strconv.Itoa(max(int(seconds), 1)))
+		}
+	}
+}
+
+func (s *server) SetRateLimits(c context.Context, req *RequestRateLimits) (*DDLResponse, error) {
+	if database.PrincipalFrom(c).Role != database.AdminRole {
+		return nil, status.Error(codes.PermissionDenied, "only admins can change rate limits")
+	}
+	var limits RateLimits
+	if err := json.Unmarshal(req.GetLimits(), &limits); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	setRateLimits(limits)
+	return &DDLResponse{}, nil
+}
+
+// getRateLimits returns the rate limits of this node.
+func getRateLimits(ctx *gin.Context) {
+	if database.PrincipalFrom(ctx.Request.Context()).Role != database.AdminRole {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "rate limits are only available to admins",
+		})
+		return
+	}
+
+	limiter.Lock()
+	defer limiter.Unlock()
+	ctx.JSON(http.StatusOK, limiter.limits)
+}
+
+// putRateLimits replaces the rate limits on every node of the cluster. Nodes
+// joining later start from their --rate-limits file.
+func putRateLimits(ctx *gin.Context) {
+	if database.PrincipalFrom(ctx.Request.Context()).Role != database.AdminRole {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "only admins can change rate limits",
+		})
+		return
+	}
+
+	var limits RateLimits
+	if err := ctx.BindJSON(&limits); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	data, _ := json.Marshal(limits)
+	req := &RequestRateLimits{Limits: data}
+
+	nodes := make(map[string]string)
+	code := http.StatusOK
+	for _, member := range consist.Consist.GetMembers() {
+		address := member.String()
+
+		var err error
+		if address == config.GRPCAddr {
+			_, err = local.SetRateLimits(ctx.Request.Context(), req)
+		} else {
+			client, conn, dialErr := dial(address)
+			if dialErr != nil {
+				err = dialErr
+			} else {
+				_, err = client.SetRateLimits(outgoing(ctx.Request.Context()), req)
+				conn.Close()
+			}
+		}
+		nodes[address] = "ok"
+		if err != nil {
+			nodes[address] = status.Convert(err).Message()
+			code = http.StatusBadGateway
+		}
+	}
+	ctx.JSON(code, gin.H{"nodes": nodes})
+}
@@ -0,0 +1,247 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trianglehasfoursides/bedroompop/audit"
+	"github.com/trianglehasfoursides/bedroompop/config"
+	"github.com/trianglehasfoursides/bedroompop/consist"
+	"github.com/trianglehasfoursides/bedroompop/database"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// recoveryChunkSize is how much of a file a RecoveryChunk carries.
+const recoveryChunkSize = 1 << 20
+
+func (s *server) ExportRecovery(req *RequestRestore, stream grpc.ServerStreamingServer[RecoveryChunk]) error {
+	if database.PrincipalFrom(stream.Context()).Role != database.AdminRole {
+		return status.Error(codes.PermissionDenied, "only admins can restore backups")
+	}
+
+	var until time.Time
+	if req.GetUntil() != 0 {
+		until = time.Unix(0, req.GetUntil())
+	}
+	backup, err := database.FindBackup(stream.Context(), req.GetSource(), req.GetBackupId(), until)
+	if err != nil {
+		return err
+	}
+
+	info := backupMessage(backup)
+	buf := make([]byte, recoveryChunkSize)
+	return database.ExportRecovery(stream.Context(), backup, until, func(file string, r io.Reader) error {
+		err := sendFile(stream, info, file, r, buf)
+		info = nil
+		return err
+	})
+}
+
+// sendFile sends a file over a recovery stream in chunks read into buf, the
+// first one naming it and carrying info when set.
+func sendFile(stream grpc.ServerStreamingServer[RecoveryChunk], info *BackupInfo, file string, r io.Reader, buf []byte) error {
+	chunk := &RecoveryChunk{Backup: info, File: file}
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 || chunk.File != "" {
+			chunk.Data = buf[:n]
+			if err := stream.Send(chunk); err != nil {
+				return err
+			}
+			chunk = &RecoveryChunk{}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *server) Restore(c context.Context, req *RequestRestore) (*DDLResponse, error) {
+	err := restore(c, req)
+	record(c, audit.OpRestore, req.GetName(), "", err)
+	if err != nil {
+		return nil, err
+	}
+	return &DDLResponse{Msg: "sucess"}, nil
+}
+
+// restore fetches the backup and WAL from the node owning the source into a
+// temporary directory and restores them as the target database.
+func restore(c context.Context, req *RequestRestore) error {
+	if database.PrincipalFrom(c).Role != database.AdminRole {
+		return status.Error(codes.PermissionDenied, "only admins can restore backups")
+	}
+	if err := database.ValidateName(req.GetSource()); err != nil {
+		return err
+	}
+	if err := database.Get(req.GetName()); err == nil {
+		return database.ErrExists
+	}
+
+	dir, err := os.MkdirTemp(config.DataDir, ".restore-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	address := consist.Consist.LocateKey([]byte(req.GetSource())).String()
+	backup, files, err := fetchRecovery(c, dir, address, req, local.ExportRecovery, PopServiceClient.ExportRecovery)
+	if err != nil {
+		return err
+	}
+	return database.RestoreFrom(c, req.GetName(), files[0], files[1:], backupOf(backup))
+}
+
+// fetchRecovery saves the files of a recovery stream into dir, exporting
+// them on this node or on the node at address.
+func fetchRecovery[Req any](c context.Context, dir string, address string, req Req,
+	export func(Req, grpc.ServerStreamingServer[RecoveryChunk]) error,
+	forward func(PopServiceClient, context.Context, Req, ...grpc.CallOption) (grpc.ServerStreamingClient[RecoveryChunk], error),
+) (*BackupInfo, []string, error) {
+	var files []string
+	var backup *BackupInfo
+	var file *os.File
+	save := func(chunk *RecoveryChunk) error {
+		if chunk.GetBackup() != nil {
+			backup = chunk.GetBackup()
+		}
+		if chunk.GetFile() != "" {
+			if file != nil {
+				if err := file.Close(); err != nil {
+					return err
+				}
+			}
+			path := filepath.Join(dir, fmt.Sprintf("%04d", len(files)))
+			var err error
+			if file, err = os.Create(path); err != nil {
+				return err
+			}
+			files = append(files, path)
+		}
+		if file == nil {
+			return errors.New("recovery stream starts without a file")
+		}
+		_, err := file.Write(chunk.GetData())
+		return err
+	}
+
+	var err error
+	if address == config.GRPCAddr {
+		err = export(req, &recoveryStream{ctx: c, save: save})
+	} else {
+		client, conn, dialErr := dial(address)
+		if dialErr != nil {
+			return nil, nil, dialErr
+		}
+		defer conn.Close()
+
+		stream, streamErr := forward(client, outgoing(c), req)
+		err = streamErr
+		for err == nil {
+			var chunk *RecoveryChunk
+			if chunk, err = stream.Recv(); err == nil {
+				err = save(chunk)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	}
+	if file != nil {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if backup == nil || len(files) == 0 {
+		return nil, nil, errors.New("recovery stream ended without a backup")
+	}
+	return backup, files, nil
+}
+
+// recoveryStream hands the chunks of a local export straight to restore.
+type recoveryStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	save func(*RecoveryChunk) error
+}
+
+func (s *recoveryStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *recoveryStream) Send(chunk *RecoveryChunk) error {
+	return s.save(chunk)
+}
+
+// restoreBackup restores a backup of the database in the path, rolled
+// forward to until when given, as a new database on the node owning it.
+func restoreBackup(ctx *gin.Context) {
+	body := struct {
+		BackupID string `json:"backup_id"`
+		Until    string `json:"until"`
+		Target   string `json:"target"`
+	}{}
+	if err := ctx.BindJSON(&body); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	req := &RequestRestore{
+		Name:     body.Target,
+		Source:   ctx.Param("name"),
+		BackupId: body.BackupID,
+	}
+	if body.Until != "" {
+		until, err := time.Parse(time.RFC3339Nano, body.Until)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "until must be an RFC 3339 time",
+			})
+			return
+		}
+		req.Until = until.UnixNano()
+	}
+	if req.GetName() == "" {
+		req.Name = req.GetSource()
+	}
+	if err := database.ValidateName(req.GetName()); err != nil {
+		abort(ctx, err)
+		return
+	}
+
+	var err error
+	address := consist.Consist.LocateKey([]byte(req.GetName())).String()
+	if address == config.GRPCAddr {
+		_, err = local.Restore(ctx.Request.Context(), req)
+	} else {
+		client, conn, dialErr := dial(address)
+		if dialErr != nil {
+			abort(ctx, dialErr)
+			return
+		}
+		defer conn.Close()
+		_, err = client.Restore(outgoing(ctx.Request.Context()), req)
+	}
+	if err != nil {
+		abort(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"name": req.GetName()})
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/trianglehasfoursides/bedroompop/database"
+)
+
+func columnsMessage(columns []database.Column) []*Column {
+	out := make([]*Column, len(columns))
+	for i, c := range columns {
+		out[i] = &Column{Name: c.Name, DeclType: c.Type}
+	}
+	return out
+}
+
+func columnsOf(columns []*Column) []database.Column {
+	out := make([]database.Column, len(columns))
+	for i, c := range columns {
+		out[i] = database.Column{Name: c.GetName(), Type: c.GetDeclType()}
+	}
+	return out
+}
+
+func valueMessage(v any) *Value {
+	switch v := v.(type) {
+	case int64:
+		return &Value{Kind: &Value_Integer{Integer: v}}
+	case float64:
+		return &Value{Kind: &Value_Real{Real: v}}
+	case string:
+		return &Value{Kind: &Value_Text{Text: v}}
+	case []byte:
+		return &Value{Kind: &Value_Blob{Blob: v}}
+	}
+	return &Value{Kind: &Value_Null{Null: true}}
+}
+
+func valueOf(v *Value) any {
+	switch k := v.GetKind().(type) {
+	case *Value_Integer:
+		return k.Integer
+	case *Value_Real:
+		return k.Real
+	case *Value_Text:
+		return k.Text
+	case *Value_Blob:
+		return k.Blob
+	}
+	return nil
+}
+
+// resultSet encodes typed rows. withColumns is false for the batches after
+// the first one of a stream.
+func resultSet(columns []database.Column, rows [][]any, withColumns bool) *ResultSet {
+	set := &ResultSet{Rows: make([]*Row, len(rows))}
+	if withColumns {
+		set.Columns = columnsMessage(columns)
+	}
+	for i, row := range rows {
+		set.Rows[i] = rowMessage(row)
+	}
+	return set
+}
+
+func rowsOf(set *ResultSet) [][]any {
+	rows := make([][]any, len(set.GetRows()))
+	for i, row := range set.GetRows() {
+		rows[i] = rowOf(row)
+	}
+	return rows
+}
+
+func rowMessage(row []any) *Row {
+	values := make([]*Value, len(row))
+	for i, v := range row {
+		values[i] = valueMessage(v)
+	}
+	return &Row{Values: values}
+}
+
+func rowOf(row *Row) []any {
+	values := make([]any, len(row.GetValues()))
+	for i, v := range row.GetValues() {
+		values[i] = valueOf(v)
+	}
+	return values
+}
+
+// columnarBatch transposes typed rows into one vector per column.
+func columnarBatch(columns []database.Column, rows [][]any, withColumns bool) *ColumnarBatch {
+	batch := &ColumnarBatch{
+		Length:  int64(len(rows)),
+		Vectors: make([]*ColumnVector, len(columns)),
+	}
+	if withColumns {
+		batch.Columns = columnsMessage(columns)
+	}
+	for i := range columns {
+		vector := &ColumnVector{Kinds: make([]byte, len(rows))}
+		for r, row := range rows {
+			switch v := row[i].(type) {
+			case int64:
+				vector.Kinds[r] = byte(Kind_KIND_INTEGER)
+				vector.Integers = append(vector.Integers, v)
+			case float64:
+				vector.Kinds[r] = byte(Kind_KIND_REAL)
+				vector.Reals = append(vector.Reals, v)
+			case string:
+				vector.Kinds[r] = byte(Kind_KIND_TEXT)
+				vector.Texts = append(vector.Texts, v)
+			case []byte:
+				vector.Kinds[r] = byte(Kind_KIND_BLOB)
+				vector.Blobs = append(vector.Blobs, v)
+			default:
+				vector.Kinds[r] = byte(Kind_KIND_NULL)
+			}
+		}
+		batch.Vectors[i] = vector
+	}
+	return batch
+}
+
+// objectRow encodes a row as a JSON object in the original form of the API,
+// with blobs as strings, but keeping the columns in order.
+func objectRow(columns []database.Column, row []any) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, c := range columns {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(c.Name)
+		if err != nil {
+			return nil, err
+		}
+		v := row[i]
+		if b, ok := v.([]byte); ok {
+			v = string(b)
+		}
+		value, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func objectRows(columns []database.Column, rows [][]any) ([][]byte, error) {
+	encoded := make([][]byte, 0, len(rows))
+	for _, row := range rows {
+		data, err := objectRow(columns, row)
+		if err != nil {
+			return nil, err
+		}
+		encoded = append(encoded, data)
+	}
+	return encoded, nil
+}
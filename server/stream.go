@@ -0,0 +1,182 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trianglehasfoursides/bedroompop/database"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+func (s *server) QueryStream(req *RequestQueryExec, stream grpc.ServerStreamingServer[ResponseQueryBatch]) error {
+	c, cancel := requestTimeout(stream.Context(), req)
+	defer cancel()
+	c, done, err := withAttached(c, req)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if media, ok := encodingMedia[req.GetEncoding()]; ok {
+		return encodedStream(c, req, media, stream)
+	}
+
+	first := true
+	return database.QueryStream(c, req.GetName(), req.GetQuery(), int(req.GetBatchSize()), func(columns []database.Column, rows [][]any) error {
+		batch := &ResponseQueryBatch{}
+		switch req.GetEncoding() {
+		case Encoding_ENCODING_ROWS:
+			batch.Result = resultSet(columns, rows, first)
+		case Encoding_ENCODING_COLUMNAR:
+			batch.Columnar = columnarBatch(columns, rows, first)
+		default:
+			encoded, err := objectRows(columns, rows)
+			if err != nil {
+				return err
+			}
+			batch.Rows = encoded
+			if first {
+				for _, c := range columns {
+					batch.Columns = append(batch.Columns, c.Name)
+				}
+			}
+		}
+		first = false
+		return stream.Send(batch)
+	})
+}
+
+// encodedStream sends the result encoded as media, in chunks that concatenate into one document.
+func encodedStream(c context.Context, req *RequestQueryExec, media string, stream grpc.ServerStreamingServer[ResponseQueryBatch]) error {
+	var buf bytes.Buffer
+	enc, err := newEncoder(media, formatObjects, &buf)
+	if err != nil {
+		return err
+	}
+	flush := func() error {
+		if buf.Len() == 0 {
+			return nil
+		}
+		err := stream.Send(&ResponseQueryBatch{Data: bytes.Clone(buf.Bytes())})
+		buf.Reset()
+		return err
+	}
+
+	first := true
+	err = database.QueryStream(c, req.GetName(), req.GetQuery(), int(req.GetBatchSize()), func(columns []database.Column, rows [][]any) error {
+		if first {
+			if err := enc.begin(columns); err != nil {
+				return err
+			}
+			first = false
+		}
+		if err := enc.write(rows); err != nil {
+			return err
+		}
+		return flush()
+	})
+	if err != nil {
+		return err
+	}
+	if err := enc.end(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+// encode encodes a whole result as media.
+func encode(media string, format string, columns []database.Column, rows [][]any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc, err := newEncoder(media, format, &buf)
+	if err != nil {
+		return nil, err
+	}
+	if err := enc.begin(columns); err != nil {
+		return nil, err
+	}
+	if err := enc.write(rows); err != nil {
+		return nil, err
+	}
+	if err := enc.end(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// errorTrailer reports an error raised after the response started streaming.
+const errorTrailer = "X-Query-Error"
+
+// rowWriter streams query results to the HTTP client in the media type
+// negotiated through the Accept header. Nothing is written before the first
+// batch, so errors raised by the query itself still get a proper status.
+type rowWriter struct {
+	ctx     *gin.Context
+	media   string
+	enc     encoder
+	started bool
+}
+
+func newRowWriter(ctx *gin.Context) (*rowWriter, int, error) {
+	w := &rowWriter{ctx: ctx, media: mediaJSON}
+	if ctx.GetHeader("Accept") != "" {
+		w.media = ctx.NegotiateFormat(mediaTypes...)
+		if w.media == "" {
+			return nil, http.StatusNotAcceptable, errNotAcceptable
+		}
+	}
+
+	enc, err := newEncoder(w.media, ctx.DefaultQuery("format", formatObjects), ctx.Writer)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	w.enc = enc
+	return w, 0, nil
+}
+
+// write sends a batch of rows and flushes it to the client.
+func (w *rowWriter) write(columns []database.Column, rows [][]any) error {
+	if !w.started {
+		w.started = true
+		w.ctx.Header("Content-Type", w.media)
+		w.ctx.Header("Trailer", errorTrailer)
+		w.ctx.Status(http.StatusOK)
+		if err := w.enc.begin(columns); err != nil {
+			return err
+		}
+	}
+	if err := w.enc.write(rows); err != nil {
+		return err
+	}
+	w.ctx.Writer.Flush()
+	return nil
+}
+
+// finish ends the response. Once rows were sent the status can't change
+// anymore, so the error goes into the X-Query-Error trailer and the document
+// is left unterminated; NDJSON readers also get a last line with the error.
+func (w *rowWriter) finish(err error) {
+	if err == nil {
+		err = w.enc.end()
+		if err == nil {
+			return
+		}
+	}
+	if !w.started {
+		abort(w.ctx, err)
+		return
+	}
+
+	st := status.Convert(grpcError(err))
+	w.ctx.Writer.Header().Set(errorTrailer, st.Code().String()+": "+st.Message())
+	if w.media == mediaNDJSON {
+		line, _ := json.Marshal(gin.H{
+			"error": st.Message(),
+			"code":  st.Code().String(),
+		})
+		w.ctx.Writer.Write(append(line, '\n'))
+	}
+}
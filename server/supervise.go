@@ -0,0 +1,62 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/trianglehasfoursides/bedroompop/config"
+	"github.com/trianglehasfoursides/bedroompop/consist"
+	"github.com/trianglehasfoursides/bedroompop/database"
+)
+
+// supervise keeps a goroutine running for every task of the databases this
+// node owns, as listed by tasks, with an admin principal. Tasks are looked
+// for once per interval, or when woken up, so those added start, those gone
+// stop, and so do those of databases handed over to another node.
+func supervise(interval time.Duration, wake <-chan struct{}, what string,
+	tasks func(ctx context.Context, databaseName string) (map[string]func(context.Context), error),
+) {
+	ctx := database.WithPrincipal(context.Background(), database.Principal{Name: config.Name, Role: database.AdminRole})
+	running := make(map[string]context.CancelFunc)
+
+	for {
+		if names, err := database.List(); err != nil {
+			log.Error("can't list databases", "for", what, "err", err)
+		} else {
+			wanted := make(map[string]func(context.Context))
+			for _, name := range names {
+				if consist.Consist.LocateKey([]byte(name)).String() != config.GRPCAddr {
+					continue
+				}
+				found, err := tasks(ctx, name)
+				if err != nil && !errors.Is(err, database.ErrNotFound) {
+					log.Error("can't read "+what, "database", name, "err", err)
+				}
+				for key, run := range found {
+					wanted[name+"/"+key] = run
+				}
+			}
+
+			for key, cancel := range running {
+				if _, ok := wanted[key]; !ok {
+					cancel()
+					delete(running, key)
+				}
+			}
+			for key, run := range wanted {
+				if _, ok := running[key]; !ok {
+					c, cancel := context.WithCancel(ctx)
+					running[key] = cancel
+					go run(c)
+				}
+			}
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-wake:
+		}
+	}
+}
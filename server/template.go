@@ -0,0 +1,344 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/gin-gonic/gin"
+	"github.com/trianglehasfoursides/bedroompop/audit"
+	"github.com/trianglehasfoursides/bedroompop/config"
+	"github.com/trianglehasfoursides/bedroompop/consist"
+	"github.com/trianglehasfoursides/bedroompop/database"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func templateMessage(template database.Template) *TemplateInfo {
+	return &TemplateInfo{
+		Name:    template.Name,
+		Version: template.Version,
+		Source:  template.Source,
+		Created: template.Created.UnixNano(),
+		Size:    template.Size,
+		Sha256:  template.SHA256,
+	}
+}
+
+func templateOf(info *TemplateInfo) database.Template {
+	return database.Template{
+		Name:    info.GetName(),
+		Version: info.GetVersion(),
+		Source:  info.GetSource(),
+		Created: time.Unix(0, info.GetCreated()).UTC(),
+		Size:    info.GetSize(),
+		SHA256:  info.GetSha256(),
+	}
+}
+
+func (s *server) PutTemplate(stream grpc.ClientStreamingServer[TemplateChunk, DDLResponse]) error {
+	if database.PrincipalFrom(stream.Context()).Role != database.AdminRole {
+		return status.Error(codes.PermissionDenied, "only admins can publish templates")
+	}
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	r := &chunkReader{data: first.GetData(), next: func() ([]byte, error) {
+		chunk, err := stream.Recv()
+		return chunk.GetData(), err
+	}}
+	err = database.PutTemplate(templateOf(first.GetTemplate()), r)
+	record(stream.Context(), audit.OpPublish, first.GetTemplate().GetName(), "", err)
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(&DDLResponse{Msg: "sucess"})
+}
+
+func (s *server) ExportTemplate(req *RequestTemplate, stream grpc.ServerStreamingServer[TemplateChunk]) error {
+	template, err := database.FindTemplate(req.GetName(), req.GetVersion())
+	if err != nil {
+		return err
+	}
+	file, err := database.OpenTemplate(template)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info := templateMessage(template)
+	w := bufio.NewWriterSize(chunkWriter(func(data []byte) error {
+		chunk := &TemplateChunk{Template: info, Data: data}
+		info = nil
+		return stream.Send(chunk)
+	}), dataChunkSize)
+	if _, err := io.Copy(w, file); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func (s *server) DeleteTemplate(c context.Context, req *RequestTemplate) (*DDLResponse, error) {
+	err := database.DeleteTemplate(c, req.GetName(), req.GetVersion())
+	record(c, audit.OpUnpublish, req.GetName(), "", err)
+	if err != nil {
+		return nil, err
+	}
+	return &DDLResponse{Msg: "sucess"}, nil
+}
+
+func (s *server) ListTemplates(c context.Context, req *RequestTemplate) (*ResponseTemplates, error) {
+	templates, err := database.Templates()
+	if err != nil {
+		return nil, err
+	}
+	resp := &ResponseTemplates{}
+	for _, template := range templates {
+		if req.GetName() == "" || template.Name == req.GetName() {
+			resp.Templates = append(resp.Templates, templateMessage(template))
+		}
+	}
+	return resp, nil
+}
+
+// createFromTemplate creates a database from a template, fetching the
+// template from the other nodes first if this one doesn't have it, as when
+// it joined after the template was published.
+func createFromTemplate(c context.Context, req *RequestCreate) error {
+	name, version, err := database.ParseTemplate(req.GetTemplate())
+	if err != nil {
+		return err
+	}
+	template, err := database.FindTemplate(name, version)
+	if errors.Is(err, database.ErrTemplateNotFound) {
+		template, err = fetchTemplate(c, &RequestTemplate{Name: name, Version: version})
+	}
+	if err != nil {
+		return err
+	}
+	return database.CreateFromTemplate(c, req.GetName(), template, req.GetMigration())
+}
+
+// fetchTemplate copies a template from the first other node that has it.
+// Nodes that fail are skipped, their error is only returned when no node
+// has the template.
+func fetchTemplate(c context.Context, req *RequestTemplate) (database.Template, error) {
+	var failed error
+	for _, member := range consist.Consist.GetMembers() {
+		address := member.String()
+		if address == config.GRPCAddr {
+			continue
+		}
+		template, err := fetchTemplateFrom(c, address, req)
+		if err == nil {
+			return template, nil
+		}
+		if status.Code(err) != codes.NotFound {
+			log.Warn("can't fetch template", "node", address, "err", err)
+			failed = err
+		}
+	}
+	if failed != nil {
+		return database.Template{}, failed
+	}
+	return database.Template{}, database.ErrTemplateNotFound
+}
+
+func fetchTemplateFrom(c context.Context, address string, req *RequestTemplate) (database.Template, error) {
+	client, conn, err := dial(address)
+	if err != nil {
+		return database.Template{}, err
+	}
+	defer conn.Close()
+
+	stream, err := client.ExportTemplate(outgoing(c), req)
+	if err != nil {
+		return database.Template{}, err
+	}
+	first, err := stream.Recv()
+	if err != nil {
+		return database.Template{}, err
+	}
+	template := templateOf(first.GetTemplate())
+	r := &chunkReader{data: first.GetData(), next: func() ([]byte, error) {
+		chunk, err := stream.Recv()
+		return chunk.GetData(), err
+	}}
+	if err := database.PutTemplate(template, r); err != nil {
+		return database.Template{}, err
+	}
+	return template, nil
+}
+
+// templateStream feeds a local publish straight from the built image.
+type templateStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	next func() (*TemplateChunk, error)
+}
+
+func (s *templateStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *templateStream) Recv() (*TemplateChunk, error) {
+	return s.next()
+}
+
+func (s *templateStream) SendAndClose(*DDLResponse) error {
+	return nil
+}
+
+// templateChunks reads the image of a template as chunks, the first one
+// describing it.
+func templateChunks(template database.Template, r io.Reader) func() (*TemplateChunk, error) {
+	info := templateMessage(template)
+	buf := make([]byte, dataChunkSize)
+	return func() (*TemplateChunk, error) {
+		n, err := io.ReadFull(r, buf)
+		if n == 0 {
+			return nil, err
+		}
+		chunk := &TemplateChunk{Template: info, Data: buf[:n]}
+		info = nil
+		return chunk, nil
+	}
+}
+
+// pushTemplate sends the image of a template at path to the node at address.
+func pushTemplate(c context.Context, address string, template database.Template, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	next := templateChunks(template, file)
+
+	if address == config.GRPCAddr {
+		return local.PutTemplate(&templateStream{ctx: c, next: next})
+	}
+
+	client, conn, err := dial(address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	stream, err := client.PutTemplate(outgoing(c))
+	if err != nil {
+		return err
+	}
+	for {
+		chunk, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			stream.CloseSend()
+			return err
+		}
+		if err := stream.Send(chunk); err != nil {
+			// the node stopped reading, its error is in CloseAndRecv
+			break
+		}
+	}
+	_, err = stream.CloseAndRecv()
+	return err
+}
+
+// publishTemplate builds a template version from the request body, either a
+// SQLite file or a migration, and copies it to every node.
+func publishTemplate(ctx *gin.Context) {
+	dir, err := os.MkdirTemp(config.DataDir, ".template-")
+	if err != nil {
+		abort(ctx, err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "image")
+	template, err := database.BuildTemplate(ctx.Request.Context(), ctx.Param("name"), ctx.Param("version"), ctx.Request.Body, path)
+	if err != nil {
+		abort(ctx, err)
+		return
+	}
+
+	nodes := make(map[string]string)
+	code := http.StatusCreated
+	for _, member := range consist.Consist.GetMembers() {
+		address := member.String()
+		nodes[address] = "ok"
+		if err := pushTemplate(ctx.Request.Context(), address, template, path); err != nil {
+			nodes[address] = status.Convert(grpcError(err)).Message()
+			code = http.StatusBadGateway
+		}
+	}
+	ctx.JSON(code, gin.H{"template": template, "nodes": nodes})
+}
+
+// deleteTemplate removes a template version from every node.
+func deleteTemplate(ctx *gin.Context) {
+	if database.PrincipalFrom(ctx.Request.Context()).Role != database.AdminRole {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "only admins can delete templates",
+		})
+		return
+	}
+	req := &RequestTemplate{Name: ctx.Param("name"), Version: ctx.Param("version")}
+
+	found := false
+	nodes := make(map[string]string)
+	code := http.StatusOK
+	for _, member := range consist.Consist.GetMembers() {
+		address := member.String()
+
+		var err error
+		if address == config.GRPCAddr {
+			_, err = local.DeleteTemplate(ctx.Request.Context(), req)
+		} else {
+			client, conn, dialErr := dial(address)
+			if dialErr != nil {
+				err = dialErr
+			} else {
+				_, err = client.DeleteTemplate(outgoing(ctx.Request.Context()), req)
+				conn.Close()
+			}
+		}
+		nodes[address] = "ok"
+		if st := status.Convert(grpcError(err)); st.Code() == codes.NotFound {
+			nodes[address] = "not found"
+		} else if err != nil {
+			nodes[address] = st.Message()
+			code = http.StatusBadGateway
+		} else {
+			found = true
+		}
+	}
+	if !found && code == http.StatusOK {
+		abort(ctx, database.ErrTemplateNotFound)
+		return
+	}
+	ctx.JSON(code, gin.H{"nodes": nodes})
+}
+
+// listTemplates lists the template versions, as kept by this node.
+func listTemplates(ctx *gin.Context) {
+	resp, err := local.ListTemplates(ctx.Request.Context(), &RequestTemplate{})
+	if err != nil {
+		abort(ctx, err)
+		return
+	}
+	list := []database.Template{}
+	for _, info := range resp.GetTemplates() {
+		list = append(list, templateOf(info))
+	}
+	ctx.JSON(http.StatusOK, gin.H{"templates": list})
+}
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trianglehasfoursides/bedroompop/audit"
+	"github.com/trianglehasfoursides/bedroompop/config"
+	"github.com/trianglehasfoursides/bedroompop/consist"
+	"github.com/trianglehasfoursides/bedroompop/database"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func trashMessage(entries []database.TrashEntry) *ResponseTrash {
+	resp := &ResponseTrash{}
+	for _, entry := range entries {
+		resp.Entries = append(resp.Entries, &TrashEntry{
+			Name:    entry.Name,
+			Id:      entry.ID,
+			Dropped: entry.Dropped.UnixNano(),
+			Expires: entry.Expires.UnixNano(),
+			Size:    entry.Size,
+		})
+	}
+	return resp
+}
+
+func trashOf(resp *ResponseTrash) []database.TrashEntry {
+	entries := []database.TrashEntry{}
+	for _, entry := range resp.GetEntries() {
+		entries = append(entries, database.TrashEntry{
+			Name:    entry.GetName(),
+			ID:      entry.GetId(),
+			Dropped: time.Unix(0, entry.GetDropped()).UTC(),
+			Expires: time.Unix(0, entry.GetExpires()).UTC(),
+			Size:    entry.GetSize(),
+		})
+	}
+	return entries
+}
+
+func (s *server) ListTrash(c context.Context, req *RequestTrash) (*ResponseTrash, error) {
+	if database.PrincipalFrom(c).Role != database.AdminRole {
+		return nil, status.Error(codes.PermissionDenied, "the trash is only available to admins")
+	}
+	entries, err := database.Trash()
+	if err != nil {
+		return nil, err
+	}
+	var found []database.TrashEntry
+	for _, entry := range entries {
+		if req.GetName() == "" || entry.Name == req.GetName() {
+			found = append(found, entry)
+		}
+	}
+	return trashMessage(found), nil
+}
+
+func (s *server) RestoreTrash(c context.Context, req *RequestTrash) (*ResponseTrash, error) {
+	entry, err := database.Undrop(c, req.GetName(), req.GetId())
+	record(c, audit.OpUndrop, req.GetName(), entry.ID, err)
+	if err != nil {
+		return nil, err
+	}
+	return trashMessage([]database.TrashEntry{entry}), nil
+}
+
+func (s *server) PurgeTrash(c context.Context, req *RequestTrash) (*ResponseTrash, error) {
+	if !req.GetConfirm() {
+		return nil, status.Error(codes.InvalidArgument, "purging deletes dropped databases for good and has to be confirmed")
+	}
+	entries, err := database.PurgeTrash(c, req.GetName(), req.GetId())
+	record(c, audit.OpPurge, req.GetName(), req.GetId(), err)
+	if err != nil {
+		return nil, err
+	}
+	return trashMessage(entries), nil
+}
+
+// listTrash lists the dropped databases that can still be restored, from
+// every node.
+func listTrash(ctx *gin.Context) {
+	if database.PrincipalFrom(ctx.Request.Context()).Role != database.AdminRole {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "the trash is only available to admins",
+		})
+		return
+	}
+
+	req := &RequestTrash{Name: ctx.Query("name")}
+	entries := []database.TrashEntry{}
+	nodes := make(map[string]string)
+	for _, member := range consist.Consist.GetMembers() {
+		address := member.String()
+
+		var resp *ResponseTrash
+		var err error
+		if address == config.GRPCAddr {
+			resp, err = local.ListTrash(ctx.Request.Context(), req)
+		} else {
+			client, conn, dialErr := dial(address)
+			if dialErr != nil {
+				nodes[address] = dialErr.Error()
+				continue
+			}
+			resp, err = client.ListTrash(outgoing(ctx.Request.Context()), req)
+			conn.Close()
+		}
+		if err != nil {
+			nodes[address] = status.Convert(grpcError(err)).Message()
+			continue
+		}
+		nodes[address] = "ok"
+		entries = append(entries, trashOf(resp)...)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Name != entries[j].Name {
+			return entries[i].Name < entries[j].Name
+		}
+		return entries[i].Dropped.Before(entries[j].Dropped)
+	})
+	ctx.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"nodes":   nodes,
+	})
+}
+
+// restoreTrash moves a dropped database back in place, the one dropped last
+// unless the body names the id of another.
+func restoreTrash(ctx *gin.Context) {
+	body := struct {
+		ID string `json:"id"`
+	}{}
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.BindJSON(&body); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+
+	req := &RequestTrash{Name: ctx.Param("name"), Id: body.ID}
+	resp, err := ownerCall(ctx.Request.Context(), req, local.RestoreTrash, PopServiceClient.RestoreTrash)
+	if err != nil {
+		abort(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"restored": trashOf(resp)[0]})
+}
+
+// purgeTrash deletes the dropped copies of a database for good, or only the
+// one with the id query parameter. It needs confirm=true.
+func purgeTrash(ctx *gin.Context) {
+	confirm, _ := strconv.ParseBool(ctx.Query("confirm"))
+	if !confirm {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "purging deletes dropped databases for good, set confirm=true",
+		})
+		return
+	}
+
+	req := &RequestTrash{Name: ctx.Param("name"), Id: ctx.Query("id"), Confirm: true}
+	resp, err := ownerCall(ctx.Request.Context(), req, local.PurgeTrash, PopServiceClient.PurgeTrash)
+	if err != nil {
+		abort(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"purged": trashOf(resp)})
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/gin-gonic/gin"
+	"github.com/trianglehasfoursides/bedroompop/config"
+	"github.com/trianglehasfoursides/bedroompop/consist"
+	"github.com/trianglehasfoursides/bedroompop/database"
+	"github.com/trianglehasfoursides/bedroompop/metering"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *server) Usage(c context.Context, req *RequestUsage) (*ResponseUsage, error) {
+	if database.PrincipalFrom(c).Role != database.AdminRole {
+		return nil, status.Error(codes.PermissionDenied, "usage is only available to admins")
+	}
+
+	filter := metering.Filter{Database: req.GetDatabase()}
+	if req.GetFrom() != 0 {
+		filter.From = time.Unix(0, req.GetFrom())
+	}
+	if req.GetTo() != 0 {
+		filter.To = time.Unix(0, req.GetTo())
+	}
+
+	resp := &ResponseUsage{}
+	for _, r := range metering.Query(filter) {
+		resp.Records = append(resp.Records, &UsageRecord{
+			Database:     r.Database,
+			Window:       r.Window.UnixNano(),
+			StorageBytes: r.StorageBytes,
+			RowsRead:     r.RowsRead,
+			RowsWritten:  r.RowsWritten,
+			Queries:      r.Queries,
+			VmSteps:      r.VMSteps,
+		})
+	}
+	return resp, nil
+}
+
+// usage rolls the usage of every node in the cluster up per database and
+// window. Databases move between nodes as members come and go, so the
+// totals are summed and the largest storage is kept.
+func usage(ctx *gin.Context) {
+	if database.PrincipalFrom(ctx.Request.Context()).Role != database.AdminRole {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "usage is only available to admins",
+		})
+		return
+	}
+
+	req := &RequestUsage{Database: ctx.Query("database")}
+	for param, bound := range map[string]*int64{"from": &req.From, "to": &req.To} {
+		if v := ctx.Query(param); v != "" {
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+					"error": param + " must be an RFC 3339 time",
+				})
+				return
+			}
+			*bound = t.UnixNano()
+		}
+	}
+
+	type window struct {
+		database string
+		start    int64
+	}
+	merged := make(map[window]*metering.Record)
+	nodes := make(map[string]string)
+	for _, member := range consist.Consist.GetMembers() {
+		address := member.String()
+
+		var resp *ResponseUsage
+		var err error
+		if address == config.GRPCAddr {
+			resp, err = local.Usage(ctx.Request.Context(), req)
+		} else {
+			client, conn, dialErr := dial(address)
+			if dialErr != nil {
+				nodes[address] = dialErr.Error()
+				continue
+			}
+			resp, err = client.Usage(outgoing(ctx.Request.Context()), req)
+			conn.Close()
+		}
+		if err != nil {
+			nodes[address] = status.Convert(err).Message()
+			continue
+		}
+
+		nodes[address] = "ok"
+		for _, r := range resp.GetRecords() {
+			k := window{r.GetDatabase(), r.GetWindow()}
+			record, ok := merged[k]
+			if !ok {
+				record = &metering.Record{Database: r.GetDatabase(), Window: time.Unix(0, r.GetWindow()).UTC()}
+				merged[k] = record
+			}
+			record.StorageBytes = max(record.StorageBytes, r.GetStorageBytes())
+			record.RowsRead += r.GetRowsRead()
+			record.RowsWritten += r.GetRowsWritten()
+			record.Queries += r.GetQueries()
+			record.VMSteps += r.GetVmSteps()
+		}
+	}
+
+	records := make([]metering.Record, 0, len(merged))
+	for _, record := range merged {
+		records = append(records, *record)
+	}
+	metering.Sort(records)
+	ctx.JSON(http.StatusOK, gin.H{
+		"records": records,
+		"nodes":   nodes,
+	})
+}
+
+// CollectUsage collects what the other nodes counted in the current window
+// once per interval, so quotas are checked against the usage of the whole
+// cluster, as /usage reports it. A node that can't be reached keeps what it
+// last reported.
+func CollectUsage(interval time.Duration) {
+	node := database.WithPrincipal(context.Background(), database.Principal{Name: config.Name, Role: database.AdminRole})
+	collected := make(map[string][]metering.Record)
+	for range time.Tick(interval) {
+		req := &RequestUsage{From: time.Now().UnixNano()}
+		members := make(map[string]bool)
+		for _, member := range consist.Consist.GetMembers() {
+			address := member.String()
+			if address == config.GRPCAddr {
+				continue
+			}
+			members[address] = true
+			records, err := nodeUsage(node, address, req, interval)
+			if err != nil {
+				log.Warn("can't collect usage", "node", address, "err", status.Convert(err).Message())
+				continue
+			}
+			collected[address] = records
+		}
+
+		var records []metering.Record
+		for address := range collected {
+			if !members[address] {
+				delete(collected, address)
+				continue
+			}
+			records = append(records, collected[address]...)
+		}
+		metering.SetRemote(records)
+	}
+}
+
+// nodeUsage asks a node for its usage.
+func nodeUsage(ctx context.Context, address string, req *RequestUsage, timeout time.Duration) ([]metering.Record, error) {
+	client, conn, err := dial(address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	resp, err := client.Usage(outgoing(ctx), req)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]metering.Record, 0, len(resp.GetRecords()))
+	for _, r := range resp.GetRecords() {
+		records = append(records, metering.Record{
+			Database: r.GetDatabase(),
+			Window:   time.Unix(0, r.GetWindow()).UTC(),
+			Usage: metering.Usage{
+				StorageBytes: r.GetStorageBytes(),
+				RowsRead:     r.GetRowsRead(),
+				RowsWritten:  r.GetRowsWritten(),
+				Queries:      r.GetQueries(),
+				VMSteps:      r.GetVmSteps(),
+			},
+		})
+	}
+	return records, nil
+}
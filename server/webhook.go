@@ -0,0 +1,633 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/gin-gonic/gin"
+	"github.com/trianglehasfoursides/bedroompop/audit"
+	"github.com/trianglehasfoursides/bedroompop/config"
+	"github.com/trianglehasfoursides/bedroompop/database"
+)
+
+// Webhooks are delivered by the node owning their database, which follows
+// its changelog and posts the changes in batches, signed with the secret of
+// the webhook. A batch is retried with exponential backoff until it was
+// posted as many times as the configuration allows, and then kept as a dead
+// letter. How far a webhook got is kept in its database, so the next owner
+// carries on from there, or from the start of its own changelog when the
+// database was moved to it.
+
+// webhookLinger is how long a batch waits for more changes.
+const webhookLinger = 100 * time.Millisecond
+
+// webhookCheckpoint is how often a webhook skipping every change it is sent
+// records how far it got.
+const webhookCheckpoint = 10 * time.Second
+
+// maxWebhookBackoff is the longest wait between two attempts.
+const maxWebhookBackoff = 5 * time.Minute
+
+func webhookMessage(hook database.Webhook) *Webhook {
+	return &Webhook{
+		Id:        hook.ID,
+		Url:       hook.URL,
+		Table:     hook.Table,
+		Events:    hook.Events,
+		Secret:    hook.Secret,
+		BatchSize: int32(hook.BatchSize),
+		Created:   hook.Created.UnixNano(),
+		Log:       hook.Log,
+		Offset:    hook.Offset,
+	}
+}
+
+func webhookOf(msg *Webhook) database.Webhook {
+	hook := database.Webhook{
+		ID:        msg.GetId(),
+		URL:       msg.GetUrl(),
+		Table:     msg.GetTable(),
+		Events:    msg.GetEvents(),
+		Secret:    msg.GetSecret(),
+		BatchSize: int(msg.GetBatchSize()),
+		Log:       msg.GetLog(),
+		Offset:    msg.GetOffset(),
+	}
+	if msg.GetCreated() != 0 {
+		hook.Created = time.Unix(0, msg.GetCreated()).UTC()
+	}
+	return hook
+}
+
+func webhooksOf(resp *ResponseWebhooks) []database.Webhook {
+	hooks := []database.Webhook{}
+	for _, hook := range resp.GetWebhooks() {
+		hooks = append(hooks, webhookOf(hook))
+	}
+	return hooks
+}
+
+func deliveriesMessage(deliveries []database.Delivery, dead []database.DeadLetter) *ResponseDeliveries {
+	resp := &ResponseDeliveries{}
+	for _, d := range deliveries {
+		resp.Deliveries = append(resp.Deliveries, &Delivery{
+			Id:       d.ID,
+			Webhook:  d.Webhook,
+			Time:     d.Time.UnixNano(),
+			Attempt:  int32(d.Attempt),
+			Status:   int32(d.Status),
+			Error:    d.Error,
+			First:    d.First,
+			Last:     d.Last,
+			Events:   int32(d.Events),
+			Duration: int64(d.Duration),
+		})
+	}
+	for _, d := range dead {
+		resp.Dead = append(resp.Dead, &DeadLetter{
+			Id:       d.ID,
+			Webhook:  d.Webhook,
+			Created:  d.Created.UnixNano(),
+			First:    d.First,
+			Last:     d.Last,
+			Events:   int32(d.Events),
+			Attempts: int32(d.Attempts),
+			Error:    d.Error,
+			Payload:  d.Payload,
+		})
+	}
+	return resp
+}
+
+func deliveriesOf(resp *ResponseDeliveries) []database.Delivery {
+	deliveries := []database.Delivery{}
+	for _, d := range resp.GetDeliveries() {
+		deliveries = append(deliveries, database.Delivery{
+			ID:       d.GetId(),
+			Webhook:  d.GetWebhook(),
+			Time:     time.Unix(0, d.GetTime()).UTC(),
+			Attempt:  int(d.GetAttempt()),
+			Status:   int(d.GetStatus()),
+			Error:    d.GetError(),
+			First:    d.GetFirst(),
+			Last:     d.GetLast(),
+			Events:   int(d.GetEvents()),
+			Duration: time.Duration(d.GetDuration()),
+		})
+	}
+	return deliveries
+}
+
+// deadLetterObject shows a dead letter with the body it failed to post as
+// JSON rather than bytes.
+type deadLetterObject struct {
+	database.DeadLetter
+	Payload json.RawMessage `json:"payload"`
+}
+
+func deadLettersOf(resp *ResponseDeliveries) []deadLetterObject {
+	dead := []deadLetterObject{}
+	for _, d := range resp.GetDead() {
+		dead = append(dead, deadLetterObject{
+			DeadLetter: database.DeadLetter{
+				ID:       d.GetId(),
+				Webhook:  d.GetWebhook(),
+				Created:  time.Unix(0, d.GetCreated()).UTC(),
+				First:    d.GetFirst(),
+				Last:     d.GetLast(),
+				Events:   int(d.GetEvents()),
+				Attempts: int(d.GetAttempts()),
+				Error:    d.GetError(),
+			},
+			Payload: d.GetPayload(),
+		})
+	}
+	return dead
+}
+
+func (s *server) CreateWebhook(c context.Context, req *RequestWebhook) (*ResponseWebhooks, error) {
+	hook, err := database.CreateWebhook(c, req.GetName(), webhookOf(req.GetWebhook()))
+	record(c, audit.OpWebhook, req.GetName(), hook.ID+" "+hook.URL, err)
+	if err != nil {
+		return nil, err
+	}
+	webhooksChanged()
+	return &ResponseWebhooks{Webhooks: []*Webhook{webhookMessage(hook)}}, nil
+}
+
+func (s *server) ListWebhooks(c context.Context, req *RequestWebhook) (*ResponseWebhooks, error) {
+	hooks, err := database.Webhooks(c, req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	resp := &ResponseWebhooks{}
+	for _, hook := range hooks {
+		// the secret is only shown when the webhook is created
+		hook.Secret = ""
+		resp.Webhooks = append(resp.Webhooks, webhookMessage(hook))
+	}
+	return resp, nil
+}
+
+func (s *server) DeleteWebhook(c context.Context, req *RequestWebhook) (*ResponseWebhooks, error) {
+	err := database.DeleteWebhook(c, req.GetName(), req.GetId())
+	record(c, audit.OpUnhook, req.GetName(), req.GetId(), err)
+	if err != nil {
+		return nil, err
+	}
+	webhooksChanged()
+	return &ResponseWebhooks{Webhooks: []*Webhook{{Id: req.GetId()}}}, nil
+}
+
+func (s *server) ListDeliveries(c context.Context, req *RequestWebhook) (*ResponseDeliveries, error) {
+	deliveries, err := database.Deliveries(c, req.GetName(), req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	return deliveriesMessage(deliveries, nil), nil
+}
+
+func (s *server) ListDeadLetters(c context.Context, req *RequestWebhook) (*ResponseDeliveries, error) {
+	dead, err := database.DeadLetters(c, req.GetName(), req.GetId(), req.GetDead())
+	if err != nil {
+		return nil, err
+	}
+	return deliveriesMessage(nil, dead), nil
+}
+
+// RedeliverDeadLetters posts dead letters once more, removing those that go
+// through, and returns the deliveries with the dead letters left.
+func (s *server) RedeliverDeadLetters(c context.Context, req *RequestWebhook) (*ResponseDeliveries, error) {
+	resp, err := redeliver(c, req)
+	record(c, audit.OpRedeliver, req.GetName(), req.GetId(), err)
+	return resp, err
+}
+
+func redeliver(c context.Context, req *RequestWebhook) (*ResponseDeliveries, error) {
+	hook, err := database.FindWebhook(c, req.GetName(), req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	dead, err := database.DeadLetters(c, req.GetName(), req.GetId(), req.GetDead())
+	if err != nil {
+		return nil, err
+	}
+
+	var deliveries []database.Delivery
+	for _, letter := range dead {
+		delivery := post(c, hook, letter.Payload)
+		delivery.Attempt = letter.Attempts + 1
+		delivery.First, delivery.Last, delivery.Events = letter.First, letter.Last, letter.Events
+		delivery, err := database.LogDelivery(c, req.GetName(), delivery)
+		if err != nil {
+			return nil, err
+		}
+		var failure error
+		if delivery.Error != "" {
+			failure = errors.New(delivery.Error)
+		}
+		if err := database.ResolveDeadLetter(c, req.GetName(), hook.ID, letter.ID, failure); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	left, err := database.DeadLetters(c, req.GetName(), req.GetId(), req.GetDead())
+	if err != nil {
+		return nil, err
+	}
+	return deliveriesMessage(deliveries, left), nil
+}
+
+// DiscardDeadLetters removes dead letters without posting them, and returns
+// those it removed.
+func (s *server) DiscardDeadLetters(c context.Context, req *RequestWebhook) (*ResponseDeliveries, error) {
+	dead, err := database.DeadLetters(c, req.GetName(), req.GetId(), req.GetDead())
+	for i := 0; err == nil && i < len(dead); i++ {
+		err = database.ResolveDeadLetter(c, req.GetName(), req.GetId(), dead[i].ID, nil)
+	}
+	record(c, audit.OpDiscard, req.GetName(), req.GetId(), err)
+	if err != nil {
+		return nil, err
+	}
+	return deliveriesMessage(nil, dead), nil
+}
+
+// createWebhook registers a webhook from a JSON body holding its url, and
+// optionally the table and events it delivers, its batch size and secret.
+// The secret is only shown in the response.
+func createWebhook(ctx *gin.Context) {
+	hook := database.Webhook{}
+	if err := ctx.BindJSON(&hook); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	req := &RequestWebhook{Name: ctx.Param("name"), Webhook: webhookMessage(hook)}
+	resp, err := ownerCall(ctx.Request.Context(), req, local.CreateWebhook, PopServiceClient.CreateWebhook)
+	if err != nil {
+		abort(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"webhook": webhooksOf(resp)[0]})
+}
+
+func listWebhooks(ctx *gin.Context) {
+	req := &RequestWebhook{Name: ctx.Param("name")}
+	resp, err := ownerCall(ctx.Request.Context(), req, local.ListWebhooks, PopServiceClient.ListWebhooks)
+	if err != nil {
+		abort(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"webhooks": webhooksOf(resp)})
+}
+
+func deleteWebhook(ctx *gin.Context) {
+	req := &RequestWebhook{Name: ctx.Param("name"), Id: ctx.Param("id")}
+	if _, err := ownerCall(ctx.Request.Context(), req, local.DeleteWebhook, PopServiceClient.DeleteWebhook); err != nil {
+		abort(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"deleted": req.GetId()})
+}
+
+// listDeliveries returns the last deliveries of a webhook, most recent first.
+func listDeliveries(ctx *gin.Context) {
+	req := &RequestWebhook{Name: ctx.Param("name"), Id: ctx.Param("id")}
+	resp, err := ownerCall(ctx.Request.Context(), req, local.ListDeliveries, PopServiceClient.ListDeliveries)
+	if err != nil {
+		abort(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"deliveries": deliveriesOf(resp)})
+}
+
+// deadLetterRequest reads the dead letter a request is about from the dead
+// query parameter, every dead letter of the webhook when it is missing.
+func deadLetterRequest(ctx *gin.Context) (*RequestWebhook, bool) {
+	req := &RequestWebhook{Name: ctx.Param("name"), Id: ctx.Param("id")}
+	if dead := ctx.Query("dead"); dead != "" {
+		id, err := strconv.ParseInt(dead, 10, 64)
+		if err != nil || id <= 0 {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "dead must be the id of a dead letter",
+			})
+			return nil, false
+		}
+		req.Dead = id
+	}
+	return req, true
+}
+
+func listDeadLetters(ctx *gin.Context) {
+	req, ok := deadLetterRequest(ctx)
+	if !ok {
+		return
+	}
+	resp, err := ownerCall(ctx.Request.Context(), req, local.ListDeadLetters, PopServiceClient.ListDeadLetters)
+	if err != nil {
+		abort(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"dead": deadLettersOf(resp)})
+}
+
+func redeliverDeadLetters(ctx *gin.Context) {
+	req, ok := deadLetterRequest(ctx)
+	if !ok {
+		return
+	}
+	resp, err := ownerCall(ctx.Request.Context(), req, local.RedeliverDeadLetters, PopServiceClient.RedeliverDeadLetters)
+	if err != nil {
+		abort(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"deliveries": deliveriesOf(resp),
+		"dead":       deadLettersOf(resp),
+	})
+}
+
+func discardDeadLetters(ctx *gin.Context) {
+	req, ok := deadLetterRequest(ctx)
+	if !ok {
+		return
+	}
+	resp, err := ownerCall(ctx.Request.Context(), req, local.DiscardDeadLetters, PopServiceClient.DiscardDeadLetters)
+	if err != nil {
+		abort(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"discarded": deadLettersOf(resp)})
+}
+
+// webhookChanges wakes up DeliverWebhooks when webhooks were added or removed.
+var webhookChanges = make(chan struct{}, 1)
+
+func webhooksChanged() {
+	select {
+	case webhookChanges <- struct{}{}:
+	default:
+	}
+}
+
+// DeliverWebhooks keeps a delivery running for every webhook of the
+// databases this node owns, looking for webhooks added or removed and for
+// databases changing owners once per interval.
+func DeliverWebhooks(interval time.Duration) {
+	supervise(interval, webhookChanges, "webhooks", func(ctx context.Context, databaseName string) (map[string]func(context.Context), error) {
+		hooks, err := database.Webhooks(ctx, databaseName)
+		tasks := make(map[string]func(context.Context))
+		for _, hook := range hooks {
+			tasks[hook.ID] = func(c context.Context) { deliver(c, databaseName, hook) }
+		}
+		return tasks, err
+	})
+}
+
+// deliver posts the changes of a database to a webhook until ctx is done,
+// starting over when following the changelog fails.
+func deliver(ctx context.Context, databaseName string, hook database.Webhook) {
+	for {
+		err := follow(ctx, databaseName, &hook)
+		if ctx.Err() != nil || errors.Is(err, database.ErrWebhookNotFound) || errors.Is(err, database.ErrNotFound) {
+			return
+		}
+		log.Error("webhook delivery failed", "database", databaseName, "webhook", hook.ID, "err", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(10 * time.Second):
+		}
+	}
+}
+
+// follow subscribes to the changelog after the last change delivered to
+// the webhook and posts the changes it is sent in batches.
+func follow(ctx context.Context, databaseName string, hook *database.Webhook) error {
+	position, err := database.Changelog(ctx, databaseName)
+	if err != nil {
+		return err
+	}
+	if hook.Log != position.Log {
+		// The database was moved here and its changes here start with this
+		// changelog. Those its last owner logged after the offset stay there,
+		// so the gap is logged as a failed delivery before moving on.
+		lost := database.Delivery{
+			Webhook: hook.ID,
+			Time:    time.Now().UTC(),
+			Error:   fmt.Sprintf("the database moved to this node, changes after %d in changelog %s may not have been delivered", hook.Offset, hook.Log),
+			First:   hook.Offset + 1,
+		}
+		if _, err := database.LogDelivery(ctx, databaseName, lost); err != nil {
+			return err
+		}
+		hook.Log, hook.Offset = position.Log, 0
+		if err := database.AdvanceWebhook(ctx, databaseName, hook.ID, hook.Log, hook.Offset, nil); err != nil {
+			return err
+		}
+	}
+	if hook.Offset > 0 && position.Oldest > hook.Offset+1 {
+		lost := database.Delivery{
+			Webhook: hook.ID,
+			Time:    time.Now().UTC(),
+			Error:   fmt.Sprintf("changes after %d expired before they were delivered", hook.Offset),
+			First:   hook.Offset + 1,
+			Last:    position.Oldest - 1,
+		}
+		if _, err := database.LogDelivery(ctx, databaseName, lost); err != nil {
+			return err
+		}
+		hook.Offset = position.Oldest - 1
+	}
+
+	c, cancel := context.WithCancel(ctx)
+	defer cancel()
+	changes := make(chan database.ChangeEvent)
+	failed := make(chan error, 1)
+	go func() {
+		failed <- database.Subscribe(c, databaseName, int64(hook.Offset),
+			func(database.Position) error { return nil },
+			func(change database.ChangeEvent) error {
+				select {
+				case changes <- change:
+					return nil
+				case <-c.Done():
+					return c.Err()
+				}
+			})
+	}()
+
+	saved := time.Now()
+	for {
+		var batch []database.ChangeEvent
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-failed:
+			return err
+		case change := <-changes:
+			batch = append(batch, change)
+		}
+		linger := time.NewTimer(webhookLinger)
+	collect:
+		for len(batch) < hook.BatchSize {
+			select {
+			case change := <-changes:
+				batch = append(batch, change)
+			case <-linger.C:
+				break collect
+			}
+		}
+		linger.Stop()
+
+		var matched []database.ChangeEvent
+		for _, change := range batch {
+			if hook.Matches(change) {
+				matched = append(matched, change)
+			}
+		}
+		last := batch[len(batch)-1].Offset
+		if len(matched) == 0 {
+			hook.Offset = last
+			if time.Since(saved) < webhookCheckpoint {
+				continue
+			}
+			if err := database.AdvanceWebhook(ctx, databaseName, hook.ID, hook.Log, last, nil); err != nil {
+				return err
+			}
+			saved = time.Now()
+			continue
+		}
+
+		dead, err := deliverBatch(ctx, databaseName, *hook, matched)
+		if err != nil || ctx.Err() != nil {
+			return err
+		}
+		if err := database.AdvanceWebhook(ctx, databaseName, hook.ID, hook.Log, last, dead); err != nil {
+			return err
+		}
+		hook.Offset, saved = last, time.Now()
+	}
+}
+
+// deliverBatch posts changes to a webhook until it takes them, backing off
+// between attempts, and returns them as a dead letter once it ran out of
+// attempts.
+func deliverBatch(ctx context.Context, databaseName string, hook database.Webhook, changes []database.ChangeEvent) (*database.DeadLetter, error) {
+	payload, err := webhookPayload(databaseName, hook, changes)
+	if err != nil {
+		return nil, err
+	}
+	first, last := changes[0].Offset, changes[len(changes)-1].Offset
+
+	for attempt := 1; ; attempt++ {
+		delivery := post(ctx, hook, payload)
+		if ctx.Err() != nil {
+			// the node taking over delivers them again
+			return nil, nil
+		}
+		delivery.Attempt = attempt
+		delivery.First, delivery.Last, delivery.Events = first, last, len(changes)
+		if _, err := database.LogDelivery(ctx, databaseName, delivery); err != nil {
+			return nil, err
+		}
+		if delivery.Error == "" {
+			return nil, nil
+		}
+		if attempt >= config.WebhookAttempts {
+			log.Warn("webhook delivery failed for good", "database", databaseName, "webhook", hook.ID,
+				"first", first, "last", last, "err", delivery.Error)
+			return &database.DeadLetter{
+				First:    first,
+				Last:     last,
+				Events:   len(changes),
+				Attempts: attempt,
+				Error:    delivery.Error,
+				Payload:  payload,
+			}, nil
+		}
+
+		backoff := min(time.Second<<(attempt-1), maxWebhookBackoff)
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// webhookPayload is the body changes are posted in.
+func webhookPayload(databaseName string, hook database.Webhook, changes []database.ChangeEvent) ([]byte, error) {
+	events := make([]json.RawMessage, 0, len(changes))
+	for _, change := range changes {
+		event, err := changeObject(changeMessage(change))
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return json.Marshal(struct {
+		Database string            `json:"database"`
+		Webhook  string            `json:"webhook"`
+		Log      string            `json:"log"`
+		Events   []json.RawMessage `json:"events"`
+	}{databaseName, hook.ID, hook.Log, events})
+}
+
+// post sends a payload to a webhook once. The X-Bedroompop-Signature header
+// holds the HMAC-SHA256, under the secret of the webhook, of the timestamp in
+// X-Bedroompop-Timestamp, a dot and the body.
+func post(ctx context.Context, hook database.Webhook, payload []byte) database.Delivery {
+	started := time.Now()
+	delivery := database.Delivery{Webhook: hook.ID, Time: started.UTC()}
+
+	timestamp := strconv.FormatInt(started.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(hook.Secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+
+	c, cancel := context.WithTimeout(ctx, config.WebhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(c, http.MethodPost, hook.URL, bytes.NewReader(payload))
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Bedroompop-Webhook")
+	req.Header.Set("X-Bedroompop-Webhook", hook.ID)
+	req.Header.Set("X-Bedroompop-Timestamp", timestamp)
+	req.Header.Set("X-Bedroompop-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	resp, err := http.DefaultClient.Do(req)
+	delivery.Duration = time.Since(started)
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	defer resp.Body.Close()
+	delivery.Status = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		delivery.Error = resp.Status
+		if len(body) > 0 {
+			delivery.Error += ": " + string(bytes.TrimSpace(body))
+		}
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+	return delivery
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mem is an in-process store, lost when the node stops.
+type Mem struct {
+	mu      sync.Mutex
+	objects map[string]memObject
+}
+
+type memObject struct {
+	data     []byte
+	modified time.Time
+}
+
+func NewMem() *Mem {
+	return &Mem{objects: make(map[string]memObject)}
+}
+
+func (m *Mem) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = memObject{data: data, modified: time.Now()}
+	return nil
+}
+
+func (m *Mem) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	object, ok := m.objects[key]
+	if !ok {
+		return nil, ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader(object.data)), nil
+}
+
+func (m *Mem) List(ctx context.Context, prefix string) ([]Object, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	objects := []Object{}
+	for key, object := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, Object{Key: key, Size: int64(len(object.data)), Modified: object.modified})
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+func (m *Mem) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.objects, key)
+	return nil
+}
@@ -0,0 +1,277 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3 stores objects in a bucket of an S3-compatible object store, such as
+// AWS S3 or MinIO. Requests are path-style and signed with Signature V4.
+type S3 struct {
+	endpoint  *url.URL
+	bucket    string
+	prefix    string
+	region    string
+	accessKey string
+	secretKey string
+	token     string
+	client    *http.Client
+}
+
+// emptySHA256 is the hash of an empty payload.
+const emptySHA256 = "e3b0c44298fc1c149afbfb4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// NewS3 opens a bucket, keeping objects under prefix. The query may set the
+// endpoint, which defaults to AWS, and the region, which defaults to us-east-1.
+func NewS3(bucket string, prefix string, query url.Values) (*S3, error) {
+	if bucket == "" {
+		return nil, errors.New("S3 backup store needs a bucket")
+	}
+	s := &S3{
+		bucket:    bucket,
+		region:    query.Get("region"),
+		accessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		token:     os.Getenv("AWS_SESSION_TOKEN"),
+		client:    &http.Client{},
+	}
+	if s.region == "" {
+		s.region = "us-east-1"
+	}
+	if prefix = strings.Trim(prefix, "/"); prefix != "" {
+		s.prefix = prefix + "/"
+	}
+	if s.accessKey == "" || s.secretKey == "" {
+		return nil, errors.New("S3 backup store needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+
+	endpoint := query.Get("endpoint")
+	if endpoint == "" {
+		endpoint = "https://s3." + s.region + ".amazonaws.com"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", endpoint)
+	}
+	s.endpoint = u
+	return s, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	resp, err := s.do(ctx, http.MethodPut, key, nil, r, size)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *S3) List(ctx context.Context, prefix string) ([]Object, error) {
+	var result struct {
+		Contents []struct {
+			Key          string
+			Size         int64
+			LastModified time.Time
+		}
+		IsTruncated           bool
+		NextContinuationToken string
+	}
+
+	objects := []Object{}
+	query := url.Values{"list-type": {"2"}, "prefix": {s.prefix + prefix}}
+	for {
+		resp, err := s.do(ctx, http.MethodGet, "", query, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		result.Contents = nil
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range result.Contents {
+			objects = append(objects, Object{Key: strings.TrimPrefix(c.Key, s.prefix), Size: c.Size, Modified: c.LastModified})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, nil, 0)
+	if errors.Is(err, ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// do sends a signed request for an object, or for the bucket when key is
+// empty. Responses other than 2xx are turned into errors.
+func (s *S3) do(ctx context.Context, method string, key string, query url.Values, body io.Reader, size int64) (*http.Response, error) {
+	path := "/" + s.bucket + "/"
+	if key != "" {
+		path += s.prefix + key
+	}
+	u := *s.endpoint
+	u.Path = path
+	u.RawPath = escapePath(path)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	payload := emptySHA256
+	if body != nil {
+		// the body is streamed, so its hash isn't part of the signature
+		payload = "UNSIGNED-PAYLOAD"
+		req.ContentLength = size
+	}
+	s.sign(req, payload, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	var failure struct {
+		Code    string
+		Message string
+	}
+	xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&failure)
+	if resp.StatusCode == http.StatusNotFound && failure.Code != "NoSuchBucket" {
+		return nil, ErrNotExist
+	}
+	if failure.Code == "" {
+		failure.Code = resp.Status
+	}
+	return nil, fmt.Errorf("S3 %s %s: %s %s", method, path, failure.Code, failure.Message)
+}
+
+// sign adds a Signature V4 authorization to the request.
+func (s *S3) sign(req *http.Request, payload string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payload)
+	if s.token != "" {
+		req.Header.Set("X-Amz-Security-Token", s.token)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payload,
+	}, "\n")
+	scope := day + "/" + s.region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), day)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// escape percent-encodes everything but the characters SigV4 leaves alone.
+func escape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || keepSlash && c == '/' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func escapePath(path string) string {
+	return escape(path, true)
+}
+
+// canonicalQuery encodes a query sorted by name, as SigV4 signs it.
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var parts []string
+	for _, name := range names {
+		for _, value := range query[name] {
+			parts = append(parts, escape(name, false)+"="+escape(value, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
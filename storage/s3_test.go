@@ -0,0 +1,306 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+)
+
+// fakeS3 serves one bucket out of a Mem store, checking the Signature V4 of
+// every request the way S3 does. Listings are cut after pageSize objects.
+type fakeS3 struct {
+	bucket   string
+	secret   string
+	pageSize int
+	objects  *Mem
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := f.verify(r); err != nil {
+		fail(w, http.StatusForbidden, "SignatureDoesNotMatch", err.Error())
+		return
+	}
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != f.bucket {
+		fail(w, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		f.list(w, r)
+	case r.Method == http.MethodPut:
+		if r.ContentLength < 0 {
+			fail(w, http.StatusLengthRequired, "MissingContentLength", "")
+			return
+		}
+		f.objects.Put(r.Context(), key, r.Body, r.ContentLength)
+	case r.Method == http.MethodGet:
+		object, err := f.objects.Get(r.Context(), key)
+		if err != nil {
+			fail(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+			return
+		}
+		io.Copy(w, object)
+	case r.Method == http.MethodDelete:
+		f.objects.Delete(r.Context(), key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		fail(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "")
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("list-type") != "2" {
+		fail(w, http.StatusBadRequest, "InvalidArgument", "only ListObjectsV2 is served")
+		return
+	}
+	objects, _ := f.objects.List(r.Context(), query.Get("prefix"))
+	start := 0
+	if token := query.Get("continuation-token"); token != "" {
+		start, _ = strconv.Atoi(token)
+	}
+	end := min(start+f.pageSize, len(objects))
+
+	type content struct {
+		Key          string
+		Size         int64
+		LastModified string
+	}
+	result := struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Contents              []content
+		IsTruncated           bool
+		NextContinuationToken string `xml:",omitempty"`
+	}{IsTruncated: end < len(objects)}
+	for _, object := range objects[start:end] {
+		result.Contents = append(result.Contents, content{object.Key, object.Size, object.Modified.UTC().Format(time.RFC3339Nano)})
+	}
+	if result.IsTruncated {
+		result.NextContinuationToken = strconv.Itoa(end)
+	}
+	xml.NewEncoder(w).Encode(result)
+}
+
+// verify recomputes the signature of a request from what was received.
+func (f *fakeS3) verify(r *http.Request) error {
+	auth := strings.TrimPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ")
+	fields := make(map[string]string)
+	for _, field := range strings.Split(auth, ", ") {
+		name, value, _ := strings.Cut(field, "=")
+		fields[name] = value
+	}
+	credential := strings.SplitN(fields["Credential"], "/", 2)
+	if len(credential) != 2 || credential[0] != testAccessKey {
+		return errors.New("unknown access key")
+	}
+	scope := credential[1]
+	day, region, _ := strings.Cut(scope, "/")
+	region, _, _ = strings.Cut(region, "/")
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	if !strings.HasPrefix(amzDate, day) {
+		return errors.New("date doesn't match the scope")
+	}
+	payload := r.Header.Get("X-Amz-Content-Sha256")
+	if payload != "UNSIGNED-PAYLOAD" && payload != emptySHA256 {
+		return errors.New("unexpected payload hash " + payload)
+	}
+
+	var canonicalHeaders strings.Builder
+	for _, name := range strings.Split(fields["SignedHeaders"], ";") {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + value + "\n")
+	}
+
+	// S3 signs the query sorted and strictly escaped whatever order it came in
+	query := r.URL.Query()
+	var params []string
+	for name, values := range query {
+		for _, value := range values {
+			params = append(params, strictEscape(name)+"="+strictEscape(value))
+		}
+	}
+	sort.Strings(params)
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		strings.Join(params, "&"),
+		canonicalHeaders.String(),
+		fields["SignedHeaders"],
+		payload,
+	}, "\n")
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := []byte("AWS4" + f.secret)
+	for _, part := range []string{day, region, "s3", "aws4_request"} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(stringToSign))
+	if !hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(fields["Signature"])) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// strictEscape escapes like RFC 3986, which url.QueryEscape doesn't quite.
+func strictEscape(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(url.QueryEscape(s), "+", "%20"), "%7E", "~")
+}
+
+func fail(w http.ResponseWriter, code int, errorCode string, message string) {
+	w.WriteHeader(code)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", errorCode, message)
+}
+
+// newTestS3 starts a fake S3 and opens bucket under prefix against it.
+func newTestS3(t *testing.T, fake *fakeS3, bucket string, prefix string) *S3 {
+	t.Helper()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	t.Setenv("AWS_ACCESS_KEY_ID", testAccessKey)
+	t.Setenv("AWS_SECRET_ACCESS_KEY", testSecretKey)
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	s, err := NewS3(bucket, prefix, url.Values{"endpoint": {server.URL}, "region": {"eu-west-1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestS3RoundTrip(t *testing.T) {
+	fake := &fakeS3{bucket: "backups", secret: testSecretKey, pageSize: 2, objects: NewMem()}
+	s := newTestS3(t, fake, "backups", "/cluster-a/")
+	ctx := context.Background()
+
+	keys := []string{
+		"orders/20260101T000000Z.json",
+		"orders/20260101T000000Z.sqlite",
+		"orders/wal/0001/header",
+		"orders/wal/0001/0000000000000020-1767225600000000000.frames",
+		"odd name/with+plus&amp=~tilde.json",
+	}
+	for _, key := range keys {
+		data := []byte("data of " + key)
+		if err := s.Put(ctx, key, bytes.NewReader(data), int64(len(data))); err != nil {
+			t.Fatalf("put %s: %v", key, err)
+		}
+	}
+	if _, err := fake.objects.Get(ctx, "cluster-a/"+keys[0]); err != nil {
+		t.Errorf("objects aren't kept under the prefix: %v", err)
+	}
+
+	for _, key := range keys {
+		r, err := s.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("get %s: %v", key, err)
+		}
+		data, _ := io.ReadAll(r)
+		r.Close()
+		if string(data) != "data of "+key {
+			t.Errorf("get %s = %q", key, data)
+		}
+	}
+
+	// five objects come back over three pages
+	objects, err := s.List(ctx, "orders/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var listed []string
+	for _, object := range objects {
+		listed = append(listed, object.Key)
+		if object.Size != int64(len("data of "+object.Key)) || object.Modified.IsZero() {
+			t.Errorf("listed %+v", object)
+		}
+	}
+	want := append([]string{}, keys[:4]...)
+	sort.Strings(want)
+	if strings.Join(listed, ",") != strings.Join(want, ",") {
+		t.Errorf("listed %v, want %v", listed, want)
+	}
+	if all, err := s.List(ctx, ""); err != nil || len(all) != len(keys) {
+		t.Errorf("listed %d objects in all, want %d: %v", len(all), len(keys), err)
+	}
+
+	if err := s.Delete(ctx, keys[1]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, keys[1]); !errors.Is(err, ErrNotExist) {
+		t.Errorf("get of a deleted object = %v, want ErrNotExist", err)
+	}
+	if err := s.Delete(ctx, keys[1]); err != nil {
+		t.Errorf("deleting a missing object = %v", err)
+	}
+}
+
+func TestS3Errors(t *testing.T) {
+	ctx := context.Background()
+
+	wrongSecret := newTestS3(t, &fakeS3{bucket: "backups", secret: "another secret", pageSize: 10, objects: NewMem()}, "backups", "")
+	_, err := wrongSecret.List(ctx, "")
+	if err == nil || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Errorf("list with a wrong secret = %v", err)
+	}
+
+	noBucket := newTestS3(t, &fakeS3{bucket: "backups", secret: testSecretKey, pageSize: 10, objects: NewMem()}, "missing", "")
+	_, err = noBucket.Get(ctx, "orders/1.json")
+	if err == nil || errors.Is(err, ErrNotExist) || !strings.Contains(err.Error(), "NoSuchBucket") {
+		t.Errorf("get from a missing bucket = %v, want NoSuchBucket", err)
+	}
+}
+
+func TestNewS3(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	if _, err := NewS3("backups", "", nil); err == nil {
+		t.Error("opened a bucket without credentials")
+	}
+
+	t.Setenv("AWS_ACCESS_KEY_ID", testAccessKey)
+	t.Setenv("AWS_SECRET_ACCESS_KEY", testSecretKey)
+	if _, err := NewS3("", "", nil); err == nil {
+		t.Error("opened a store without a bucket")
+	}
+	if _, err := NewS3("backups", "", url.Values{"endpoint": {"minio:9000"}}); err == nil {
+		t.Error("opened a store with an endpoint without scheme")
+	}
+
+	store, err := Open("s3://backups/nightly/?endpoint=http://127.0.0.1:9000&region=eu-central-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := store.(*S3)
+	if s.bucket != "backups" || s.prefix != "nightly/" || s.region != "eu-central-1" || s.endpoint.Host != "127.0.0.1:9000" {
+		t.Errorf("opened %+v", s)
+	}
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// A Store keeps backups off the node, as objects named by slash separated
+// keys. Objects are written whole and never modified, so a Store only needs
+// to put, get, list and delete them.
+type Store interface {
+	// Put writes size bytes read from r as the object key, replacing it if it exists.
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Get opens the object key, failing with ErrNotExist if there is none.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// List returns the objects whose key starts with prefix, ordered by key.
+	List(ctx context.Context, prefix string) ([]Object, error)
+	// Delete removes the object key. Removing a missing object isn't an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Object describes a stored object.
+type Object struct {
+	Key      string
+	Size     int64
+	Modified time.Time
+}
+
+var ErrNotExist = errors.New("object not found")
+
+// Open opens the store at target, one of
+//
+//	s3://bucket/prefix?endpoint=http://host:9000&region=us-east-1
+//	file:///mnt/backups, or a plain directory path
+//	mem://
+//
+// S3 credentials are read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN. mem:// keeps objects in memory, for trying things out.
+func Open(target string) (Store, error) {
+	scheme, rest, ok := strings.Cut(target, "://")
+	if !ok {
+		return NewDir(target)
+	}
+
+	switch scheme {
+	case "file":
+		return NewDir(rest)
+	case "mem":
+		return NewMem(), nil
+	case "s3":
+		u, err := url.Parse(target)
+		if err != nil {
+			return nil, err
+		}
+		return NewS3(u.Host, strings.TrimPrefix(u.Path, "/"), u.Query())
+	}
+	return nil, fmt.Errorf("unknown backup store %q", scheme)
+}